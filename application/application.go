@@ -22,6 +22,7 @@ import (
 	"github.com/resourced/resourced-master/config"
 	"github.com/resourced/resourced-master/mailer"
 	"github.com/resourced/resourced-master/messagebus"
+	"github.com/resourced/resourced-master/sms"
 )
 
 // New is the constructor for Application struct.
@@ -53,6 +54,7 @@ func New(configDir string) (*Application, error) {
 	app.CassandraDBConfig = cassandraDBConfig
 	app.cookieStore = sessions.NewCookieStore([]byte(app.GeneralConfig.CookieSecret))
 	app.Mailers = make(map[string]*mailer.Mailer)
+	app.SMSProviders = make(map[string]sms.Provider)
 	app.HandlerInstruments = app.NewHandlerInstruments()
 	app.LatencyGauges = make(map[string]metrics.Gauge)
 	app.MetricsRegistry = app.NewMetricsRegistry(app.HandlerInstruments, app.LatencyGauges)
@@ -75,6 +77,14 @@ func New(configDir string) (*Application, error) {
 		app.Mailers["GeneralConfig.Checks"] = mailer
 	}
 
+	if app.GeneralConfig.Checks.SMS != nil {
+		provider, err := sms.New(app.GeneralConfig.Checks.SMS)
+		if err != nil {
+			return nil, err
+		}
+		app.SMSProviders["GeneralConfig.Checks"] = provider
+	}
+
 	// Setup loggers
 	app.OutLogger = logrus.New()
 	app.OutLogger.Out = os.Stdout
@@ -93,6 +103,7 @@ type Application struct {
 	CassandraDBConfig  *config.CassandraDBConfig
 	cookieStore        *sessions.CookieStore
 	Mailers            map[string]*mailer.Mailer
+	SMSProviders       map[string]sms.Provider
 	HandlerInstruments map[string]chan int64
 	LatencyGauges      map[string]metrics.Gauge
 	MetricsRegistry    metrics.Registry
@@ -118,6 +129,10 @@ func (app *Application) GetContext() context.Context {
 		ctx = context.WithValue(ctx, "mailer."+key, mailr)
 	}
 
+	for key, provider := range app.SMSProviders {
+		ctx = context.WithValue(ctx, "sms."+key, provider)
+	}
+
 	ctx = context.WithValue(ctx, "bus", app.MessageBus)
 
 	return ctx
@@ -262,6 +277,24 @@ func (app *Application) MigrateAllPG(direction string) error {
 	return nil
 }
 
+// cassandraMigrationAlreadyApplied reports if err only says that an ALTER TABLE migration already ran.
+// Every migration is replayed on every run, and Cassandra has no IF NOT EXISTS for columns.
+func cassandraMigrationAlreadyApplied(sql string, err error) bool {
+	if !strings.HasPrefix(strings.ToUpper(strings.TrimSpace(sql)), "ALTER TABLE") {
+		return false
+	}
+
+	message := strings.ToLower(err.Error())
+
+	for _, applied := range []string{"conflicts with an existing column", "already exists", "was not found in table", "unconfigured table"} {
+		if strings.Contains(message, applied) {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (app *Application) MigrateAllCassandra(direction string) error {
 	migrationDir := filepath.Join(".", "migrations", "cassandra", direction)
 
@@ -279,21 +312,21 @@ func (app *Application) MigrateAllCassandra(direction string) error {
 		// -----------------------------------------------
 		// Metrics
 		err = app.CassandraDBConfig.TSMetricSession.Query(sql).Exec()
-		if err != nil {
+		if err != nil && !cassandraMigrationAlreadyApplied(sql, err) {
 			return fmt.Errorf("Failed to execute migration file on %v. File: %v, Error: %v", app.GeneralConfig.Metrics.Cassandra.Keyspace, fullFilename, err)
 		}
 
 		// -----------------------------------------------
 		// Logs
 		err = app.CassandraDBConfig.TSLogSession.Query(sql).Exec()
-		if err != nil {
+		if err != nil && !cassandraMigrationAlreadyApplied(sql, err) {
 			return fmt.Errorf("Failed to execute migration file on %v. File: %v, Error: %v", app.GeneralConfig.Metrics.Cassandra.Keyspace, fullFilename, err)
 		}
 
 		// -----------------------------------------------
 		// Events
 		err = app.CassandraDBConfig.TSEventSession.Query(sql).Exec()
-		if err != nil {
+		if err != nil && !cassandraMigrationAlreadyApplied(sql, err) {
 			return fmt.Errorf("Failed to execute migration file on %v. File: %v, Error: %v", app.GeneralConfig.Metrics.Cassandra.Keyspace, fullFilename, err)
 		}
 	}
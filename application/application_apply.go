@@ -0,0 +1,48 @@
+package application
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/resourced/resourced-master/models/declarative"
+)
+
+// Apply makes a cluster match the TOML or JSON files at path, a file or a directory, and prints the plan to out.
+// With dryRun, it only prints the plan.
+// Running daemons pick up check changes on their next refetch, which peer heartbeats trigger.
+func (app *Application) Apply(clusterID int64, path string, dryRun bool, out io.Writer) error {
+	desired, err := declarative.LoadPath(path)
+	if err != nil {
+		return err
+	}
+
+	err = desired.Normalize()
+	if err != nil {
+		return err
+	}
+
+	state, err := declarative.LoadState(app.GetContext(), clusterID)
+	if err != nil {
+		return err
+	}
+
+	plan := state.Plan(desired)
+
+	fmt.Fprint(out, plan.String())
+
+	if dryRun || plan.IsEmpty() {
+		return nil
+	}
+
+	applied, err := state.Apply(app.GetContext(), desired)
+	if err != nil {
+		if !applied.IsEmpty() {
+			fmt.Fprintf(out, "\nApplied before the failure:\n%v", applied.String())
+		}
+		return err
+	}
+
+	fmt.Fprintln(out, "Applied.")
+
+	return nil
+}
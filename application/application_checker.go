@@ -7,6 +7,7 @@ import (
 
 	"github.com/resourced/resourced-master/models/check_expression"
 	"github.com/resourced/resourced-master/models/pg"
+	"github.com/resourced/resourced-master/models/shims"
 )
 
 // CheckAndRunTriggers pulls list of all checks, distributed evenly across N master daemons,
@@ -14,6 +15,15 @@ import (
 func (app *Application) CheckAndRunTriggers() {
 	checkRowsChan := make(chan []*pg.CheckRow)
 
+	// Deliver notifications queued by the triggers.
+	go app.SendCheckNotifications()
+
+	// Move unacknowledged escalations along their policies.
+	go app.EscalateChecks()
+
+	// Track error budgets and run burn-rate alerts.
+	go app.EvaluateSLOs()
+
 	// Fetch Checks data, split by number of daemons, every time there's a value in app.RefetchChecksChan
 	go func() {
 		for refetchChecks := range app.RefetchChecksChan {
@@ -76,7 +86,25 @@ func (app *Application) CheckAndRunTriggers() {
 
 						deletedFrom := clusterRow.GetDeletedFromUNIXTimestampForInsert("ts_checks")
 
-						err = pg.NewTSCheck(app.GetContext(), checkRow.ClusterID).Create(nil, checkRow.ClusterID, checkRow.ID, finalResult, expressionResults, deletedFrom)
+						// Failures while a parent check fails are stored, but they do not run triggers.
+						var suppressedByParentID *int64
+
+						// Checks that alert per host can have failing hosts while the check passes.
+						if finalResult || checkRow.PerHostAlerting {
+							parentRow, err := checkRow.FailingParent(app.GetContext())
+							if err != nil {
+								app.ErrLogger.WithFields(logrus.Fields{
+									"Method":    "checkRow.FailingParent",
+									"ClusterID": checkRow.ClusterID,
+									"CheckID":   checkRow.ID,
+								}).Error(err)
+							}
+							if parentRow != nil {
+								suppressedByParentID = &parentRow.ID
+							}
+						}
+
+						err = pg.NewTSCheck(app.GetContext(), checkRow.ClusterID).Create(nil, checkRow.ClusterID, checkRow.ID, finalResult, expressionResults, suppressedByParentID, deletedFrom)
 						if err != nil {
 							app.ErrLogger.WithFields(logrus.Fields{
 								"Method":    "TSCheck.Create",
@@ -87,7 +115,28 @@ func (app *Application) CheckAndRunTriggers() {
 							return
 						}
 
-						// 3. Run check's triggers.
+						if checkRow.PerHostAlerting {
+							err = checkRow.UpdateHostStates(app.GetContext(), expressionResults, finalResult)
+							if err != nil {
+								app.ErrLogger.WithFields(logrus.Fields{
+									"Method":    "checkRow.UpdateHostStates",
+									"ClusterID": checkRow.ClusterID,
+									"CheckID":   checkRow.ID,
+								}).Error(err)
+							}
+						}
+
+						// 3. Detect flapping, which suppresses the triggers.
+						err = checkRow.DetectFlapping(app.GetContext())
+						if err != nil {
+							app.ErrLogger.WithFields(logrus.Fields{
+								"Method":    "checkRow.DetectFlapping",
+								"ClusterID": checkRow.ClusterID,
+								"CheckID":   checkRow.ID,
+							}).Error(err)
+						}
+
+						// 4. Run check's triggers.
 						err = checkRow.RunTriggers(app.GetContext())
 						// err = checkRow.RunTriggers(app.GeneralConfig, app.PGDBConfig.Core, app.PGDBConfig.GetTSCheck(checkRow.ClusterID), app.Mailers["GeneralConfig.Checks"])
 						if err != nil {
@@ -104,3 +153,152 @@ func (app *Application) CheckAndRunTriggers() {
 		}
 	}()
 }
+
+// SendCheckNotifications flushes queued check notifications every GroupingWindow.
+// Each master daemon only flushes the queue of its own clusters.
+func (app *Application) SendCheckNotifications() {
+	groupingWindow, err := time.ParseDuration(app.GeneralConfig.Checks.Notifications.GroupingWindow)
+	if err != nil {
+		app.ErrLogger.WithFields(logrus.Fields{
+			"Method":         "Application.SendCheckNotifications",
+			"GroupingWindow": app.GeneralConfig.Checks.Notifications.GroupingWindow,
+			"Error":          err,
+		}).Error("Failed to parse Checks.Notifications.GroupingWindow")
+		return
+	}
+
+	for range time.Tick(groupingWindow) {
+		clusters, err := app.myClusters()
+		if err != nil {
+			app.ErrLogger.WithFields(logrus.Fields{
+				"Method": "Application.myClusters",
+			}).Error(err)
+			continue
+		}
+
+		for _, cluster := range clusters {
+			app.SendCheckNotificationsOnce(cluster.ID)
+		}
+	}
+}
+
+// SendCheckNotificationsOnce delivers due notifications of a cluster, one message per recipient.
+func (app *Application) SendCheckNotificationsOnce(clusterID int64) error {
+	queue := pg.NewCheckNotificationQueue(app.GetContext())
+
+	rows, err := queue.AllDueByClusterID(nil, clusterID)
+	if err != nil {
+		app.ErrLogger.WithFields(logrus.Fields{
+			"Method":    "CheckNotificationQueue.AllDueByClusterID",
+			"ClusterID": clusterID,
+		}).Error(err)
+		return err
+	}
+
+	for _, group := range pg.GroupCheckNotificationQueueRows(rows) {
+		err = queue.SendGroup(nil, group)
+		if err != nil {
+			// Failed notifications stay in the queue and are retried on the next flush.
+			app.ErrLogger.WithFields(logrus.Fields{
+				"Method":    "CheckNotificationQueue.SendGroup",
+				"ClusterID": clusterID,
+				"Transport": group[0].Transport,
+				"Recipient": group[0].Recipient,
+			}).Error(err)
+		}
+	}
+
+	return err
+}
+
+// EscalateChecks walks every open escalation of this daemon's clusters once a minute.
+func (app *Application) EscalateChecks() {
+	for range time.Tick(time.Minute) {
+		clusters, err := app.myClusters()
+		if err != nil {
+			app.ErrLogger.WithFields(logrus.Fields{
+				"Method": "Application.myClusters",
+			}).Error(err)
+			continue
+		}
+
+		for _, cluster := range clusters {
+			app.EscalateChecksOnce(cluster.ID)
+		}
+	}
+}
+
+// EscalateChecksOnce resolves recovered escalations of a cluster and moves unacknowledged ones to their next step.
+func (app *Application) EscalateChecksOnce(clusterID int64) error {
+	escalation := pg.NewEscalation(app.GetContext())
+
+	rows, err := escalation.AllOpenByClusterID(nil, clusterID)
+	if err != nil {
+		app.ErrLogger.WithFields(logrus.Fields{
+			"Method":    "Escalation.AllOpenByClusterID",
+			"ClusterID": clusterID,
+		}).Error(err)
+		return err
+	}
+
+	for _, row := range rows {
+		err = escalation.Process(nil, row)
+		if err != nil {
+			app.ErrLogger.WithFields(logrus.Fields{
+				"Method":       "Escalation.Process",
+				"ClusterID":    clusterID,
+				"CheckID":      row.CheckID,
+				"EscalationID": row.ID,
+			}).Error(err)
+		}
+	}
+
+	return err
+}
+
+// EvaluateSLOs evaluates every SLO of this daemon's clusters once a minute.
+func (app *Application) EvaluateSLOs() {
+	for range time.Tick(time.Minute) {
+		clusters, err := app.myClusters()
+		if err != nil {
+			app.ErrLogger.WithFields(logrus.Fields{
+				"Method": "Application.myClusters",
+			}).Error(err)
+			continue
+		}
+
+		for _, cluster := range clusters {
+			app.EvaluateSLOsOnce(cluster.ID)
+		}
+	}
+}
+
+// EvaluateSLOsOnce records the error budget of every SLO of a cluster and runs their burn-rate alerts.
+func (app *Application) EvaluateSLOsOnce(clusterID int64) error {
+	slo := pg.NewSLO(app.GetContext())
+	slo.EventSource = shims.NewSLOEventSource(app.GetContext())
+
+	rows, err := slo.AllByClusterID(nil, clusterID)
+	if err != nil {
+		app.ErrLogger.WithFields(logrus.Fields{
+			"Method":    "SLO.AllByClusterID",
+			"ClusterID": clusterID,
+		}).Error(err)
+		return err
+	}
+
+	now := time.Now().UTC()
+
+	for _, row := range rows {
+		_, err = slo.Evaluate(nil, row, now)
+		if err != nil {
+			app.ErrLogger.WithFields(logrus.Fields{
+				"Method":    "SLO.Evaluate",
+				"ClusterID": clusterID,
+				"SLOID":     row.ID,
+			}).Error(err)
+		}
+	}
+
+	return err
+}
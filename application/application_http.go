@@ -106,12 +106,25 @@ func (app *Application) Mux() *chi.Mux {
 			r.Get("/", stopwatch.LatencyFuncHandler(app.getHandlerInstrument("GetChecks"), []string{"GET"}, handlers.GetChecks).(http.HandlerFunc))
 			r.Post("/", handlers.PostChecks)
 
+			r.Route("/maintenance-windows", func(r chi.Router) {
+				r.Use(CSRF, middlewares.MustLogin, middlewares.SetClusters, middlewares.MustBeMember)
+				r.Post("/", handlers.PostMaintenanceWindows)
+
+				r.Route("/:maintenanceWindowID", func(r chi.Router) {
+					r.Use(CSRF, middlewares.MustLogin, middlewares.SetClusters, middlewares.MustBeMember)
+					r.Post("/", handlers.PostPutDeleteMaintenanceWindowID)
+					r.Delete("/", handlers.PostPutDeleteMaintenanceWindowID)
+				})
+			})
+
 			r.Route("/:checkID", func(r chi.Router) {
 				r.Use(CSRF, middlewares.MustLogin, middlewares.SetClusters, middlewares.MustBeMember)
 				r.Post("/", handlers.PostPutDeleteCheckID)
 				r.Put("/", handlers.PostPutDeleteCheckID)
 				r.Delete("/", handlers.PostPutDeleteCheckID)
 				r.Post("/silence", handlers.PostCheckIDSilence)
+				r.Get("/availability", handlers.GetCheckIDAvailability)
+				r.Get("/notifications", handlers.GetCheckIDNotifications)
 
 				r.Route("/triggers", func(r chi.Router) {
 					r.Use(CSRF, middlewares.MustLogin, middlewares.SetClusters, middlewares.MustBeMember)
@@ -180,6 +193,10 @@ func (app *Application) Mux() *chi.Mux {
 		})
 	}
 
+	// Signed links in notification emails, they do not require a session.
+	r.Get("/escalations/:id/ack", handlers.GetEscalationsIDAck)
+	r.Post("/escalations/:id/ack", handlers.PostEscalationsIDAck)
+
 	r.Route("/api", func(r chi.Router) {
 		r.Route("/hosts", func(r chi.Router) {
 			r.Use(middlewares.MustLoginApi)
@@ -243,9 +260,95 @@ func (app *Application) Mux() *chi.Mux {
 			r.Post("/", handlers.PostApiLogs)
 		})
 
-		r.Route("/checks/:id", func(r chi.Router) {
+		r.Route("/checks", func(r chi.Router) {
+			r.Use(middlewares.MustLoginApi)
+			r.Get("/", tollbooth.LimitFuncHandler(generalAPILimiter, handlers.GetApiChecks).(http.HandlerFunc))
+			r.Post("/", handlers.PostApiChecks)
+			r.Post("/preview", tollbooth.LimitFuncHandler(generalAPILimiter, handlers.PostApiChecksPreview).(http.HandlerFunc))
+
+			r.Route("/:id", func(r chi.Router) {
+				r.Get("/", tollbooth.LimitFuncHandler(generalAPILimiter, handlers.GetApiChecksID).(http.HandlerFunc))
+				r.Put("/", handlers.PutApiChecksID)
+				r.Delete("/", tollbooth.LimitFuncHandler(generalAPILimiter, handlers.DeleteApiChecksID).(http.HandlerFunc))
+				r.Get("/results", tollbooth.LimitFuncHandler(generalAPILimiter, handlers.GetApiCheckIDResults).(http.HandlerFunc))
+				r.Get("/availability", tollbooth.LimitFuncHandler(generalAPILimiter, handlers.GetApiCheckIDAvailability).(http.HandlerFunc))
+				r.Get("/notifications", tollbooth.LimitFuncHandler(generalAPILimiter, handlers.GetApiCheckIDNotifications).(http.HandlerFunc))
+				r.Get("/hosts", tollbooth.LimitFuncHandler(generalAPILimiter, handlers.GetApiCheckIDHosts).(http.HandlerFunc))
+			})
+		})
+
+		r.Route("/oncall-schedules", func(r chi.Router) {
+			r.Use(middlewares.MustLoginApi)
+			r.Get("/", tollbooth.LimitFuncHandler(generalAPILimiter, handlers.GetApiOnCallSchedules).(http.HandlerFunc))
+			r.Post("/", handlers.PostApiOnCallSchedules)
+			r.Put("/:id", handlers.PutApiOnCallSchedulesID)
+			r.Delete("/:id", tollbooth.LimitFuncHandler(generalAPILimiter, handlers.DeleteApiOnCallSchedulesID).(http.HandlerFunc))
+		})
+
+		r.Route("/escalation-policies", func(r chi.Router) {
+			r.Use(middlewares.MustLoginApi)
+			r.Get("/", tollbooth.LimitFuncHandler(generalAPILimiter, handlers.GetApiEscalationPolicies).(http.HandlerFunc))
+			r.Post("/", handlers.PostApiEscalationPolicies)
+			r.Put("/:id", handlers.PutApiEscalationPoliciesID)
+			r.Delete("/:id", tollbooth.LimitFuncHandler(generalAPILimiter, handlers.DeleteApiEscalationPoliciesID).(http.HandlerFunc))
+		})
+
+		r.Route("/escalations", func(r chi.Router) {
+			r.Use(middlewares.MustLoginApi)
+			r.Get("/", tollbooth.LimitFuncHandler(generalAPILimiter, handlers.GetApiEscalations).(http.HandlerFunc))
+			r.Post("/:id/ack", handlers.PostApiEscalationsIDAck)
+		})
+
+		r.Route("/maintenance-windows", func(r chi.Router) {
+			r.Use(middlewares.MustLoginApi)
+			r.Get("/", tollbooth.LimitFuncHandler(generalAPILimiter, handlers.GetApiMaintenanceWindows).(http.HandlerFunc))
+			r.Post("/", handlers.PostApiMaintenanceWindows)
+			r.Delete("/:id", tollbooth.LimitFuncHandler(generalAPILimiter, handlers.DeleteApiMaintenanceWindowsID).(http.HandlerFunc))
+		})
+
+		r.Route("/slos", func(r chi.Router) {
+			r.Use(middlewares.MustLoginApi)
+			r.Get("/", tollbooth.LimitFuncHandler(generalAPILimiter, handlers.GetApiSLOs).(http.HandlerFunc))
+			r.Post("/", handlers.PostApiSLOs)
+
+			r.Route("/:id", func(r chi.Router) {
+				r.Get("/", tollbooth.LimitFuncHandler(generalAPILimiter, handlers.GetApiSLOsID).(http.HandlerFunc))
+				r.Put("/", handlers.PutApiSLOsID)
+				r.Delete("/", tollbooth.LimitFuncHandler(generalAPILimiter, handlers.DeleteApiSLOsID).(http.HandlerFunc))
+				r.Get("/budgets", tollbooth.LimitFuncHandler(generalAPILimiter, handlers.GetApiSLOsIDBudgets).(http.HandlerFunc))
+				r.Get("/notifications", tollbooth.LimitFuncHandler(generalAPILimiter, handlers.GetApiSLOsIDNotifications).(http.HandlerFunc))
+			})
+		})
+
+		r.Route("/email-templates", func(r chi.Router) {
+			r.Use(middlewares.MustLoginApi)
+			r.Get("/", tollbooth.LimitFuncHandler(generalAPILimiter, handlers.GetApiEmailTemplates).(http.HandlerFunc))
+			r.Put("/", handlers.PutApiEmailTemplates)
+			r.Delete("/", tollbooth.LimitFuncHandler(generalAPILimiter, handlers.DeleteApiEmailTemplates).(http.HandlerFunc))
+			r.Post("/preview", tollbooth.LimitFuncHandler(generalAPILimiter, handlers.PostApiEmailTemplatesPreview).(http.HandlerFunc))
+		})
+
+		r.Route("/heartbeats", func(r chi.Router) {
+			r.Group(func(r chi.Router) {
+				r.Use(middlewares.MustLoginApi)
+				r.Get("/", tollbooth.LimitFuncHandler(generalAPILimiter, handlers.GetApiHeartbeats).(http.HandlerFunc))
+				r.Post("/", tollbooth.LimitFuncHandler(generalAPILimiter, handlers.PostApiHeartbeats).(http.HandlerFunc))
+				r.Delete("/:token", tollbooth.LimitFuncHandler(generalAPILimiter, handlers.DeleteApiHeartbeatsToken).(http.HandlerFunc))
+			})
+
+			// Jobs ping with the token alone.
+			r.Get("/:token", tollbooth.LimitFuncHandler(generalAPILimiter, handlers.GetPostApiHeartbeatsToken).(http.HandlerFunc))
+			r.Post("/:token", tollbooth.LimitFuncHandler(generalAPILimiter, handlers.GetPostApiHeartbeatsToken).(http.HandlerFunc))
+		})
+
+		r.Route("/export", func(r chi.Router) {
+			r.Use(middlewares.MustLoginApi)
+			r.Get("/", tollbooth.LimitFuncHandler(generalAPILimiter, handlers.GetApiExport).(http.HandlerFunc))
+		})
+
+		r.Route("/import", func(r chi.Router) {
 			r.Use(middlewares.MustLoginApi)
-			r.Get("/results", tollbooth.LimitFuncHandler(generalAPILimiter, handlers.GetApiCheckIDResults).(http.HandlerFunc))
+			r.Post("/", tollbooth.LimitFuncHandler(generalAPILimiter, handlers.PostApiImport).(http.HandlerFunc))
 		})
 	})
 
@@ -1,6 +1,8 @@
 package application
 
 import (
+	"time"
+
 	"github.com/Sirupsen/logrus"
 	"github.com/didip/stopwatch"
 
@@ -53,6 +55,14 @@ func (app *Application) PruneAll() {
 				app.PruneTSCheckOnce(cluster.ID)
 			}(cluster)
 
+			go func(cluster *pg.ClusterRow) {
+				app.PruneCheckNotificationDeliveriesOnce(cluster.ID)
+			}(cluster)
+
+			go func(cluster *pg.ClusterRow) {
+				app.PruneSLOBudgetsOnce(cluster.ID)
+			}(cluster)
+
 			if app.GeneralConfig.GetMetricsDBType() == "pg" {
 				go func(cluster *pg.ClusterRow) {
 					app.PruneTSMetricOnce(cluster.ID)
@@ -95,6 +105,56 @@ func (app *Application) PruneTSCheckOnce(clusterID int64) (err error) {
 	return err
 }
 
+// PruneCheckNotificationDeliveriesOnce deletes delivery attempts older than the checks data retention.
+func (app *Application) PruneCheckNotificationDeliveriesOnce(clusterID int64) (err error) {
+	before := time.Now().UTC().AddDate(0, 0, -app.GeneralConfig.Checks.DataRetention)
+
+	f := func() {
+		err = pg.NewCheckNotificationDelivery(app.GetContext()).DeleteByClusterIDAndCreatedBefore(nil, clusterID, before)
+	}
+
+	latency := stopwatch.Measure(f)
+
+	logFields := logrus.Fields{
+		"Method":       "Application.PruneCheckNotificationDeliveriesOnce",
+		"NanoSeconds":  latency,
+		"MicroSeconds": latency / 1000,
+		"MilliSeconds": latency / 1000 / 1000,
+	}
+	if err != nil {
+		app.ErrLogger.WithFields(logFields).Error(err)
+	} else {
+		app.OutLogger.WithFields(logFields).Info("Latency measurement")
+	}
+
+	return err
+}
+
+// PruneSLOBudgetsOnce deletes SLO error budget history older than the checks data retention.
+func (app *Application) PruneSLOBudgetsOnce(clusterID int64) (err error) {
+	before := time.Now().UTC().AddDate(0, 0, -app.GeneralConfig.Checks.DataRetention)
+
+	f := func() {
+		err = pg.NewSLOBudget(app.GetContext()).DeleteByClusterIDAndCreatedBefore(nil, clusterID, before)
+	}
+
+	latency := stopwatch.Measure(f)
+
+	logFields := logrus.Fields{
+		"Method":       "Application.PruneSLOBudgetsOnce",
+		"NanoSeconds":  latency,
+		"MicroSeconds": latency / 1000,
+		"MilliSeconds": latency / 1000 / 1000,
+	}
+	if err != nil {
+		app.ErrLogger.WithFields(logFields).Error(err)
+	} else {
+		app.OutLogger.WithFields(logFields).Info("Latency measurement")
+	}
+
+	return err
+}
+
 // PruneTSMetricOnce deletes old ts_metrics data.
 func (app *Application) PruneTSMetricOnce(clusterID int64) (err error) {
 	if app.GeneralConfig.GetMetricsDBType() != "pg" {
@@ -1,6 +1,7 @@
 package application
 
 import (
+	"errors"
 	"os"
 	"testing"
 )
@@ -15,3 +16,19 @@ func TestConstructor(t *testing.T) {
 		t.Errorf("Failed to configure hostname properly")
 	}
 }
+
+func TestCassandraMigrationAlreadyApplied(t *testing.T) {
+	conflict := errors.New("Invalid column name tags because it conflicts with an existing column")
+
+	if !cassandraMigrationAlreadyApplied("ALTER TABLE ts_events ADD tags set<text>;", conflict) {
+		t.Errorf("Adding a column that exists already should be tolerated")
+	}
+
+	if cassandraMigrationAlreadyApplied("CREATE TABLE ts_events (id bigint PRIMARY KEY);", conflict) {
+		t.Errorf("Only ALTER TABLE migrations should be tolerated")
+	}
+
+	if cassandraMigrationAlreadyApplied("ALTER TABLE ts_events ADD tags set<text>;", errors.New("no hosts available in the pool")) {
+		t.Errorf("Other errors should not be tolerated")
+	}
+}
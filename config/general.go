@@ -49,6 +49,14 @@ func NewGeneralConfig(configDir string) (config GeneralConfig, err error) {
 		config.LogLevel = "info"
 	}
 
+	if config.Checks.Notifications.GroupingWindow == "" {
+		config.Checks.Notifications.GroupingWindow = "30s"
+	}
+
+	if config.Checks.Notifications.DigestInterval == "" {
+		config.Checks.Notifications.DigestInterval = "1h"
+	}
+
 	return config, err
 }
 
@@ -134,10 +142,22 @@ type GeneralConfig struct {
 	Checks struct {
 		Email *EmailConfig
 
+		// SMS delivers SMS triggers through a provider API, instead of SMSEmailGateway.
+		SMS *SMSConfig
+
 		SMSEmailGateway map[string]string
 
 		PostgreSQL PostgreSQLPerClusterConfig
 
+		Notifications struct {
+			// GroupingWindow is how often queued notifications are flushed.
+			// Notifications to the same recipient within one window are sent as a single message.
+			GroupingWindow string
+
+			// DigestInterval is how often digest-mode triggers deliver their notifications.
+			DigestInterval string
+		}
+
 		DataRetention int
 	}
 
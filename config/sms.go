@@ -0,0 +1,16 @@
+package config
+
+// SMSConfig stores the SMS provider configuration.
+type SMSConfig struct {
+	// Provider is the name of the SMS provider API, e.g. "twilio".
+	Provider string
+
+	// URL overrides the base URL of the provider API.
+	URL string
+
+	AccountSID string
+	AuthToken  string
+
+	// From is the E.164 number messages are sent from.
+	From string
+}
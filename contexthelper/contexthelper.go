@@ -9,6 +9,7 @@ import (
 	"github.com/resourced/resourced-master/config"
 	"github.com/resourced/resourced-master/mailer"
 	"github.com/resourced/resourced-master/messagebus"
+	"github.com/resourced/resourced-master/sms"
 )
 
 func GetGeneralConfig(ctx context.Context) (config.GeneralConfig, error) {
@@ -64,3 +65,12 @@ func GetMailer(ctx context.Context, name string) (*mailer.Mailer, error) {
 
 	return valInterface.(*mailer.Mailer), nil
 }
+
+func GetSMSProvider(ctx context.Context, name string) (sms.Provider, error) {
+	valInterface := ctx.Value("sms." + name)
+	if valInterface == nil {
+		return nil, errors.New(name + " SMS provider is nil")
+	}
+
+	return valInterface.(sms.Provider), nil
+}
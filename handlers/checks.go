@@ -2,11 +2,14 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
+	"io/ioutil"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/Sirupsen/logrus"
 	"github.com/gorilla/csrf"
@@ -17,6 +20,7 @@ import (
 	"github.com/resourced/resourced-master/libslice"
 	"github.com/resourced/resourced-master/messagebus"
 	"github.com/resourced/resourced-master/models/cassandra"
+	"github.com/resourced/resourced-master/models/check_expression"
 	"github.com/resourced/resourced-master/models/pg"
 )
 
@@ -42,6 +46,15 @@ func GetChecks(w http.ResponseWriter, r *http.Request) {
 	metricsChan := make(chan *pg.MetricRowsWithError)
 	defer close(metricsChan)
 
+	activeMaintenanceWindowsChan := make(chan *pg.MaintenanceWindowRowsWithError)
+	defer close(activeMaintenanceWindowsChan)
+
+	upcomingMaintenanceWindowsChan := make(chan *pg.MaintenanceWindowRowsWithError)
+	defer close(upcomingMaintenanceWindowsChan)
+
+	escalationPoliciesChan := make(chan *pg.EscalationPolicyRowsWithError)
+	defer close(escalationPoliciesChan)
+
 	// --------------------------
 	// Fetch SQL rows in parallel
 	// --------------------------
@@ -57,6 +70,24 @@ func GetChecks(w http.ResponseWriter, r *http.Request) {
 		metricsChan <- metricsWithError
 	}(currentCluster)
 
+	go func(currentCluster *cassandra.ClusterRow) {
+		maintenanceWindowsWithError := &pg.MaintenanceWindowRowsWithError{}
+		maintenanceWindowsWithError.MaintenanceWindows, maintenanceWindowsWithError.Error = pg.NewMaintenanceWindow(r.Context()).AllActiveByClusterID(nil, currentCluster.ID)
+		activeMaintenanceWindowsChan <- maintenanceWindowsWithError
+	}(currentCluster)
+
+	go func(currentCluster *cassandra.ClusterRow) {
+		maintenanceWindowsWithError := &pg.MaintenanceWindowRowsWithError{}
+		maintenanceWindowsWithError.MaintenanceWindows, maintenanceWindowsWithError.Error = pg.NewMaintenanceWindow(r.Context()).AllUpcomingByClusterID(nil, currentCluster.ID, 7*24*time.Hour)
+		upcomingMaintenanceWindowsChan <- maintenanceWindowsWithError
+	}(currentCluster)
+
+	go func(currentCluster *cassandra.ClusterRow) {
+		escalationPoliciesWithError := &pg.EscalationPolicyRowsWithError{}
+		escalationPoliciesWithError.EscalationPolicies, escalationPoliciesWithError.Error = pg.NewEscalationPolicy(r.Context()).AllByClusterID(nil, currentCluster.ID)
+		escalationPoliciesChan <- escalationPoliciesWithError
+	}(currentCluster)
+
 	// -----------------------------------
 	// Wait for channels to return results
 	// -----------------------------------
@@ -74,19 +105,47 @@ func GetChecks(w http.ResponseWriter, r *http.Request) {
 		hasError = true
 	}
 
+	activeMaintenanceWindowsWithError := <-activeMaintenanceWindowsChan
+	if activeMaintenanceWindowsWithError.Error != nil && activeMaintenanceWindowsWithError.Error.Error() != "sql: no rows in result set" {
+		libhttp.HandleErrorHTML(w, activeMaintenanceWindowsWithError.Error, 500)
+		hasError = true
+	}
+
+	upcomingMaintenanceWindowsWithError := <-upcomingMaintenanceWindowsChan
+	if upcomingMaintenanceWindowsWithError.Error != nil && upcomingMaintenanceWindowsWithError.Error.Error() != "sql: no rows in result set" {
+		libhttp.HandleErrorHTML(w, upcomingMaintenanceWindowsWithError.Error, 500)
+		hasError = true
+	}
+
+	escalationPoliciesWithError := <-escalationPoliciesChan
+	if escalationPoliciesWithError.Error != nil && escalationPoliciesWithError.Error.Error() != "sql: no rows in result set" {
+		libhttp.HandleErrorHTML(w, escalationPoliciesWithError.Error, 500)
+		hasError = true
+	}
+
 	if hasError {
 		return
 	}
 
+	checkNames := make(map[int64]string)
+	for _, checkRow := range checksWithError.Checks {
+		checkNames[checkRow.ID] = checkRow.Name
+	}
+
 	data := struct {
-		CSRFToken      string
-		Addr           string
-		CurrentUser    *cassandra.UserRow
-		AccessToken    *cassandra.AccessTokenRow
-		Clusters       []*cassandra.ClusterRow
-		CurrentCluster *cassandra.ClusterRow
-		Checks         []*pg.CheckRow
-		Metrics        []*pg.MetricRow
+		CSRFToken                  string
+		Addr                       string
+		CurrentUser                *cassandra.UserRow
+		AccessToken                *cassandra.AccessTokenRow
+		Clusters                   []*cassandra.ClusterRow
+		CurrentCluster             *cassandra.ClusterRow
+		Checks                     []*pg.CheckRow
+		Metrics                    []*pg.MetricRow
+		ActiveMaintenanceWindows   []*pg.MaintenanceWindowRow
+		UpcomingMaintenanceWindows []*pg.MaintenanceWindowRow
+		EscalationPolicies         []*pg.EscalationPolicyRow
+		CheckNames                 map[int64]string
+		CheckDependencyTree        []*pg.CheckDependencyNode
 	}{
 		csrf.Token(r),
 		r.Context().Value("Addr").(string),
@@ -96,6 +155,11 @@ func GetChecks(w http.ResponseWriter, r *http.Request) {
 		currentCluster,
 		checksWithError.Checks,
 		metricsWithError.Metrics,
+		activeMaintenanceWindowsWithError.MaintenanceWindows,
+		upcomingMaintenanceWindowsWithError.MaintenanceWindows,
+		escalationPoliciesWithError.EscalationPolicies,
+		checkNames,
+		pg.BuildCheckDependencyTree(checksWithError.Checks),
 	}
 
 	var tmpl *template.Template
@@ -145,7 +209,29 @@ func PostChecks(w http.ResponseWriter, r *http.Request) {
 	data["interval"] = intervalInSeconds + "s"
 	data["hosts_query"] = r.FormValue("HostsQuery")
 	data["hosts_list"] = hostsListJSON
-	data["expressions"] = r.FormValue("Expressions")
+	data["expressions"], err = checkExpressionsJSON(r, currentCluster.ID, 0, []byte(r.FormValue("Expressions")))
+	if err != nil {
+		libhttp.HandleErrorHTML(w, err, 400)
+		return
+	}
+	data["escalation_policy_id"], err = escalationPolicyIDFromForm(r, currentCluster.ID)
+	if err != nil {
+		libhttp.HandleErrorHTML(w, err, 400)
+		return
+	}
+	data["per_host_alerting"] = r.FormValue("PerHostAlerting") == "true"
+
+	parentIDs, err := parentIDsFromForm(r)
+	if err != nil {
+		libhttp.HandleErrorHTML(w, err, 400)
+		return
+	}
+	data["parent_ids"], err = checkParentIDsJSON(r, currentCluster.ID, 0, parentIDs)
+	if err != nil {
+		libhttp.HandleErrorHTML(w, err, 400)
+		return
+	}
+
 	data["triggers"] = []byte("[]")
 	data["last_result_hosts"] = []byte("[]")
 	data["last_result_expressions"] = []byte("[]")
@@ -221,7 +307,30 @@ func PutCheckID(w http.ResponseWriter, r *http.Request) {
 	data["interval"] = intervalInSeconds + "s"
 	data["hosts_query"] = r.FormValue("HostsQuery")
 	data["hosts_list"] = hostsListJSON
-	data["expressions"] = r.FormValue("Expressions")
+	currentCluster := r.Context().Value("currentCluster").(*cassandra.ClusterRow)
+
+	data["expressions"], err = checkExpressionsJSON(r, currentCluster.ID, id, []byte(r.FormValue("Expressions")))
+	if err != nil {
+		libhttp.HandleErrorHTML(w, err, 400)
+		return
+	}
+	data["escalation_policy_id"], err = escalationPolicyIDFromForm(r, currentCluster.ID)
+	if err != nil {
+		libhttp.HandleErrorHTML(w, err, 400)
+		return
+	}
+	data["per_host_alerting"] = r.FormValue("PerHostAlerting") == "true"
+
+	parentIDs, err := parentIDsFromForm(r)
+	if err != nil {
+		libhttp.HandleErrorHTML(w, err, 400)
+		return
+	}
+	data["parent_ids"], err = checkParentIDsJSON(r, currentCluster.ID, id, parentIDs)
+	if err != nil {
+		libhttp.HandleErrorHTML(w, err, 400)
+		return
+	}
 
 	_, err = pg.NewCheck(r.Context()).UpdateByID(nil, data, id)
 	if err != nil {
@@ -266,7 +375,31 @@ func PostCheckIDSilence(w http.ResponseWriter, r *http.Request) {
 	}
 
 	data := make(map[string]interface{})
-	data["is_silenced"] = !checkRow.IsSilenced
+
+	if checkRow.IsSilencedAt(time.Now().UTC()) {
+		data["is_silenced"] = false
+		data["silenced_until"] = nil
+		data["silence_reason"] = ""
+
+	} else {
+		data["is_silenced"] = true
+		data["silenced_until"] = nil
+		data["silence_reason"] = r.FormValue("SilenceReason")
+
+		// Without SilenceDurationMinute, the check stays silenced until it is unsilenced by hand.
+		silenceDurationMinuteString := r.FormValue("SilenceDurationMinute")
+		if silenceDurationMinuteString != "" {
+			silenceDurationMinute, err := strconv.ParseInt(silenceDurationMinuteString, 10, 64)
+			if err != nil {
+				libhttp.HandleErrorHTML(w, err, 500)
+				return
+			}
+
+			if silenceDurationMinute > 0 {
+				data["silenced_until"] = time.Now().UTC().Add(time.Duration(silenceDurationMinute) * time.Minute)
+			}
+		}
+	}
 
 	_, err = check.UpdateByID(nil, data, id)
 	if err != nil {
@@ -277,6 +410,82 @@ func PostCheckIDSilence(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, r.Referer(), 301)
 }
 
+// escalationPolicyIDFromForm returns nil when the check does not use an escalation policy.
+// The policy must belong to the cluster.
+func escalationPolicyIDFromForm(r *http.Request, clusterID int64) (interface{}, error) {
+	escalationPolicyIDString := r.FormValue("EscalationPolicyID")
+	if escalationPolicyIDString == "" {
+		return nil, nil
+	}
+
+	escalationPolicyID, err := strconv.ParseInt(escalationPolicyIDString, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	err = pg.NewEscalationPolicy(r.Context()).ValidateClusterID(nil, clusterID, escalationPolicyID)
+	if err != nil {
+		return nil, err
+	}
+
+	return escalationPolicyID, nil
+}
+
+// parentIDsFromForm returns every ParentIDs value of the form.
+func parentIDsFromForm(r *http.Request) ([]int64, error) {
+	err := r.ParseForm()
+	if err != nil {
+		return nil, err
+	}
+
+	parentIDs := make([]int64, 0)
+
+	for _, parentIDString := range r.Form["ParentIDs"] {
+		if parentIDString == "" {
+			continue
+		}
+
+		parentID, err := strconv.ParseInt(parentIDString, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		parentIDs = append(parentIDs, parentID)
+	}
+
+	return parentIDs, nil
+}
+
+// checkParentIDsJSON rejects parents outside of the cluster and dependency cycles. checkID is 0 for new checks.
+func checkParentIDsJSON(r *http.Request, clusterID, checkID int64, parentIDs []int64) ([]byte, error) {
+	if parentIDs == nil {
+		parentIDs = make([]int64, 0)
+	}
+
+	err := pg.NewCheck(r.Context()).ValidateParentIDs(nil, clusterID, checkID, parentIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(parentIDs)
+}
+
+// checkExpressionsJSON normalizes expressions and rejects CheckState expressions using checks outside of the cluster,
+// the check itself or checks that use it in turn. checkID is 0 for new checks.
+func checkExpressionsJSON(r *http.Request, clusterID, checkID int64, expressions []byte) ([]byte, error) {
+	expressionsJSON, err := pg.NormalizeExpressionsJSON(expressions)
+	if err != nil {
+		return nil, err
+	}
+
+	err = pg.NewCheck(r.Context()).ValidateCheckStateCheckIDs(nil, clusterID, checkID, expressionsJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	return expressionsJSON, nil
+}
+
 func newCheckTriggerFromForm(r *http.Request) (pg.CheckTrigger, error) {
 	lowViolationsCountString := r.FormValue("LowViolationsCount")
 	lowViolationsCount, err := strconv.ParseInt(lowViolationsCountString, 10, 64)
@@ -304,6 +513,15 @@ func newCheckTriggerFromForm(r *http.Request) (pg.CheckTrigger, error) {
 		}
 	}
 
+	renotifyIntervalMinute := int64(0)
+	renotifyIntervalMinuteString := r.FormValue("RenotifyIntervalMinute")
+	if renotifyIntervalMinuteString != "" {
+		renotifyIntervalMinute, err = strconv.ParseInt(renotifyIntervalMinuteString, 10, 64)
+		if err != nil {
+			return pg.CheckTrigger{}, err
+		}
+	}
+
 	action := pg.CheckTriggerAction{}
 	action.Transport = r.FormValue("ActionTransport")
 	action.Email = r.FormValue("ActionEmail")
@@ -312,10 +530,19 @@ func newCheckTriggerFromForm(r *http.Request) (pg.CheckTrigger, error) {
 	action.PagerDutyServiceKey = r.FormValue("ActionPagerDutyServiceKey")
 	action.PagerDutyDescription = r.FormValue("ActionPagerDutyDescription")
 
+	if action.Transport == "sms" {
+		err = pg.ValidateSMSAction(r.Context(), action)
+		if err != nil {
+			return pg.CheckTrigger{}, err
+		}
+	}
+
 	trigger := pg.CheckTrigger{}
 	trigger.LowViolationsCount = lowViolationsCount
 	trigger.HighViolationsCount = highViolationsCount
 	trigger.CreatedIntervalMinute = createdIntervalMinute
+	trigger.RenotifyIntervalMinute = renotifyIntervalMinute
+	trigger.Digest = r.FormValue("Digest") == "true"
 	trigger.Action = action
 
 	return trigger, nil
@@ -528,3 +755,361 @@ func GetApiCheckIDResults(w http.ResponseWriter, r *http.Request) {
 
 	w.Write(tsCheckRowsJSON)
 }
+
+// GetApiCheckIDHosts returns the alert state of every host of a check that alerts per host.
+func GetApiCheckIDHosts(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	accessTokenRow := r.Context().Value("accessToken").(*cassandra.AccessTokenRow)
+
+	id, err := getInt64SlugFromPath(w, r, "id")
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	checkRow, err := pg.NewCheck(r.Context()).GetByID(nil, id)
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	if accessTokenRow.ClusterID != checkRow.ClusterID {
+		libhttp.HandleErrorJson(w, fmt.Errorf("No permission to access check with ID: %v", id))
+		return
+	}
+
+	stateRows, err := pg.NewCheckHostState(r.Context()).AllByCheckID(nil, checkRow.ID)
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	stateRowsJSON, err := json.Marshal(stateRows)
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	w.Write(stateRowsJSON)
+}
+
+type checkPayload struct {
+	Name               string
+	Interval           string
+	HostsQuery         string
+	HostsList          []string
+	Expressions        json.RawMessage
+	EscalationPolicyID *int64
+	ParentIDs          []int64
+	PerHostAlerting    bool
+
+	// ID is only read by the preview, which validates edits of an existing check like saving them does.
+	ID int64
+}
+
+// checkDataFromRequest parses a check from the JSON body. Expressions may be nested or a legacy flat list.
+// checkID is 0 for new checks.
+func checkDataFromRequest(r *http.Request, clusterID, checkID int64) (map[string]interface{}, error) {
+	dataJSON, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	payload := checkPayload{}
+
+	err = json.Unmarshal(dataJSON, &payload)
+	if err != nil {
+		return nil, err
+	}
+
+	return checkDataFromPayload(r, clusterID, checkID, payload)
+}
+
+// checkDataFromPayload validates a check and returns its columns. checkID is 0 for new checks.
+func checkDataFromPayload(r *http.Request, clusterID, checkID int64, payload checkPayload) (map[string]interface{}, error) {
+	if payload.Name == "" {
+		return nil, errors.New("Name cannot be empty")
+	}
+
+	if payload.Interval == "" {
+		payload.Interval = "60s"
+	}
+	_, err := time.ParseDuration(payload.Interval)
+	if err != nil {
+		return nil, err
+	}
+
+	if payload.HostsList == nil {
+		payload.HostsList = make([]string, 0)
+	}
+
+	hostsListJSON, err := json.Marshal(libslice.RemoveEmpty(payload.HostsList))
+	if err != nil {
+		return nil, err
+	}
+
+	expressionsJSON, err := checkExpressionsJSON(r, clusterID, checkID, payload.Expressions)
+	if err != nil {
+		return nil, err
+	}
+
+	parentIDsJSON, err := checkParentIDsJSON(r, clusterID, checkID, payload.ParentIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	data := make(map[string]interface{})
+	data["name"] = payload.Name
+	data["interval"] = payload.Interval
+	data["hosts_query"] = payload.HostsQuery
+	data["hosts_list"] = hostsListJSON
+	data["expressions"] = expressionsJSON
+	data["parent_ids"] = parentIDsJSON
+	data["per_host_alerting"] = payload.PerHostAlerting
+	data["escalation_policy_id"] = nil
+	if payload.EscalationPolicyID != nil {
+		err = pg.NewEscalationPolicy(r.Context()).ValidateClusterID(nil, clusterID, *payload.EscalationPolicyID)
+		if err != nil {
+			return nil, err
+		}
+		data["escalation_policy_id"] = *payload.EscalationPolicyID
+	}
+
+	return data, nil
+}
+
+func publishChecksRefetch(r *http.Request) {
+	errLogger, err := contexthelper.GetLogger(r.Context(), "ErrLogger")
+	if err != nil {
+		return
+	}
+
+	bus := r.Context().Value("bus").(*messagebus.MessageBus)
+	go func() {
+		err := bus.Publish("checks-refetch", "true")
+		if err != nil {
+			errLogger.WithFields(logrus.Fields{"Error": err}).Error("Failed to publish checks-refetch message to message bus")
+		}
+	}()
+}
+
+func GetApiChecks(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	accessTokenRow := r.Context().Value("accessToken").(*cassandra.AccessTokenRow)
+
+	rows, err := pg.NewCheck(r.Context()).AllByClusterID(nil, accessTokenRow.ClusterID)
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	rowsJSON, err := json.Marshal(rows)
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	w.Write(rowsJSON)
+}
+
+func PostApiChecks(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	accessTokenRow := r.Context().Value("accessToken").(*cassandra.AccessTokenRow)
+
+	data, err := checkDataFromRequest(r, accessTokenRow.ClusterID, 0)
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+	data["triggers"] = []byte("[]")
+	data["last_result_hosts"] = []byte("[]")
+	data["last_result_expressions"] = []byte("[]")
+
+	row, err := pg.NewCheck(r.Context()).Create(nil, accessTokenRow.ClusterID, data)
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	publishChecksRefetch(r)
+
+	rowJSON, err := json.Marshal(row)
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	w.Write(rowJSON)
+}
+
+func GetApiChecksID(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	accessTokenRow := r.Context().Value("accessToken").(*cassandra.AccessTokenRow)
+
+	id, err := getInt64SlugFromPath(w, r, "id")
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	row, err := pg.NewCheck(r.Context()).GetByID(nil, id)
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	if row.ClusterID != accessTokenRow.ClusterID {
+		libhttp.HandleErrorJson(w, fmt.Errorf("No permission to access check with ID: %v", id))
+		return
+	}
+
+	rowJSON, err := json.Marshal(row)
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	w.Write(rowJSON)
+}
+
+func PutApiChecksID(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	accessTokenRow := r.Context().Value("accessToken").(*cassandra.AccessTokenRow)
+
+	id, err := getInt64SlugFromPath(w, r, "id")
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	check := pg.NewCheck(r.Context())
+
+	row, err := check.GetByID(nil, id)
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	if row.ClusterID != accessTokenRow.ClusterID {
+		libhttp.HandleErrorJson(w, fmt.Errorf("No permission to access check with ID: %v", id))
+		return
+	}
+
+	data, err := checkDataFromRequest(r, row.ClusterID, id)
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	_, err = check.UpdateByID(nil, data, id)
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	publishChecksRefetch(r)
+
+	row, err = check.GetByID(nil, id)
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	rowJSON, err := json.Marshal(row)
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	w.Write(rowJSON)
+}
+
+func DeleteApiChecksID(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	accessTokenRow := r.Context().Value("accessToken").(*cassandra.AccessTokenRow)
+
+	id, err := getInt64SlugFromPath(w, r, "id")
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	_, err = pg.NewCheck(r.Context()).DeleteByClusterIDAndID(nil, accessTokenRow.ClusterID, id)
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	publishChecksRefetch(r)
+
+	w.Write([]byte(fmt.Sprintf(`{"Message": "Deleted check", "ID": %v}`, id)))
+}
+
+type checkPreview struct {
+	Result      bool
+	Expressions []pg.CheckExpression
+}
+
+// PostApiChecksPreview evaluates a check definition once.
+// Nothing is written to ts_checks and no triggers run.
+func PostApiChecksPreview(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	accessTokenRow := r.Context().Value("accessToken").(*cassandra.AccessTokenRow)
+
+	dataJSON, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	payload := checkPayload{}
+
+	err = json.Unmarshal(dataJSON, &payload)
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	// A definition that previews fine must also save fine.
+	data, err := checkDataFromPayload(r, accessTokenRow.ClusterID, payload.ID, payload)
+	if err != nil {
+		libhttp.HandleBadRequestJson(w, err)
+		return
+	}
+
+	checkRow := &pg.CheckRow{
+		ID:              payload.ID,
+		ClusterID:       accessTokenRow.ClusterID,
+		Name:            payload.Name,
+		Interval:        data["interval"].(string),
+		HostsQuery:      payload.HostsQuery,
+		HostsList:       data["hosts_list"].([]byte),
+		Expressions:     data["expressions"].([]byte),
+		PerHostAlerting: payload.PerHostAlerting,
+	}
+
+	evaluator := &check_expression.CheckExpressionEvaluator{
+		AppContext: r.Context(),
+	}
+
+	expressionResults, finalResult, err := evaluator.EvalExpressions(checkRow)
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	previewJSON, err := json.Marshal(checkPreview{Result: finalResult, Expressions: expressionResults})
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	w.Write(previewJSON)
+}
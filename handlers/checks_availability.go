@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/csrf"
+
+	"github.com/resourced/resourced-master/libhttp"
+	"github.com/resourced/resourced-master/models/cassandra"
+	"github.com/resourced/resourced-master/models/pg"
+)
+
+// parseAvailabilityTime accepts UNIX seconds or a YYYY-MM-DD date.
+// The second value is true for dates.
+func parseAvailabilityTime(value string, defaultValue time.Time) (time.Time, bool, error) {
+	if value == "" {
+		return defaultValue, false, nil
+	}
+
+	unix, err := strconv.ParseInt(value, 10, 64)
+	if err == nil {
+		return time.Unix(unix, 0).UTC(), false, nil
+	}
+
+	t, err := time.Parse("2006-01-02", value)
+	return t, true, err
+}
+
+// checkAvailabilityFromQuery computes availability of checkRow for the from, to and breakdown query parameters.
+// The range defaults to the last 30 days.
+func checkAvailabilityFromQuery(r *http.Request, qParams url.Values, checkRow *pg.CheckRow, clusterRow *cassandra.ClusterRow) (pg.CheckAvailability, error) {
+	now := time.Now().UTC()
+
+	to, isDate, err := parseAvailabilityTime(qParams.Get("to"), now)
+	if err != nil {
+		return pg.CheckAvailability{}, err
+	}
+	if isDate {
+		// The whole day is included.
+		to = to.AddDate(0, 0, 1)
+	}
+	if to.After(now) {
+		to = now
+	}
+
+	from, _, err := parseAvailabilityTime(qParams.Get("from"), to.AddDate(0, 0, -30))
+	if err != nil {
+		return pg.CheckAvailability{}, err
+	}
+	if !from.Before(to) {
+		return pg.CheckAvailability{}, fmt.Errorf("from must be before to")
+	}
+
+	rows, err := pg.NewTSCheck(r.Context(), checkRow.ClusterID).AllResultsByClusterIDCheckIDAndRange(
+		nil, checkRow.ClusterID, checkRow.ID, from.Unix(), to.Unix(), clusterRow.GetDeletedFromUNIXTimestampForSelect("ts_checks"))
+	if err != nil {
+		return pg.CheckAvailability{}, err
+	}
+
+	// Results older than a few intervals no longer describe the check, e.g. while the checker was down.
+	var staleAfter time.Duration
+
+	interval, err := time.ParseDuration(checkRow.Interval)
+	if err == nil {
+		staleAfter = 3 * interval
+	}
+
+	return pg.NewCheckAvailabilityWithBreakdown(rows, from, to, staleAfter, qParams.Get("breakdown"))
+}
+
+func writeCheckAvailabilityCSV(w http.ResponseWriter, checkRow *pg.CheckRow, availability pg.CheckAvailability) {
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="check-%v-availability.csv"`, checkRow.ID))
+
+	writer := csv.NewWriter(w)
+	writer.WriteAll(availability.CSVRecords())
+}
+
+// GetCheckIDAvailability shows uptime, outages, MTTR and MTBF of a check.
+func GetCheckIDAvailability(w http.ResponseWriter, r *http.Request) {
+	currentUser := r.Context().Value("currentUser").(*cassandra.UserRow)
+
+	currentCluster := r.Context().Value("currentCluster").(*cassandra.ClusterRow)
+
+	id, err := getInt64SlugFromPath(w, r, "checkID")
+	if err != nil {
+		libhttp.HandleErrorHTML(w, err, 500)
+		return
+	}
+
+	checkRow, err := pg.NewCheck(r.Context()).GetByID(nil, id)
+	if err != nil {
+		libhttp.HandleErrorHTML(w, err, 500)
+		return
+	}
+
+	if checkRow.ClusterID != currentCluster.ID {
+		libhttp.HandleErrorHTML(w, fmt.Errorf("No permission to access check with ID: %v", id), 403)
+		return
+	}
+
+	qParams := r.URL.Query()
+
+	availability, err := checkAvailabilityFromQuery(r, qParams, checkRow, currentCluster)
+	if err != nil {
+		libhttp.HandleErrorHTML(w, err, 500)
+		return
+	}
+
+	if qParams.Get("format") == "csv" {
+		writeCheckAvailabilityCSV(w, checkRow, availability)
+		return
+	}
+
+	accessToken, err := getAccessToken(w, r, "read")
+	if err != nil {
+		libhttp.HandleErrorHTML(w, err, 500)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html")
+
+	data := struct {
+		CSRFToken      string
+		Addr           string
+		CurrentUser    *cassandra.UserRow
+		AccessToken    *cassandra.AccessTokenRow
+		Clusters       []*cassandra.ClusterRow
+		CurrentCluster *cassandra.ClusterRow
+		Check          *pg.CheckRow
+		Availability   pg.CheckAvailability
+		Breakdown      string
+		ToDate         string
+	}{
+		csrf.Token(r),
+		r.Context().Value("Addr").(string),
+		currentUser,
+		accessToken,
+		r.Context().Value("clusters").([]*cassandra.ClusterRow),
+		currentCluster,
+		checkRow,
+		availability,
+		qParams.Get("breakdown"),
+		availability.To.Add(-time.Second).Format("2006-01-02"),
+	}
+
+	tmpl, err := template.New("dashboard.html.tmpl").Funcs(template.FuncMap{
+		"seconds": func(seconds float64) string {
+			return (time.Duration(seconds) * time.Second).String()
+		},
+	}).ParseFiles("templates/dashboard.html.tmpl", "templates/checks/availability.html.tmpl")
+	if err != nil {
+		libhttp.HandleErrorHTML(w, err, 500)
+		return
+	}
+
+	tmpl.Execute(w, data)
+}
+
+// GetApiCheckIDAvailability returns uptime, outages, MTTR and MTBF of a check as JSON, or as CSV with format=csv.
+func GetApiCheckIDAvailability(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	accessTokenRow := r.Context().Value("accessToken").(*cassandra.AccessTokenRow)
+
+	id, err := getInt64SlugFromPath(w, r, "id")
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	checkRow, err := pg.NewCheck(r.Context()).GetByID(nil, id)
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	if accessTokenRow.ClusterID != checkRow.ClusterID {
+		libhttp.HandleErrorJson(w, fmt.Errorf("No permission to access check with ID: %v", id))
+		return
+	}
+
+	clusterRow, err := cassandra.NewCluster(r.Context()).GetByID(accessTokenRow.ClusterID)
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	qParams := r.URL.Query()
+
+	availability, err := checkAvailabilityFromQuery(r, qParams, checkRow, clusterRow)
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	if qParams.Get("format") == "csv" {
+		writeCheckAvailabilityCSV(w, checkRow, availability)
+		return
+	}
+
+	availabilityJSON, err := json.Marshal(availability)
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	w.Write(availabilityJSON)
+}
@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/csrf"
+
+	"github.com/resourced/resourced-master/libhttp"
+	"github.com/resourced/resourced-master/models/cassandra"
+	"github.com/resourced/resourced-master/models/pg"
+)
+
+// notificationsLimitFromQuery returns the limit query param, 100 by default.
+func notificationsLimitFromQuery(r *http.Request) int64 {
+	limit, err := strconv.ParseInt(r.URL.Query().Get("limit"), 10, 64)
+	if err != nil || limit <= 0 {
+		limit = 100
+	}
+
+	return limit
+}
+
+// GetCheckIDNotifications shows the latest notification delivery attempts of a check.
+func GetCheckIDNotifications(w http.ResponseWriter, r *http.Request) {
+	currentUser := r.Context().Value("currentUser").(*cassandra.UserRow)
+
+	currentCluster := r.Context().Value("currentCluster").(*cassandra.ClusterRow)
+
+	id, err := getInt64SlugFromPath(w, r, "checkID")
+	if err != nil {
+		libhttp.HandleErrorHTML(w, err, 500)
+		return
+	}
+
+	checkRow, err := pg.NewCheck(r.Context()).GetByID(nil, id)
+	if err != nil {
+		libhttp.HandleErrorHTML(w, err, 500)
+		return
+	}
+
+	if checkRow.ClusterID != currentCluster.ID {
+		libhttp.HandleErrorHTML(w, fmt.Errorf("No permission to access check with ID: %v", id), 403)
+		return
+	}
+
+	deliveryRows, err := pg.NewCheckNotificationDelivery(r.Context()).AllByCheckIDAndLimit(nil, checkRow.ID, notificationsLimitFromQuery(r))
+	if err != nil {
+		libhttp.HandleErrorHTML(w, err, 500)
+		return
+	}
+
+	accessToken, err := getAccessToken(w, r, "read")
+	if err != nil {
+		libhttp.HandleErrorHTML(w, err, 500)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html")
+
+	data := struct {
+		CSRFToken      string
+		Addr           string
+		CurrentUser    *cassandra.UserRow
+		AccessToken    *cassandra.AccessTokenRow
+		Clusters       []*cassandra.ClusterRow
+		CurrentCluster *cassandra.ClusterRow
+		Check          *pg.CheckRow
+		Deliveries     []*pg.CheckNotificationDeliveryRow
+	}{
+		csrf.Token(r),
+		r.Context().Value("Addr").(string),
+		currentUser,
+		accessToken,
+		r.Context().Value("clusters").([]*cassandra.ClusterRow),
+		currentCluster,
+		checkRow,
+		deliveryRows,
+	}
+
+	tmpl, err := template.ParseFiles("templates/dashboard.html.tmpl", "templates/checks/notifications.html.tmpl")
+	if err != nil {
+		libhttp.HandleErrorHTML(w, err, 500)
+		return
+	}
+
+	tmpl.Execute(w, data)
+}
+
+// GetApiCheckIDNotifications returns the latest notification delivery attempts of a check, newest first.
+func GetApiCheckIDNotifications(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	accessTokenRow := r.Context().Value("accessToken").(*cassandra.AccessTokenRow)
+
+	id, err := getInt64SlugFromPath(w, r, "id")
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	checkRow, err := pg.NewCheck(r.Context()).GetByID(nil, id)
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	if accessTokenRow.ClusterID != checkRow.ClusterID {
+		libhttp.HandleErrorJson(w, fmt.Errorf("No permission to access check with ID: %v", id))
+		return
+	}
+
+	deliveryRows, err := pg.NewCheckNotificationDelivery(r.Context()).AllByCheckIDAndLimit(nil, checkRow.ID, notificationsLimitFromQuery(r))
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	deliveryRowsJSON, err := json.Marshal(deliveryRows)
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	w.Write(deliveryRowsJSON)
+}
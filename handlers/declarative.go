@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/resourced/resourced-master/libhttp"
+	"github.com/resourced/resourced-master/models/cassandra"
+	"github.com/resourced/resourced-master/models/declarative"
+)
+
+// declarativeFormat returns the format query string, toml by default.
+func declarativeFormat(r *http.Request) string {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "toml"
+	}
+	return format
+}
+
+// GetApiExport returns the checks, triggers, graphs and metrics of the cluster as TOML or JSON.
+func GetApiExport(w http.ResponseWriter, r *http.Request) {
+	accessTokenRow := r.Context().Value("accessToken").(*cassandra.AccessTokenRow)
+
+	format := declarativeFormat(r)
+
+	state, err := declarative.LoadState(r.Context(), accessTokenRow.ClusterID)
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	content, err := declarative.Encode(format, state.Spec)
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	if format == "json" {
+		w.Header().Set("Content-Type", "application/json")
+	} else {
+		w.Header().Set("Content-Type", "application/toml")
+	}
+
+	w.Write(content)
+}
+
+// PostApiImport makes the cluster match the TOML or JSON document in the body.
+// Checks and graphs missing from the document are deleted.
+// With dry_run=true, it only returns the plan.
+func PostApiImport(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	accessTokenRow := r.Context().Value("accessToken").(*cassandra.AccessTokenRow)
+
+	content, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	desired, err := declarative.Decode(declarativeFormat(r), content)
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	err = desired.Normalize()
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	state, err := declarative.LoadState(r.Context(), accessTokenRow.ClusterID)
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	dryRun := r.URL.Query().Get("dry_run") == "true"
+
+	var plan *declarative.Plan
+
+	if dryRun {
+		plan = state.Plan(desired)
+
+	} else {
+		plan, err = state.Apply(r.Context(), desired)
+		if err != nil {
+			if plan.IsEmpty() {
+				libhttp.HandleErrorJson(w, err)
+				return
+			}
+
+			// Some changes were applied before the failure, the client needs to know which.
+			publishChecksRefetch(r)
+
+			errJSON, _ := json.Marshal(map[string]interface{}{
+				"Error":   err.Error(),
+				"Applied": plan.Changes,
+			})
+			http.Error(w, string(errJSON), http.StatusInternalServerError)
+			return
+		}
+
+		publishChecksRefetch(r)
+	}
+
+	planJSON, err := json.Marshal(map[string]interface{}{
+		"DryRun":  dryRun,
+		"Changes": plan.Changes,
+		"Plan":    plan.String(),
+	})
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	w.Write(planJSON)
+}
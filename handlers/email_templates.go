@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/resourced/resourced-master/libhttp"
+	"github.com/resourced/resourced-master/models/cassandra"
+	"github.com/resourced/resourced-master/models/pg"
+)
+
+type emailTemplatePayload struct {
+	Subject  string
+	TextBody string
+	HTMLBody string
+
+	// CheckID is optional, previews render its last violation instead of sample data.
+	CheckID int64
+}
+
+// emailTemplateFromRequest parses email templates from the JSON body.
+func emailTemplateFromRequest(r *http.Request) (*emailTemplatePayload, *pg.EmailTemplateRow, error) {
+	dataJSON, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	payload := &emailTemplatePayload{}
+
+	err = json.Unmarshal(dataJSON, payload)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	row := &pg.EmailTemplateRow{Subject: payload.Subject, TextBody: payload.TextBody, HTMLBody: payload.HTMLBody}
+
+	return payload, row, nil
+}
+
+// GetApiEmailTemplates returns the alert email templates of the cluster, and the defaults they override.
+func GetApiEmailTemplates(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	accessTokenRow := r.Context().Value("accessToken").(*cassandra.AccessTokenRow)
+
+	row, err := pg.NewEmailTemplate(r.Context()).GetOrDefaultByClusterID(nil, accessTokenRow.ClusterID)
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+	if row == nil {
+		row = &pg.EmailTemplateRow{ClusterID: accessTokenRow.ClusterID}
+	}
+
+	defaults, err := pg.DefaultEmailTemplate()
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	rowJSON, err := json.Marshal(map[string]interface{}{
+		"Templates": row,
+		"Defaults":  defaults,
+	})
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	w.Write(rowJSON)
+}
+
+// PutApiEmailTemplates overrides the alert email templates of the cluster. Empty templates use the defaults.
+func PutApiEmailTemplates(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	accessTokenRow := r.Context().Value("accessToken").(*cassandra.AccessTokenRow)
+
+	_, row, err := emailTemplateFromRequest(r)
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	err = row.Validate()
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	data := make(map[string]interface{})
+	data["subject"] = row.Subject
+	data["text_body"] = row.TextBody
+	data["html_body"] = row.HTMLBody
+
+	row, err = pg.NewEmailTemplate(r.Context()).Save(nil, accessTokenRow.ClusterID, data)
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	rowJSON, err := json.Marshal(row)
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	w.Write(rowJSON)
+}
+
+// DeleteApiEmailTemplates resets the alert email templates of the cluster to the defaults.
+func DeleteApiEmailTemplates(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	accessTokenRow := r.Context().Value("accessToken").(*cassandra.AccessTokenRow)
+
+	err := pg.NewEmailTemplate(r.Context()).DeleteByClusterID(nil, accessTokenRow.ClusterID)
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	w.Write([]byte(`{"Message": "Email templates are reset to defaults"}`))
+}
+
+// PostApiEmailTemplatesPreview renders email templates without saving them.
+// They render against the last violation of CheckID when given, sample data otherwise.
+func PostApiEmailTemplatesPreview(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	accessTokenRow := r.Context().Value("accessToken").(*cassandra.AccessTokenRow)
+
+	payload, row, err := emailTemplateFromRequest(r)
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	data := pg.SampleEmailTriggerData()
+
+	if payload.CheckID > 0 {
+		checkRow, err := pg.NewCheck(r.Context()).GetByID(nil, payload.CheckID)
+		if err != nil {
+			libhttp.HandleErrorJson(w, err)
+			return
+		}
+		if checkRow.ClusterID != accessTokenRow.ClusterID {
+			libhttp.HandleErrorJson(w, fmt.Errorf("No permission to access check with ID: %v", payload.CheckID))
+			return
+		}
+
+		lastViolation, err := pg.NewTSCheck(r.Context(), checkRow.ClusterID).LastByClusterIDCheckIDAndResult(nil, checkRow.ClusterID, checkRow.ID, true)
+		if err != nil {
+			libhttp.HandleErrorJson(w, errors.New("Check has no violation to preview"))
+			return
+		}
+
+		data.Check = checkRow
+		data.LastViolation = lastViolation
+	}
+
+	content, err := row.Render(data)
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	contentJSON, err := json.Marshal(content)
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	w.Write(contentJSON)
+}
@@ -0,0 +1,480 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/resourced/resourced-master/contexthelper"
+	"github.com/resourced/resourced-master/libhttp"
+	"github.com/resourced/resourced-master/models/cassandra"
+	"github.com/resourced/resourced-master/models/pg"
+)
+
+type onCallSchedulePayload struct {
+	Name                   string
+	RotationStart          time.Time
+	HandoffIntervalMinutes int64
+	Members                []pg.OnCallMember
+	Overrides              []pg.OnCallOverride
+}
+
+type escalationPolicyPayload struct {
+	Name  string
+	Steps []pg.EscalationStep
+}
+
+func onCallScheduleDataFromRequest(r *http.Request) (map[string]interface{}, error) {
+	dataJSON, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	payload := onCallSchedulePayload{}
+
+	err = json.Unmarshal(dataJSON, &payload)
+	if err != nil {
+		return nil, err
+	}
+
+	if payload.Name == "" {
+		return nil, errors.New("Name cannot be empty")
+	}
+	if len(payload.Members) == 0 {
+		return nil, errors.New("On-call schedule must have at least one member")
+	}
+	if payload.HandoffIntervalMinutes <= 0 {
+		payload.HandoffIntervalMinutes = 7 * 24 * 60
+	}
+	if payload.RotationStart.IsZero() {
+		payload.RotationStart = time.Now().UTC()
+	}
+	if payload.Overrides == nil {
+		payload.Overrides = make([]pg.OnCallOverride, 0)
+	}
+
+	membersJSON, err := json.Marshal(payload.Members)
+	if err != nil {
+		return nil, err
+	}
+
+	overridesJSON, err := json.Marshal(payload.Overrides)
+	if err != nil {
+		return nil, err
+	}
+
+	data := make(map[string]interface{})
+	data["name"] = payload.Name
+	data["rotation_start"] = payload.RotationStart.UTC()
+	data["handoff_interval_minutes"] = payload.HandoffIntervalMinutes
+	data["members"] = membersJSON
+	data["overrides"] = overridesJSON
+
+	return data, nil
+}
+
+func escalationPolicyDataFromRequest(r *http.Request) (map[string]interface{}, error) {
+	dataJSON, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	payload := escalationPolicyPayload{}
+
+	err = json.Unmarshal(dataJSON, &payload)
+	if err != nil {
+		return nil, err
+	}
+
+	if payload.Name == "" {
+		return nil, errors.New("Name cannot be empty")
+	}
+	if len(payload.Steps) == 0 {
+		return nil, errors.New("Escalation policy must have at least one step")
+	}
+
+	for i, step := range payload.Steps {
+		if len(step.Actions) == 0 {
+			return nil, fmt.Errorf("Escalation step %v must have at least one action", i+1)
+		}
+
+		for _, action := range step.Actions {
+			if action.Transport == "escalation" {
+				return nil, fmt.Errorf("Escalation step %v cannot escalate into another policy", i+1)
+			}
+		}
+	}
+
+	stepsJSON, err := json.Marshal(payload.Steps)
+	if err != nil {
+		return nil, err
+	}
+
+	data := make(map[string]interface{})
+	data["name"] = payload.Name
+	data["steps"] = stepsJSON
+
+	return data, nil
+}
+
+func GetApiOnCallSchedules(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	accessTokenRow := r.Context().Value("accessToken").(*cassandra.AccessTokenRow)
+
+	rows, err := pg.NewOnCallSchedule(r.Context()).AllByClusterID(nil, accessTokenRow.ClusterID)
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	rowsJSON, err := json.Marshal(rows)
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	w.Write(rowsJSON)
+}
+
+func PostApiOnCallSchedules(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	accessTokenRow := r.Context().Value("accessToken").(*cassandra.AccessTokenRow)
+
+	data, err := onCallScheduleDataFromRequest(r)
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	row, err := pg.NewOnCallSchedule(r.Context()).Create(nil, accessTokenRow.ClusterID, data)
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	rowJSON, err := json.Marshal(row)
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	w.Write(rowJSON)
+}
+
+func PutApiOnCallSchedulesID(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	accessTokenRow := r.Context().Value("accessToken").(*cassandra.AccessTokenRow)
+
+	id, err := getInt64SlugFromPath(w, r, "id")
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	schedule := pg.NewOnCallSchedule(r.Context())
+
+	row, err := schedule.GetByID(nil, id)
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	if row.ClusterID != accessTokenRow.ClusterID {
+		libhttp.HandleErrorJson(w, fmt.Errorf("No permission to access on-call schedule with ID: %v", id))
+		return
+	}
+
+	data, err := onCallScheduleDataFromRequest(r)
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	_, err = schedule.UpdateByID(nil, data, id)
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	row, err = schedule.GetByID(nil, id)
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	rowJSON, err := json.Marshal(row)
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	w.Write(rowJSON)
+}
+
+func DeleteApiOnCallSchedulesID(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	accessTokenRow := r.Context().Value("accessToken").(*cassandra.AccessTokenRow)
+
+	id, err := getInt64SlugFromPath(w, r, "id")
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	_, err = pg.NewOnCallSchedule(r.Context()).DeleteByClusterIDAndID(nil, accessTokenRow.ClusterID, id)
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	w.Write([]byte(fmt.Sprintf(`{"Message": "Deleted on-call schedule", "ID": %v}`, id)))
+}
+
+func GetApiEscalationPolicies(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	accessTokenRow := r.Context().Value("accessToken").(*cassandra.AccessTokenRow)
+
+	rows, err := pg.NewEscalationPolicy(r.Context()).AllByClusterID(nil, accessTokenRow.ClusterID)
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	rowsJSON, err := json.Marshal(rows)
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	w.Write(rowsJSON)
+}
+
+func PostApiEscalationPolicies(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	accessTokenRow := r.Context().Value("accessToken").(*cassandra.AccessTokenRow)
+
+	data, err := escalationPolicyDataFromRequest(r)
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	row, err := pg.NewEscalationPolicy(r.Context()).Create(nil, accessTokenRow.ClusterID, data)
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	rowJSON, err := json.Marshal(row)
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	w.Write(rowJSON)
+}
+
+func PutApiEscalationPoliciesID(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	accessTokenRow := r.Context().Value("accessToken").(*cassandra.AccessTokenRow)
+
+	id, err := getInt64SlugFromPath(w, r, "id")
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	policy := pg.NewEscalationPolicy(r.Context())
+
+	row, err := policy.GetByID(nil, id)
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	if row.ClusterID != accessTokenRow.ClusterID {
+		libhttp.HandleErrorJson(w, fmt.Errorf("No permission to access escalation policy with ID: %v", id))
+		return
+	}
+
+	data, err := escalationPolicyDataFromRequest(r)
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	_, err = policy.UpdateByID(nil, data, id)
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	row, err = policy.GetByID(nil, id)
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	rowJSON, err := json.Marshal(row)
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	w.Write(rowJSON)
+}
+
+func DeleteApiEscalationPoliciesID(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	accessTokenRow := r.Context().Value("accessToken").(*cassandra.AccessTokenRow)
+
+	id, err := getInt64SlugFromPath(w, r, "id")
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	_, err = pg.NewEscalationPolicy(r.Context()).DeleteByClusterIDAndID(nil, accessTokenRow.ClusterID, id)
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	w.Write([]byte(fmt.Sprintf(`{"Message": "Deleted escalation policy", "ID": %v}`, id)))
+}
+
+func GetApiEscalations(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	accessTokenRow := r.Context().Value("accessToken").(*cassandra.AccessTokenRow)
+
+	rows, err := pg.NewEscalation(r.Context()).AllOpenByClusterID(nil, accessTokenRow.ClusterID)
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	rowsJSON, err := json.Marshal(rows)
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	w.Write(rowsJSON)
+}
+
+func PostApiEscalationsIDAck(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	accessTokenRow := r.Context().Value("accessToken").(*cassandra.AccessTokenRow)
+
+	id, err := getInt64SlugFromPath(w, r, "id")
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	escalation := pg.NewEscalation(r.Context())
+
+	row, err := escalation.GetByID(nil, id)
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	if row.ClusterID != accessTokenRow.ClusterID {
+		libhttp.HandleErrorJson(w, fmt.Errorf("No permission to access escalation with ID: %v", id))
+		return
+	}
+
+	err = escalation.Acknowledge(nil, id, fmt.Sprintf("user(ID: %v) via API", accessTokenRow.UserID))
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	w.Write([]byte(fmt.Sprintf(`{"Message": "Acknowledged escalation", "ID": %v}`, id)))
+}
+
+// GetEscalationsIDAck asks to confirm the acknowledgement behind the signed link sent in notification emails.
+// It does not acknowledge anything, because mail scanners and link prefetchers follow such links.
+func GetEscalationsIDAck(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html")
+
+	row, ok := escalationFromAckRequest(w, r, r.URL.Query().Get("signature"))
+	if !ok {
+		return
+	}
+
+	if row.IsAcknowledged() {
+		w.Write([]byte(fmt.Sprintf("<p>Escalation for check(ID: %v) is already acknowledged.</p>", row.CheckID)))
+		return
+	}
+
+	w.Write([]byte(fmt.Sprintf(`<form method="post" action="/escalations/%v/ack">
+<input type="hidden" name="signature" value="%v">
+<p>Acknowledge the escalation for check(ID: %v)? No further steps will be notified.</p>
+<button type="submit">Acknowledge</button>
+</form>`, row.ID, html.EscapeString(r.URL.Query().Get("signature")), row.CheckID)))
+}
+
+// PostEscalationsIDAck acknowledges an escalation confirmed from the page of GetEscalationsIDAck.
+func PostEscalationsIDAck(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html")
+
+	row, ok := escalationFromAckRequest(w, r, r.FormValue("signature"))
+	if !ok {
+		return
+	}
+
+	if !row.IsAcknowledged() {
+		err := pg.NewEscalation(r.Context()).Acknowledge(nil, row.ID, "email link")
+		if err != nil {
+			libhttp.HandleErrorHTML(w, err, 500)
+			return
+		}
+	}
+
+	w.Write([]byte(fmt.Sprintf("<p>Escalation for check(ID: %v) is acknowledged. No further steps will be notified.</p>", row.CheckID)))
+}
+
+// escalationFromAckRequest returns the escalation of an acknowledgement link after verifying its signature.
+// It writes the error response when it fails.
+func escalationFromAckRequest(w http.ResponseWriter, r *http.Request, signature string) (*pg.EscalationRow, bool) {
+	generalConfig, err := contexthelper.GetGeneralConfig(r.Context())
+	if err != nil {
+		libhttp.HandleErrorHTML(w, err, 500)
+		return nil, false
+	}
+
+	id, err := getInt64SlugFromPath(w, r, "id")
+	if err != nil {
+		libhttp.HandleErrorHTML(w, err, 500)
+		return nil, false
+	}
+
+	if !pg.VerifyEscalationAck(generalConfig.CookieSecret, id, signature) {
+		libhttp.HandleErrorHTML(w, errors.New("Invalid acknowledgement link"), 403)
+		return nil, false
+	}
+
+	row, err := pg.NewEscalation(r.Context()).GetByID(nil, id)
+	if err != nil {
+		libhttp.HandleErrorHTML(w, err, 500)
+		return nil, false
+	}
+
+	return row, true
+}
@@ -0,0 +1,226 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pressly/chi"
+
+	"github.com/resourced/resourced-master/libhttp"
+	"github.com/resourced/resourced-master/models/cassandra"
+	"github.com/resourced/resourced-master/models/pg"
+)
+
+// heartbeatDurationSeconds parses the run duration of a ping, either seconds, e.g. "12.5", or a duration, e.g. "1m30s".
+func heartbeatDurationSeconds(value string) (*float64, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	seconds, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, fmt.Errorf("Duration is malformed: %v", value)
+		}
+		seconds = duration.Seconds()
+	}
+
+	return &seconds, nil
+}
+
+// heartbeatExitStatus parses the exit status of a ping.
+func heartbeatExitStatus(value string) (*int64, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	exitStatus, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("Exit status is malformed: %v", value)
+	}
+
+	return &exitStatus, nil
+}
+
+func GetApiHeartbeats(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	accessTokenRow := r.Context().Value("accessToken").(*cassandra.AccessTokenRow)
+
+	rows, err := pg.NewHeartbeat(r.Context()).AllByClusterID(nil, accessTokenRow.ClusterID)
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	rowsJSON, err := json.Marshal(rows)
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	w.Write(rowsJSON)
+}
+
+// PostApiHeartbeats creates a heartbeat monitor and the check that watches it.
+// Triggers, silences and history of the monitor are those of the check.
+func PostApiHeartbeats(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	accessTokenRow := r.Context().Value("accessToken").(*cassandra.AccessTokenRow)
+
+	dataJSON, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	row := &pg.HeartbeatRow{Period: "24h", Grace: "1h"}
+
+	err = json.Unmarshal(dataJSON, row)
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+	row.ClusterID = accessTokenRow.ClusterID
+	row.CheckID = nil
+
+	heartbeat := pg.NewHeartbeat(r.Context())
+
+	row, err = heartbeat.Create(nil, row)
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	expression := pg.CheckExpression{}
+	expression.Type = "Heartbeat"
+	expression.HeartbeatID = row.ID
+
+	expressionsJSON, err := json.Marshal([]pg.CheckExpression{expression})
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	data := make(map[string]interface{})
+	data["name"] = row.Name
+	data["interval"] = pg.HeartbeatCheckInterval
+	data["hosts_query"] = ""
+	data["hosts_list"] = []byte("[]")
+	data["expressions"] = expressionsJSON
+	data["parent_ids"] = []byte("[]")
+	data["triggers"] = []byte("[]")
+	data["last_result_hosts"] = []byte("[]")
+	data["last_result_expressions"] = []byte("[]")
+
+	checkRow, err := pg.NewCheck(r.Context()).Create(nil, accessTokenRow.ClusterID, data)
+	if err != nil {
+		heartbeat.DeleteByID(nil, row.ID)
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	_, err = heartbeat.UpdateByID(nil, map[string]interface{}{"check_id": checkRow.ID}, row.ID)
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	publishChecksRefetch(r)
+
+	row, err = heartbeat.GetByID(nil, row.ID)
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	rowJSON, err := json.Marshal(row)
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	w.Write(rowJSON)
+}
+
+// DeleteApiHeartbeatsToken deletes a heartbeat monitor together with its check.
+func DeleteApiHeartbeatsToken(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	accessTokenRow := r.Context().Value("accessToken").(*cassandra.AccessTokenRow)
+
+	heartbeat := pg.NewHeartbeat(r.Context())
+
+	row, err := heartbeat.GetByToken(nil, chi.URLParam(r, "token"))
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	if row.ClusterID != accessTokenRow.ClusterID {
+		libhttp.HandleErrorJson(w, fmt.Errorf("No permission to access heartbeat with ID: %v", row.ID))
+		return
+	}
+
+	// Deleting the check deletes the monitor too.
+	if row.CheckID != nil {
+		_, err = pg.NewCheck(r.Context()).DeleteByClusterIDAndID(nil, row.ClusterID, *row.CheckID)
+	} else {
+		_, err = heartbeat.DeleteByID(nil, row.ID)
+	}
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	publishChecksRefetch(r)
+
+	w.Write([]byte(fmt.Sprintf(`{"Message": "Deleted heartbeat", "ID": %v}`, row.ID)))
+}
+
+// GetPostApiHeartbeatsToken records a ping. The token is the only credential, so cron jobs can call it with curl.
+// Optional parameters: duration, in seconds or as a duration like 1m30s, and exit_status.
+func GetPostApiHeartbeatsToken(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	durationSeconds, err := heartbeatDurationSeconds(strings.TrimSpace(r.FormValue("duration")))
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	exitStatus, err := heartbeatExitStatus(strings.TrimSpace(r.FormValue("exit_status")))
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	heartbeat := pg.NewHeartbeat(r.Context())
+	token := chi.URLParam(r, "token")
+
+	_, err = heartbeat.GetByToken(nil, token)
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows in result set") {
+			libhttp.HandleErrorJson(w, errors.New("Heartbeat does not exist"))
+			return
+		}
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	_, err = heartbeat.Ping(nil, token, durationSeconds, exitStatus)
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	w.Write([]byte(`{"Message": "OK"}`))
+}
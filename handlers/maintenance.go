@@ -0,0 +1,214 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/resourced/resourced-master/libhttp"
+	"github.com/resourced/resourced-master/models/cassandra"
+	"github.com/resourced/resourced-master/models/pg"
+)
+
+// maintenanceWindowFormTimeLayout matches the value of datetime-local inputs.
+const maintenanceWindowFormTimeLayout = "2006-01-02T15:04"
+
+func newMaintenanceWindowFromForm(r *http.Request) (*pg.MaintenanceWindowRow, error) {
+	row := &pg.MaintenanceWindowRow{}
+	row.Name = r.FormValue("Name")
+	row.Reason = r.FormValue("Reason")
+	row.Scope = r.FormValue("Scope")
+	row.HostsQuery = r.FormValue("HostsQuery")
+	row.Cron = r.FormValue("Cron")
+
+	startsAt, err := time.Parse(maintenanceWindowFormTimeLayout, r.FormValue("StartsAt"))
+	if err != nil {
+		return nil, err
+	}
+	row.StartsAt = startsAt
+
+	endsAt, err := time.Parse(maintenanceWindowFormTimeLayout, r.FormValue("EndsAt"))
+	if err != nil {
+		return nil, err
+	}
+	row.EndsAt = endsAt
+
+	checkIDString := r.FormValue("CheckID")
+	if checkIDString != "" {
+		checkID, err := strconv.ParseInt(checkIDString, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		row.CheckID = &checkID
+	}
+
+	durationMinutesString := r.FormValue("DurationMinutes")
+	if durationMinutesString != "" {
+		row.DurationMinutes, err = strconv.ParseInt(durationMinutesString, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return row, nil
+}
+
+func PostMaintenanceWindows(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html")
+
+	currentCluster := r.Context().Value("currentCluster").(*cassandra.ClusterRow)
+
+	row, err := newMaintenanceWindowFromForm(r)
+	if err != nil {
+		libhttp.HandleErrorHTML(w, err, 500)
+		return
+	}
+
+	row.ClusterID = currentCluster.ID
+
+	_, err = pg.NewMaintenanceWindow(r.Context()).Create(nil, row)
+	if err != nil {
+		libhttp.HandleErrorHTML(w, err, 500)
+		return
+	}
+
+	http.Redirect(w, r, r.Referer(), 301)
+}
+
+func PostPutDeleteMaintenanceWindowID(w http.ResponseWriter, r *http.Request) {
+	method := r.FormValue("_method")
+	if method == "" {
+		method = "delete"
+	}
+
+	if method == "delete" {
+		DeleteMaintenanceWindowID(w, r)
+	}
+}
+
+func DeleteMaintenanceWindowID(w http.ResponseWriter, r *http.Request) {
+	id, err := getInt64SlugFromPath(w, r, "maintenanceWindowID")
+	if err != nil {
+		libhttp.HandleErrorHTML(w, err, 500)
+		return
+	}
+
+	currentCluster := r.Context().Value("currentCluster").(*cassandra.ClusterRow)
+
+	_, err = pg.NewMaintenanceWindow(r.Context()).DeleteByClusterIDAndID(nil, currentCluster.ID, id)
+	if err != nil {
+		libhttp.HandleErrorHTML(w, err, 500)
+		return
+	}
+
+	http.Redirect(w, r, r.Referer(), 301)
+}
+
+func GetApiMaintenanceWindows(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	accessTokenRow := r.Context().Value("accessToken").(*cassandra.AccessTokenRow)
+
+	within, err := time.ParseDuration(r.URL.Query().Get("Within"))
+	if err != nil || within <= 0 {
+		within = 7 * 24 * time.Hour
+	}
+
+	mw := pg.NewMaintenanceWindow(r.Context())
+
+	active, err := mw.AllActiveByClusterID(nil, accessTokenRow.ClusterID)
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	upcoming, err := mw.AllUpcomingByClusterID(nil, accessTokenRow.ClusterID, within)
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	data := make(map[string][]*pg.MaintenanceWindowRow)
+	data["Active"] = active
+	data["Upcoming"] = upcoming
+
+	dataJSON, err := json.Marshal(data)
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	w.Write(dataJSON)
+}
+
+func PostApiMaintenanceWindows(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	accessTokenRow := r.Context().Value("accessToken").(*cassandra.AccessTokenRow)
+
+	dataJSON, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	row := &pg.MaintenanceWindowRow{}
+
+	err = json.Unmarshal(dataJSON, row)
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	row.ClusterID = accessTokenRow.ClusterID
+
+	if row.CheckID != nil {
+		checkRow, err := pg.NewCheck(r.Context()).GetByID(nil, *row.CheckID)
+		if err != nil {
+			libhttp.HandleErrorJson(w, err)
+			return
+		}
+
+		if checkRow.ClusterID != accessTokenRow.ClusterID {
+			libhttp.HandleErrorJson(w, fmt.Errorf("No permission to access check with ID: %v", *row.CheckID))
+			return
+		}
+	}
+
+	row, err = pg.NewMaintenanceWindow(r.Context()).Create(nil, row)
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	rowJSON, err := json.Marshal(row)
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	w.Write(rowJSON)
+}
+
+func DeleteApiMaintenanceWindowsID(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	accessTokenRow := r.Context().Value("accessToken").(*cassandra.AccessTokenRow)
+
+	id, err := getInt64SlugFromPath(w, r, "id")
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	_, err = pg.NewMaintenanceWindow(r.Context()).DeleteByClusterIDAndID(nil, accessTokenRow.ClusterID, id)
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	w.Write([]byte(fmt.Sprintf(`{"Message": "Deleted maintenance window", "ID": %v}`, id)))
+}
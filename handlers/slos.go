@@ -0,0 +1,326 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/resourced/resourced-master/libhttp"
+	"github.com/resourced/resourced-master/models/cassandra"
+	"github.com/resourced/resourced-master/models/pg"
+)
+
+type sloPayload struct {
+	Name           string
+	TargetPercent  float64
+	Window         string
+	SourceType     string
+	Source         pg.SLOSource
+	BurnRateAlerts []pg.SLOBurnRateAlert
+
+	// Actions are notified by the default burn-rate alerts, used when BurnRateAlerts is empty.
+	Actions []pg.CheckTriggerAction
+}
+
+// sloDataFromRequest parses an SLO from the JSON body.
+func sloDataFromRequest(r *http.Request, clusterID int64) (map[string]interface{}, error) {
+	dataJSON, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	payload := sloPayload{}
+
+	err = json.Unmarshal(dataJSON, &payload)
+	if err != nil {
+		return nil, err
+	}
+
+	if payload.Window == "" {
+		payload.Window = "30d"
+	}
+
+	row := &pg.SLORow{Name: payload.Name, TargetPercent: payload.TargetPercent, Window: payload.Window, SourceType: payload.SourceType}
+
+	window, err := row.WindowDuration()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(payload.BurnRateAlerts) == 0 {
+		if payload.Actions == nil {
+			payload.Actions = make([]pg.CheckTriggerAction, 0)
+		}
+		payload.BurnRateAlerts = pg.DefaultSLOBurnRateAlerts(window, payload.Actions)
+	}
+
+	for i := range payload.BurnRateAlerts {
+		if payload.BurnRateAlerts[i].ID == 0 {
+			payload.BurnRateAlerts[i].ID = pg.NewExplicitID()
+		}
+		if payload.BurnRateAlerts[i].Actions == nil {
+			payload.BurnRateAlerts[i].Actions = make([]pg.CheckTriggerAction, 0)
+		}
+	}
+
+	row.Source, err = json.Marshal(payload.Source)
+	if err != nil {
+		return nil, err
+	}
+
+	row.BurnRateAlerts, err = json.Marshal(payload.BurnRateAlerts)
+	if err != nil {
+		return nil, err
+	}
+
+	clusterRow, err := pg.NewCluster(r.Context()).GetByID(nil, clusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = row.Validate(clusterRow.GetDataRetention())
+	if err != nil {
+		return nil, err
+	}
+
+	if payload.SourceType == "check" {
+		checkRow, err := pg.NewCheck(r.Context()).GetByID(nil, payload.Source.CheckID)
+		if err != nil {
+			return nil, err
+		}
+		if checkRow.ClusterID != clusterID {
+			return nil, errors.New("SLO cannot be based on a check of a different cluster")
+		}
+	}
+
+	data := make(map[string]interface{})
+	data["name"] = row.Name
+	data["target_percent"] = row.TargetPercent
+	data["time_window"] = row.Window
+	data["source_type"] = row.SourceType
+	data["source"] = []byte(row.Source)
+	data["burn_rate_alerts"] = []byte(row.BurnRateAlerts)
+
+	return data, nil
+}
+
+// getSLOFromPath returns the SLO in the id slug, as long as it belongs to the cluster of the access token.
+func getSLOFromPath(w http.ResponseWriter, r *http.Request) (*pg.SLORow, error) {
+	accessTokenRow := r.Context().Value("accessToken").(*cassandra.AccessTokenRow)
+
+	id, err := getInt64SlugFromPath(w, r, "id")
+	if err != nil {
+		return nil, err
+	}
+
+	row, err := pg.NewSLO(r.Context()).GetByID(nil, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if row.ClusterID != accessTokenRow.ClusterID {
+		return nil, fmt.Errorf("No permission to access SLO with ID: %v", id)
+	}
+
+	return row, nil
+}
+
+func GetApiSLOs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	accessTokenRow := r.Context().Value("accessToken").(*cassandra.AccessTokenRow)
+
+	rows, err := pg.NewSLO(r.Context()).AllByClusterID(nil, accessTokenRow.ClusterID)
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	rowsJSON, err := json.Marshal(rows)
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	w.Write(rowsJSON)
+}
+
+func PostApiSLOs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	accessTokenRow := r.Context().Value("accessToken").(*cassandra.AccessTokenRow)
+
+	data, err := sloDataFromRequest(r, accessTokenRow.ClusterID)
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	row, err := pg.NewSLO(r.Context()).Create(nil, accessTokenRow.ClusterID, data)
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	rowJSON, err := json.Marshal(row)
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	w.Write(rowJSON)
+}
+
+func GetApiSLOsID(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	row, err := getSLOFromPath(w, r)
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	rowJSON, err := json.Marshal(row)
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	w.Write(rowJSON)
+}
+
+func PutApiSLOsID(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	row, err := getSLOFromPath(w, r)
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	data, err := sloDataFromRequest(r, row.ClusterID)
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	slo := pg.NewSLO(r.Context())
+
+	_, err = slo.UpdateByID(nil, data, row.ID)
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	row, err = slo.GetByID(nil, row.ID)
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	rowJSON, err := json.Marshal(row)
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	w.Write(rowJSON)
+}
+
+func DeleteApiSLOsID(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	accessTokenRow := r.Context().Value("accessToken").(*cassandra.AccessTokenRow)
+
+	id, err := getInt64SlugFromPath(w, r, "id")
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	_, err = pg.NewSLO(r.Context()).DeleteByClusterIDAndID(nil, accessTokenRow.ClusterID, id)
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	w.Write([]byte(fmt.Sprintf(`{"Message": "Deleted SLO", "ID": %v}`, id)))
+}
+
+// GetApiSLOsIDBudgets returns the error budget history of an SLO.
+// from and to accept UNIX seconds or YYYY-MM-DD dates, the range defaults to the SLO window.
+func GetApiSLOsIDBudgets(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	row, err := getSLOFromPath(w, r)
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	window, err := row.WindowDuration()
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	qParams := r.URL.Query()
+
+	to, isDate, err := parseAvailabilityTime(qParams.Get("to"), time.Now().UTC())
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+	if isDate {
+		// The whole day is included.
+		to = to.AddDate(0, 0, 1)
+	}
+
+	from, _, err := parseAvailabilityTime(qParams.Get("from"), to.Add(-window))
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	rows, err := pg.NewSLOBudget(r.Context()).AllBySLOIDAndRange(nil, row.ID, from.Unix(), to.Unix())
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	rowsJSON, err := json.Marshal(rows)
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	w.Write(rowsJSON)
+}
+
+// GetApiSLOsIDNotifications returns the latest notification delivery attempts of the burn-rate alerts of an SLO, newest first.
+func GetApiSLOsIDNotifications(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	row, err := getSLOFromPath(w, r)
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	deliveryRows, err := pg.NewCheckNotificationDelivery(r.Context()).AllBySLOIDAndLimit(nil, row.ID, notificationsLimitFromQuery(r))
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	deliveryRowsJSON, err := json.Marshal(deliveryRows)
+	if err != nil {
+		libhttp.HandleErrorJson(w, err)
+		return
+	}
+
+	w.Write(deliveryRowsJSON)
+}
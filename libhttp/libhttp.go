@@ -57,6 +57,12 @@ func HandleErrorJson(w http.ResponseWriter, err error) {
 	http.Error(w, string(errJson), http.StatusInternalServerError)
 }
 
+// HandleBadRequestJson wraps error in JSON structure, for requests that are invalid.
+func HandleBadRequestJson(w http.ResponseWriter, err error) {
+	errJson, _ := json.Marshal(map[string]string{"Error": err.Error()})
+	http.Error(w, string(errJson), http.StatusBadRequest)
+}
+
 // HandleErrorHTML wraps error in HTML.
 func HandleErrorHTML(w http.ResponseWriter, err error, statusCode int) {
 	data := struct {
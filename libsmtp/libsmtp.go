@@ -2,8 +2,11 @@
 package libsmtp
 
 import (
+	"bytes"
 	"encoding/base64"
 	"fmt"
+	"mime/multipart"
+	"net/textproto"
 )
 
 // BuildMessage is a helper function to build email message.
@@ -25,3 +28,44 @@ func BuildMessage(from, to, subject, body string) string {
 
 	return message
 }
+
+// BuildMultipartMessage is a helper function to build email message with both plain text and HTML bodies.
+// Mail clients show the HTML body when they can, the plain text one otherwise.
+func BuildMultipartMessage(from, to, subject, textBody, htmlBody string) string {
+	var buffer bytes.Buffer
+
+	writer := multipart.NewWriter(&buffer)
+
+	headers := make(map[string]string)
+	headers["Return-Path"] = from
+	headers["From"] = from
+	headers["To"] = to
+	headers["Subject"] = subject
+	headers["MIME-Version"] = "1.0"
+	headers["Content-Type"] = fmt.Sprintf(`multipart/alternative; boundary="%v"`, writer.Boundary())
+
+	message := ""
+	for k, v := range headers {
+		message += fmt.Sprintf("%s: %s\r\n", k, v)
+	}
+	message += "\r\n"
+
+	for _, part := range []struct {
+		ContentType string
+		Body        string
+	}{
+		{`text/plain; charset="utf-8"`, textBody},
+		{`text/html; charset="utf-8"`, htmlBody},
+	} {
+		partHeaders := make(textproto.MIMEHeader)
+		partHeaders.Set("Content-Type", part.ContentType)
+		partHeaders.Set("Content-Transfer-Encoding", "base64")
+
+		partWriter, _ := writer.CreatePart(partHeaders)
+		partWriter.Write([]byte(base64.StdEncoding.EncodeToString([]byte(part.Body))))
+	}
+
+	writer.Close()
+
+	return message + buffer.String()
+}
@@ -0,0 +1,56 @@
+package libsmtp
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"strings"
+	"testing"
+)
+
+func TestBuildMultipartMessage(t *testing.T) {
+	message := BuildMultipartMessage("from@example.com", "to@example.com", "subject", "plain body", "<p>html body</p>")
+
+	msg, err := mail.ReadMessage(strings.NewReader(message))
+	if err != nil {
+		t.Fatalf("Message should be parseable. Error: %v", err)
+	}
+
+	if msg.Header.Get("Subject") != "subject" {
+		t.Errorf("Subject is incorrect. Subject: %v", msg.Header.Get("Subject"))
+	}
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("Message should be multipart/alternative. Content-Type: %v", msg.Header.Get("Content-Type"))
+	}
+
+	reader := multipart.NewReader(msg.Body, params["boundary"])
+
+	expected := []struct {
+		ContentType string
+		Body        string
+	}{
+		{"text/plain", "plain body"},
+		{"text/html", "<p>html body</p>"},
+	}
+
+	for _, e := range expected {
+		part, err := reader.NextPart()
+		if err != nil {
+			t.Fatalf("Message should have a %v part. Error: %v", e.ContentType, err)
+		}
+
+		if !strings.HasPrefix(part.Header.Get("Content-Type"), e.ContentType) {
+			t.Errorf("Part has incorrect Content-Type. Expected: %v, Got: %v", e.ContentType, part.Header.Get("Content-Type"))
+		}
+
+		encoded, _ := ioutil.ReadAll(part)
+		decoded, err := base64.StdEncoding.DecodeString(string(encoded))
+		if err != nil || string(decoded) != e.Body {
+			t.Errorf("Part has incorrect body. Expected: %v, Got: %v", e.Body, string(decoded))
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package libtime
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CronSchedule is a parsed 5-field cron expression: minute, hour, day of month, month and day of week.
+type CronSchedule struct {
+	minute     map[int]bool
+	hour       map[int]bool
+	dayOfMonth map[int]bool
+	month      map[int]bool
+	dayOfWeek  map[int]bool
+
+	// Cron treats day of month and day of week as OR when both are restricted.
+	dayOfMonthStar bool
+	dayOfWeekStar  bool
+}
+
+// ParseCron parses a 5-field cron expression.
+// Each field accepts *, single values, ranges (1-5), lists (1,3,5) and steps (*/15, 0-30/10).
+func ParseCron(expression string) (*CronSchedule, error) {
+	fields := strings.Fields(expression)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("Cron expression must have 5 fields. Expression: %v", expression)
+	}
+
+	schedule := &CronSchedule{}
+	var err error
+
+	schedule.minute, err = parseCronField(fields[0], 0, 59)
+	if err != nil {
+		return nil, err
+	}
+
+	schedule.hour, err = parseCronField(fields[1], 0, 23)
+	if err != nil {
+		return nil, err
+	}
+
+	schedule.dayOfMonth, err = parseCronField(fields[2], 1, 31)
+	if err != nil {
+		return nil, err
+	}
+
+	schedule.month, err = parseCronField(fields[3], 1, 12)
+	if err != nil {
+		return nil, err
+	}
+
+	schedule.dayOfWeek, err = parseCronField(fields[4], 0, 7)
+	if err != nil {
+		return nil, err
+	}
+
+	// Both 0 and 7 mean Sunday.
+	if schedule.dayOfWeek[7] {
+		schedule.dayOfWeek[0] = true
+	}
+
+	schedule.dayOfMonthStar = strings.HasPrefix(fields[2], "*")
+	schedule.dayOfWeekStar = strings.HasPrefix(fields[4], "*")
+
+	return schedule, nil
+}
+
+func parseCronField(field string, min, max int) (map[int]bool, error) {
+	result := make(map[int]bool)
+
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+
+		if strings.Contains(part, "/") {
+			stepParts := strings.SplitN(part, "/", 2)
+
+			var err error
+			step, err = strconv.Atoi(stepParts[1])
+			if err != nil || step <= 0 {
+				return nil, fmt.Errorf("Invalid cron step: %v", part)
+			}
+
+			part = stepParts[0]
+		}
+
+		low, high := min, max
+
+		if part != "*" {
+			rangeParts := strings.SplitN(part, "-", 2)
+
+			var err error
+			low, err = strconv.Atoi(rangeParts[0])
+			if err != nil {
+				return nil, fmt.Errorf("Invalid cron value: %v", part)
+			}
+
+			high = low
+			if len(rangeParts) == 2 {
+				high, err = strconv.Atoi(rangeParts[1])
+				if err != nil {
+					return nil, fmt.Errorf("Invalid cron range: %v", part)
+				}
+			} else if step > 1 {
+				high = max
+			}
+		}
+
+		if low < min || high > max || low > high {
+			return nil, fmt.Errorf("Cron value out of range [%v-%v]: %v", min, max, field)
+		}
+
+		for i := low; i <= high; i += step {
+			result[i] = true
+		}
+	}
+
+	return result, nil
+}
+
+func (schedule *CronSchedule) dayMatches(t time.Time) bool {
+	domMatch := schedule.dayOfMonth[t.Day()]
+	dowMatch := schedule.dayOfWeek[int(t.Weekday())]
+
+	if schedule.dayOfMonthStar || schedule.dayOfWeekStar {
+		return domMatch && dowMatch
+	}
+
+	return domMatch || dowMatch
+}
+
+// Matches checks if the minute of t is part of the schedule.
+func (schedule *CronSchedule) Matches(t time.Time) bool {
+	return schedule.minute[t.Minute()] &&
+		schedule.hour[t.Hour()] &&
+		schedule.month[int(t.Month())] &&
+		schedule.dayMatches(t)
+}
+
+// Next returns the first scheduled minute strictly after t.
+// Zero time is returned when nothing matches within 5 years.
+func (schedule *CronSchedule) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		if !schedule.month[int(t.Month())] {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+
+		if !schedule.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+
+		if !schedule.hour[t.Hour()] {
+			t = t.Truncate(time.Hour).Add(time.Hour)
+			continue
+		}
+
+		if !schedule.minute[t.Minute()] {
+			t = t.Add(time.Minute)
+			continue
+		}
+
+		return t
+	}
+
+	return time.Time{}
+}
+
+// LastStartWithin returns the latest scheduled minute in (t - lookback, t].
+// The boolean is false when no occurrence started within the lookback.
+func (schedule *CronSchedule) LastStartWithin(t time.Time, lookback time.Duration) (time.Time, bool) {
+	t = t.Truncate(time.Minute)
+
+	for elapsed := time.Duration(0); elapsed < lookback; elapsed += time.Minute {
+		candidate := t.Add(-elapsed)
+
+		if schedule.Matches(candidate) {
+			return candidate, true
+		}
+	}
+
+	return time.Time{}, false
+}
@@ -0,0 +1,62 @@
+package libtime
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCron(t *testing.T) {
+	for _, expression := range []string{"* * * * *", "*/15 2-4 1,15 * 1-5", "0 22 * * 7", "30 1 * 1-6/2 *"} {
+		_, err := ParseCron(expression)
+		if err != nil {
+			t.Errorf("Cron expression should be valid. Expression: %v, Error: %v", expression, err)
+		}
+	}
+
+	for _, expression := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "*/0 * * * *", "5-1 * * * *", "a * * * *"} {
+		_, err := ParseCron(expression)
+		if err == nil {
+			t.Errorf("Cron expression should be invalid. Expression: %v", expression)
+		}
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	// Every Saturday at 22:30
+	schedule, err := ParseCron("30 22 * * 6")
+	if err != nil {
+		t.Fatalf("Cron expression should be valid. Error: %v", err)
+	}
+
+	// 2016-01-01 is a Friday
+	from := time.Date(2016, 1, 1, 12, 0, 0, 0, time.UTC)
+	expected := time.Date(2016, 1, 2, 22, 30, 0, 0, time.UTC)
+
+	next := schedule.Next(from)
+	if !next.Equal(expected) {
+		t.Errorf("Next occurrence is incorrect. Expected: %v, Got: %v", expected, next)
+	}
+
+	next = schedule.Next(expected)
+	if !next.Equal(expected.AddDate(0, 0, 7)) {
+		t.Errorf("Next occurrence should be a week later. Got: %v", next)
+	}
+}
+
+func TestCronLastStartWithin(t *testing.T) {
+	// Every day at 02:00
+	schedule, err := ParseCron("0 2 * * *")
+	if err != nil {
+		t.Fatalf("Cron expression should be valid. Error: %v", err)
+	}
+
+	start, ok := schedule.LastStartWithin(time.Date(2016, 1, 1, 3, 15, 0, 0, time.UTC), 2*time.Hour)
+	if !ok || !start.Equal(time.Date(2016, 1, 1, 2, 0, 0, 0, time.UTC)) {
+		t.Errorf("Occurrence should have started at 02:00. Got: %v", start)
+	}
+
+	_, ok = schedule.LastStartWithin(time.Date(2016, 1, 1, 4, 15, 0, 0, time.UTC), 2*time.Hour)
+	if ok {
+		t.Errorf("Occurrence should have ended by 04:15")
+	}
+}
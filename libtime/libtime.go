@@ -2,6 +2,8 @@
 package libtime
 
 import (
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -16,6 +18,24 @@ func SleepString(definition string) error {
 	return nil
 }
 
+// ParseDurationWithDays is time.ParseDuration that also understands whole days, e.g. "30d", and weeks, e.g. "4w".
+func ParseDurationWithDays(definition string) (time.Duration, error) {
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if !strings.HasSuffix(definition, suffix) {
+			continue
+		}
+
+		count, err := strconv.Atoi(strings.TrimSuffix(definition, suffix))
+		if err != nil {
+			return 0, err
+		}
+
+		return time.Duration(count) * unit, nil
+	}
+
+	return time.ParseDuration(definition)
+}
+
 // IsLeapYear check if given year is a leap year.
 func IsLeapYear(y int) bool {
 	year := time.Date(y, time.December, 31, 0, 0, 0, 0, time.Local)
@@ -2,6 +2,7 @@ package libtime
 
 import (
 	"testing"
+	"time"
 )
 
 func TestSleepString(t *testing.T) {
@@ -9,3 +10,20 @@ func TestSleepString(t *testing.T) {
 		t.Errorf("Failed to sleep")
 	}
 }
+
+func TestParseDurationWithDays(t *testing.T) {
+	for definition, expected := range map[string]time.Duration{"30d": 30 * 24 * time.Hour, "4w": 28 * 24 * time.Hour, "90m": 90 * time.Minute} {
+		duration, err := ParseDurationWithDays(definition)
+		if err != nil {
+			t.Fatalf("Failed to parse %v. Error: %v", definition, err)
+		}
+		if duration != expected {
+			t.Errorf("%v should be %v. Got: %v", definition, expected, duration)
+		}
+	}
+
+	_, err := ParseDurationWithDays("xd")
+	if err == nil {
+		t.Errorf("Malformed days should fail to parse")
+	}
+}
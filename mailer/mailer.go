@@ -44,3 +44,10 @@ func (m *Mailer) Send(to, subject, body string) error {
 	message := libsmtp.BuildMessage(m.From, to, subject, body)
 	return smtp.SendMail(m.HostAndPort, m.Auth, m.From, []string{to}, []byte(message))
 }
+
+// SendMultipart sends email with both plain text and HTML bodies.
+func (m *Mailer) SendMultipart(to, subject, textBody, htmlBody string) error {
+	subject = m.SubjectPrefix + " " + subject
+	message := libsmtp.BuildMultipartMessage(m.From, to, subject, textBody, htmlBody)
+	return smtp.SendMail(m.HostAndPort, m.Auth, m.From, []string{to}, []byte(message))
+}
@@ -27,6 +27,11 @@ var (
 
 	appMigrateCassandra    = appMigrateArg.Command("cassandra", "Run Cassandra migrations.")
 	appMigrateCassandraCmd = appMigrateCassandra.Arg("command", "Cassandra migration commands. Valid choices: up or down").Required().String()
+
+	appApplyArg       = kingpin.Command("apply", "Make a cluster match checks, triggers, graphs and metrics declared in TOML or JSON files.")
+	appApplyPath      = appApplyArg.Flag("filename", "Path to a TOML or JSON file, or to a directory of them.").Short('f').Required().String()
+	appApplyClusterID = appApplyArg.Flag("cluster", "ID of the cluster to apply to.").Required().Int64()
+	appApplyDryRun    = appApplyArg.Flag("dry-run", "Print the plan without changing anything.").Bool()
 )
 
 func init() {
@@ -129,5 +134,11 @@ func main() {
 			logrus.Fatal(err)
 		}
 
+	case "apply":
+		err := app.Apply(*appApplyClusterID, *appApplyPath, *appApplyDryRun, os.Stdout)
+		if err != nil {
+			logrus.Fatal(err)
+		}
+
 	}
 }
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS check_notifications_queue CASCADE;
+DROP TABLE IF EXISTS check_notifications CASCADE;
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS maintenance_windows CASCADE;
+
+ALTER TABLE IF EXISTS checks DROP COLUMN IF EXISTS silenced_until;
+ALTER TABLE IF EXISTS checks DROP COLUMN IF EXISTS silence_reason;
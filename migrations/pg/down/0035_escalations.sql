@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS escalations CASCADE;
+ALTER TABLE IF EXISTS checks DROP COLUMN IF EXISTS escalation_policy_id;
+DROP TABLE IF EXISTS escalation_policies CASCADE;
+DROP TABLE IF EXISTS oncall_schedules CASCADE;
@@ -0,0 +1,32 @@
+-- Unfold trees created by the up migration back into flat lists joined by BooleanOperator entries.
+-- Trees that cannot be expressed as a flat list, e.g. with a not group, are left as is.
+CREATE OR REPLACE FUNCTION flatten_check_expression(expression jsonb) RETURNS jsonb AS $$
+DECLARE
+    flat jsonb;
+    child jsonb;
+    operator text;
+    first boolean := true;
+BEGIN
+    IF expression->>'Type' <> 'BooleanGroup' OR expression->>'Operator' NOT IN ('all', 'any') THEN
+        RETURN jsonb_build_array(expression);
+    END IF;
+
+    operator := CASE WHEN expression->>'Operator' = 'any' THEN 'or' ELSE 'and' END;
+
+    FOR child IN SELECT value FROM jsonb_array_elements(expression->'Expressions') LOOP
+        IF first THEN
+            flat := flatten_check_expression(child);
+            first := false;
+        ELSE
+            flat := flat || jsonb_build_array(jsonb_build_object('Type', 'BooleanOperator', 'Operator', operator), child);
+        END IF;
+    END LOOP;
+
+    RETURN COALESCE(flat, '[]'::jsonb);
+END;
+$$ language plpgsql;
+
+UPDATE checks SET expressions = flatten_check_expression(expressions->0)
+WHERE jsonb_typeof(expressions) = 'array' AND jsonb_array_length(expressions) = 1;
+
+DROP FUNCTION IF EXISTS flatten_check_expression(jsonb);
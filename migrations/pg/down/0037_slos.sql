@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS slo_notifications CASCADE;
+DROP TABLE IF EXISTS slo_budgets CASCADE;
+DROP TABLE IF EXISTS slos CASCADE;
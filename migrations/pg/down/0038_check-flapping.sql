@@ -0,0 +1,3 @@
+ALTER TABLE IF EXISTS checks DROP COLUMN IF EXISTS flap_percent;
+ALTER TABLE IF EXISTS checks DROP COLUMN IF EXISTS flapping_since;
+ALTER TABLE IF EXISTS checks DROP COLUMN IF EXISTS is_flapping;
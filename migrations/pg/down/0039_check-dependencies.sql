@@ -0,0 +1,3 @@
+ALTER TABLE IF EXISTS ts_checks DROP COLUMN IF EXISTS suppressed_by_parent_id;
+
+ALTER TABLE IF EXISTS checks DROP COLUMN IF EXISTS parent_ids;
@@ -0,0 +1,2 @@
+ALTER TABLE IF EXISTS check_notifications_queue DROP COLUMN IF EXISTS html_body;
+DROP TABLE IF EXISTS email_templates CASCADE;
@@ -0,0 +1,2 @@
+ALTER TABLE IF EXISTS check_notifications_queue DROP COLUMN IF EXISTS attempts;
+DROP TABLE IF EXISTS check_notification_deliveries CASCADE;
@@ -0,0 +1,8 @@
+DELETE FROM check_notifications WHERE hostname <> '';
+ALTER TABLE IF EXISTS check_notifications DROP CONSTRAINT IF EXISTS check_notifications_pkey;
+ALTER TABLE IF EXISTS check_notifications DROP COLUMN IF EXISTS hostname;
+ALTER TABLE IF EXISTS check_notifications ADD PRIMARY KEY (check_id, trigger_id);
+
+DROP TABLE IF EXISTS check_host_states CASCADE;
+
+ALTER TABLE IF EXISTS checks DROP COLUMN IF EXISTS per_host_alerting;
@@ -0,0 +1 @@
+ALTER TABLE IF EXISTS ts_events DROP COLUMN IF EXISTS tags;
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_check_notification_deliveries_slo_id_created;
+
+ALTER TABLE IF EXISTS check_notification_deliveries DROP COLUMN IF EXISTS slo_id;
+ALTER TABLE IF EXISTS check_notifications_queue DROP COLUMN IF EXISTS slo_id;
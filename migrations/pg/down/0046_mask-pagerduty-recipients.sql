@@ -0,0 +1 @@
+-- Masked service keys cannot be recovered.
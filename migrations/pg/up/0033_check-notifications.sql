@@ -0,0 +1,24 @@
+CREATE TABLE IF NOT EXISTS check_notifications (
+    cluster_id bigint,
+    check_id bigint REFERENCES checks (id) ON UPDATE CASCADE ON DELETE CASCADE,
+    trigger_id bigint NOT NULL,
+    last_notified TIMESTAMP WITHOUT TIME ZONE NOT NULL DEFAULT (NOW() at time zone 'utc'),
+    PRIMARY KEY (check_id, trigger_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_check_notifications_cluster_id on check_notifications (cluster_id);
+
+CREATE TABLE IF NOT EXISTS check_notifications_queue (
+    id BIGSERIAL PRIMARY KEY NOT NULL,
+    cluster_id bigint,
+    check_id bigint REFERENCES checks (id) ON UPDATE CASCADE ON DELETE CASCADE,
+    trigger_id bigint NOT NULL,
+    transport TEXT NOT NULL,
+    recipient TEXT NOT NULL,
+    subject TEXT NOT NULL DEFAULT '',
+    body TEXT NOT NULL DEFAULT '',
+    created TIMESTAMP WITHOUT TIME ZONE NOT NULL DEFAULT (NOW() at time zone 'utc'),
+    send_after TIMESTAMP WITHOUT TIME ZONE NOT NULL DEFAULT (NOW() at time zone 'utc')
+);
+
+CREATE INDEX IF NOT EXISTS idx_check_notifications_queue_cluster_id_send_after on check_notifications_queue (cluster_id, send_after);
@@ -0,0 +1,18 @@
+ALTER TABLE checks ADD COLUMN IF NOT EXISTS silenced_until TIMESTAMP WITHOUT TIME ZONE;
+ALTER TABLE checks ADD COLUMN IF NOT EXISTS silence_reason TEXT NOT NULL DEFAULT '';
+
+CREATE TABLE IF NOT EXISTS maintenance_windows (
+    id BIGSERIAL PRIMARY KEY NOT NULL,
+    cluster_id bigint REFERENCES clusters (id) ON UPDATE CASCADE ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    scope TEXT NOT NULL DEFAULT 'cluster',
+    check_id bigint REFERENCES checks (id) ON UPDATE CASCADE ON DELETE CASCADE,
+    hosts_query TEXT NOT NULL DEFAULT '',
+    starts_at TIMESTAMP WITHOUT TIME ZONE NOT NULL DEFAULT (NOW() at time zone 'utc'),
+    ends_at TIMESTAMP WITHOUT TIME ZONE NOT NULL,
+    cron TEXT NOT NULL DEFAULT '',
+    duration_minutes bigint NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS idx_maintenance_windows_cluster_id_ends_at on maintenance_windows (cluster_id, ends_at);
@@ -0,0 +1,38 @@
+CREATE TABLE IF NOT EXISTS oncall_schedules (
+    id BIGSERIAL PRIMARY KEY NOT NULL,
+    cluster_id bigint REFERENCES clusters (id) ON UPDATE CASCADE ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    rotation_start TIMESTAMP WITHOUT TIME ZONE NOT NULL DEFAULT (NOW() at time zone 'utc'),
+    handoff_interval_minutes bigint NOT NULL DEFAULT 10080,
+    members jsonb NOT NULL DEFAULT '[]',
+    overrides jsonb NOT NULL DEFAULT '[]'
+);
+
+CREATE INDEX IF NOT EXISTS idx_oncall_schedules_cluster_id on oncall_schedules (cluster_id);
+
+CREATE TABLE IF NOT EXISTS escalation_policies (
+    id BIGSERIAL PRIMARY KEY NOT NULL,
+    cluster_id bigint REFERENCES clusters (id) ON UPDATE CASCADE ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    steps jsonb NOT NULL DEFAULT '[]'
+);
+
+CREATE INDEX IF NOT EXISTS idx_escalation_policies_cluster_id on escalation_policies (cluster_id);
+
+ALTER TABLE checks ADD COLUMN IF NOT EXISTS escalation_policy_id bigint REFERENCES escalation_policies (id) ON UPDATE CASCADE ON DELETE SET NULL;
+
+CREATE TABLE IF NOT EXISTS escalations (
+    id BIGSERIAL PRIMARY KEY NOT NULL,
+    cluster_id bigint,
+    check_id bigint REFERENCES checks (id) ON UPDATE CASCADE ON DELETE CASCADE,
+    escalation_policy_id bigint REFERENCES escalation_policies (id) ON UPDATE CASCADE ON DELETE CASCADE,
+    step bigint NOT NULL DEFAULT 0,
+    created TIMESTAMP WITHOUT TIME ZONE NOT NULL DEFAULT (NOW() at time zone 'utc'),
+    last_escalated TIMESTAMP WITHOUT TIME ZONE NOT NULL DEFAULT (NOW() at time zone 'utc'),
+    acknowledged_at TIMESTAMP WITHOUT TIME ZONE,
+    acknowledged_by TEXT NOT NULL DEFAULT '',
+    resolved_at TIMESTAMP WITHOUT TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_escalations_cluster_id_resolved_at on escalations (cluster_id, resolved_at);
+CREATE INDEX IF NOT EXISTS idx_escalations_check_id on escalations (check_id);
@@ -0,0 +1,50 @@
+-- Flat expression lists, e.g. [A, or, B, and, C], were folded left to right.
+-- Nest them into a single BooleanGroup tree, ((A or B) and C), so they keep their meaning.
+CREATE OR REPLACE FUNCTION nest_flat_check_expressions(expressions jsonb) RETURNS jsonb AS $$
+DECLARE
+    expression jsonb;
+    root jsonb;
+    operator text := '';
+    group_operator text;
+    nested boolean := false;
+BEGIN
+    IF expressions IS NULL OR jsonb_typeof(expressions) <> 'array' THEN
+        RETURN expressions;
+    END IF;
+
+    IF NOT EXISTS(SELECT 1 FROM jsonb_array_elements(expressions) e WHERE e->>'Type' = 'BooleanOperator') THEN
+        RETURN expressions;
+    END IF;
+
+    FOR expression IN SELECT value FROM jsonb_array_elements(expressions) LOOP
+        IF expression->>'Type' = 'BooleanOperator' THEN
+            operator := expression->>'Operator';
+            CONTINUE;
+        END IF;
+
+        IF root IS NULL THEN
+            root := expression;
+            CONTINUE;
+        END IF;
+
+        group_operator := CASE WHEN operator = 'or' THEN 'any' ELSE 'all' END;
+
+        IF nested AND root->>'Operator' = group_operator THEN
+            root := jsonb_set(root, '{Expressions}', (root->'Expressions') || jsonb_build_array(expression));
+        ELSE
+            root := jsonb_build_object('Type', 'BooleanGroup', 'Operator', group_operator, 'Expressions', jsonb_build_array(root, expression));
+            nested := true;
+        END IF;
+    END LOOP;
+
+    IF root IS NULL THEN
+        RETURN '[]'::jsonb;
+    END IF;
+
+    RETURN jsonb_build_array(root);
+END;
+$$ language plpgsql;
+
+UPDATE checks SET expressions = nest_flat_check_expressions(expressions);
+
+DROP FUNCTION IF EXISTS nest_flat_check_expressions(jsonb);
@@ -0,0 +1,33 @@
+CREATE TABLE IF NOT EXISTS slos (
+    id BIGSERIAL PRIMARY KEY NOT NULL,
+    cluster_id bigint REFERENCES clusters (id) ON UPDATE CASCADE ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    target_percent double precision NOT NULL DEFAULT 99.9,
+    time_window TEXT NOT NULL DEFAULT '30d',
+    source_type TEXT NOT NULL,
+    source jsonb NOT NULL DEFAULT '{}',
+    burn_rate_alerts jsonb NOT NULL DEFAULT '[]'
+);
+
+CREATE INDEX IF NOT EXISTS idx_slos_cluster_id on slos (cluster_id);
+
+CREATE TABLE IF NOT EXISTS slo_budgets (
+    cluster_id bigint,
+    slo_id bigint REFERENCES slos (id) ON UPDATE CASCADE ON DELETE CASCADE,
+    created TIMESTAMP WITHOUT TIME ZONE NOT NULL DEFAULT (NOW() at time zone 'utc'),
+    good double precision NOT NULL DEFAULT 0,
+    total double precision NOT NULL DEFAULT 0,
+    sli_percent double precision NOT NULL DEFAULT 100,
+    budget_remaining_percent double precision NOT NULL DEFAULT 100
+);
+
+CREATE INDEX IF NOT EXISTS idx_slo_budgets_slo_id_created on slo_budgets (slo_id, created);
+
+CREATE TABLE IF NOT EXISTS slo_notifications (
+    cluster_id bigint,
+    slo_id bigint REFERENCES slos (id) ON UPDATE CASCADE ON DELETE CASCADE,
+    alert_id bigint NOT NULL,
+    firing boolean NOT NULL DEFAULT false,
+    last_notified TIMESTAMP WITHOUT TIME ZONE NOT NULL DEFAULT (NOW() at time zone 'utc'),
+    PRIMARY KEY (slo_id, alert_id)
+);
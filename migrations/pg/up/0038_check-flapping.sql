@@ -0,0 +1,3 @@
+ALTER TABLE checks ADD COLUMN IF NOT EXISTS is_flapping boolean NOT NULL DEFAULT false;
+ALTER TABLE checks ADD COLUMN IF NOT EXISTS flapping_since TIMESTAMP WITHOUT TIME ZONE;
+ALTER TABLE checks ADD COLUMN IF NOT EXISTS flap_percent double precision NOT NULL DEFAULT 0;
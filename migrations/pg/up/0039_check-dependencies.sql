@@ -0,0 +1,3 @@
+ALTER TABLE checks ADD COLUMN IF NOT EXISTS parent_ids jsonb NOT NULL DEFAULT '[]';
+
+ALTER TABLE ts_checks ADD COLUMN IF NOT EXISTS suppressed_by_parent_id bigint;
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS email_templates (
+    cluster_id bigint PRIMARY KEY NOT NULL,
+    subject TEXT NOT NULL DEFAULT '',
+    text_body TEXT NOT NULL DEFAULT '',
+    html_body TEXT NOT NULL DEFAULT '',
+    updated TIMESTAMP WITHOUT TIME ZONE NOT NULL DEFAULT (NOW() at time zone 'utc')
+);
+
+ALTER TABLE check_notifications_queue ADD COLUMN IF NOT EXISTS html_body TEXT NOT NULL DEFAULT '';
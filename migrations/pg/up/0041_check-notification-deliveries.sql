@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS check_notification_deliveries (
+    id BIGSERIAL PRIMARY KEY NOT NULL,
+    cluster_id bigint,
+    check_id bigint REFERENCES checks (id) ON UPDATE CASCADE ON DELETE CASCADE,
+    trigger_id bigint NOT NULL,
+    transport TEXT NOT NULL,
+    recipient TEXT NOT NULL DEFAULT '',
+    payload_hash TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL,
+    error TEXT NOT NULL DEFAULT '',
+    attempt integer NOT NULL DEFAULT 1,
+    created TIMESTAMP WITHOUT TIME ZONE NOT NULL DEFAULT (NOW() at time zone 'utc')
+);
+
+CREATE INDEX IF NOT EXISTS idx_check_notification_deliveries_check_id_created on check_notification_deliveries (check_id, created);
+CREATE INDEX IF NOT EXISTS idx_check_notification_deliveries_cluster_id_created on check_notification_deliveries (cluster_id, created);
+
+ALTER TABLE check_notifications_queue ADD COLUMN IF NOT EXISTS attempts integer NOT NULL DEFAULT 0;
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS heartbeats (
+    id BIGSERIAL PRIMARY KEY NOT NULL,
+    cluster_id bigint REFERENCES clusters (id) ON UPDATE CASCADE ON DELETE CASCADE,
+    check_id bigint REFERENCES checks (id) ON UPDATE CASCADE ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    token TEXT NOT NULL,
+    period TEXT NOT NULL DEFAULT '24h',
+    grace TEXT NOT NULL DEFAULT '1h',
+    created TIMESTAMP WITHOUT TIME ZONE NOT NULL DEFAULT (NOW() at time zone 'utc'),
+    last_ping_at TIMESTAMP WITHOUT TIME ZONE,
+    last_duration_seconds double precision,
+    last_exit_status integer
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_heartbeats_token on heartbeats (token);
+CREATE INDEX IF NOT EXISTS idx_heartbeats_cluster_id on heartbeats (cluster_id);
@@ -0,0 +1,18 @@
+ALTER TABLE checks ADD COLUMN IF NOT EXISTS per_host_alerting boolean NOT NULL DEFAULT false;
+
+CREATE TABLE IF NOT EXISTS check_host_states (
+    cluster_id bigint,
+    check_id bigint REFERENCES checks (id) ON UPDATE CASCADE ON DELETE CASCADE,
+    hostname TEXT NOT NULL,
+    is_failing boolean NOT NULL DEFAULT false,
+    failing_since TIMESTAMP WITHOUT TIME ZONE,
+    recovered_at TIMESTAMP WITHOUT TIME ZONE,
+    updated TIMESTAMP WITHOUT TIME ZONE NOT NULL DEFAULT (NOW() at time zone 'utc'),
+    PRIMARY KEY (check_id, hostname)
+);
+
+CREATE INDEX IF NOT EXISTS idx_check_host_states_cluster_id on check_host_states (cluster_id);
+
+ALTER TABLE check_notifications ADD COLUMN IF NOT EXISTS hostname TEXT NOT NULL DEFAULT '';
+ALTER TABLE check_notifications DROP CONSTRAINT IF EXISTS check_notifications_pkey;
+ALTER TABLE check_notifications ADD PRIMARY KEY (check_id, trigger_id, hostname);
@@ -0,0 +1 @@
+ALTER TABLE ts_events ADD COLUMN IF NOT EXISTS tags jsonb NOT NULL DEFAULT '[]';
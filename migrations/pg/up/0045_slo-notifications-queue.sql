@@ -0,0 +1,4 @@
+ALTER TABLE check_notifications_queue ADD COLUMN IF NOT EXISTS slo_id bigint REFERENCES slos (id) ON UPDATE CASCADE ON DELETE CASCADE;
+ALTER TABLE check_notification_deliveries ADD COLUMN IF NOT EXISTS slo_id bigint REFERENCES slos (id) ON UPDATE CASCADE ON DELETE CASCADE;
+
+CREATE INDEX IF NOT EXISTS idx_check_notification_deliveries_slo_id_created on check_notification_deliveries (slo_id, created);
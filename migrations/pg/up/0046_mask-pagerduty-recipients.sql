@@ -0,0 +1,2 @@
+UPDATE check_notification_deliveries SET recipient = '****' || right(recipient, 4) WHERE transport = 'pagerduty' AND recipient NOT LIKE '****%';
+UPDATE check_notifications_queue SET recipient = '****' || right(recipient, 4) WHERE transport = 'pagerduty' AND recipient NOT LIKE '****%';
@@ -22,11 +22,12 @@ func NewTSEvent(ctx context.Context) *TSEvent {
 }
 
 type TSEventRow struct {
-	ID          int64  `db:"id"`
-	ClusterID   int64  `db:"cluster_id"`
-	CreatedFrom int64  `db:"created_from"`
-	CreatedTo   int64  `db:"created_to"`
-	Description string `db:"description"`
+	ID          int64    `db:"id"`
+	ClusterID   int64    `db:"cluster_id"`
+	CreatedFrom int64    `db:"created_from"`
+	CreatedTo   int64    `db:"created_to"`
+	Description string   `db:"description"`
+	Tags        []string `db:"tags"`
 }
 
 type TSEvent struct {
@@ -151,6 +152,46 @@ func (ts *TSEvent) AllBandsByClusterIDAndCreatedFromRangeForHighchart(clusterID,
 	return hcRows, err
 }
 
+// AllByClusterIDAndCreatedFromRange returns all rows given created_from range.
+func (ts *TSEvent) AllByClusterIDAndCreatedFromRange(clusterID, from, to int64) ([]shared.TSEventPayload, error) {
+	session, err := ts.GetCassandraSession()
+	if err != nil {
+		return nil, err
+	}
+
+	events := []shared.TSEventPayload{}
+	query := fmt.Sprintf(`SELECT id, created_from, created_to, description, tags FROM %v WHERE cluster_id=? AND created_from >= ? AND created_from <= ?`, ts.table)
+
+	var scannedID, scannedCreatedFrom, scannedCreatedTo int64
+	var scannedDescription string
+	var scannedTags []string
+
+	iter := session.Query(query, clusterID, from, to).Iter()
+	for iter.Scan(&scannedID, &scannedCreatedFrom, &scannedCreatedTo, &scannedDescription, &scannedTags) {
+		events = append(events, shared.TSEventPayload{
+			ID:          scannedID,
+			CreatedFrom: scannedCreatedFrom,
+			CreatedTo:   scannedCreatedTo,
+			Description: scannedDescription,
+			Tags:        scannedTags,
+		})
+		scannedTags = nil
+	}
+	if err := iter.Close(); err != nil {
+		err = fmt.Errorf("%v. Query: %v", err.Error(), query)
+		logrus.WithFields(logrus.Fields{
+			"Method":    "TSEvent.AllByClusterIDAndCreatedFromRange",
+			"ClusterID": clusterID,
+			"From":      from,
+			"To":        to,
+		}).Error(err)
+
+		return nil, err
+	}
+
+	return events, nil
+}
+
 // GetByClusterIDAndID returns record by cluster_id and id.
 func (ts *TSEvent) GetByClusterIDAndID(clusterID, id int64) (*TSEventRow, error) {
 	session, err := ts.GetCassandraSession()
@@ -245,12 +286,13 @@ func (ts *TSEvent) CreateFromJSON(id, clusterID int64, jsonData []byte, ttl time
 	}
 
 	err = session.Query(
-		fmt.Sprintf(`INSERT INTO %v (id, cluster_id, created_from, created_to, description) VALUES (?, ?, ?, ?, ?) USING TTL ?`, ts.table),
+		fmt.Sprintf(`INSERT INTO %v (id, cluster_id, created_from, created_to, description, tags) VALUES (?, ?, ?, ?, ?, ?) USING TTL ?`, ts.table),
 		id,
 		clusterID,
 		payload.From,
 		payload.To,
 		payload.Description,
+		payload.Tags,
 		ttl,
 	).Exec()
 
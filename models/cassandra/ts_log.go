@@ -312,3 +312,37 @@ func (ts *TSLog) CountByClusterIDFromTimestampHostAndQuery(clusterID int64, from
 
 	return count, err
 }
+
+// CountByClusterIDRangeAndQuery returns count of logs across all hosts, created between from and to, that match resourced query.
+func (ts *TSLog) CountByClusterIDRangeAndQuery(clusterID, from, to int64, resourcedQuery string) (int64, error) {
+	session, err := ts.GetCassandraSession()
+	if err != nil {
+		return -1, err
+	}
+
+	luceneQuery := querybuilder.Parse(resourcedQuery, []string{"master_tags"})
+	if luceneQuery == "" {
+		return -1, errors.New("Query is unparsable")
+	}
+
+	var count int64
+
+	query := fmt.Sprintf(`SELECT count(logline) FROM %v WHERE expr(idx_ts_logs_lucene, '{
+    filter: {
+        type: "boolean",
+        must: [
+            {type: "match", field: "cluster_id", value: %v},
+            {type:"range", field:"created", lower:%v, upper:%v, include_lower: true, include_upper: false}
+        ]
+    },
+    query: %v
+}')`, ts.table, clusterID, from, to, luceneQuery)
+
+	err = session.Query(query).Scan(&count)
+	if err != nil {
+		err = fmt.Errorf("%v. Query: %v, ClusterID: %v, From: %v, To: %v", err.Error(), query, clusterID, from, to)
+		return -1, err
+	}
+
+	return count, err
+}
@@ -182,6 +182,25 @@ func (ts *TSMetric) AllByMetricIDAndRange(clusterID, metricID int64, from, to in
 	return rows, nil
 }
 
+// SumByMetricIDAndRange adds up the values of a metric, across all hosts, created between from and to.
+func (ts *TSMetric) SumByMetricIDAndRange(clusterID, metricID, from, to int64) (float64, error) {
+	rows, err := ts.AllByMetricIDAndRange(clusterID, metricID, from, to)
+	if err != nil {
+		return -1, err
+	}
+
+	var sum float64
+
+	for _, row := range rows {
+		// to is excluded, the same way the pg query excludes it.
+		if row.Created < to {
+			sum = sum + row.Value
+		}
+	}
+
+	return sum, nil
+}
+
 func (ts *TSMetric) AllByMetricIDAndRangeForHighchart(clusterID, metricID, from, to int64) ([]*shared.TSMetricHighchartPayload, error) {
 	tsMetricRows, err := ts.AllByMetricIDAndRange(clusterID, metricID, from, to)
 	if err != nil {
@@ -258,3 +277,50 @@ func (ts *TSMetric) GetAggregateXMinutesByMetricIDAndHostname(clusterID, metricI
 
 	return row, nil
 }
+
+// GetAggregateXMinutesByMetricIDAndHostnames rolls up a metric across hostnames over the last X minutes.
+// Cassandra has no percentile function, so the rollup happens here.
+func (ts *TSMetric) GetAggregateXMinutesByMetricIDAndHostnames(clusterID, metricID int64, minutes int, hostnames []string, percentile float64) (*shared.TSMetricAggregateRow, error) {
+	session, err := ts.GetCassandraSession()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	from := now.Add(-1 * time.Duration(minutes) * time.Minute).UTC().Unix()
+
+	wantedHosts := make(map[string]bool)
+	for _, hostname := range hostnames {
+		wantedHosts[hostname] = true
+	}
+
+	query := fmt.Sprintf("SELECT host, value FROM %v WHERE cluster_id=? AND metric_id=? AND created >= ?", ts.table)
+
+	values := make([]float64, 0)
+
+	var scannedHost string
+	var scannedValue float64
+
+	iter := session.Query(query, clusterID, metricID, from).Iter()
+	for iter.Scan(&scannedHost, &scannedValue) {
+		if wantedHosts[scannedHost] {
+			values = append(values, scannedValue)
+		}
+	}
+	if err := iter.Close(); err != nil {
+		err = fmt.Errorf("%v. Query: %v", err.Error(), query)
+		logrus.WithFields(logrus.Fields{
+			"Method":    "TSMetric.GetAggregateXMinutesByMetricIDAndHostnames",
+			"ClusterID": clusterID,
+			"MetricID":  metricID,
+			"From":      from,
+		}).Error(err)
+
+		return nil, err
+	}
+
+	row := shared.AggregateValues(values, percentile)
+	row.ClusterID = clusterID
+
+	return row, nil
+}
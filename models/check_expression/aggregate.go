@@ -0,0 +1,102 @@
+package check_expression
+
+import (
+	"fmt"
+
+	"github.com/resourced/resourced-master/models/pg"
+	"github.com/resourced/resourced-master/models/shared"
+	"github.com/resourced/resourced-master/models/shims"
+)
+
+// MetricAggregateValue picks the aggregate named by PrevAggr out of row.
+func MetricAggregateValue(row *shared.TSMetricAggregateRow, expression pg.CheckExpression) (float64, error) {
+	switch expression.PrevAggr {
+	case "avg", "":
+		return row.Avg, nil
+	case "max":
+		return row.Max, nil
+	case "min":
+		return row.Min, nil
+	case "sum":
+		return row.Sum, nil
+	case "percentile":
+		return row.Percentile, nil
+	}
+
+	return 0, fmt.Errorf("Unrecognized aggregate: %v", expression.PrevAggr)
+}
+
+// EvalMetricAggregateExpression compares one aggregate of Metric across all hosts over the last PrevRange minutes against Value.
+// Unlike per-host expressions, MinHost does not apply: the whole set of hosts passes or fails together.
+func (evaluator *CheckExpressionEvaluator) EvalMetricAggregateExpression(checkRow *pg.CheckRow, hostRows []*pg.HostRow, expression pg.CheckExpression) pg.CheckExpression {
+	if hostRows == nil || len(hostRows) <= 0 {
+		expression.Result.Value = true
+		expression.Result.Message = "There are no hosts to check"
+		return expression
+	}
+
+	hostnames := make([]string, len(hostRows))
+	for i, hostRow := range hostRows {
+		hostnames[i] = hostRow.Hostname
+	}
+
+	metric, err := pg.NewMetric(evaluator.AppContext).GetByClusterIDAndKey(nil, checkRow.ClusterID, expression.Metric)
+	if err != nil {
+		expression.Result.Value = true
+		expression.Result.Message = fmt.Sprintf("Unable to find metric %v: %v", expression.Metric, err)
+		expression.Result.BadHostnames = hostnames
+		return expression
+	}
+
+	aggregateData, err := shims.NewTSMetric(evaluator.AppContext, checkRow.ClusterID).GetAggregateXMinutesByMetricIDAndHostnames(checkRow.ClusterID, metric.ID, expression.PrevRange, hostnames, expression.Percentile)
+	if err != nil {
+		expression.Result.Value = true
+		expression.Result.Message = err.Error()
+		expression.Result.BadHostnames = hostnames
+		return expression
+	}
+
+	return evaluator.compareMetricAggregate(hostnames, aggregateData, expression)
+}
+
+func (evaluator *CheckExpressionEvaluator) compareMetricAggregate(hostnames []string, aggregateData *shared.TSMetricAggregateRow, expression pg.CheckExpression) pg.CheckExpression {
+	// No data points at all is treated as a failure, the same way per-host expressions treat missing history.
+	if aggregateData.Count <= 0 {
+		expression.Result.Value = true
+		expression.Result.Message = fmt.Sprintf("There is no data for %v in the last %v minutes", expression.Metric, expression.PrevRange)
+		expression.Result.BadHostnames = hostnames
+		return expression
+	}
+
+	val, err := MetricAggregateValue(aggregateData, expression)
+	if err != nil {
+		expression.Result.Value = true
+		expression.Result.Message = err.Error()
+		expression.Result.BadHostnames = hostnames
+		return expression
+	}
+
+	if expression.Operator == ">" {
+		expression.Result.Value = val > expression.Value
+
+	} else if expression.Operator == "<" {
+		expression.Result.Value = val < expression.Value
+	}
+
+	aggregateName := expression.PrevAggr
+	if aggregateName == "percentile" {
+		aggregateName = fmt.Sprintf("p%v", expression.Percentile)
+	}
+
+	expression.Result.Message = fmt.Sprintf("%v of %v across %v hosts over %v minutes is %v", aggregateName, expression.Metric, len(hostnames), expression.PrevRange, val)
+
+	if expression.Result.Value {
+		expression.Result.BadHostnames = hostnames
+		expression.Result.GoodHostnames = make([]string, 0)
+	} else {
+		expression.Result.BadHostnames = make([]string, 0)
+		expression.Result.GoodHostnames = hostnames
+	}
+
+	return expression
+}
@@ -0,0 +1,56 @@
+package check_expression
+
+import (
+	"testing"
+
+	"github.com/resourced/resourced-master/models/pg"
+	"github.com/resourced/resourced-master/models/shared"
+)
+
+func TestCompareMetricAggregate(t *testing.T) {
+	evaluator := CheckExpressionEvaluator{}
+	hostnames := []string{"web-1", "web-2"}
+
+	aggregateData := shared.AggregateValues([]float64{70, 90, 95, 85}, 95)
+
+	expression := pg.CheckExpression{}
+	expression.Type = "MetricAggregate"
+	expression.Metric = "/cpu.Usage"
+	expression.Operator = ">"
+	expression.Value = 80
+	expression.PrevRange = 10
+	expression.PrevAggr = "avg"
+
+	result := evaluator.compareMetricAggregate(hostnames, aggregateData, expression)
+	if result.Result.Value != true {
+		t.Errorf("Average of 85 should be greater than 80. Message: %v", result.Result.Message)
+	}
+	if len(result.Result.BadHostnames) != 2 {
+		t.Errorf("Every host should be bad when the aggregate fails. BadHostnames: %v", result.Result.BadHostnames)
+	}
+
+	expression.PrevAggr = "min"
+
+	result = evaluator.compareMetricAggregate(hostnames, aggregateData, expression)
+	if result.Result.Value != false {
+		t.Errorf("Minimum of 70 should not be greater than 80")
+	}
+
+	expression.PrevAggr = "percentile"
+	expression.Percentile = 95
+	expression.Value = 94
+
+	result = evaluator.compareMetricAggregate(hostnames, aggregateData, expression)
+	if result.Result.Value != true {
+		t.Errorf("p95 should be greater than 94. Message: %v", result.Result.Message)
+	}
+
+	expression.PrevAggr = "sum"
+	expression.Operator = "<"
+	expression.Value = 10000
+
+	result = evaluator.compareMetricAggregate(hostnames, shared.AggregateValues(nil, 0), expression)
+	if result.Result.Value != true {
+		t.Errorf("Missing data should fail the expression")
+	}
+}
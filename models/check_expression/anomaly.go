@@ -0,0 +1,316 @@
+package check_expression
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/resourced/resourced-master/models/pg"
+	"github.com/resourced/resourced-master/models/shims"
+)
+
+const (
+	defaultAnomalySensitivity = 3
+
+	// holtWintersBucket is the spacing of the evenly sampled series Holt-Winters needs.
+	holtWintersBucket = 5 * time.Minute
+
+	holtWintersAlpha = 0.5
+	holtWintersBeta  = 0.1
+	holtWintersGamma = 0.3
+)
+
+// AnomalyBand is the range a value is expected to fall in.
+type AnomalyBand struct {
+	Lower float64
+	Upper float64
+}
+
+func (band AnomalyBand) Contains(value float64) bool {
+	return value >= band.Lower && value <= band.Upper
+}
+
+func (band AnomalyBand) String() string {
+	return fmt.Sprintf("[%.2f, %.2f]", band.Lower, band.Upper)
+}
+
+func meanAndStdDev(values []float64) (float64, float64) {
+	var sum float64
+	for _, value := range values {
+		sum = sum + value
+	}
+	mean := sum / float64(len(values))
+
+	var squares float64
+	for _, value := range values {
+		squares = squares + (value-mean)*(value-mean)
+	}
+
+	return mean, math.Sqrt(squares / float64(len(values)))
+}
+
+// ZScoreBand is mean ± sensitivity standard deviations of values.
+func ZScoreBand(values []float64, sensitivity float64) (AnomalyBand, error) {
+	if len(values) < 2 {
+		return AnomalyBand{}, errors.New("Not enough history to build a baseline")
+	}
+
+	mean, stdDev := meanAndStdDev(values)
+
+	return AnomalyBand{Lower: mean - sensitivity*stdDev, Upper: mean + sensitivity*stdDev}, nil
+}
+
+// HoltWintersBand forecasts the value following values with additive Holt-Winters,
+// and widens the forecast by sensitivity standard deviations of the one-step-ahead errors.
+// values must be evenly spaced and cover at least two seasons.
+func HoltWintersBand(values []float64, seasonLength int, sensitivity float64) (AnomalyBand, error) {
+	if seasonLength < 2 || len(values) < 2*seasonLength {
+		return AnomalyBand{}, errors.New("Holt-Winters needs at least two seasons of history")
+	}
+
+	var firstSeason, secondSeason float64
+	for i := 0; i < seasonLength; i++ {
+		firstSeason = firstSeason + values[i]
+		secondSeason = secondSeason + values[i+seasonLength]
+	}
+	firstSeason = firstSeason / float64(seasonLength)
+	secondSeason = secondSeason / float64(seasonLength)
+
+	level := firstSeason
+	trend := (secondSeason - firstSeason) / float64(seasonLength)
+
+	seasonals := make([]float64, seasonLength)
+	for i := 0; i < seasonLength; i++ {
+		seasonals[i] = values[i] - firstSeason
+	}
+
+	residuals := make([]float64, 0, len(values))
+
+	for t, value := range values {
+		seasonal := seasonals[t%seasonLength]
+
+		if t >= seasonLength {
+			residuals = append(residuals, value-(level+trend+seasonal))
+		}
+
+		newLevel := holtWintersAlpha*(value-seasonal) + (1-holtWintersAlpha)*(level+trend)
+		trend = holtWintersBeta*(newLevel-level) + (1-holtWintersBeta)*trend
+		seasonals[t%seasonLength] = holtWintersGamma*(value-newLevel) + (1-holtWintersGamma)*seasonal
+		level = newLevel
+	}
+
+	forecast := level + trend + seasonals[len(values)%seasonLength]
+	_, stdDev := meanAndStdDev(residuals)
+
+	return AnomalyBand{Lower: forecast - sensitivity*stdDev, Upper: forecast + sensitivity*stdDev}, nil
+}
+
+// bucketize averages highchart points, [unix milliseconds, value], into evenly spaced buckets.
+// Empty buckets carry the previous value forward.
+func bucketize(points [][]interface{}, from time.Time, bucket time.Duration, count int) []float64 {
+	sums := make([]float64, count)
+	counts := make([]int, count)
+
+	for _, point := range points {
+		if len(point) < 2 {
+			continue
+		}
+
+		millis, ok := point[0].(int64)
+		if !ok {
+			continue
+		}
+		value, ok := point[1].(float64)
+		if !ok {
+			continue
+		}
+
+		index := int(time.Unix(0, millis*int64(time.Millisecond)).Sub(from) / bucket)
+		if index < 0 || index >= count {
+			continue
+		}
+
+		sums[index] = sums[index] + value
+		counts[index] = counts[index] + 1
+	}
+
+	buckets := make([]float64, 0, count)
+	var last float64
+	seen := false
+
+	for i := 0; i < count; i++ {
+		if counts[i] > 0 {
+			last = sums[i] / float64(counts[i])
+			seen = true
+		}
+		if seen {
+			buckets = append(buckets, last)
+		}
+	}
+
+	return buckets
+}
+
+func pointValues(points [][]interface{}) []float64 {
+	values := make([]float64, 0, len(points))
+
+	for _, point := range points {
+		if len(point) < 2 {
+			continue
+		}
+		if value, ok := point[1].(float64); ok {
+			values = append(values, value)
+		}
+	}
+
+	return values
+}
+
+// hostMetricValue returns the current value of metric, e.g. "/free.Memory.Free", reported by hostRow.
+func hostMetricValue(hostRow *pg.HostRow, metric string) (float64, bool) {
+	for prefix, keyAndValue := range hostRow.DataAsFlatKeyValue() {
+		if !strings.HasPrefix(metric, prefix+".") {
+			continue
+		}
+
+		value, ok := keyAndValue[strings.TrimPrefix(metric, prefix+".")].(float64)
+		if ok {
+			return value, true
+		}
+	}
+
+	return 0, false
+}
+
+// anomalyBand builds the expected band of a host from its history, according to AnomalyMethod.
+func (evaluator *CheckExpressionEvaluator) anomalyBand(tsMetric *shims.TSMetric, clusterID, metricID int64, hostname string, deletedFrom int64, expression pg.CheckExpression, now time.Time) (AnomalyBand, error) {
+	sensitivity := expression.Sensitivity
+	if sensitivity <= 0 {
+		sensitivity = defaultAnomalySensitivity
+	}
+
+	switch expression.AnomalyMethod {
+	case "zscore", "":
+		prevRange := time.Duration(expression.PrevRange) * time.Minute
+		if prevRange <= 0 {
+			prevRange = time.Hour
+		}
+
+		payload, err := tsMetric.AllByMetricIDHostAndRangeForHighchart(clusterID, metricID, hostname, now.Add(-prevRange).Unix(), now.Unix(), deletedFrom, 0)
+		if err != nil {
+			return AnomalyBand{}, err
+		}
+		return ZScoreBand(pointValues(payload.Data), sensitivity)
+
+	case "lastweek":
+		prevRange := time.Duration(expression.PrevRange) * time.Minute
+		if prevRange <= 0 {
+			prevRange = 30 * time.Minute
+		}
+
+		// Centered on the same time last week.
+		lastWeek := now.Add(-7 * 24 * time.Hour)
+
+		payload, err := tsMetric.AllByMetricIDHostAndRangeForHighchart(clusterID, metricID, hostname, lastWeek.Add(-prevRange/2).Unix(), lastWeek.Add(prevRange/2).Unix(), deletedFrom, 0)
+		if err != nil {
+			return AnomalyBand{}, err
+		}
+		return ZScoreBand(pointValues(payload.Data), sensitivity)
+
+	case "holtwinters":
+		season := time.Duration(expression.SeasonalityMinutes) * time.Minute
+		if season <= 0 {
+			season = 24 * time.Hour
+		}
+
+		prevRange := time.Duration(expression.PrevRange) * time.Minute
+		if prevRange < 2*season {
+			prevRange = 3 * season
+		}
+
+		from := now.Add(-prevRange).Truncate(holtWintersBucket)
+
+		payload, err := tsMetric.AllByMetricIDHostAndRangeForHighchart(clusterID, metricID, hostname, from.Unix(), now.Unix(), deletedFrom, 0)
+		if err != nil {
+			return AnomalyBand{}, err
+		}
+
+		// The last, partial bucket is what is being judged, so it is left out of the history.
+		values := bucketize(payload.Data, from, holtWintersBucket, int(now.Sub(from)/holtWintersBucket))
+
+		return HoltWintersBand(values, int(season/holtWintersBucket), sensitivity)
+	}
+
+	return AnomalyBand{}, fmt.Errorf("Unrecognized anomaly method: %v", expression.AnomalyMethod)
+}
+
+// EvalAnomalyExpression fails a host when the current value of Metric falls outside of the band expected from its history.
+// The band of every host is reported in Result.Message.
+func (evaluator *CheckExpressionEvaluator) EvalAnomalyExpression(checkRow *pg.CheckRow, hostRows []*pg.HostRow, expression pg.CheckExpression) pg.CheckExpression {
+	if hostRows == nil || len(hostRows) <= 0 {
+		expression.Result.Value = true
+		expression.Result.Message = "There are no hosts to check"
+		return expression
+	}
+
+	metric, err := pg.NewMetric(evaluator.AppContext).GetByClusterIDAndKey(nil, checkRow.ClusterID, expression.Metric)
+	if err != nil {
+		expression.Result.Value = true
+		expression.Result.Message = fmt.Sprintf("Unable to find metric %v: %v", expression.Metric, err)
+		return expression
+	}
+
+	clusterRow, err := pg.NewCluster(evaluator.AppContext).GetByID(nil, checkRow.ClusterID)
+	if err != nil {
+		expression.Result.Value = true
+		expression.Result.Message = err.Error()
+		return expression
+	}
+
+	deletedFrom := clusterRow.GetDeletedFromUNIXTimestampForSelect("ts_metrics")
+	tsMetric := shims.NewTSMetric(evaluator.AppContext, checkRow.ClusterID)
+	now := time.Now().UTC()
+
+	affectedHosts := 0
+	badHostnames := make([]string, 0)
+	goodHostnames := make([]string, 0)
+	messages := make([]string, 0)
+
+	for _, hostRow := range hostRows {
+		val, ok := hostMetricValue(hostRow, expression.Metric)
+		if !ok {
+			// If a Host does not contain a particular metric,
+			// We assume that there's something wrong with it.
+			affectedHosts = affectedHosts + 1
+			badHostnames = append(badHostnames, hostRow.Hostname)
+			messages = append(messages, fmt.Sprintf("%v: %v is missing", hostRow.Hostname, expression.Metric))
+			continue
+		}
+
+		band, err := evaluator.anomalyBand(tsMetric, checkRow.ClusterID, metric.ID, hostRow.Hostname, deletedFrom, expression, now)
+		if err != nil {
+			affectedHosts = affectedHosts + 1
+			badHostnames = append(badHostnames, hostRow.Hostname)
+			messages = append(messages, fmt.Sprintf("%v: %v", hostRow.Hostname, err))
+			continue
+		}
+
+		if band.Contains(val) {
+			goodHostnames = append(goodHostnames, hostRow.Hostname)
+			messages = append(messages, fmt.Sprintf("%v: %v is within the expected band %v", hostRow.Hostname, val, band))
+		} else {
+			affectedHosts = affectedHosts + 1
+			badHostnames = append(badHostnames, hostRow.Hostname)
+			messages = append(messages, fmt.Sprintf("%v: %v is outside of the expected band %v", hostRow.Hostname, val, band))
+		}
+	}
+
+	expression.Result.Value = affectedHosts >= expression.MinHost
+	expression.Result.BadHostnames = badHostnames
+	expression.Result.GoodHostnames = goodHostnames
+	expression.Result.Message = strings.Join(messages, "\n")
+
+	return expression
+}
@@ -0,0 +1,90 @@
+package check_expression
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/resourced/resourced-master/models/pg"
+)
+
+func TestZScoreBand(t *testing.T) {
+	band, err := ZScoreBand([]float64{10, 12, 8, 10, 10}, 2)
+	if err != nil {
+		t.Fatalf("Building a band should work. Error: %v", err)
+	}
+
+	if !band.Contains(10) || !band.Contains(12) {
+		t.Errorf("Values close to the mean should be in the band. Band: %v", band)
+	}
+	if band.Contains(20) {
+		t.Errorf("20 should be an anomaly. Band: %v", band)
+	}
+
+	_, err = ZScoreBand([]float64{10}, 2)
+	if err == nil {
+		t.Errorf("A single point should not be enough history")
+	}
+}
+
+func TestHoltWintersBand(t *testing.T) {
+	seasonLength := 12
+	values := make([]float64, 0)
+
+	// Four seasons of a sine wave on top of a slow upward trend.
+	for i := 0; i < 4*seasonLength; i++ {
+		values = append(values, 100+float64(i)*0.5+20*math.Sin(2*math.Pi*float64(i)/float64(seasonLength)))
+	}
+
+	band, err := HoltWintersBand(values, seasonLength, 3)
+	if err != nil {
+		t.Fatalf("Building a band should work. Error: %v", err)
+	}
+
+	next := len(values)
+	expected := 100 + float64(next)*0.5 + 20*math.Sin(2*math.Pi*float64(next)/float64(seasonLength))
+
+	if !band.Contains(expected) {
+		t.Errorf("The next point of the pattern should be expected. Value: %v, Band: %v", expected, band)
+	}
+	if band.Contains(expected + 100) {
+		t.Errorf("A spike should be an anomaly. Band: %v", band)
+	}
+
+	_, err = HoltWintersBand(values[:seasonLength], seasonLength, 3)
+	if err == nil {
+		t.Errorf("One season should not be enough history")
+	}
+}
+
+func TestBucketize(t *testing.T) {
+	from := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+	millis := func(d time.Duration) int64 { return from.Add(d).UnixNano() / int64(time.Millisecond) }
+
+	points := [][]interface{}{
+		[]interface{}{millis(time.Minute), float64(1)},
+		[]interface{}{millis(2 * time.Minute), float64(3)},
+		[]interface{}{millis(11 * time.Minute), float64(5)},
+	}
+
+	buckets := bucketize(points, from, 5*time.Minute, 3)
+
+	if len(buckets) != 3 || buckets[0] != 2 || buckets[1] != 2 || buckets[2] != 5 {
+		t.Errorf("Buckets should be averaged and gaps carried forward. Got: %v", buckets)
+	}
+}
+
+func TestHostMetricValue(t *testing.T) {
+	hostRow := &pg.HostRow{}
+	hostRow.Data = []byte(`{"/free": {"Memory": {"Free": 1024}}}`)
+
+	value, ok := hostMetricValue(hostRow, "/free.Memory.Free")
+	if !ok || value != 1024 {
+		t.Errorf("Value should be 1024. Got: %v", value)
+	}
+
+	_, ok = hostMetricValue(hostRow, "/free.Swap.Free")
+	if ok {
+		t.Errorf("Missing metric should not be found")
+	}
+}
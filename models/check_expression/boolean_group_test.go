@@ -0,0 +1,60 @@
+package check_expression
+
+import (
+	"testing"
+	"time"
+
+	"github.com/resourced/resourced-master/models/pg"
+)
+
+func TestEvalBooleanGroup(t *testing.T) {
+	if !EvalBooleanGroup(pg.BooleanGroupAll, []bool{true, true}) || EvalBooleanGroup(pg.BooleanGroupAll, []bool{true, false}) {
+		t.Errorf("all should be bad only when every expression is bad")
+	}
+	if !EvalBooleanGroup(pg.BooleanGroupAny, []bool{false, true}) || EvalBooleanGroup(pg.BooleanGroupAny, []bool{false, false}) {
+		t.Errorf("any should be bad when at least one expression is bad")
+	}
+	if EvalBooleanGroup(pg.BooleanGroupNot, []bool{true}) || !EvalBooleanGroup(pg.BooleanGroupNot, []bool{false}) {
+		t.Errorf("not should invert its expression")
+	}
+}
+
+func TestEvalExpressionTree(t *testing.T) {
+	checkRow := checkRowForNetworkTest(`["down.example.com"]`)
+
+	evaluator := CheckExpressionEvaluator{
+		PingProber: &fakeProber{rtts: map[string]time.Duration{}},
+	}
+
+	ping := pg.CheckExpression{}
+	ping.Type = "Ping"
+	ping.MinHost = 1
+
+	not := pg.CheckExpression{}
+	not.Type = "BooleanGroup"
+	not.Operator = pg.BooleanGroupNot
+	not.Expressions = []pg.CheckExpression{ping}
+
+	group := pg.CheckExpression{}
+	group.Type = "BooleanGroup"
+	group.Operator = pg.BooleanGroupAll
+	group.Expressions = []pg.CheckExpression{ping, not}
+
+	result := evaluator.evalExpression(checkRow, nil, group)
+	if result.Result.Value {
+		t.Errorf("ping and not ping should never be bad")
+	}
+	if !result.Expressions[0].Result.Value || result.Expressions[1].Result.Value {
+		t.Errorf("Children should carry their own results. Got: %v", result.Expressions)
+	}
+	if !result.Expressions[1].Expressions[0].Result.Value {
+		t.Errorf("The expression inside not should keep its own result")
+	}
+
+	group.Operator = pg.BooleanGroupAny
+
+	result = evaluator.evalExpression(checkRow, nil, group)
+	if !result.Result.Value {
+		t.Errorf("ping or not ping should always be bad")
+	}
+}
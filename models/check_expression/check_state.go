@@ -0,0 +1,81 @@
+package check_expression
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/resourced/resourced-master/models/pg"
+)
+
+// ComponentCheckState is the latest known state of a check referenced by a CheckState expression.
+type ComponentCheckState struct {
+	ID   int64
+	Name string
+
+	// Known is false when the check does not exist or has no result yet.
+	Known   bool
+	Failing bool
+}
+
+// EvalCheckStateExpression reads the latest result of every check in CheckIDs.
+// It fails when at least MinHost of them are failing. Checks do not depend on hosts.
+func (evaluator *CheckExpressionEvaluator) EvalCheckStateExpression(checkRow *pg.CheckRow, hostRows []*pg.HostRow, expression pg.CheckExpression) pg.CheckExpression {
+	states := make([]ComponentCheckState, len(expression.CheckIDs))
+
+	for i, checkID := range expression.CheckIDs {
+		states[i] = evaluator.componentCheckState(checkRow, checkID)
+	}
+
+	return compareCheckStates(states, expression)
+}
+
+// componentCheckState returns the latest result of a check from the cluster of checkRow.
+func (evaluator *CheckExpressionEvaluator) componentCheckState(checkRow *pg.CheckRow, checkID int64) ComponentCheckState {
+	state := ComponentCheckState{ID: checkID, Name: fmt.Sprintf("#%v", checkID)}
+
+	componentRow, err := pg.NewCheck(evaluator.AppContext).GetByID(nil, checkID)
+	if err != nil || componentRow.ClusterID != checkRow.ClusterID {
+		return state
+	}
+
+	state.Name = componentRow.Name
+
+	tsCheckRows, err := pg.NewTSCheck(evaluator.AppContext, componentRow.ClusterID).LastByClusterIDCheckIDAndLimit(nil, componentRow.ClusterID, componentRow.ID, 1)
+	if err != nil || len(tsCheckRows) == 0 {
+		return state
+	}
+
+	state.Known = true
+	state.Failing = tsCheckRows[0].Result
+
+	return state
+}
+
+// compareCheckStates fails the expression when the failing checks reach the MinHost quorum.
+// Unknown checks count as neither failing nor passing.
+func compareCheckStates(states []ComponentCheckState, expression pg.CheckExpression) pg.CheckExpression {
+	failing := make([]string, 0)
+	unknown := make([]string, 0)
+
+	for _, state := range states {
+		if !state.Known {
+			unknown = append(unknown, state.Name)
+		} else if state.Failing {
+			failing = append(failing, state.Name)
+		}
+	}
+
+	expression.Result.Value = len(failing) >= expression.MinHost
+
+	message := fmt.Sprintf("%v of %v checks are failing, quorum is %v", len(failing), len(states), expression.MinHost)
+	if len(failing) > 0 {
+		message = fmt.Sprintf("%v: %v", message, strings.Join(failing, ", "))
+	}
+	if len(unknown) > 0 {
+		message = fmt.Sprintf("%v. Unknown: %v", message, strings.Join(unknown, ", "))
+	}
+
+	expression.Result.Message = message
+
+	return expression
+}
@@ -0,0 +1,35 @@
+package check_expression
+
+import (
+	"testing"
+
+	"github.com/resourced/resourced-master/models/pg"
+)
+
+func TestCompareCheckStates(t *testing.T) {
+	states := []ComponentCheckState{
+		{ID: 1, Name: "api", Known: true, Failing: true},
+		{ID: 2, Name: "web", Known: true},
+		{ID: 3, Name: "db", Known: true},
+		{ID: 4, Name: "queue", Known: true},
+		{ID: 5, Name: "#5"},
+	}
+
+	expression := pg.CheckExpression{}
+	expression.Type = "CheckState"
+	expression.CheckIDs = []int64{1, 2, 3, 4, 5}
+	expression.MinHost = 2
+
+	result := compareCheckStates(states, expression)
+	if result.Result.Value {
+		t.Errorf("1 failing check should not reach a quorum of 2. Message: %v", result.Result.Message)
+	}
+	if result.Result.Message != "1 of 5 checks are failing, quorum is 2: api. Unknown: #5" {
+		t.Errorf("Unexpected message: %v", result.Result.Message)
+	}
+
+	states[2].Failing = true
+	if !compareCheckStates(states, expression).Result.Value {
+		t.Errorf("2 failing checks should reach a quorum of 2")
+	}
+}
@@ -2,9 +2,12 @@ package check_expression
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"net/http"
-	"os/exec"
 	"strings"
 	"time"
 
@@ -16,10 +19,17 @@ import (
 
 type CheckExpressionEvaluator struct {
 	AppContext context.Context
+
+	// TLSRootCAs verifies certificates of TLSCert expressions. nil uses the system roots.
+	TLSRootCAs *x509.CertPool
+
+	// PingProber and SSHProber default to ICMPProber and SSHProber.
+	PingProber Prober
+	SSHProber  Prober
 }
 
-// EvalExpressions reduces the result of expression into a single true/false.
-// 1st value: List of all CheckExpression containing results.
+// EvalExpressions reduces the result of the expression tree into a single true/false.
+// 1st value: The expression tree containing results.
 // 2nd value: The value of all expressions.
 // 3rd value: Error
 func (evaluator *CheckExpressionEvaluator) EvalExpressions(checkRow *pg.CheckRow) ([]pg.CheckExpression, bool, error) {
@@ -47,49 +57,89 @@ func (evaluator *CheckExpressionEvaluator) EvalExpressions(checkRow *pg.CheckRow
 		return nil, false, err
 	}
 
-	expressionResults := make([]pg.CheckExpression, 0)
-	var finalResult bool
-	var lastExpressionBooleanOperator string
+	// The top level is an implicit all group.
+	root := pg.CheckExpression{}
+	root.Type = "BooleanGroup"
+	root.Operator = pg.BooleanGroupAll
+	root.Expressions = expressions
 
-	for expIndex, expression := range expressions {
-		if expression.Type == "RawHostData" {
-			expression = evaluator.EvalRawHostDataExpression(checkRow, hostRows, expression)
+	root = evaluator.evalExpression(checkRow, hostRows, root)
 
-		} else if expression.Type == "RelativeHostData" {
-			expression = evaluator.EvalRelativeHostDataExpression(checkRow, hostRows, expression)
+	return root.Expressions, root.Result.Value, nil
+}
 
-		} else if expression.Type == "LogData" {
-			expression = evaluator.EvalLogDataExpression(checkRow, hostRows, expression)
+// evalExpression evaluates a single expression, and every child of a BooleanGroup.
+func (evaluator *CheckExpressionEvaluator) evalExpression(checkRow *pg.CheckRow, hostRows []*pg.HostRow, expression pg.CheckExpression) pg.CheckExpression {
+	if expression.IsBooleanGroup() {
+		children := make([]pg.CheckExpression, 0, len(expression.Expressions))
+		values := make([]bool, 0, len(expression.Expressions))
 
-		} else if expression.Type == "Ping" {
-			expression = evaluator.EvalPingExpression(checkRow, hostRows, expression)
+		for _, child := range expression.Expressions {
+			child = evaluator.evalExpression(checkRow, hostRows, child)
+			children = append(children, child)
+			values = append(values, child.Result.Value)
+		}
 
-		} else if expression.Type == "SSH" {
-			expression = evaluator.EvalSSHExpression(checkRow, hostRows, expression)
+		expression.Expressions = children
+		expression.Result.Value = EvalBooleanGroup(expression.Operator, values)
+		return expression
+	}
 
-		} else if expression.Type == "HTTP" || expression.Type == "HTTPS" {
-			expression = evaluator.EvalHTTPExpression(checkRow, hostRows, expression)
+	if expression.Type == "RawHostData" {
+		expression = evaluator.EvalRawHostDataExpression(checkRow, hostRows, expression)
 
-		} else if expression.Type == "BooleanOperator" {
-			lastExpressionBooleanOperator = expression.Operator
-		}
+	} else if expression.Type == "RelativeHostData" {
+		expression = evaluator.EvalRelativeHostDataExpression(checkRow, hostRows, expression)
 
-		if expIndex == 0 {
-			finalResult = expression.Result.Value
+	} else if expression.Type == "LogData" {
+		expression = evaluator.EvalLogDataExpression(checkRow, hostRows, expression)
 
-		} else {
-			if lastExpressionBooleanOperator == "and" {
-				finalResult = finalResult && expression.Result.Value
+	} else if expression.Type == "Ping" {
+		expression = evaluator.EvalPingExpression(checkRow, hostRows, expression)
 
-			} else if lastExpressionBooleanOperator == "or" {
-				finalResult = finalResult || expression.Result.Value
-			}
-		}
+	} else if expression.Type == "SSH" {
+		expression = evaluator.EvalSSHExpression(checkRow, hostRows, expression)
+
+	} else if expression.Type == "HTTP" || expression.Type == "HTTPS" {
+		expression = evaluator.EvalHTTPExpression(checkRow, hostRows, expression)
+
+	} else if expression.Type == "TCP" {
+		expression = evaluator.EvalTCPExpression(checkRow, hostRows, expression)
+
+	} else if expression.Type == "DNS" {
+		expression = evaluator.EvalDNSExpression(checkRow, hostRows, expression)
+
+	} else if expression.Type == "TLSCert" {
+		expression = evaluator.EvalTLSCertExpression(checkRow, hostRows, expression)
+
+	} else if expression.Type == "MetricAggregate" {
+		expression = evaluator.EvalMetricAggregateExpression(checkRow, hostRows, expression)
+
+	} else if expression.Type == "Anomaly" {
+		expression = evaluator.EvalAnomalyExpression(checkRow, hostRows, expression)
+
+	} else if expression.Type == "Forecast" {
+		expression = evaluator.EvalForecastExpression(checkRow, hostRows, expression)
+
+	} else if expression.Type == "Synthetic" {
+		expression = evaluator.EvalSyntheticExpression(checkRow, hostRows, expression)
+
+	} else if expression.Type == "Heartbeat" {
+		expression = evaluator.EvalHeartbeatExpression(checkRow, hostRows, expression)
+
+	} else if expression.Type == "EventData" {
+		expression = evaluator.EvalEventDataExpression(checkRow, hostRows, expression)
 
-		expressionResults = append(expressionResults, expression)
+	} else if expression.Type == "CheckState" {
+		expression = evaluator.EvalCheckStateExpression(checkRow, hostRows, expression)
 	}
 
-	return expressionResults, finalResult, nil
+	return expression
+}
+
+// EvalBooleanGroup combines the values of the children of a group. true means bad.
+func EvalBooleanGroup(operator string, values []bool) bool {
+	return pg.EvalBooleanGroup(operator, values)
 }
 
 func (evaluator *CheckExpressionEvaluator) EvalRawHostDataExpression(checkRow *pg.CheckRow, hostRows []*pg.HostRow, expression pg.CheckExpression) pg.CheckExpression {
@@ -102,29 +152,25 @@ func (evaluator *CheckExpressionEvaluator) EvalRawHostDataExpression(checkRow *p
 	affectedHosts := 0
 	badHostnames := make([]string, 0)
 	goodHostnames := make([]string, 0)
+	noDataHostnames := make([]string, 0)
 
 	var perHostResult bool
 
-	for _, hostRow := range hostRows {
-		var val float64
+	now := time.Now().UTC()
 
-		for prefix, keyAndValue := range hostRow.DataAsFlatKeyValue() {
-			if !strings.HasPrefix(expression.Metric, prefix) {
-				continue
-			}
+	for _, hostRow := range hostRows {
+		val, ok := evaluator.hostDataValue(checkRow, hostRow, expression, now)
+		if !ok {
+			noDataHostnames = append(noDataHostnames, hostRow.Hostname)
 
-			for key, value := range keyAndValue {
-				if strings.HasSuffix(expression.Metric, key) {
-					val = value.(float64)
-					break
-				}
+			bad, good := NoDataVerdict(expression.GetNoDataPolicy())
+			if bad {
+				affectedHosts = affectedHosts + 1
+				badHostnames = append(badHostnames, hostRow.Hostname)
+			} else if good {
+				goodHostnames = append(goodHostnames, hostRow.Hostname)
 			}
-		}
-
-		// If a Host does not contain a particular metric,
-		// We assume that there's something wrong with it.
-		if val < float64(0) {
-			perHostResult = true
+			continue
 		}
 
 		if expression.Operator == ">" {
@@ -155,6 +201,8 @@ func (evaluator *CheckExpressionEvaluator) EvalRawHostDataExpression(checkRow *p
 	expression.Result.Value = affectedHosts >= expression.MinHost
 	expression.Result.BadHostnames = badHostnames
 	expression.Result.GoodHostnames = goodHostnames
+	expression.Result.NoDataHostnames = noDataHostnames
+	expression.Result.Message = noDataMessage(expression, noDataHostnames)
 
 	return expression
 }
@@ -169,10 +217,27 @@ func (evaluator *CheckExpressionEvaluator) EvalRelativeHostDataExpression(checkR
 	affectedHosts := 0
 	badHostnames := make([]string, 0)
 	goodHostnames := make([]string, 0)
+	noDataHostnames := make([]string, 0)
 
 	var perHostResult bool
 
+	now := time.Now().UTC()
+
 	for _, hostRow := range hostRows {
+		val, ok := evaluator.hostDataValue(checkRow, hostRow, expression, now)
+		if !ok {
+			noDataHostnames = append(noDataHostnames, hostRow.Hostname)
+
+			bad, good := NoDataVerdict(expression.GetNoDataPolicy())
+			if bad {
+				affectedHosts = affectedHosts + 1
+				badHostnames = append(badHostnames, hostRow.Hostname)
+			} else if good {
+				goodHostnames = append(goodHostnames, hostRow.Hostname)
+			}
+			continue
+		}
+
 		metric, err := pg.NewMetric(evaluator.AppContext).GetByClusterIDAndKey(nil, checkRow.ClusterID, expression.Metric)
 		if err != nil {
 			// If we are unable to pull metric metadata,
@@ -197,27 +262,6 @@ func (evaluator *CheckExpressionEvaluator) EvalRelativeHostDataExpression(checkR
 			continue
 		}
 
-		var val float64
-
-		for prefix, keyAndValue := range hostRow.DataAsFlatKeyValue() {
-			if !strings.HasPrefix(expression.Metric, prefix) {
-				continue
-			}
-
-			for key, value := range keyAndValue {
-				if strings.HasSuffix(expression.Metric, key) {
-					val = value.(float64)
-					break
-				}
-			}
-		}
-
-		// If a Host does not contain a particular metric,
-		// We assume that there's something wrong with it.
-		if val < float64(0) {
-			perHostResult = true
-		}
-
 		var prevVal float64
 
 		if expression.PrevAggr == "avg" {
@@ -254,6 +298,8 @@ func (evaluator *CheckExpressionEvaluator) EvalRelativeHostDataExpression(checkR
 	expression.Result.Value = affectedHosts >= expression.MinHost
 	expression.Result.BadHostnames = badHostnames
 	expression.Result.GoodHostnames = goodHostnames
+	expression.Result.NoDataHostnames = noDataHostnames
+	expression.Result.Message = noDataMessage(expression, noDataHostnames)
 
 	return expression
 }
@@ -330,131 +376,109 @@ func (evaluator *CheckExpressionEvaluator) EvalLogDataExpression(checkRow *pg.Ch
 	return expression
 }
 
-func (evaluator *CheckExpressionEvaluator) CheckPing(hostname string) (outBytes []byte, err error) {
-	return exec.Command("ping", "-c", "1", hostname).CombinedOutput()
+func (evaluator *CheckExpressionEvaluator) pingProber() Prober {
+	if evaluator.PingProber != nil {
+		return evaluator.PingProber
+	}
+	return &ICMPProber{}
 }
 
-func (evaluator *CheckExpressionEvaluator) EvalPingExpression(checkRow *pg.CheckRow, hostRows []*pg.HostRow, expression pg.CheckExpression) pg.CheckExpression {
-	hostnames, err := checkRow.GetHostsList()
-	if err != nil {
-		expression.Result.Value = false
-		return expression
+func (evaluator *CheckExpressionEvaluator) sshProber() Prober {
+	if evaluator.SSHProber != nil {
+		return evaluator.SSHProber
 	}
+	return &SSHProber{}
+}
 
-	if len(hostnames) == 0 && hostRows != nil && len(hostRows) > 0 {
-		hostnames = make([]string, len(hostRows))
+// CheckPing returns the round-trip time of one ICMP echo, or of a TCP connection on port when ICMP is unavailable.
+func (evaluator *CheckExpressionEvaluator) CheckPing(hostname, port string, timeout time.Duration) (time.Duration, error) {
+	return evaluator.pingProber().Probe(hostname, port, timeout)
+}
 
-		for i, hostRow := range hostRows {
-			hostnames[i] = hostRow.Hostname
-		}
-	}
+func (evaluator *CheckExpressionEvaluator) EvalPingExpression(checkRow *pg.CheckRow, hostRows []*pg.HostRow, expression pg.CheckExpression) pg.CheckExpression {
+	return evaluator.evalProbe(checkRow, hostRows, expression, evaluator.CheckPing)
+}
 
-	if hostnames == nil || len(hostnames) <= 0 {
-		expression.Result.Value = false
-		return expression
-	}
+// CheckSSH returns the time it took sshd to send its banner.
+func (evaluator *CheckExpressionEvaluator) CheckSSH(hostname, port string, timeout time.Duration) (time.Duration, error) {
+	return evaluator.sshProber().Probe(hostname, port, timeout)
+}
 
-	affectedHosts := 0
-	badHostnames := make([]string, 0)
-	goodHostnames := make([]string, 0)
+func (evaluator *CheckExpressionEvaluator) EvalSSHExpression(checkRow *pg.CheckRow, hostRows []*pg.HostRow, expression pg.CheckExpression) pg.CheckExpression {
+	return evaluator.evalProbe(checkRow, hostRows, expression, evaluator.CheckSSH)
+}
 
-	for _, hostname := range hostnames {
-		_, err := evaluator.CheckPing(hostname)
+// evalProbe runs probeFunc against every host and records the round-trip times in Result.Latencies.
+func (evaluator *CheckExpressionEvaluator) evalProbe(checkRow *pg.CheckRow, hostRows []*pg.HostRow, expression pg.CheckExpression, probeFunc func(hostname, port string, timeout time.Duration) (time.Duration, error)) pg.CheckExpression {
+	timeout := networkTimeout(expression)
+	latencies := make(map[string]float64)
+
+	expression = evaluator.evalPerHost(checkRow, hostRows, expression, func(hostname string) error {
+		rtt, err := probeFunc(hostname, expression.Port, timeout)
 		if err != nil {
-			affectedHosts = affectedHosts + 1
-			badHostnames = append(badHostnames, hostname)
-		} else {
-			goodHostnames = append(goodHostnames, hostname)
+			return err
 		}
-	}
 
-	expression.Result.Value = affectedHosts >= expression.MinHost
-	expression.Result.BadHostnames = badHostnames
-	expression.Result.GoodHostnames = goodHostnames
+		latencies[hostname] = float64(rtt) / float64(time.Millisecond)
+		return nil
+	})
+
+	expression.Result.Latencies = latencies
 
 	return expression
 }
 
-func (evaluator *CheckExpressionEvaluator) CheckSSH(hostname, port, user string) (outBytes []byte, err error) {
-	sshOptions := []string{"-o BatchMode=yes", "-o ConnectTimeout=10"}
+// maxHTTPBodyBytes caps how much of a response body is read for assertions.
+const maxHTTPBodyBytes = 1024 * 1024
 
-	if port != "" {
-		sshOptions = append(sshOptions, []string{"-p", port}...)
+// CheckHTTP sends the request described by expression to hostname.
+// It returns the response with its body already read and closed, and how long the round trip took.
+func (evaluator *CheckExpressionEvaluator) CheckHTTP(hostname string, expression pg.CheckExpression, headers map[string]string) (resp *http.Response, respBody []byte, latency time.Duration, err error) {
+	scheme := expression.Protocol
+	if scheme == "" {
+		scheme = strings.ToLower(expression.Type)
 	}
 
-	userAtHost := hostname
-
-	if user != "" {
-		userAtHost = fmt.Sprintf("%v@%v", user, hostname)
+	path := expression.HTTPPath
+	if path != "" && !strings.HasPrefix(path, "/") {
+		path = "/" + path
 	}
 
-	sshOptions = append(sshOptions, userAtHost)
-
-	return exec.Command("ssh", sshOptions...).CombinedOutput()
-}
-
-func (evaluator *CheckExpressionEvaluator) EvalSSHExpression(checkRow *pg.CheckRow, hostRows []*pg.HostRow, expression pg.CheckExpression) pg.CheckExpression {
-	hostnames, err := checkRow.GetHostsList()
-	if err != nil {
-		expression.Result.Value = false
-		return expression
-	}
+	url := fmt.Sprintf("%v://%v:%s%v", scheme, hostname, expression.Port, path)
+	method := strings.ToUpper(expression.HTTPMethod)
+	body := expression.HTTPBody
 
-	if len(hostnames) == 0 && hostRows != nil && len(hostRows) > 0 {
-		hostnames = make([]string, len(hostRows))
+	client := &http.Client{Timeout: networkTimeout(expression)}
 
-		for i, hostRow := range hostRows {
-			hostnames[i] = hostRow.Hostname
+	if expression.HTTPInsecureSkipVerify {
+		client.Transport = &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
 		}
 	}
 
-	if hostnames == nil || len(hostnames) <= 0 {
-		expression.Result.Value = false
-		return expression
-	}
-
-	affectedHosts := 0
-	badHostnames := make([]string, 0)
-	goodHostnames := make([]string, 0)
-
-	for _, hostname := range hostnames {
-		outputBytes, err := evaluator.CheckSSH(hostname, expression.Port, expression.Username)
-		outputString := string(outputBytes)
-
-		if err != nil && !strings.Contains(outputString, "Permission denied") && !strings.Contains(outputString, "Host key verification failed") {
-			affectedHosts = affectedHosts + 1
-			badHostnames = append(badHostnames, hostname)
-		} else {
-			goodHostnames = append(goodHostnames, hostname)
+	if expression.HTTPDisableRedirects {
+		client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
 		}
 	}
 
-	expression.Result.Value = affectedHosts >= expression.MinHost
-	expression.Result.BadHostnames = badHostnames
-	expression.Result.GoodHostnames = goodHostnames
-
-	return expression
-}
-
-func (evaluator *CheckExpressionEvaluator) CheckHTTP(hostname, scheme, port, method, user, pass string, headers map[string]string, body string) (resp *http.Response, err error) {
-	url := fmt.Sprintf("%v://%v:%s", scheme, hostname, port)
-
-	client := &http.Client{}
-
 	var req *http.Request
 
 	if body != "" {
-		req, err = http.NewRequest(strings.ToUpper(method), url, strings.NewReader(body))
+		req, err = http.NewRequest(method, url, strings.NewReader(body))
 
 		// Detect if POST body is JSON and set content-type
-		if strings.HasPrefix(body, "{") || strings.HasPrefix(body, "[") {
-			req.Header.Set("Content-Type", "application/json")
-		} else {
-			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if err == nil {
+			if strings.HasPrefix(body, "{") || strings.HasPrefix(body, "[") {
+				req.Header.Set("Content-Type", "application/json")
+			} else {
+				req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			}
 		}
 
 	} else {
-		req, err = http.NewRequest(strings.ToUpper(method), url, nil)
+		req, err = http.NewRequest(method, url, nil)
 	}
 
 	if err != nil {
@@ -463,17 +487,19 @@ func (evaluator *CheckExpressionEvaluator) CheckHTTP(hostname, scheme, port, met
 			"URL":        url,
 			"HTTPMethod": method,
 		}).Error(err)
-		return nil, err
+		return nil, nil, 0, err
 	}
 
 	for headerKey, headerVal := range headers {
 		req.Header.Add(headerKey, headerVal)
 	}
 
-	if user != "" || pass != "" {
-		req.SetBasicAuth(user, pass)
+	if expression.Username != "" || expression.Password != "" {
+		req.SetBasicAuth(expression.Username, expression.Password)
 	}
 
+	start := time.Now()
+
 	resp, err = client.Do(req)
 	if err != nil {
 		logrus.WithFields(logrus.Fields{
@@ -481,65 +507,47 @@ func (evaluator *CheckExpressionEvaluator) CheckHTTP(hostname, scheme, port, met
 			"URL":        url,
 			"HTTPMethod": method,
 		}).Error(err)
-		return nil, err
-
-	} else if resp != nil && resp.Body != nil {
-		resp.Body.Close()
+		return nil, nil, time.Since(start), err
 	}
+	defer resp.Body.Close()
+
+	respBody, err = ioutil.ReadAll(io.LimitReader(resp.Body, maxHTTPBodyBytes))
+	latency = time.Since(start)
 
-	return resp, err
+	return resp, respBody, latency, err
 }
 
+// EvalHTTPExpression fails a host when the request errors, or when the response breaks any of the status, body or response time assertions.
 func (evaluator *CheckExpressionEvaluator) EvalHTTPExpression(checkRow *pg.CheckRow, hostRows []*pg.HostRow, expression pg.CheckExpression) pg.CheckExpression {
-	hostnames, err := checkRow.GetHostsList()
-	if err != nil {
-		expression.Result.Value = false
-		return expression
-	}
+	headers := ParseHTTPHeaders(expression.Headers)
 
-	if len(hostnames) == 0 && hostRows != nil && len(hostRows) > 0 {
-		hostnames = make([]string, len(hostRows))
+	maxResponseTime := httpMaxResponseTime(expression)
+	latencies := make(map[string]float64)
 
-		for i, hostRow := range hostRows {
-			hostnames[i] = hostRow.Hostname
+	expression = evaluator.evalPerHost(checkRow, hostRows, expression, func(hostname string) error {
+		resp, respBody, latency, err := evaluator.CheckHTTP(hostname, expression, headers)
+		if err != nil {
+			return err
 		}
-	}
 
-	if hostnames == nil || len(hostnames) <= 0 {
-		expression.Result.Value = false
-		return expression
-	}
-
-	affectedHosts := 0
-	badHostnames := make([]string, 0)
-	goodHostnames := make([]string, 0)
+		latencies[hostname] = float64(latency) / float64(time.Millisecond)
 
-	for _, hostname := range hostnames {
-		headers := make(map[string]string)
-
-		for _, headersNewLine := range strings.Split(expression.Headers, "\n") {
-			for _, kvString := range strings.Split(headersNewLine, ",") {
-				if strings.Contains(kvString, "=") {
-					kvSlice := strings.Split(kvString, "=")
-					if len(kvSlice) >= 2 {
-						headers[strings.TrimSpace(kvSlice[0])] = strings.TrimSpace(kvSlice[1])
-					}
-				}
-			}
+		accepted, err := StatusCodeAccepted(expression.HTTPStatusCodes, resp.StatusCode)
+		if err != nil {
+			return err
+		}
+		if !accepted {
+			return fmt.Errorf("Unexpected status code: %v", resp.StatusCode)
 		}
 
-		resp, err := evaluator.CheckHTTP(hostname, expression.Protocol, expression.Port, expression.HTTPMethod, expression.Username, expression.Password, headers, expression.HTTPBody)
-		if err != nil || (resp != nil && resp.StatusCode != 200) {
-			affectedHosts = affectedHosts + 1
-			badHostnames = append(badHostnames, hostname)
-		} else {
-			goodHostnames = append(goodHostnames, hostname)
+		if maxResponseTime > 0 && latency > maxResponseTime {
+			return fmt.Errorf("Response took %v, longer than %v", latency, maxResponseTime)
 		}
-	}
 
-	expression.Result.Value = affectedHosts >= expression.MinHost
-	expression.Result.BadHostnames = badHostnames
-	expression.Result.GoodHostnames = goodHostnames
+		return evaluator.CheckHTTPBody(expression, respBody)
+	})
+
+	expression.Result.Latencies = latencies
 
 	return expression
 }
@@ -0,0 +1,72 @@
+package check_expression
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/resourced/resourced-master/models/pg"
+	"github.com/resourced/resourced-master/models/shared"
+	"github.com/resourced/resourced-master/models/shims"
+)
+
+// EvalEventDataExpression counts the events created in the previous PrevRange minutes
+// that match Search and EventTags, and compares the count with Value.
+// Events do not depend on hosts.
+func (evaluator *CheckExpressionEvaluator) EvalEventDataExpression(checkRow *pg.CheckRow, hostRows []*pg.HostRow, expression pg.CheckExpression) pg.CheckExpression {
+	clusterRow, err := pg.NewCluster(evaluator.AppContext).GetByID(nil, checkRow.ClusterID)
+	if err != nil {
+		expression.Result.Value = false
+		return expression
+	}
+
+	deletedFrom := clusterRow.GetDeletedFromUNIXTimestampForSelect("ts_events")
+
+	now := time.Now().UTC()
+	from := now.Add(-1 * time.Duration(expression.PrevRange) * time.Minute).Unix()
+
+	events, err := shims.NewTSEvent(evaluator.AppContext, checkRow.ClusterID).AllByClusterIDAndCreatedFromRange(checkRow.ClusterID, from, now.Unix(), deletedFrom)
+	if err != nil {
+		expression.Result.Value = false
+		expression.Result.Message = err.Error()
+		return expression
+	}
+
+	return compareEventCount(events, expression)
+}
+
+// compareEventCount counts the events matching the expression and compares the count with Value.
+func compareEventCount(events []shared.TSEventPayload, expression pg.CheckExpression) pg.CheckExpression {
+	count := 0
+	for _, event := range events {
+		if event.Matches(expression.Search, expression.EventTags) {
+			count = count + 1
+		}
+	}
+
+	val := float64(count)
+
+	if expression.Operator == ">" {
+		expression.Result.Value = val > expression.Value
+
+	} else if expression.Operator == "<" {
+		expression.Result.Value = val < expression.Value
+	}
+
+	filters := make([]string, 0)
+	if expression.Search != "" {
+		filters = append(filters, fmt.Sprintf("matching %q", expression.Search))
+	}
+	if len(expression.EventTags) > 0 {
+		filters = append(filters, fmt.Sprintf("tagged %v", strings.Join(expression.EventTags, ", ")))
+	}
+
+	description := "events"
+	if len(filters) > 0 {
+		description = fmt.Sprintf("events %v", strings.Join(filters, " and "))
+	}
+
+	expression.Result.Message = fmt.Sprintf("%v %v in the last %v minutes", count, description, expression.PrevRange)
+
+	return expression
+}
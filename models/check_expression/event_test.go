@@ -0,0 +1,47 @@
+package check_expression
+
+import (
+	"testing"
+
+	"github.com/resourced/resourced-master/models/pg"
+	"github.com/resourced/resourced-master/models/shared"
+)
+
+func TestCompareEventCount(t *testing.T) {
+	events := []shared.TSEventPayload{
+		{ID: 1, Description: "Deploy api v1.2", Tags: []string{"deploy", "api"}},
+		{ID: 2, Description: "Deploy web v3.0", Tags: []string{"deploy", "web"}},
+		{ID: 3, Description: "Backup completed", Tags: []string{"backup"}},
+	}
+
+	expression := pg.CheckExpression{}
+	expression.Type = "EventData"
+	expression.EventTags = []string{"deploy"}
+	expression.Operator = ">"
+	expression.Value = 1
+	expression.PrevRange = 60
+
+	result := compareEventCount(events, expression)
+	if !result.Result.Value {
+		t.Errorf("2 deploys should be more than 1. Message: %v", result.Result.Message)
+	}
+	if result.Result.Message != "2 events tagged deploy in the last 60 minutes" {
+		t.Errorf("Unexpected message: %v", result.Result.Message)
+	}
+
+	expression.EventTags = []string{"deploy", "api"}
+	if compareEventCount(events, expression).Result.Value {
+		t.Errorf("Only 1 event is tagged deploy and api")
+	}
+
+	expression.EventTags = nil
+	expression.Search = "backup completed"
+	expression.Operator = "<"
+	expression.Value = 1
+	if compareEventCount(events, expression).Result.Value {
+		t.Errorf("A backup completed event exists")
+	}
+	if !compareEventCount(events[:2], expression).Result.Value {
+		t.Errorf("Missing backup completed event should fail")
+	}
+}
@@ -0,0 +1,199 @@
+package check_expression
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/resourced/resourced-master/models/pg"
+	"github.com/resourced/resourced-master/models/shims"
+)
+
+const defaultForecastHorizon = 24 * time.Hour
+
+// LinearTrend is a least squares fit of value over time.
+type LinearTrend struct {
+	Origin time.Time
+
+	// Slope is in value per second since Origin.
+	Slope     float64
+	Intercept float64
+}
+
+// At projects the trend to t.
+func (trend LinearTrend) At(t time.Time) float64 {
+	return trend.Intercept + trend.Slope*t.Sub(trend.Origin).Seconds()
+}
+
+// Crossing returns when the trend reaches threshold. The second value is false for a flat trend.
+func (trend LinearTrend) Crossing(threshold float64) (time.Time, bool) {
+	if trend.Slope == 0 {
+		return time.Time{}, false
+	}
+
+	seconds := (threshold - trend.Intercept) / trend.Slope
+
+	return trend.Origin.Add(time.Duration(seconds * float64(time.Second))), true
+}
+
+// FitLinearTrend fits highchart points, [unix milliseconds, value], with ordinary least squares.
+func FitLinearTrend(points [][]interface{}) (LinearTrend, error) {
+	xs := make([]float64, 0, len(points))
+	ys := make([]float64, 0, len(points))
+
+	var origin int64
+	originSet := false
+
+	for _, point := range points {
+		if len(point) < 2 {
+			continue
+		}
+
+		millis, ok := point[0].(int64)
+		if !ok {
+			continue
+		}
+		value, ok := point[1].(float64)
+		if !ok {
+			continue
+		}
+
+		if !originSet {
+			origin = millis
+			originSet = true
+		}
+
+		xs = append(xs, float64(millis-origin)/1000)
+		ys = append(ys, value)
+	}
+
+	if len(xs) < 2 {
+		return LinearTrend{}, errors.New("Not enough history to fit a trend")
+	}
+
+	var sumX, sumY, sumXY, sumXX float64
+	for i := range xs {
+		sumX = sumX + xs[i]
+		sumY = sumY + ys[i]
+		sumXY = sumXY + xs[i]*ys[i]
+		sumXX = sumXX + xs[i]*xs[i]
+	}
+
+	n := float64(len(xs))
+	denominator := n*sumXX - sumX*sumX
+
+	if denominator == 0 {
+		return LinearTrend{}, errors.New("History has no time spread to fit a trend")
+	}
+
+	trend := LinearTrend{Origin: time.Unix(0, origin*int64(time.Millisecond)).UTC()}
+	trend.Slope = (n*sumXY - sumX*sumY) / denominator
+	trend.Intercept = (sumY - trend.Slope*sumX) / n
+
+	return trend, nil
+}
+
+// forecastHost decides if trend crosses expression.Value, in the direction of expression.Operator, between now and horizon.
+// It returns a non-nil error describing the crossing when it does.
+func forecastHost(trend LinearTrend, expression pg.CheckExpression, now time.Time, horizon time.Duration) (string, error) {
+	current := trend.At(now)
+	projected := trend.At(now.Add(horizon))
+
+	crossed := func(value float64) bool {
+		if expression.Operator == "<" {
+			return value < expression.Value
+		}
+		return value > expression.Value
+	}
+
+	if crossed(current) {
+		return "", fmt.Errorf("trend is already at %.2f, past %v", current, expression.Value)
+	}
+
+	if !crossed(projected) {
+		return fmt.Sprintf("projected to be %.2f in %v", projected, horizon), nil
+	}
+
+	crossing, _ := trend.Crossing(expression.Value)
+
+	return "", fmt.Errorf("projected to reach %v at %v, in %v", expression.Value, crossing.UTC().Format(time.RFC3339), crossing.Sub(now).Truncate(time.Minute))
+}
+
+// EvalForecastExpression fits a linear trend to the previous PrevRange minutes of Metric per host,
+// and fails a host when the trend crosses Value within ForecastHorizon. Projected crossing times are reported in Result.Message.
+func (evaluator *CheckExpressionEvaluator) EvalForecastExpression(checkRow *pg.CheckRow, hostRows []*pg.HostRow, expression pg.CheckExpression) pg.CheckExpression {
+	if hostRows == nil || len(hostRows) <= 0 {
+		expression.Result.Value = true
+		expression.Result.Message = "There are no hosts to check"
+		return expression
+	}
+
+	horizon, err := time.ParseDuration(expression.ForecastHorizon)
+	if err != nil || horizon <= 0 {
+		horizon = defaultForecastHorizon
+	}
+
+	prevRange := time.Duration(expression.PrevRange) * time.Minute
+	if prevRange <= 0 {
+		prevRange = 6 * time.Hour
+	}
+
+	metric, err := pg.NewMetric(evaluator.AppContext).GetByClusterIDAndKey(nil, checkRow.ClusterID, expression.Metric)
+	if err != nil {
+		expression.Result.Value = true
+		expression.Result.Message = fmt.Sprintf("Unable to find metric %v: %v", expression.Metric, err)
+		return expression
+	}
+
+	clusterRow, err := pg.NewCluster(evaluator.AppContext).GetByID(nil, checkRow.ClusterID)
+	if err != nil {
+		expression.Result.Value = true
+		expression.Result.Message = err.Error()
+		return expression
+	}
+
+	deletedFrom := clusterRow.GetDeletedFromUNIXTimestampForSelect("ts_metrics")
+	tsMetric := shims.NewTSMetric(evaluator.AppContext, checkRow.ClusterID)
+	now := time.Now().UTC()
+
+	affectedHosts := 0
+	badHostnames := make([]string, 0)
+	goodHostnames := make([]string, 0)
+	messages := make([]string, 0)
+
+	for _, hostRow := range hostRows {
+		payload, err := tsMetric.AllByMetricIDHostAndRangeForHighchart(checkRow.ClusterID, metric.ID, hostRow.Hostname, now.Add(-prevRange).Unix(), now.Unix(), deletedFrom, 0)
+		if err != nil {
+			affectedHosts = affectedHosts + 1
+			badHostnames = append(badHostnames, hostRow.Hostname)
+			messages = append(messages, fmt.Sprintf("%v: %v", hostRow.Hostname, err))
+			continue
+		}
+
+		trend, err := FitLinearTrend(payload.Data)
+		if err != nil {
+			affectedHosts = affectedHosts + 1
+			badHostnames = append(badHostnames, hostRow.Hostname)
+			messages = append(messages, fmt.Sprintf("%v: %v", hostRow.Hostname, err))
+			continue
+		}
+
+		message, err := forecastHost(trend, expression, now, horizon)
+		if err != nil {
+			affectedHosts = affectedHosts + 1
+			badHostnames = append(badHostnames, hostRow.Hostname)
+			messages = append(messages, fmt.Sprintf("%v: %v", hostRow.Hostname, err))
+		} else {
+			goodHostnames = append(goodHostnames, hostRow.Hostname)
+			messages = append(messages, fmt.Sprintf("%v: %v", hostRow.Hostname, message))
+		}
+	}
+
+	expression.Result.Value = affectedHosts >= expression.MinHost
+	expression.Result.BadHostnames = badHostnames
+	expression.Result.GoodHostnames = goodHostnames
+	expression.Result.Message = strings.Join(messages, "\n")
+
+	return expression
+}
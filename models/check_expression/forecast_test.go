@@ -0,0 +1,67 @@
+package check_expression
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/resourced/resourced-master/models/pg"
+)
+
+func TestFitLinearTrend(t *testing.T) {
+	start := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	points := make([][]interface{}, 0)
+	for i := 0; i < 6; i++ {
+		created := start.Add(time.Duration(i) * time.Hour)
+		points = append(points, []interface{}{created.UnixNano() / int64(time.Millisecond), 50 + float64(i)*2})
+	}
+
+	trend, err := FitLinearTrend(points)
+	if err != nil {
+		t.Fatalf("Fitting a trend should work. Error: %v", err)
+	}
+
+	// 2% per hour starting at 50% reaches 100% after 25 hours.
+	crossing, ok := trend.Crossing(100)
+	if !ok || !crossing.Equal(start.Add(25*time.Hour)) {
+		t.Errorf("Trend should reach 100 at %v. Got: %v", start.Add(25*time.Hour), crossing)
+	}
+
+	_, err = FitLinearTrend(points[:1])
+	if err == nil {
+		t.Errorf("A single point should not be enough to fit a trend")
+	}
+}
+
+func TestForecastHost(t *testing.T) {
+	start := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := start.Add(5 * time.Hour)
+
+	trend := LinearTrend{Origin: start, Intercept: 50, Slope: 2.0 / 3600}
+
+	expression := pg.CheckExpression{}
+	expression.Operator = ">"
+	expression.Value = 100
+
+	_, err := forecastHost(trend, expression, now, 24*time.Hour)
+	if err == nil {
+		t.Fatalf("Disk reaching 100 in 20 hours should fail a 24h forecast")
+	}
+	if !strings.Contains(err.Error(), "2016-01-02T01:00:00Z") {
+		t.Errorf("Message should contain the projected crossing time. Got: %v", err)
+	}
+
+	_, err = forecastHost(trend, expression, now, 12*time.Hour)
+	if err != nil {
+		t.Errorf("Disk reaching 100 in 20 hours should not fail a 12h forecast. Error: %v", err)
+	}
+
+	expression.Operator = "<"
+	expression.Value = 10
+
+	_, err = forecastHost(trend, expression, now, 24*time.Hour)
+	if err != nil {
+		t.Errorf("A rising trend should never fall below 10. Error: %v", err)
+	}
+}
@@ -0,0 +1,30 @@
+package check_expression
+
+import (
+	"time"
+
+	"github.com/resourced/resourced-master/models/pg"
+)
+
+// EvalHeartbeatExpression fails when the monitor missed its ping, or when the last run reported a non-zero exit status.
+// Heartbeats do not depend on hosts.
+func (evaluator *CheckExpressionEvaluator) EvalHeartbeatExpression(checkRow *pg.CheckRow, hostRows []*pg.HostRow, expression pg.CheckExpression) pg.CheckExpression {
+	heartbeatRow, err := pg.NewHeartbeat(evaluator.AppContext).GetByID(nil, expression.HeartbeatID)
+	if err != nil || heartbeatRow.ClusterID != checkRow.ClusterID {
+		expression.Result.Value = true
+		expression.Result.Message = "Heartbeat monitor does not exist"
+		return expression
+	}
+
+	bad, message, err := heartbeatRow.StatusAt(time.Now().UTC())
+	if err != nil {
+		expression.Result.Value = true
+		expression.Result.Message = err.Error()
+		return expression
+	}
+
+	expression.Result.Value = bad
+	expression.Result.Message = message
+
+	return expression
+}
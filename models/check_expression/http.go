@@ -0,0 +1,169 @@
+package check_expression
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/resourced/resourced-master/models/pg"
+)
+
+// ParseHTTPHeaders parses headers written as key=value, separated by commas or new lines.
+func ParseHTTPHeaders(headersString string) map[string]string {
+	headers := make(map[string]string)
+
+	for _, headersNewLine := range strings.Split(headersString, "\n") {
+		for _, kvString := range strings.Split(headersNewLine, ",") {
+			if strings.Contains(kvString, "=") {
+				// Values, e.g. base64 tokens, may contain "=" themselves.
+				kvSlice := strings.SplitN(kvString, "=", 2)
+				if len(kvSlice) >= 2 {
+					headers[strings.TrimSpace(kvSlice[0])] = strings.TrimSpace(kvSlice[1])
+				}
+			}
+		}
+	}
+
+	return headers
+}
+
+// StatusCodeAccepted checks statusCode against a comma separated list of codes ("200"),
+// classes ("2xx") and ranges ("200-299"). An empty list only accepts 200.
+func StatusCodeAccepted(statusCodes string, statusCode int) (bool, error) {
+	if strings.TrimSpace(statusCodes) == "" {
+		return statusCode == 200, nil
+	}
+
+	for _, chunk := range strings.Split(statusCodes, ",") {
+		chunk = strings.ToLower(strings.TrimSpace(chunk))
+		if chunk == "" {
+			continue
+		}
+
+		if len(chunk) == 3 && strings.HasSuffix(chunk, "xx") {
+			class, err := strconv.Atoi(chunk[:1])
+			if err != nil {
+				return false, fmt.Errorf("Invalid status code class: %v", chunk)
+			}
+			if statusCode/100 == class {
+				return true, nil
+			}
+			continue
+		}
+
+		if strings.Contains(chunk, "-") {
+			bounds := strings.SplitN(chunk, "-", 2)
+
+			low, err := strconv.Atoi(strings.TrimSpace(bounds[0]))
+			if err != nil {
+				return false, fmt.Errorf("Invalid status code range: %v", chunk)
+			}
+			high, err := strconv.Atoi(strings.TrimSpace(bounds[1]))
+			if err != nil {
+				return false, fmt.Errorf("Invalid status code range: %v", chunk)
+			}
+			if statusCode >= low && statusCode <= high {
+				return true, nil
+			}
+			continue
+		}
+
+		code, err := strconv.Atoi(chunk)
+		if err != nil {
+			return false, fmt.Errorf("Invalid status code: %v", chunk)
+		}
+		if statusCode == code {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// JSONPathLookup walks a JSON document using a simple JSONPath, e.g. "$.data.items[0].status".
+// The second value is false when the path does not exist.
+func JSONPathLookup(document []byte, path string) (interface{}, bool, error) {
+	var current interface{}
+
+	err := json.Unmarshal(document, &current)
+	if err != nil {
+		return nil, false, err
+	}
+
+	path = strings.TrimPrefix(strings.TrimSpace(path), "$")
+
+	// Rewrite indexes as path segments: a[0].b -> a.0.b
+	path = strings.Replace(path, "[", ".", -1)
+	path = strings.Replace(path, "]", "", -1)
+
+	for _, segment := range strings.Split(path, ".") {
+		if segment == "" {
+			continue
+		}
+
+		switch node := current.(type) {
+		case map[string]interface{}:
+			value, ok := node[segment]
+			if !ok {
+				return nil, false, nil
+			}
+			current = value
+
+		case []interface{}:
+			index, err := strconv.Atoi(segment)
+			if err != nil || index < 0 || index >= len(node) {
+				return nil, false, nil
+			}
+			current = node[index]
+
+		default:
+			return nil, false, nil
+		}
+	}
+
+	return current, true, nil
+}
+
+// CheckHTTPBody applies the body assertions of expression to body.
+func (evaluator *CheckExpressionEvaluator) CheckHTTPBody(expression pg.CheckExpression, body []byte) error {
+	if expression.HTTPBodyContains != "" && !strings.Contains(string(body), expression.HTTPBodyContains) {
+		return fmt.Errorf("Body does not contain: %v", expression.HTTPBodyContains)
+	}
+
+	if expression.HTTPBodyRegex != "" {
+		matched, err := regexp.Match(expression.HTTPBodyRegex, body)
+		if err != nil {
+			return err
+		}
+		if !matched {
+			return fmt.Errorf("Body does not match: %v", expression.HTTPBodyRegex)
+		}
+	}
+
+	if expression.HTTPJSONPath != "" {
+		value, found, err := JSONPathLookup(body, expression.HTTPJSONPath)
+		if err != nil {
+			return fmt.Errorf("Body is not valid JSON: %v", err)
+		}
+		if !found {
+			return fmt.Errorf("%v does not exist in body", expression.HTTPJSONPath)
+		}
+		if expression.HTTPJSONPathValue != "" && fmt.Sprintf("%v", value) != expression.HTTPJSONPathValue {
+			return fmt.Errorf("%v is %v, expected %v", expression.HTTPJSONPath, value, expression.HTTPJSONPathValue)
+		}
+	}
+
+	return nil
+}
+
+// httpMaxResponseTime returns 0 when the expression has no response time limit.
+func httpMaxResponseTime(expression pg.CheckExpression) time.Duration {
+	maxResponseTime, err := time.ParseDuration(expression.HTTPMaxResponseTime)
+	if err != nil || maxResponseTime <= 0 {
+		return 0
+	}
+	return maxResponseTime
+}
@@ -0,0 +1,164 @@
+package check_expression
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/resourced/resourced-master/models/pg"
+)
+
+func TestStatusCodeAccepted(t *testing.T) {
+	cases := []struct {
+		statusCodes string
+		statusCode  int
+		accepted    bool
+	}{
+		{"", 200, true},
+		{"", 204, false},
+		{"200,204", 204, true},
+		{"2xx", 299, true},
+		{"2xx", 301, false},
+		{"200-399", 302, true},
+		{"200-399", 404, false},
+	}
+
+	for _, c := range cases {
+		accepted, err := StatusCodeAccepted(c.statusCodes, c.statusCode)
+		if err != nil {
+			t.Fatalf("Parsing %v should work. Error: %v", c.statusCodes, err)
+		}
+		if accepted != c.accepted {
+			t.Errorf("Status code %v with %q should be accepted: %v", c.statusCode, c.statusCodes, c.accepted)
+		}
+	}
+
+	_, err := StatusCodeAccepted("ok", 200)
+	if err == nil {
+		t.Errorf("Invalid status codes should return an error")
+	}
+}
+
+func TestJSONPathLookup(t *testing.T) {
+	document := []byte(`{"status": "ok", "data": {"items": [{"count": 3}]}}`)
+
+	value, found, err := JSONPathLookup(document, "$.data.items[0].count")
+	if err != nil || !found {
+		t.Fatalf("Path should exist. Error: %v", err)
+	}
+	if value.(float64) != 3 {
+		t.Errorf("Value should be 3. Got: %v", value)
+	}
+
+	_, found, _ = JSONPathLookup(document, "$.data.items[1]")
+	if found {
+		t.Errorf("Out of range index should not be found")
+	}
+}
+
+func TestCheckEvalHTTPExpressionAssertions(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/health":
+			w.Write([]byte(`{"status": "ok"}`))
+		case "/slow":
+			time.Sleep(50 * time.Millisecond)
+			w.Write([]byte(`slow`))
+		case "/moved":
+			http.Redirect(w, r, "/health", http.StatusFound)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	serverURL, _ := url.Parse(server.URL)
+
+	checkRow := checkRowForNetworkTest(`["127.0.0.1"]`)
+	evaluator := CheckExpressionEvaluator{}
+
+	expression := pg.CheckExpression{}
+	expression.Type = "HTTP"
+	expression.Protocol = "http"
+	expression.MinHost = 1
+	expression.Port = serverURL.Port()
+	expression.HTTPMethod = "GET"
+	expression.HTTPPath = "/health"
+	expression.HTTPBodyContains = "ok"
+	expression.HTTPJSONPath = "$.status"
+	expression.HTTPJSONPathValue = "ok"
+
+	result := evaluator.EvalHTTPExpression(checkRow, nil, expression)
+	if result.Result.Value != false {
+		t.Fatalf("Expression should not fail when every assertion passes. Message: %v", result.Result.Message)
+	}
+	if _, ok := result.Result.Latencies["127.0.0.1"]; !ok {
+		t.Errorf("Latency should be recorded")
+	}
+
+	expression.HTTPJSONPathValue = "degraded"
+
+	result = evaluator.EvalHTTPExpression(checkRow, nil, expression)
+	if result.Result.Value != true {
+		t.Errorf("Expression should fail when the JSONPath value differs")
+	}
+
+	expression.HTTPJSONPath = ""
+	expression.HTTPJSONPathValue = ""
+	expression.HTTPBodyRegex = `^\{.*"status"`
+
+	result = evaluator.EvalHTTPExpression(checkRow, nil, expression)
+	if result.Result.Value != false {
+		t.Errorf("Expression should not fail when the body matches the regex. Message: %v", result.Result.Message)
+	}
+
+	expression.HTTPBodyContains = ""
+	expression.HTTPBodyRegex = ""
+	expression.HTTPPath = "/missing"
+
+	result = evaluator.EvalHTTPExpression(checkRow, nil, expression)
+	if result.Result.Value != true {
+		t.Errorf("Expression should fail on 404")
+	}
+
+	expression.HTTPStatusCodes = "2xx,404"
+
+	result = evaluator.EvalHTTPExpression(checkRow, nil, expression)
+	if result.Result.Value != false {
+		t.Errorf("Expression should not fail when 404 is accepted. Message: %v", result.Result.Message)
+	}
+
+	expression.HTTPStatusCodes = ""
+	expression.HTTPPath = "/moved"
+
+	result = evaluator.EvalHTTPExpression(checkRow, nil, expression)
+	if result.Result.Value != false {
+		t.Errorf("Redirects should be followed by default. Message: %v", result.Result.Message)
+	}
+
+	expression.HTTPDisableRedirects = true
+
+	result = evaluator.EvalHTTPExpression(checkRow, nil, expression)
+	if result.Result.Value != true {
+		t.Errorf("Expression should fail on 302 when redirects are disabled")
+	}
+
+	expression.HTTPDisableRedirects = false
+	expression.HTTPPath = "/slow"
+	expression.HTTPMaxResponseTime = "10ms"
+
+	result = evaluator.EvalHTTPExpression(checkRow, nil, expression)
+	if result.Result.Value != true {
+		t.Errorf("Expression should fail when the response is slower than HTTPMaxResponseTime")
+	}
+
+	expression.HTTPMaxResponseTime = ""
+	expression.Timeout = "10ms"
+
+	result = evaluator.EvalHTTPExpression(checkRow, nil, expression)
+	if result.Result.Value != true {
+		t.Errorf("Expression should fail when the request times out")
+	}
+}
@@ -0,0 +1,278 @@
+package check_expression
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/resourced/resourced-master/models/pg"
+)
+
+// defaultNetworkTimeout is used when an expression does not define Timeout.
+const defaultNetworkTimeout = 10 * time.Second
+
+func networkTimeout(expression pg.CheckExpression) time.Duration {
+	timeout, err := time.ParseDuration(expression.Timeout)
+	if err != nil || timeout <= 0 {
+		return defaultNetworkTimeout
+	}
+	return timeout
+}
+
+// hostnamesToCheck returns the hosts list of a check, or the hostnames of hostRows when the list is empty.
+func (evaluator *CheckExpressionEvaluator) hostnamesToCheck(checkRow *pg.CheckRow, hostRows []*pg.HostRow) ([]string, error) {
+	hostnames, err := checkRow.GetHostsList()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(hostnames) == 0 && hostRows != nil && len(hostRows) > 0 {
+		hostnames = make([]string, len(hostRows))
+
+		for i, hostRow := range hostRows {
+			hostnames[i] = hostRow.Hostname
+		}
+	}
+
+	return hostnames, nil
+}
+
+// evalPerHost runs checkFunc against every host and applies the MinHost semantics.
+// checkFunc returns a non-nil error when the host is bad.
+func (evaluator *CheckExpressionEvaluator) evalPerHost(checkRow *pg.CheckRow, hostRows []*pg.HostRow, expression pg.CheckExpression, checkFunc func(hostname string) error) pg.CheckExpression {
+	hostnames, err := evaluator.hostnamesToCheck(checkRow, hostRows)
+	if err != nil || len(hostnames) <= 0 {
+		expression.Result.Value = false
+		return expression
+	}
+
+	return evaluator.evalHostnames(hostnames, expression, checkFunc)
+}
+
+// evalHostnames runs checkFunc against every hostname and applies the MinHost semantics.
+func (evaluator *CheckExpressionEvaluator) evalHostnames(hostnames []string, expression pg.CheckExpression, checkFunc func(hostname string) error) pg.CheckExpression {
+	affectedHosts := 0
+	badHostnames := make([]string, 0)
+	goodHostnames := make([]string, 0)
+	messages := make([]string, 0)
+
+	for _, hostname := range hostnames {
+		err := checkFunc(hostname)
+		if err != nil {
+			affectedHosts = affectedHosts + 1
+			badHostnames = append(badHostnames, hostname)
+			messages = append(messages, fmt.Sprintf("%v: %v", hostname, err))
+		} else {
+			goodHostnames = append(goodHostnames, hostname)
+		}
+	}
+
+	expression.Result.Value = affectedHosts >= expression.MinHost
+	expression.Result.BadHostnames = badHostnames
+	expression.Result.GoodHostnames = goodHostnames
+	expression.Result.Message = strings.Join(messages, "\n")
+
+	return expression
+}
+
+func (evaluator *CheckExpressionEvaluator) CheckTCP(hostname, port string, timeout time.Duration) error {
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(hostname, port), timeout)
+	if err != nil {
+		return err
+	}
+
+	return conn.Close()
+}
+
+// EvalTCPExpression fails a host when a TCP connection to Port cannot be opened within Timeout.
+func (evaluator *CheckExpressionEvaluator) EvalTCPExpression(checkRow *pg.CheckRow, hostRows []*pg.HostRow, expression pg.CheckExpression) pg.CheckExpression {
+	timeout := networkTimeout(expression)
+
+	return evaluator.evalPerHost(checkRow, hostRows, expression, func(hostname string) error {
+		return evaluator.CheckTCP(hostname, expression.Port, timeout)
+	})
+}
+
+// CheckDNS resolves name and returns the records of recordType, sorted.
+// When nameserver is empty, the system resolver is used.
+func (evaluator *CheckExpressionEvaluator) CheckDNS(nameserver, port, name, recordType string, timeout time.Duration) ([]string, error) {
+	resolver := net.DefaultResolver
+
+	if nameserver != "" {
+		if port == "" {
+			port = "53"
+		}
+
+		resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+				dialer := net.Dialer{Timeout: timeout}
+				return dialer.DialContext(ctx, network, net.JoinHostPort(nameserver, port))
+			},
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	records := make([]string, 0)
+
+	switch strings.ToUpper(recordType) {
+	case "", "A", "AAAA":
+		addrs, err := resolver.LookupIPAddr(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, addr := range addrs {
+			isIPv4 := addr.IP.To4() != nil
+
+			if (strings.ToUpper(recordType) == "A" && !isIPv4) || (strings.ToUpper(recordType) == "AAAA" && isIPv4) {
+				continue
+			}
+			records = append(records, addr.IP.String())
+		}
+
+	case "CNAME":
+		cname, err := resolver.LookupCNAME(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, strings.TrimSuffix(cname, "."))
+
+	case "MX":
+		mxs, err := resolver.LookupMX(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		for _, mx := range mxs {
+			records = append(records, strings.TrimSuffix(mx.Host, "."))
+		}
+
+	case "NS":
+		nss, err := resolver.LookupNS(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		for _, ns := range nss {
+			records = append(records, strings.TrimSuffix(ns.Host, "."))
+		}
+
+	case "TXT":
+		txts, err := resolver.LookupTXT(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, txts...)
+
+	default:
+		return nil, fmt.Errorf("Unsupported DNS record type: %v", recordType)
+	}
+
+	if len(records) == 0 {
+		return nil, fmt.Errorf("No %v records for %v", recordType, name)
+	}
+
+	sort.Strings(records)
+
+	return records, nil
+}
+
+// EvalDNSExpression fails a host when DNSName does not resolve, or when any of DNSExpected is missing from the answer.
+// DNSExpected is a comma or newline separated list of records.
+func (evaluator *CheckExpressionEvaluator) EvalDNSExpression(checkRow *pg.CheckRow, hostRows []*pg.HostRow, expression pg.CheckExpression) pg.CheckExpression {
+	timeout := networkTimeout(expression)
+
+	expected := make([]string, 0)
+	for _, line := range strings.Split(expression.DNSExpected, "\n") {
+		for _, record := range strings.Split(line, ",") {
+			record = strings.TrimSuffix(strings.TrimSpace(record), ".")
+			if record != "" {
+				expected = append(expected, record)
+			}
+		}
+	}
+
+	return evaluator.evalPerHost(checkRow, hostRows, expression, func(hostname string) error {
+		var records []string
+		var err error
+
+		if expression.DNSName == "" {
+			records, err = evaluator.CheckDNS("", "", hostname, expression.DNSRecordType, timeout)
+		} else {
+			records, err = evaluator.CheckDNS(hostname, expression.Port, expression.DNSName, expression.DNSRecordType, timeout)
+		}
+		if err != nil {
+			return err
+		}
+
+		for _, record := range expected {
+			found := false
+
+			for _, actual := range records {
+				if strings.EqualFold(record, actual) {
+					found = true
+					break
+				}
+			}
+
+			if !found {
+				return fmt.Errorf("Expected record %v, got: %v", record, strings.Join(records, ", "))
+			}
+		}
+
+		return nil
+	})
+}
+
+// CheckTLSCert performs a TLS handshake, verifying the chain and the server name, and returns the leaf certificate.
+// rootCAs nil means the system roots.
+func (evaluator *CheckExpressionEvaluator) CheckTLSCert(hostname, port, serverName string, rootCAs *x509.CertPool, timeout time.Duration) (*x509.Certificate, error) {
+	if port == "" {
+		port = "443"
+	}
+	if serverName == "" {
+		serverName = hostname
+	}
+
+	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: timeout}, "tcp", net.JoinHostPort(hostname, port), &tls.Config{
+		ServerName: serverName,
+		RootCAs:    rootCAs,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	certs := conn.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("No certificate presented")
+	}
+
+	return certs[0], nil
+}
+
+// EvalTLSCertExpression fails a host when its certificate chain or name is invalid,
+// or when the certificate expires within CertExpiryDays.
+func (evaluator *CheckExpressionEvaluator) EvalTLSCertExpression(checkRow *pg.CheckRow, hostRows []*pg.HostRow, expression pg.CheckExpression) pg.CheckExpression {
+	timeout := networkTimeout(expression)
+
+	return evaluator.evalPerHost(checkRow, hostRows, expression, func(hostname string) error {
+		cert, err := evaluator.CheckTLSCert(hostname, expression.Port, expression.TLSServerName, evaluator.TLSRootCAs, timeout)
+		if err != nil {
+			return err
+		}
+
+		expiresIn := cert.NotAfter.Sub(time.Now())
+		if expiresIn < time.Duration(expression.CertExpiryDays)*24*time.Hour {
+			return fmt.Errorf("Certificate expires in %v days, on %v", int(expiresIn.Hours()/24), cert.NotAfter.UTC().Format("2006-01-02"))
+		}
+
+		return nil
+	})
+}
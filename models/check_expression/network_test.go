@@ -0,0 +1,202 @@
+package check_expression
+
+import (
+	"crypto/x509"
+	"encoding/binary"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/resourced/resourced-master/models/pg"
+)
+
+func checkRowForNetworkTest(hostnames string) *pg.CheckRow {
+	checkRow := &pg.CheckRow{}
+	checkRow.HostsList = []byte(hostnames)
+	return checkRow
+}
+
+// serveDNSForTest answers every A question with ip, and every other question with an empty answer.
+func serveDNSForTest(t *testing.T, ip net.IP) (string, func()) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listening on UDP should work. Error: %v", err)
+	}
+
+	go func() {
+		buf := make([]byte, 512)
+
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+
+			// Skip the question name, then QTYPE and QCLASS.
+			questionEnd := 12
+			for questionEnd < n && buf[questionEnd] != 0 {
+				questionEnd = questionEnd + int(buf[questionEnd]) + 1
+			}
+			questionEnd = questionEnd + 5
+			if questionEnd > n {
+				continue
+			}
+
+			qtype := binary.BigEndian.Uint16(buf[questionEnd-4 : questionEnd-2])
+
+			response := make([]byte, 0, 512)
+			response = append(response, buf[0], buf[1], 0x81, 0x80, 0, 1)
+
+			if qtype == 1 {
+				response = append(response, 0, 1)
+			} else {
+				response = append(response, 0, 0)
+			}
+
+			response = append(response, 0, 0, 0, 0)
+			response = append(response, buf[12:questionEnd]...)
+
+			if qtype == 1 {
+				response = append(response, 0xc0, 0x0c, 0, 1, 0, 1, 0, 0, 0, 60, 0, 4)
+				response = append(response, ip.To4()...)
+			}
+
+			conn.WriteTo(response, addr)
+		}
+	}()
+
+	_, port, _ := net.SplitHostPort(conn.LocalAddr().String())
+
+	return port, func() { conn.Close() }
+}
+
+func TestCheckEvalTCPExpression(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listening on TCP should work. Error: %v", err)
+	}
+
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+
+	checkRow := checkRowForNetworkTest(`["127.0.0.1"]`)
+	evaluator := CheckExpressionEvaluator{}
+
+	expression := pg.CheckExpression{}
+	expression.Type = "TCP"
+	expression.MinHost = 1
+	expression.Port = port
+	expression.Timeout = "1s"
+
+	result := evaluator.EvalTCPExpression(checkRow, nil, expression)
+	if result.Result.Value != false {
+		t.Fatalf("Expression should not fail while the port is listening. Message: %v", result.Result.Message)
+	}
+	if len(result.Result.GoodHostnames) != 1 {
+		t.Errorf("127.0.0.1 should be a good host. GoodHostnames: %v", result.Result.GoodHostnames)
+	}
+
+	listener.Close()
+
+	result = evaluator.EvalTCPExpression(checkRow, nil, expression)
+	if result.Result.Value != true {
+		t.Fatalf("Expression should fail once the port is closed")
+	}
+	if len(result.Result.BadHostnames) != 1 {
+		t.Errorf("127.0.0.1 should be a bad host. BadHostnames: %v", result.Result.BadHostnames)
+	}
+
+	expression.MinHost = 2
+
+	result = evaluator.EvalTCPExpression(checkRow, nil, expression)
+	if result.Result.Value != false {
+		t.Errorf("Expression should not fail when fewer than MinHost hosts are bad")
+	}
+}
+
+func TestCheckEvalDNSExpression(t *testing.T) {
+	port, closeDNS := serveDNSForTest(t, net.ParseIP("10.0.0.1"))
+	defer closeDNS()
+
+	checkRow := checkRowForNetworkTest(`["127.0.0.1"]`)
+	evaluator := CheckExpressionEvaluator{}
+
+	expression := pg.CheckExpression{}
+	expression.Type = "DNS"
+	expression.MinHost = 1
+	expression.Port = port
+	expression.Timeout = "2s"
+	expression.DNSName = "db.example.com."
+	expression.DNSRecordType = "A"
+	expression.DNSExpected = "10.0.0.1"
+
+	result := evaluator.EvalDNSExpression(checkRow, nil, expression)
+	if result.Result.Value != false {
+		t.Fatalf("Expression should not fail when the expected record is returned. Message: %v", result.Result.Message)
+	}
+
+	expression.DNSExpected = "10.0.0.1, 10.0.0.2"
+
+	result = evaluator.EvalDNSExpression(checkRow, nil, expression)
+	if result.Result.Value != true {
+		t.Fatalf("Expression should fail when an expected record is missing")
+	}
+
+	expression.DNSExpected = ""
+	expression.DNSRecordType = "AAAA"
+
+	result = evaluator.EvalDNSExpression(checkRow, nil, expression)
+	if result.Result.Value != true {
+		t.Fatalf("Expression should fail when there are no records of the requested type")
+	}
+}
+
+func TestCheckEvalTLSCertExpression(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	serverURL, _ := url.Parse(server.URL)
+
+	rootCAs := x509.NewCertPool()
+	rootCAs.AddCert(server.Certificate())
+
+	checkRow := checkRowForNetworkTest(`["127.0.0.1"]`)
+	evaluator := CheckExpressionEvaluator{TLSRootCAs: rootCAs}
+
+	expression := pg.CheckExpression{}
+	expression.Type = "TLSCert"
+	expression.MinHost = 1
+	expression.Port = serverURL.Port()
+	expression.Timeout = "2s"
+	expression.CertExpiryDays = 30
+
+	result := evaluator.EvalTLSCertExpression(checkRow, nil, expression)
+	if result.Result.Value != false {
+		t.Fatalf("Expression should not fail on a valid certificate. Message: %v", result.Result.Message)
+	}
+
+	// The test certificate expires long before 100 years from now.
+	expression.CertExpiryDays = 365 * 100
+
+	result = evaluator.EvalTLSCertExpression(checkRow, nil, expression)
+	if result.Result.Value != true {
+		t.Fatalf("Expression should fail when the certificate expires within CertExpiryDays")
+	}
+
+	expression.CertExpiryDays = 30
+	expression.TLSServerName = "wrong.test"
+
+	result = evaluator.EvalTLSCertExpression(checkRow, nil, expression)
+	if result.Result.Value != true {
+		t.Fatalf("Expression should fail when the hostname does not match the certificate")
+	}
+
+	expression.TLSServerName = ""
+	evaluator.TLSRootCAs = nil
+
+	result = evaluator.EvalTLSCertExpression(checkRow, nil, expression)
+	if result.Result.Value != true {
+		t.Fatalf("Expression should fail when the chain is not trusted")
+	}
+}
@@ -0,0 +1,84 @@
+package check_expression
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/resourced/resourced-master/models/pg"
+	"github.com/resourced/resourced-master/models/shims"
+)
+
+// lastValueLookback is how far back the last_value policy looks for a value a host stopped reporting.
+const lastValueLookback = 24 * time.Hour
+
+// NoDataVerdict tells how a host without data counts under a no data policy: bad, good, or neither.
+func NoDataVerdict(policy string) (bad bool, good bool) {
+	switch policy {
+	case pg.NoDataPolicyFail:
+		return true, false
+	case pg.NoDataPolicyUnknown, pg.NoDataPolicyLastValue:
+		return false, false
+	}
+
+	return false, true
+}
+
+// noDataMessage reports the hosts without data of an expression.
+func noDataMessage(expression pg.CheckExpression, noDataHostnames []string) string {
+	if len(noDataHostnames) == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf("No data for %v (policy: %v): %v", expression.Metric, expression.GetNoDataPolicy(), strings.Join(noDataHostnames, ", "))
+}
+
+// hostDataValue returns the current value of the expression's Metric on a host.
+// Data older than MaxDataAge counts as missing, unless the policy is last_value.
+// The second value is false when the host has no data.
+func (evaluator *CheckExpressionEvaluator) hostDataValue(checkRow *pg.CheckRow, hostRow *pg.HostRow, expression pg.CheckExpression, now time.Time) (float64, bool) {
+	val, ok := hostMetricValue(hostRow, expression.Metric)
+
+	if ok && !expression.IsHostDataStale(hostRow.Updated, now) {
+		return val, true
+	}
+
+	if expression.GetNoDataPolicy() != pg.NoDataPolicyLastValue {
+		return 0, false
+	}
+
+	// Stale data is still the last value the host reported.
+	if ok {
+		return val, true
+	}
+
+	return evaluator.lastReportedValue(checkRow, hostRow.Hostname, expression.Metric, now)
+}
+
+// lastReportedValue returns the newest value of metric stored for a host within lastValueLookback.
+func (evaluator *CheckExpressionEvaluator) lastReportedValue(checkRow *pg.CheckRow, hostname, metricKey string, now time.Time) (float64, bool) {
+	metric, err := pg.NewMetric(evaluator.AppContext).GetByClusterIDAndKey(nil, checkRow.ClusterID, metricKey)
+	if err != nil {
+		return 0, false
+	}
+
+	clusterRow, err := pg.NewCluster(evaluator.AppContext).GetByID(nil, checkRow.ClusterID)
+	if err != nil {
+		return 0, false
+	}
+
+	deletedFrom := clusterRow.GetDeletedFromUNIXTimestampForSelect("ts_metrics")
+
+	payload, err := shims.NewTSMetric(evaluator.AppContext, checkRow.ClusterID).AllByMetricIDHostAndRangeForHighchart(checkRow.ClusterID, metric.ID, hostname, now.Add(-lastValueLookback).Unix(), now.Unix(), deletedFrom, 0)
+	if err != nil || payload == nil {
+		return 0, false
+	}
+
+	// Points are oldest first.
+	values := pointValues(payload.Data)
+	if len(values) == 0 {
+		return 0, false
+	}
+
+	return values[len(values)-1], true
+}
@@ -0,0 +1,93 @@
+package check_expression
+
+import (
+	"testing"
+	"time"
+
+	"github.com/resourced/resourced-master/models/pg"
+)
+
+func hostRowsForNoDataTest() []*pg.HostRow {
+	now := time.Now().UTC()
+
+	fresh := &pg.HostRow{Hostname: "fresh", Updated: now}
+	fresh.Data = []byte(`{"/free": {"Memory": {"Free": 1024}}}`)
+
+	missing := &pg.HostRow{Hostname: "missing", Updated: now}
+	missing.Data = []byte(`{"/uptime": {"Seconds": 60}}`)
+
+	stale := &pg.HostRow{Hostname: "stale", Updated: now.Add(-time.Hour)}
+	stale.Data = []byte(`{"/free": {"Memory": {"Free": 1024}}}`)
+
+	return []*pg.HostRow{fresh, missing, stale}
+}
+
+func rawHostDataExpressionForNoDataTest(policy string) pg.CheckExpression {
+	expression := pg.CheckExpression{}
+	expression.Type = "RawHostData"
+	expression.Metric = "/free.Memory.Free"
+	expression.Operator = "<"
+	expression.Value = 100
+	expression.MinHost = 1
+	expression.NoDataPolicy = policy
+	expression.MaxDataAge = "10m"
+	return expression
+}
+
+func TestNoDataVerdict(t *testing.T) {
+	for policy, expected := range map[string][2]bool{
+		pg.NoDataPolicyOK:        {false, true},
+		"":                       {false, true},
+		pg.NoDataPolicyFail:      {true, false},
+		pg.NoDataPolicyUnknown:   {false, false},
+		pg.NoDataPolicyLastValue: {false, false},
+	} {
+		bad, good := NoDataVerdict(policy)
+		if bad != expected[0] || good != expected[1] {
+			t.Errorf("Policy %q should count hosts without data as bad: %v, good: %v", policy, expected[0], expected[1])
+		}
+	}
+}
+
+func TestEvalRawHostDataExpressionNoDataPolicy(t *testing.T) {
+	evaluator := &CheckExpressionEvaluator{}
+	checkRow := &pg.CheckRow{}
+
+	// A missing metric used to read as 0, which is less than 100.
+	result := evaluator.EvalRawHostDataExpression(checkRow, hostRowsForNoDataTest(), rawHostDataExpressionForNoDataTest(pg.NoDataPolicyOK))
+	if result.Result.Value || len(result.Result.BadHostnames) != 0 || len(result.Result.GoodHostnames) != 3 {
+		t.Errorf("Hosts without data should pass. Result: %+v", result.Result)
+	}
+	if len(result.Result.NoDataHostnames) != 2 || result.Result.NoDataHostnames[0] != "missing" || result.Result.NoDataHostnames[1] != "stale" {
+		t.Errorf("Missing and stale hosts should have no data. NoDataHostnames: %v", result.Result.NoDataHostnames)
+	}
+	if result.Result.Message == "" {
+		t.Errorf("Hosts without data should be reported in the message")
+	}
+
+	result = evaluator.EvalRawHostDataExpression(checkRow, hostRowsForNoDataTest(), rawHostDataExpressionForNoDataTest(pg.NoDataPolicyFail))
+	if !result.Result.Value || len(result.Result.BadHostnames) != 2 || len(result.Result.GoodHostnames) != 1 {
+		t.Errorf("Hosts without data should fail. Result: %+v", result.Result)
+	}
+
+	result = evaluator.EvalRawHostDataExpression(checkRow, hostRowsForNoDataTest(), rawHostDataExpressionForNoDataTest(pg.NoDataPolicyUnknown))
+	if result.Result.Value || len(result.Result.BadHostnames) != 0 || len(result.Result.GoodHostnames) != 1 || len(result.Result.NoDataHostnames) != 2 {
+		t.Errorf("Hosts without data should be neither good nor bad. Result: %+v", result.Result)
+	}
+}
+
+func TestHostDataValueLastValue(t *testing.T) {
+	evaluator := &CheckExpressionEvaluator{}
+	hostRows := hostRowsForNoDataTest()
+	expression := rawHostDataExpressionForNoDataTest(pg.NoDataPolicyLastValue)
+
+	value, ok := evaluator.hostDataValue(&pg.CheckRow{}, hostRows[2], expression, time.Now().UTC())
+	if !ok || value != 1024 {
+		t.Errorf("Stale hosts should be judged by their last value. Value: %v", value)
+	}
+
+	_, ok = evaluator.hostDataValue(&pg.CheckRow{}, hostRows[2], rawHostDataExpressionForNoDataTest(pg.NoDataPolicyOK), time.Now().UTC())
+	if ok {
+		t.Errorf("Stale hosts should have no data unless the policy is last_value")
+	}
+}
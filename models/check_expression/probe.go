@@ -0,0 +1,157 @@
+package check_expression
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Prober checks that a host answers, and returns the round-trip time.
+type Prober interface {
+	Probe(hostname, port string, timeout time.Duration) (time.Duration, error)
+}
+
+// errICMPUnavailable means the ICMP socket could not be opened, e.g. net.ipv4.ping_group_range excludes this process.
+var errICMPUnavailable = errors.New("ICMP datagram sockets are unavailable")
+
+// ICMPProber sends one ICMP echo request through an unprivileged datagram socket.
+// When the socket cannot be opened, it falls back to a TCP connection on port, or FallbackPort when port is empty.
+type ICMPProber struct {
+	FallbackPort string
+}
+
+func (p *ICMPProber) Probe(hostname, port string, timeout time.Duration) (time.Duration, error) {
+	ipAddr, err := net.ResolveIPAddr("ip4", hostname)
+	if err == nil {
+		rtt, err := pingICMP(ipAddr.IP, timeout)
+		if err != errICMPUnavailable {
+			return rtt, err
+		}
+	}
+
+	if port == "" {
+		port = p.FallbackPort
+	}
+	if port == "" {
+		port = "80"
+	}
+
+	return probeTCPReachable(hostname, port, timeout)
+}
+
+// probeTCPReachable treats a refused connection as reachable, because the host itself answered.
+func probeTCPReachable(hostname, port string, timeout time.Duration) (time.Duration, error) {
+	start := time.Now()
+
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(hostname, port), timeout)
+	rtt := time.Since(start)
+
+	if err != nil {
+		if opErr, ok := err.(*net.OpError); ok {
+			if sysErr, ok := opErr.Err.(*os.SyscallError); ok && sysErr.Err == syscall.ECONNREFUSED {
+				return rtt, nil
+			}
+		}
+		return rtt, err
+	}
+
+	return rtt, conn.Close()
+}
+
+// icmpChecksum is the RFC 1071 internet checksum.
+func icmpChecksum(b []byte) uint16 {
+	var sum uint32
+
+	for i := 0; i+1 < len(b); i = i + 2 {
+		sum = sum + (uint32(b[i])<<8 | uint32(b[i+1]))
+	}
+	if len(b)%2 == 1 {
+		sum = sum + uint32(b[len(b)-1])<<8
+	}
+
+	for sum>>16 != 0 {
+		sum = (sum & 0xffff) + (sum >> 16)
+	}
+
+	return ^uint16(sum)
+}
+
+// icmpEchoRequest builds an ICMP echo request with the given id and sequence.
+func icmpEchoRequest(id, seq uint16, payload []byte) []byte {
+	message := make([]byte, 8+len(payload))
+	message[0] = 8 // Echo request
+	binary.BigEndian.PutUint16(message[4:6], id)
+	binary.BigEndian.PutUint16(message[6:8], seq)
+	copy(message[8:], payload)
+
+	binary.BigEndian.PutUint16(message[2:4], icmpChecksum(message))
+
+	return message
+}
+
+// SSHProber reads the server identification line and waits for the server's key exchange packet,
+// which proves sshd is serving without requiring credentials.
+type SSHProber struct{}
+
+func (p *SSHProber) Probe(hostname, port string, timeout time.Duration) (time.Duration, error) {
+	if port == "" {
+		port = "22"
+	}
+
+	start := time.Now()
+
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(hostname, port), timeout)
+	if err != nil {
+		return time.Since(start), err
+	}
+	defer conn.Close()
+
+	conn.SetDeadline(start.Add(timeout))
+
+	reader := bufio.NewReader(conn)
+
+	// Servers may send other lines before the identification string.
+	for i := 0; ; i++ {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return time.Since(start), fmt.Errorf("Unable to read SSH banner: %v", err)
+		}
+		if strings.HasPrefix(line, "SSH-") {
+			if !strings.HasPrefix(line, "SSH-2.0-") && !strings.HasPrefix(line, "SSH-1.99-") {
+				return time.Since(start), fmt.Errorf("Unsupported SSH version: %v", strings.TrimSpace(line))
+			}
+			break
+		}
+		if i >= 20 {
+			return time.Since(start), errors.New("No SSH banner received")
+		}
+	}
+
+	rtt := time.Since(start)
+
+	_, err = conn.Write([]byte("SSH-2.0-ResourcedMaster\r\n"))
+	if err != nil {
+		return rtt, err
+	}
+
+	// Binary packet: uint32 packet_length, byte padding_length, then the payload starting with the message number.
+	header := make([]byte, 6)
+	_, err = io.ReadFull(reader, header)
+	if err != nil {
+		return rtt, fmt.Errorf("Unable to read SSH key exchange: %v", err)
+	}
+
+	const sshMsgKexInit = 20
+	if header[5] != sshMsgKexInit {
+		return rtt, fmt.Errorf("Unexpected SSH message: %v", header[5])
+	}
+
+	return rtt, nil
+}
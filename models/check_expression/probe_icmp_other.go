@@ -0,0 +1,14 @@
+//go:build !linux && !darwin
+// +build !linux,!darwin
+
+package check_expression
+
+import (
+	"net"
+	"time"
+)
+
+// pingICMP is not implemented on this platform, so ICMPProber always uses its TCP fallback.
+func pingICMP(ip net.IP, timeout time.Duration) (time.Duration, error) {
+	return 0, errICMPUnavailable
+}
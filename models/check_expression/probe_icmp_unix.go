@@ -0,0 +1,78 @@
+//go:build linux || darwin
+// +build linux darwin
+
+package check_expression
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"os"
+	"syscall"
+	"time"
+)
+
+// pingICMP sends one echo request to ip over a SOCK_DGRAM ICMP socket, which does not require root.
+func pingICMP(ip net.IP, timeout time.Duration) (time.Duration, error) {
+	ip4 := ip.To4()
+	if ip4 == nil {
+		return 0, errICMPUnavailable
+	}
+
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_DGRAM, syscall.IPPROTO_ICMP)
+	if err != nil {
+		return 0, errICMPUnavailable
+	}
+	defer syscall.Close(fd)
+
+	tv := syscall.NsecToTimeval(timeout.Nanoseconds())
+
+	err = syscall.SetsockoptTimeval(fd, syscall.SOL_SOCKET, syscall.SO_RCVTIMEO, &tv)
+	if err != nil {
+		return 0, err
+	}
+
+	// Linux rewrites the id with the socket's own, so replies are matched on the sequence.
+	seq := uint16(time.Now().UnixNano())
+	request := icmpEchoRequest(uint16(os.Getpid()), seq, []byte("resourced"))
+
+	addr := &syscall.SockaddrInet4{}
+	copy(addr.Addr[:], ip4)
+
+	start := time.Now()
+
+	err = syscall.Sendto(fd, request, 0, addr)
+	if err != nil {
+		return 0, err
+	}
+
+	reply := make([]byte, 1500)
+
+	for {
+		n, _, err := syscall.Recvfrom(fd, reply, 0)
+		if err != nil {
+			if err == syscall.EAGAIN || err == syscall.EWOULDBLOCK {
+				return time.Since(start), errors.New("ICMP echo request timed out")
+			}
+			if err == syscall.EINTR {
+				continue
+			}
+			return time.Since(start), err
+		}
+
+		message := reply[:n]
+
+		// Darwin includes the IP header.
+		if len(message) >= 20 && message[0]>>4 == 4 {
+			message = message[int(message[0]&0x0f)*4:]
+		}
+
+		if len(message) >= 8 && message[0] == 0 && binary.BigEndian.Uint16(message[6:8]) == seq {
+			return time.Since(start), nil
+		}
+
+		if time.Since(start) > timeout {
+			return time.Since(start), errors.New("ICMP echo request timed out")
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package check_expression
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/resourced/resourced-master/models/pg"
+)
+
+type fakeProber struct {
+	rtts map[string]time.Duration
+}
+
+func (p *fakeProber) Probe(hostname, port string, timeout time.Duration) (time.Duration, error) {
+	rtt, ok := p.rtts[hostname]
+	if !ok {
+		return 0, errors.New("unreachable")
+	}
+	return rtt, nil
+}
+
+func TestCheckEvalPingExpressionWithFakeProber(t *testing.T) {
+	checkRow := checkRowForNetworkTest(`["up.example.com", "down.example.com"]`)
+
+	evaluator := CheckExpressionEvaluator{
+		PingProber: &fakeProber{rtts: map[string]time.Duration{"up.example.com": 3 * time.Millisecond}},
+	}
+
+	expression := pg.CheckExpression{}
+	expression.Type = "Ping"
+	expression.MinHost = 1
+
+	result := evaluator.EvalPingExpression(checkRow, nil, expression)
+	if result.Result.Value != true {
+		t.Fatalf("Expression should fail because down.example.com is unreachable")
+	}
+	if len(result.Result.BadHostnames) != 1 || result.Result.BadHostnames[0] != "down.example.com" {
+		t.Errorf("down.example.com should be the only bad host. BadHostnames: %v", result.Result.BadHostnames)
+	}
+	if result.Result.Latencies["up.example.com"] != 3 {
+		t.Errorf("Latency of up.example.com should be 3ms. Got: %v", result.Result.Latencies["up.example.com"])
+	}
+
+	expression.MinHost = 2
+
+	result = evaluator.EvalPingExpression(checkRow, nil, expression)
+	if result.Result.Value != false {
+		t.Errorf("Expression should not fail when fewer than MinHost hosts are bad")
+	}
+}
+
+func TestICMPProberLocalhost(t *testing.T) {
+	prober := &ICMPProber{}
+
+	// Whether ICMP datagram sockets are allowed or not, localhost must be reachable.
+	_, err := prober.Probe("127.0.0.1", "1", time.Second)
+	if err != nil {
+		t.Errorf("127.0.0.1 should be reachable. Error: %v", err)
+	}
+}
+
+func TestSSHProber(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listening on TCP should work. Error: %v", err)
+	}
+	defer listener.Close()
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			conn.Write([]byte("SSH-2.0-OpenSSH_7.2\r\n"))
+
+			buf := make([]byte, 256)
+			conn.Read(buf)
+
+			// packet_length, padding_length, SSH_MSG_KEXINIT
+			conn.Write([]byte{0, 0, 0, 12, 4, 20, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0})
+			conn.Close()
+		}
+	}()
+
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+
+	prober := &SSHProber{}
+
+	_, err = prober.Probe("127.0.0.1", port, time.Second)
+	if err != nil {
+		t.Errorf("SSH probe should succeed. Error: %v", err)
+	}
+
+	httpListener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listening on TCP should work. Error: %v", err)
+	}
+	defer httpListener.Close()
+
+	go func() {
+		conn, err := httpListener.Accept()
+		if err != nil {
+			return
+		}
+		conn.Write([]byte("HTTP/1.1 400 Bad Request\r\n\r\n"))
+		time.Sleep(200 * time.Millisecond)
+		conn.Close()
+	}()
+
+	_, port, _ = net.SplitHostPort(httpListener.Addr().String())
+
+	_, err = prober.Probe("127.0.0.1", port, 100*time.Millisecond)
+	if err == nil {
+		t.Errorf("SSH probe should fail against a service that is not sshd")
+	}
+}
+
+func TestICMPChecksum(t *testing.T) {
+	request := icmpEchoRequest(1, 1, []byte("resourced"))
+
+	if icmpChecksum(request) != 0 {
+		t.Errorf("Checksum over a message that includes its checksum should be 0")
+	}
+}
@@ -0,0 +1,277 @@
+package check_expression
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/resourced/resourced-master/models/pg"
+)
+
+var syntheticVariableRegexp = regexp.MustCompile(`\$\{([A-Za-z0-9_.-]+)\}`)
+
+// ExpandSyntheticVariables replaces ${name} with the value of the variable. Unknown variables are left as is.
+func ExpandSyntheticVariables(input string, variables map[string]string) string {
+	return syntheticVariableRegexp.ReplaceAllStringFunc(input, func(match string) string {
+		value, ok := variables[syntheticVariableRegexp.FindStringSubmatch(match)[1]]
+		if !ok {
+			return match
+		}
+		return value
+	})
+}
+
+// ExtractSyntheticVariable reads the value of extract out of a response.
+func ExtractSyntheticVariable(extract pg.SyntheticExtract, header http.Header, body []byte) (string, error) {
+	if extract.Header != "" {
+		value := header.Get(extract.Header)
+		if value == "" {
+			return "", fmt.Errorf("Header %v is missing, %v cannot be extracted", extract.Header, extract.Variable)
+		}
+		return value, nil
+	}
+
+	if extract.JSONPath != "" {
+		value, found, err := JSONPathLookup(body, extract.JSONPath)
+		if err != nil {
+			return "", fmt.Errorf("Body is not valid JSON: %v", err)
+		}
+		if !found {
+			return "", fmt.Errorf("%v does not exist in body, %v cannot be extracted", extract.JSONPath, extract.Variable)
+		}
+		return fmt.Sprintf("%v", value), nil
+	}
+
+	re, err := regexp.Compile(extract.Regex)
+	if err != nil {
+		return "", err
+	}
+
+	matches := re.FindSubmatch(body)
+	if matches == nil {
+		return "", fmt.Errorf("Body does not match %v, %v cannot be extracted", extract.Regex, extract.Variable)
+	}
+	if len(matches) > 1 {
+		return string(matches[1]), nil
+	}
+	return string(matches[0]), nil
+}
+
+// syntheticStepURL expands the URL of a step. URLs starting with "/" are sent to hostname.
+func syntheticStepURL(expression pg.CheckExpression, step pg.SyntheticStep, hostname string, variables map[string]string) string {
+	stepURL := ExpandSyntheticVariables(step.URL, variables)
+	if !strings.HasPrefix(stepURL, "/") {
+		return stepURL
+	}
+
+	scheme := expression.Protocol
+	if scheme == "" {
+		scheme = "http"
+	}
+
+	if expression.Port == "" {
+		return fmt.Sprintf("%v://%v%v", scheme, hostname, stepURL)
+	}
+	return fmt.Sprintf("%v://%v:%v%v", scheme, hostname, expression.Port, stepURL)
+}
+
+// CheckSynthetic runs the steps of expression against hostname, in order, with one cookie jar.
+// It stops at the first failing step, and returns the results of every step it ran.
+func (evaluator *CheckExpressionEvaluator) CheckSynthetic(hostname string, expression pg.CheckExpression) ([]pg.SyntheticStepResult, error) {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	client := &http.Client{Timeout: networkTimeout(expression), Jar: jar}
+
+	if expression.HTTPInsecureSkipVerify {
+		client.Transport = &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		}
+	}
+
+	if expression.HTTPDisableRedirects {
+		client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		}
+	}
+
+	variables := map[string]string{"hostname": hostname}
+	results := make([]pg.SyntheticStepResult, 0, len(expression.SyntheticSteps))
+
+	for i, step := range expression.SyntheticSteps {
+		result := pg.SyntheticStepResult{Name: step.DisplayName(i)}
+
+		err := evaluator.runSyntheticStep(client, expression, step, hostname, variables, &result)
+		if err != nil {
+			result.Error = err.Error()
+			results = append(results, result)
+			return results, fmt.Errorf("%v: %v", result.Name, err)
+		}
+
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// runSyntheticStep sends one step, checks its assertions and stores its extracted variables.
+func (evaluator *CheckExpressionEvaluator) runSyntheticStep(client *http.Client, expression pg.CheckExpression, step pg.SyntheticStep, hostname string, variables map[string]string, result *pg.SyntheticStepResult) error {
+	stepURL := syntheticStepURL(expression, step, hostname, variables)
+
+	_, err := url.Parse(stepURL)
+	if err != nil {
+		return err
+	}
+
+	method := strings.ToUpper(step.HTTPMethod)
+	if method == "" {
+		method = "GET"
+	}
+
+	body := ExpandSyntheticVariables(step.HTTPBody, variables)
+
+	var req *http.Request
+
+	if body != "" {
+		req, err = http.NewRequest(method, stepURL, strings.NewReader(body))
+		if err == nil {
+			if strings.HasPrefix(body, "{") || strings.HasPrefix(body, "[") {
+				req.Header.Set("Content-Type", "application/json")
+			} else {
+				req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			}
+		}
+	} else {
+		req, err = http.NewRequest(method, stepURL, nil)
+	}
+	if err != nil {
+		return err
+	}
+
+	for headerKey, headerVal := range ParseHTTPHeaders(ExpandSyntheticVariables(step.Headers, variables)) {
+		req.Header.Set(headerKey, headerVal)
+	}
+
+	if expression.Username != "" || expression.Password != "" {
+		req.SetBasicAuth(expression.Username, expression.Password)
+	}
+
+	start := time.Now()
+
+	resp, err := client.Do(req)
+	if err != nil {
+		result.Latency = float64(time.Since(start)) / float64(time.Millisecond)
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxHTTPBodyBytes))
+	latency := time.Since(start)
+
+	result.StatusCode = resp.StatusCode
+	result.Latency = float64(latency) / float64(time.Millisecond)
+
+	if err != nil {
+		return err
+	}
+
+	accepted, err := StatusCodeAccepted(step.HTTPStatusCodes, resp.StatusCode)
+	if err != nil {
+		return err
+	}
+	if !accepted {
+		return fmt.Errorf("Unexpected status code: %v", resp.StatusCode)
+	}
+
+	// Steps carry the same assertions as HTTP expressions.
+	assertions := pg.CheckExpression{}
+	assertions.HTTPBodyContains = ExpandSyntheticVariables(step.HTTPBodyContains, variables)
+	assertions.HTTPBodyRegex = step.HTTPBodyRegex
+	assertions.HTTPJSONPath = step.HTTPJSONPath
+	assertions.HTTPJSONPathValue = ExpandSyntheticVariables(step.HTTPJSONPathValue, variables)
+	assertions.HTTPMaxResponseTime = step.HTTPMaxResponseTime
+
+	maxResponseTime := httpMaxResponseTime(assertions)
+	if maxResponseTime > 0 && latency > maxResponseTime {
+		return fmt.Errorf("Response took %v, longer than %v", latency, maxResponseTime)
+	}
+
+	err = evaluator.CheckHTTPBody(assertions, respBody)
+	if err != nil {
+		return err
+	}
+
+	for _, extract := range step.Extract {
+		value, err := ExtractSyntheticVariable(extract, resp.Header, respBody)
+		if err != nil {
+			return err
+		}
+		variables[extract.Variable] = value
+	}
+
+	return nil
+}
+
+// EvalSyntheticExpression runs the steps against every host, and fails a host when any step fails.
+// Checks without hosts run once, against the host of the first step's URL.
+func (evaluator *CheckExpressionEvaluator) EvalSyntheticExpression(checkRow *pg.CheckRow, hostRows []*pg.HostRow, expression pg.CheckExpression) pg.CheckExpression {
+	if len(expression.SyntheticSteps) == 0 {
+		expression.Result.Value = true
+		expression.Result.Message = "Synthetic expression has no steps"
+		return expression
+	}
+
+	hostnames, err := evaluator.hostnamesToCheck(checkRow, hostRows)
+	if err != nil {
+		expression.Result.Value = true
+		expression.Result.Message = err.Error()
+		return expression
+	}
+
+	if len(hostnames) == 0 {
+		firstURL, err := url.Parse(expression.SyntheticSteps[0].URL)
+		if err != nil || firstURL.Hostname() == "" {
+			expression.Result.Value = true
+			expression.Result.Message = "The first step needs an absolute URL when the check has no hosts"
+			return expression
+		}
+		hostnames = []string{firstURL.Hostname()}
+	}
+
+	// Failing steps are bad even when MinHost is left out.
+	if expression.MinHost < 1 {
+		expression.MinHost = 1
+	}
+
+	steps := make(map[string][]pg.SyntheticStepResult)
+	latencies := make(map[string]float64)
+
+	expression = evaluator.evalHostnames(hostnames, expression, func(hostname string) error {
+		results, err := evaluator.CheckSynthetic(hostname, expression)
+
+		steps[hostname] = results
+
+		var total float64
+		for _, result := range results {
+			total = total + result.Latency
+		}
+		latencies[hostname] = total
+
+		return err
+	})
+
+	expression.Result.Steps = steps
+	expression.Result.Latencies = latencies
+
+	return expression
+}
@@ -0,0 +1,109 @@
+package check_expression
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/resourced/resourced-master/models/pg"
+)
+
+func TestExpandSyntheticVariables(t *testing.T) {
+	output := ExpandSyntheticVariables("/users/${user_id}?host=${hostname}&missing=${missing}", map[string]string{"user_id": "42", "hostname": "web-1"})
+	if output != "/users/42?host=web-1&missing=${missing}" {
+		t.Errorf("Variables should be expanded. Got: %v", output)
+	}
+}
+
+func TestCheckEvalSyntheticExpression(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			if r.Method != "POST" {
+				http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc"})
+			w.Write([]byte(`{"token": "t0k=n", "user": {"id": 42}}`))
+
+		case "/users/42":
+			cookie, err := r.Cookie("session")
+			if err != nil || cookie.Value != "abc" || r.Header.Get("Authorization") != "Bearer t0k=n" {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]string{"name": "alice"})
+
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	serverURL, _ := url.Parse(server.URL)
+
+	checkRow := checkRowForNetworkTest(`["127.0.0.1"]`)
+	evaluator := CheckExpressionEvaluator{}
+
+	expression := pg.CheckExpression{}
+	expression.Type = "Synthetic"
+	expression.Protocol = "http"
+	expression.Port = serverURL.Port()
+	expression.SyntheticSteps = []pg.SyntheticStep{
+		{
+			Name:       "login",
+			HTTPMethod: "POST",
+			URL:        "/login",
+			HTTPBody:   "user=alice",
+			Extract: []pg.SyntheticExtract{
+				{Variable: "token", JSONPath: "$.token"},
+				{Variable: "user_id", Regex: `"id": (\d+)`},
+			},
+		},
+		{
+			Name:              "profile",
+			URL:               "/users/${user_id}",
+			Headers:           "Authorization=Bearer ${token}",
+			HTTPJSONPath:      "$.name",
+			HTTPJSONPathValue: "alice",
+		},
+	}
+
+	result := evaluator.EvalSyntheticExpression(checkRow, nil, expression)
+	if result.Result.Value != false {
+		t.Fatalf("Expression should not fail when every step passes. Message: %v", result.Result.Message)
+	}
+
+	steps := result.Result.Steps["127.0.0.1"]
+	if len(steps) != 2 || steps[0].Name != "login" || steps[1].StatusCode != 200 {
+		t.Fatalf("Every step should be reported. Steps: %+v", steps)
+	}
+
+	expression.SyntheticSteps[1].HTTPJSONPathValue = "bob"
+
+	result = evaluator.EvalSyntheticExpression(checkRow, nil, expression)
+	if result.Result.Value != true || !strings.Contains(result.Result.Message, "profile") {
+		t.Errorf("Expression should fail on a failing assertion. Message: %v", result.Result.Message)
+	}
+
+	// A failing step stops the run.
+	expression.SyntheticSteps[0].URL = "/missing"
+
+	result = evaluator.EvalSyntheticExpression(checkRow, nil, expression)
+	steps = result.Result.Steps["127.0.0.1"]
+	if result.Result.Value != true || len(steps) != 1 || steps[0].StatusCode != 404 || steps[0].Error == "" {
+		t.Errorf("Expression should stop at the first failing step. Steps: %+v", steps)
+	}
+
+	// Without hosts, absolute URLs are used as is.
+	expression.SyntheticSteps[0].URL = server.URL + "/login"
+	expression.SyntheticSteps[1].HTTPJSONPathValue = "alice"
+
+	result = evaluator.EvalSyntheticExpression(checkRowForNetworkTest(`[]`), nil, expression)
+	if result.Result.Value != false {
+		t.Errorf("Expression without hosts should run against the first URL. Message: %v", result.Result.Message)
+	}
+}
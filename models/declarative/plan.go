@@ -0,0 +1,163 @@
+package declarative
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+)
+
+const (
+	ActionCreate = "create"
+	ActionUpdate = "update"
+	ActionDelete = "delete"
+
+	KindMetric = "metric"
+	KindGraph  = "graph"
+	KindCheck  = "check"
+)
+
+// Change is one step of a plan.
+type Change struct {
+	Action string
+	Kind   string
+	Name   string
+
+	// Fields are the names of the fields an update changes.
+	Fields []string `json:",omitempty"`
+}
+
+// Plan lists the changes that turn the current state of a cluster into the desired one,
+// in the order they are applied.
+type Plan struct {
+	Changes []Change
+}
+
+// Diff compares two normalized specs.
+func Diff(current, desired *Spec) *Plan {
+	plan := &Plan{Changes: make([]Change, 0)}
+
+	currentMetrics := make(map[string]bool)
+	for _, key := range current.Metrics {
+		currentMetrics[key] = true
+	}
+	for _, key := range desired.Metrics {
+		if !currentMetrics[key] {
+			plan.Changes = append(plan.Changes, Change{Action: ActionCreate, Kind: KindMetric, Name: key})
+		}
+	}
+
+	currentGraphs := make(map[string]GraphSpec)
+	for _, graph := range current.Graphs {
+		currentGraphs[graph.Name] = graph
+	}
+	desiredGraphs := make(map[string]bool)
+
+	for _, graph := range desired.Graphs {
+		desiredGraphs[graph.Name] = true
+
+		currentGraph, ok := currentGraphs[graph.Name]
+		if !ok {
+			plan.Changes = append(plan.Changes, Change{Action: ActionCreate, Kind: KindGraph, Name: graph.Name})
+			continue
+		}
+
+		fields := changedFields(currentGraph, graph)
+		if len(fields) > 0 {
+			plan.Changes = append(plan.Changes, Change{Action: ActionUpdate, Kind: KindGraph, Name: graph.Name, Fields: fields})
+		}
+	}
+
+	currentChecks := make(map[string]CheckSpec)
+	for _, check := range current.Checks {
+		currentChecks[check.Name] = check
+	}
+	desiredChecks := make(map[string]bool)
+
+	for _, check := range desired.Checks {
+		desiredChecks[check.Name] = true
+
+		currentCheck, ok := currentChecks[check.Name]
+		if !ok {
+			plan.Changes = append(plan.Changes, Change{Action: ActionCreate, Kind: KindCheck, Name: check.Name})
+			continue
+		}
+
+		fields := changedFields(currentCheck, check)
+		if len(fields) > 0 {
+			plan.Changes = append(plan.Changes, Change{Action: ActionUpdate, Kind: KindCheck, Name: check.Name, Fields: fields})
+		}
+	}
+
+	for _, graph := range current.Graphs {
+		if !desiredGraphs[graph.Name] {
+			plan.Changes = append(plan.Changes, Change{Action: ActionDelete, Kind: KindGraph, Name: graph.Name})
+		}
+	}
+
+	for _, check := range current.Checks {
+		if !desiredChecks[check.Name] {
+			plan.Changes = append(plan.Changes, Change{Action: ActionDelete, Kind: KindCheck, Name: check.Name})
+		}
+	}
+
+	return plan
+}
+
+// changedFields returns the names of the struct fields that differ between current and desired.
+func changedFields(current, desired interface{}) []string {
+	currentValue := reflect.ValueOf(current)
+	desiredValue := reflect.ValueOf(desired)
+
+	fields := make([]string, 0)
+
+	for i := 0; i < currentValue.NumField(); i++ {
+		if !reflect.DeepEqual(currentValue.Field(i).Interface(), desiredValue.Field(i).Interface()) {
+			fields = append(fields, currentValue.Type().Field(i).Name)
+		}
+	}
+
+	return fields
+}
+
+// IsEmpty is true when the cluster already matches the desired state.
+func (plan *Plan) IsEmpty() bool {
+	return len(plan.Changes) == 0
+}
+
+// Count returns the number of changes with the given action.
+func (plan *Plan) Count(action string) int {
+	count := 0
+	for _, change := range plan.Changes {
+		if change.Action == action {
+			count++
+		}
+	}
+	return count
+}
+
+// String prints the plan, one change per line.
+func (plan *Plan) String() string {
+	if plan.IsEmpty() {
+		return "No changes. The cluster matches the desired state.\n"
+	}
+
+	symbols := map[string]string{
+		ActionCreate: "+",
+		ActionUpdate: "~",
+		ActionDelete: "-",
+	}
+
+	var buffer bytes.Buffer
+
+	for _, change := range plan.Changes {
+		buffer.WriteString(fmt.Sprintf("%v %v %q", symbols[change.Action], change.Kind, change.Name))
+		if len(change.Fields) > 0 {
+			buffer.WriteString(fmt.Sprintf(" %v", change.Fields))
+		}
+		buffer.WriteString("\n")
+	}
+
+	buffer.WriteString(fmt.Sprintf("\nPlan: %v to create, %v to update, %v to delete.\n", plan.Count(ActionCreate), plan.Count(ActionUpdate), plan.Count(ActionDelete)))
+
+	return buffer.String()
+}
@@ -0,0 +1,74 @@
+package declarative
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/resourced/resourced-master/models/pg"
+)
+
+func TestDiff(t *testing.T) {
+	current := &Spec{
+		Metrics: []string{"/load-avg.LoadAvg1m"},
+		Graphs:  []GraphSpec{{Name: "Old"}},
+		Checks: []CheckSpec{
+			{Name: "Kept", Interval: "60s"},
+			{Name: "Removed", Interval: "60s"},
+			{Name: "Changed", Interval: "60s", HostsQuery: "tags.role = web"},
+		},
+	}
+
+	desired := &Spec{
+		Metrics: []string{"/free.Memory.Free", "/load-avg.LoadAvg1m"},
+		Checks: []CheckSpec{
+			{Name: "Added", Interval: "60s"},
+			{Name: "Changed", Interval: "30s", HostsQuery: "tags.role = web", DependsOn: []string{"Kept"}},
+			{Name: "Kept", Interval: "60s"},
+		},
+	}
+
+	plan := Diff(current, desired)
+
+	expected := []Change{
+		{Action: ActionCreate, Kind: KindMetric, Name: "/free.Memory.Free"},
+		{Action: ActionCreate, Kind: KindCheck, Name: "Added"},
+		{Action: ActionUpdate, Kind: KindCheck, Name: "Changed", Fields: []string{"Interval", "DependsOn"}},
+		{Action: ActionDelete, Kind: KindGraph, Name: "Old"},
+		{Action: ActionDelete, Kind: KindCheck, Name: "Removed"},
+	}
+
+	if !reflect.DeepEqual(plan.Changes, expected) {
+		t.Fatalf("Unexpected plan. Changes: %v", plan.Changes)
+	}
+
+	output := plan.String()
+	if !strings.Contains(output, `~ check "Changed" [Interval DependsOn]`) {
+		t.Errorf("Plan should print updated fields. Output: %v", output)
+	}
+	if !strings.Contains(output, "Plan: 2 to create, 1 to update, 2 to delete.") {
+		t.Errorf("Plan should print a summary. Output: %v", output)
+	}
+
+	if !Diff(desired, desired).IsEmpty() {
+		t.Errorf("Identical specs should have an empty plan")
+	}
+}
+
+func TestMatchTriggers(t *testing.T) {
+	kept := TriggerSpec{LowViolationsCount: 1, HighViolationsCount: 5, Action: ActionSpec{Transport: "email", Email: "ops@example.com"}}
+	added := TriggerSpec{LowViolationsCount: 6, HighViolationsCount: 10, Action: ActionSpec{Transport: "email", Email: "ops@example.com"}}
+
+	existing := []pg.CheckTrigger{kept.CheckTrigger(100), added.CheckTrigger(0)}
+	existing[1].Action.Email = "old@example.com"
+	existing[1].ID = 200
+
+	triggers := matchTriggers(existing, []TriggerSpec{added, kept, added})
+
+	if triggers[1].ID != 100 {
+		t.Errorf("Unchanged trigger should keep its ID. ID: %v", triggers[1].ID)
+	}
+	if triggers[0].ID == 200 || triggers[0].ID == 100 || triggers[0].ID == triggers[2].ID {
+		t.Errorf("Changed and new triggers should get unique new IDs. IDs: %v, %v", triggers[0].ID, triggers[2].ID)
+	}
+}
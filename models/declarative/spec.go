@@ -0,0 +1,462 @@
+// Package declarative describes checks, triggers, graphs and metrics of a cluster as TOML or JSON documents,
+// and turns the difference between such a document and a cluster into a plan of changes.
+package declarative
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+
+	"github.com/resourced/resourced-master/models/pg"
+)
+
+// Spec is the desired state of a cluster.
+type Spec struct {
+	// Metrics are created when missing. They are never deleted because agents report them on their own.
+	Metrics []string    `toml:",omitempty" json:",omitempty"`
+	Graphs  []GraphSpec `toml:",omitempty" json:",omitempty"`
+	Checks  []CheckSpec `toml:",omitempty" json:",omitempty"`
+}
+
+// GraphSpec is a graph, identified by its name.
+type GraphSpec struct {
+	Name        string
+	Description string   `toml:",omitempty" json:",omitempty"`
+	Range       string   `toml:",omitempty" json:",omitempty"`
+	Metrics     []string `toml:",omitempty" json:",omitempty"`
+}
+
+// CheckSpec is a check, identified by its name.
+type CheckSpec struct {
+	Name       string
+	Interval   string   `toml:",omitempty" json:",omitempty"`
+	HostsQuery string   `toml:",omitempty" json:",omitempty"`
+	HostsList  []string `toml:",omitempty" json:",omitempty"`
+
+	// DependsOn are the names of the parent checks.
+	DependsOn []string `toml:",omitempty" json:",omitempty"`
+
+	EscalationPolicyID int64 `toml:",omitzero" json:",omitempty"`
+
+	// PerHostAlerting gives every bad host its own violations count and notifications.
+	PerHostAlerting bool `toml:",omitempty" json:",omitempty"`
+
+	// Expressions have the same fields as the checks API, without results.
+	Expressions []map[string]interface{} `toml:",omitempty" json:",omitempty"`
+
+	Triggers []TriggerSpec `toml:",omitempty" json:",omitempty"`
+}
+
+// TriggerSpec is a check trigger. Triggers have no name, they are matched by content.
+type TriggerSpec struct {
+	LowViolationsCount     int64
+	HighViolationsCount    int64
+	CreatedIntervalMinute  int64 `toml:",omitzero" json:",omitempty"`
+	RenotifyIntervalMinute int64 `toml:",omitzero" json:",omitempty"`
+	Digest                 bool  `toml:",omitempty" json:",omitempty"`
+	Action                 ActionSpec
+}
+
+// ActionSpec is what a trigger does.
+type ActionSpec struct {
+	Transport            string
+	Email                string `toml:",omitempty" json:",omitempty"`
+	SMSPhone             string `toml:",omitempty" json:",omitempty"`
+	SMSCarrier           string `toml:",omitempty" json:",omitempty"`
+	PagerDutyServiceKey  string `toml:",omitempty" json:",omitempty"`
+	PagerDutyDescription string `toml:",omitempty" json:",omitempty"`
+	OnCallScheduleID     int64  `toml:",omitzero" json:",omitempty"`
+}
+
+// NewTriggerSpec returns the declarative form of a trigger.
+func NewTriggerSpec(trigger pg.CheckTrigger) TriggerSpec {
+	return TriggerSpec{
+		LowViolationsCount:     trigger.LowViolationsCount,
+		HighViolationsCount:    trigger.HighViolationsCount,
+		CreatedIntervalMinute:  trigger.CreatedIntervalMinute,
+		RenotifyIntervalMinute: trigger.RenotifyIntervalMinute,
+		Digest:                 trigger.Digest,
+		Action: ActionSpec{
+			Transport:            trigger.Action.Transport,
+			Email:                trigger.Action.Email,
+			SMSPhone:             trigger.Action.SMSPhone,
+			SMSCarrier:           trigger.Action.SMSCarrier,
+			PagerDutyServiceKey:  trigger.Action.PagerDutyServiceKey,
+			PagerDutyDescription: trigger.Action.PagerDutyDescription,
+			OnCallScheduleID:     trigger.Action.OnCallScheduleID,
+		},
+	}
+}
+
+// CheckTrigger returns the trigger with the given ID.
+func (ts TriggerSpec) CheckTrigger(id int64) pg.CheckTrigger {
+	trigger := pg.CheckTrigger{}
+	trigger.ID = id
+	trigger.LowViolationsCount = ts.LowViolationsCount
+	trigger.HighViolationsCount = ts.HighViolationsCount
+	trigger.CreatedIntervalMinute = ts.CreatedIntervalMinute
+	trigger.RenotifyIntervalMinute = ts.RenotifyIntervalMinute
+	trigger.Digest = ts.Digest
+	trigger.Action.Transport = ts.Action.Transport
+	trigger.Action.Email = ts.Action.Email
+	trigger.Action.SMSPhone = ts.Action.SMSPhone
+	trigger.Action.SMSCarrier = ts.Action.SMSCarrier
+	trigger.Action.PagerDutyServiceKey = ts.Action.PagerDutyServiceKey
+	trigger.Action.PagerDutyDescription = ts.Action.PagerDutyDescription
+	trigger.Action.OnCallScheduleID = ts.Action.OnCallScheduleID
+
+	return trigger
+}
+
+// Decode parses a spec. format is "toml" or "json".
+func Decode(format string, data []byte) (*Spec, error) {
+	spec := &Spec{}
+
+	switch strings.ToLower(format) {
+	case "toml":
+		_, err := toml.Decode(string(data), spec)
+		if err != nil {
+			return nil, err
+		}
+	case "json":
+		err := json.Unmarshal(data, spec)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("Unsupported format: %v. Valid choices: toml or json", format)
+	}
+
+	return spec, nil
+}
+
+// Encode returns a spec as "toml" or "json".
+func Encode(format string, spec *Spec) ([]byte, error) {
+	switch strings.ToLower(format) {
+	case "toml":
+		var buffer bytes.Buffer
+
+		err := toml.NewEncoder(&buffer).Encode(spec)
+		if err != nil {
+			return nil, err
+		}
+		return buffer.Bytes(), nil
+
+	case "json":
+		return json.MarshalIndent(spec, "", "  ")
+
+	default:
+		return nil, fmt.Errorf("Unsupported format: %v. Valid choices: toml or json", format)
+	}
+}
+
+// LoadPath reads a .toml or .json file, or every such file in a directory, into one spec.
+func LoadPath(path string) (*Spec, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	filenames := []string{path}
+
+	if info.IsDir() {
+		filenames = make([]string, 0)
+
+		for _, pattern := range []string{"*.toml", "*.json"} {
+			matches, err := filepath.Glob(filepath.Join(path, pattern))
+			if err != nil {
+				return nil, err
+			}
+			filenames = append(filenames, matches...)
+		}
+
+		sort.Strings(filenames)
+	}
+
+	spec := &Spec{}
+
+	for _, filename := range filenames {
+		data, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+
+		fileSpec, err := Decode(strings.TrimPrefix(filepath.Ext(filename), "."), data)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %v", filename, err)
+		}
+
+		spec.Merge(fileSpec)
+	}
+
+	return spec, nil
+}
+
+// Merge appends everything declared in other.
+func (spec *Spec) Merge(other *Spec) {
+	spec.Metrics = append(spec.Metrics, other.Metrics...)
+	spec.Graphs = append(spec.Graphs, other.Graphs...)
+	spec.Checks = append(spec.Checks, other.Checks...)
+}
+
+// Normalize validates a spec and fills in defaults, so that specs meaning the same thing compare equal.
+func (spec *Spec) Normalize() error {
+	metrics := make(map[string]bool)
+	for _, key := range spec.Metrics {
+		if key == "" {
+			return fmt.Errorf("Metric key cannot be empty")
+		}
+		metrics[key] = true
+	}
+
+	graphNames := make(map[string]bool)
+
+	for i := range spec.Graphs {
+		graph := &spec.Graphs[i]
+
+		if graph.Name == "" {
+			return fmt.Errorf("Graph name cannot be empty")
+		}
+		if graphNames[graph.Name] {
+			return fmt.Errorf("Graph %q is declared more than once", graph.Name)
+		}
+		graphNames[graph.Name] = true
+
+		if len(graph.Metrics) == 0 {
+			graph.Metrics = nil
+		}
+
+		// Graphs may only show metrics that exist.
+		for _, key := range graph.Metrics {
+			metrics[key] = true
+		}
+	}
+
+	spec.Metrics = make([]string, 0, len(metrics))
+	for key := range metrics {
+		spec.Metrics = append(spec.Metrics, key)
+	}
+	sort.Strings(spec.Metrics)
+
+	if len(spec.Metrics) == 0 {
+		spec.Metrics = nil
+	}
+
+	checkIndexes := make(map[string]int)
+
+	for i := range spec.Checks {
+		check := &spec.Checks[i]
+
+		if check.Name == "" {
+			return fmt.Errorf("Check name cannot be empty")
+		}
+		if _, ok := checkIndexes[check.Name]; ok {
+			return fmt.Errorf("Check %q is declared more than once", check.Name)
+		}
+		checkIndexes[check.Name] = i
+
+		if check.Interval == "" {
+			check.Interval = "60s"
+		}
+		_, err := time.ParseDuration(check.Interval)
+		if err != nil {
+			return fmt.Errorf("Check %q: %v", check.Name, err)
+		}
+
+		check.HostsList = nonEmptyStrings(check.HostsList)
+		check.DependsOn = nonEmptyStrings(check.DependsOn)
+		sort.Strings(check.DependsOn)
+
+		expressionsJSON, err := json.Marshal(check.Expressions)
+		if err != nil {
+			return fmt.Errorf("Check %q: %v", check.Name, err)
+		}
+		if check.Expressions == nil {
+			expressionsJSON = []byte("[]")
+		}
+
+		expressionsJSON, err = pg.NormalizeExpressionsJSON(expressionsJSON)
+		if err != nil {
+			return fmt.Errorf("Check %q: %v", check.Name, err)
+		}
+
+		check.Expressions, err = canonicalExpressions(expressionsJSON)
+		if err != nil {
+			return fmt.Errorf("Check %q: %v", check.Name, err)
+		}
+
+		if len(check.Triggers) == 0 {
+			check.Triggers = nil
+		}
+	}
+
+	// Parents must be declared too, and must not form a cycle.
+	parentsByCheckID := make(map[int64][]int64)
+
+	for i, check := range spec.Checks {
+		parentIDs := make([]int64, 0, len(check.DependsOn))
+
+		for _, parentName := range check.DependsOn {
+			parentIndex, ok := checkIndexes[parentName]
+			if !ok {
+				return fmt.Errorf("Check %q depends on %q which is not declared", check.Name, parentName)
+			}
+			parentIDs = append(parentIDs, int64(parentIndex))
+		}
+
+		parentsByCheckID[int64(i)] = parentIDs
+	}
+
+	cycle := pg.FindCheckDependencyCycle(parentsByCheckID)
+	if cycle != nil {
+		names := make([]string, len(cycle))
+		for i, index := range cycle {
+			names[i] = spec.Checks[index].Name
+		}
+		return fmt.Errorf("Check dependencies cannot form a cycle: %v", strings.Join(names, " -> "))
+	}
+
+	sort.Slice(spec.Graphs, func(i, j int) bool { return spec.Graphs[i].Name < spec.Graphs[j].Name })
+	sort.Slice(spec.Checks, func(i, j int) bool { return spec.Checks[i].Name < spec.Checks[j].Name })
+
+	return nil
+}
+
+// canonicalExpressions turns expressions JSON into maps without results and zero values.
+// Whole numbers become integers, so they read naturally in TOML.
+func canonicalExpressions(expressionsJSON []byte) ([]map[string]interface{}, error) {
+	var expressions []pg.CheckExpression
+
+	err := json.Unmarshal(expressionsJSON, &expressions)
+	if err != nil {
+		return nil, err
+	}
+
+	// Round trip through the struct drops unknown fields.
+	expressionsJSON, err = json.Marshal(expressions)
+	if err != nil {
+		return nil, err
+	}
+
+	var generic []interface{}
+
+	err = json.Unmarshal(expressionsJSON, &generic)
+	if err != nil {
+		return nil, err
+	}
+
+	return canonicalExpressionList(generic), nil
+}
+
+func canonicalExpressionList(generic []interface{}) []map[string]interface{} {
+	if len(generic) == 0 {
+		return nil
+	}
+
+	result := make([]map[string]interface{}, 0, len(generic))
+
+	for _, item := range generic {
+		expression, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		canonical := make(map[string]interface{})
+
+		for key, value := range expression {
+			if key == "Result" {
+				continue
+			}
+
+			if key == "Expressions" {
+				if children, ok := value.([]interface{}); ok && len(children) > 0 {
+					canonical[key] = canonicalExpressionList(children)
+				}
+				continue
+			}
+
+			value = canonicalValue(value)
+			if value == nil || reflect.ValueOf(value).IsZero() {
+				continue
+			}
+
+			canonical[key] = value
+		}
+
+		result = append(result, canonical)
+	}
+
+	return result
+}
+
+// canonicalValue drops zero values inside nested objects, e.g. the steps of Synthetic expressions.
+// Lists of objects become []map[string]interface{}, which TOML encodes as arrays of tables.
+func canonicalValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+			return int64(v)
+		}
+		return v
+	case []interface{}:
+		if len(v) == 0 {
+			return nil
+		}
+
+		objects := make([]map[string]interface{}, 0, len(v))
+		for _, item := range v {
+			object, ok := item.(map[string]interface{})
+			if !ok {
+				return v
+			}
+			objects = append(objects, canonicalObject(object))
+		}
+		return objects
+	case map[string]interface{}:
+		object := canonicalObject(v)
+		if len(object) == 0 {
+			return nil
+		}
+		return object
+	}
+
+	return value
+}
+
+func canonicalObject(object map[string]interface{}) map[string]interface{} {
+	canonical := make(map[string]interface{})
+
+	for key, value := range object {
+		value = canonicalValue(value)
+		if value == nil || reflect.ValueOf(value).IsZero() {
+			continue
+		}
+		canonical[key] = value
+	}
+
+	return canonical
+}
+
+func nonEmptyStrings(values []string) []string {
+	result := make([]string, 0, len(values))
+	for _, value := range values {
+		if value != "" {
+			result = append(result, value)
+		}
+	}
+
+	if len(result) == 0 {
+		return nil
+	}
+	return result
+}
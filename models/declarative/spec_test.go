@@ -0,0 +1,161 @@
+package declarative
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testSpecTOML = `
+Metrics = ["/free.Memory.Free"]
+
+[[Graphs]]
+Name = "Load"
+Range = "1h"
+Metrics = ["/load-avg.LoadAvg1m"]
+
+[[Checks]]
+Name = "High load"
+HostsQuery = "tags.role = web"
+DependsOn = ["Ping"]
+
+  [[Checks.Expressions]]
+  Type = "RawHostData"
+  Metric = "/load-avg.LoadAvg1m"
+  Operator = ">"
+  Value = 4.0
+  MinHost = 1
+
+  [[Checks.Triggers]]
+  LowViolationsCount = 1
+  HighViolationsCount = 5
+
+    [Checks.Triggers.Action]
+    Transport = "email"
+    Email = "ops@example.com"
+
+[[Checks]]
+Name = "Ping"
+Interval = "30s"
+HostsList = ["web-1", ""]
+
+  [[Checks.Expressions]]
+  Type = "Ping"
+  MinHost = 1
+`
+
+func TestDecodeAndNormalize(t *testing.T) {
+	spec, err := Decode("toml", []byte(testSpecTOML))
+	if err != nil {
+		t.Fatalf("Decoding TOML should work. Error: %v", err)
+	}
+
+	err = spec.Normalize()
+	if err != nil {
+		t.Fatalf("Normalizing spec should work. Error: %v", err)
+	}
+
+	if !reflect.DeepEqual(spec.Metrics, []string{"/free.Memory.Free", "/load-avg.LoadAvg1m"}) {
+		t.Errorf("Graph metrics should be declared as metrics. Metrics: %v", spec.Metrics)
+	}
+
+	if spec.Checks[0].Name != "High load" || spec.Checks[1].Name != "Ping" {
+		t.Fatalf("Checks should be sorted by name. Checks: %v", spec.Checks)
+	}
+
+	if spec.Checks[0].Interval != "60s" {
+		t.Errorf("Interval should default to 60s. Interval: %v", spec.Checks[0].Interval)
+	}
+
+	if !reflect.DeepEqual(spec.Checks[1].HostsList, []string{"web-1"}) {
+		t.Errorf("Empty hostnames should be removed. HostsList: %v", spec.Checks[1].HostsList)
+	}
+
+	expression := spec.Checks[0].Expressions[0]
+	if expression["Value"] != int64(4) {
+		t.Errorf("Whole numbers should become integers. Value: %#v", expression["Value"])
+	}
+	if _, ok := expression["Result"]; ok {
+		t.Errorf("Results should be dropped. Expression: %v", expression)
+	}
+	if _, ok := expression["Port"]; ok {
+		t.Errorf("Zero values should be dropped. Expression: %v", expression)
+	}
+}
+
+func TestEncodeRoundTrip(t *testing.T) {
+	for _, format := range []string{"toml", "json"} {
+		spec, _ := Decode("toml", []byte(testSpecTOML))
+		spec.Normalize()
+
+		content, err := Encode(format, spec)
+		if err != nil {
+			t.Fatalf("Encoding %v should work. Error: %v", format, err)
+		}
+
+		decoded, err := Decode(format, content)
+		if err != nil {
+			t.Fatalf("Decoding %v should work. Error: %v", format, err)
+		}
+
+		err = decoded.Normalize()
+		if err != nil {
+			t.Fatalf("Normalizing decoded %v should work. Error: %v", format, err)
+		}
+
+		if !Diff(spec, decoded).IsEmpty() {
+			t.Errorf("Encoded %v should decode to the same spec. Plan: %v", format, Diff(spec, decoded))
+		}
+	}
+}
+
+func TestNormalizeErrors(t *testing.T) {
+	// Every check needs an expression to be valid.
+	ping := `
+  [[Checks.Expressions]]
+  Type = "Ping"
+  MinHost = 1
+`
+
+	tests := map[string]string{
+		"declared more than once": `
+[[Checks]]
+Name = "A"` + ping + `
+[[Checks]]
+Name = "A"` + ping,
+		"is not declared": `
+[[Checks]]
+Name = "A"
+DependsOn = ["B"]` + ping,
+		"cannot form a cycle": `
+[[Checks]]
+Name = "A"
+DependsOn = ["B"]` + ping + `
+[[Checks]]
+Name = "B"
+DependsOn = ["A"]` + ping,
+		"invalid duration": `
+[[Checks]]
+Name = "A"
+Interval = "often"` + ping,
+	}
+
+	for expected, content := range tests {
+		spec, err := Decode("toml", []byte(content))
+		if err != nil {
+			t.Fatalf("Decoding TOML should work. Error: %v", err)
+		}
+
+		err = spec.Normalize()
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("Normalize should fail with %q. Error: %v", expected, err)
+		}
+	}
+}
+
+func TestDecodeUnsupportedFormat(t *testing.T) {
+	_, err := Decode("yaml", []byte("Checks: []"))
+	if err == nil {
+		t.Errorf("Unsupported formats should fail")
+	}
+}
@@ -0,0 +1,606 @@
+package declarative
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/resourced/resourced-master/models/cassandra"
+	"github.com/resourced/resourced-master/models/pg"
+)
+
+// State is what a cluster currently has, as a spec and as rows keyed by name.
+type State struct {
+	ClusterID int64
+	Spec      *Spec
+
+	checks    map[string]*pg.CheckRow
+	graphs    map[string]*cassandra.GraphRow
+	metricIDs map[string]int64
+
+	escalationPolicyIDs map[int64]bool
+
+	// heartbeatChecks are the checks heartbeat monitors own, keyed by name. They are managed with their monitors,
+	// so they are left out of Spec, and the plan never touches them.
+	heartbeatChecks map[string]*pg.CheckRow
+}
+
+// LoadState reads the checks, graphs, metrics and escalation policies of a cluster.
+// Checks and graphs are identified by name, so names must be unique within the cluster.
+// Checks owned by heartbeat monitors are not part of the state's spec.
+func LoadState(ctx context.Context, clusterID int64) (*State, error) {
+	state := &State{
+		ClusterID: clusterID,
+		Spec:      &Spec{},
+		checks:    make(map[string]*pg.CheckRow),
+		graphs:    make(map[string]*cassandra.GraphRow),
+
+		escalationPolicyIDs: make(map[int64]bool),
+		heartbeatChecks:     make(map[string]*pg.CheckRow),
+	}
+
+	escalationPolicyRows, err := pg.NewEscalationPolicy(ctx).AllByClusterID(nil, clusterID)
+	if err != nil {
+		return nil, err
+	}
+	for _, escalationPolicyRow := range escalationPolicyRows {
+		state.escalationPolicyIDs[escalationPolicyRow.ID] = true
+	}
+
+	metricIDs, err := cassandra.NewMetric(ctx).AllByClusterIDAsMap(clusterID)
+	if err != nil {
+		return nil, err
+	}
+	state.metricIDs = metricIDs
+
+	for key := range metricIDs {
+		state.Spec.Metrics = append(state.Spec.Metrics, key)
+	}
+	sort.Strings(state.Spec.Metrics)
+
+	graphRows, err := cassandra.NewGraph(ctx).AllByClusterID(clusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, graphRow := range graphRows {
+		if _, ok := state.graphs[graphRow.Name]; ok {
+			return nil, fmt.Errorf("Graph name %q is used by more than one graph, rename them before applying", graphRow.Name)
+		}
+		state.graphs[graphRow.Name] = graphRow
+
+		graph := GraphSpec{
+			Name:        graphRow.Name,
+			Description: graphRow.Description,
+			Range:       graphRow.Range,
+		}
+		for _, metricRow := range graphRow.MetricsFromJSON() {
+			graph.Metrics = append(graph.Metrics, metricRow.Key)
+		}
+
+		state.Spec.Graphs = append(state.Spec.Graphs, graph)
+	}
+	sort.Slice(state.Spec.Graphs, func(i, j int) bool { return state.Spec.Graphs[i].Name < state.Spec.Graphs[j].Name })
+
+	checkRows, err := pg.NewCheck(ctx).AllByClusterID(nil, clusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	heartbeatRows, err := pg.NewHeartbeat(ctx).AllByClusterID(nil, clusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = state.addChecks(checkRows, heartbeatRows)
+	if err != nil {
+		return nil, err
+	}
+
+	return state, nil
+}
+
+// addChecks adds the checks of the cluster to the state, setting aside the ones heartbeat monitors own.
+func (state *State) addChecks(checkRows []*pg.CheckRow, heartbeatRows []*pg.HeartbeatRow) error {
+	heartbeatCheckIDs := make(map[int64]bool)
+	for _, heartbeatRow := range heartbeatRows {
+		if heartbeatRow.CheckID != nil {
+			heartbeatCheckIDs[*heartbeatRow.CheckID] = true
+		}
+	}
+
+	checkNames := make(map[int64]string)
+
+	for _, checkRow := range checkRows {
+		_, isCheck := state.checks[checkRow.Name]
+		_, isHeartbeatCheck := state.heartbeatChecks[checkRow.Name]
+		if isCheck || isHeartbeatCheck {
+			return fmt.Errorf("Check name %q is used by more than one check, rename them before applying", checkRow.Name)
+		}
+
+		if heartbeatCheckIDs[checkRow.ID] {
+			state.heartbeatChecks[checkRow.Name] = checkRow
+			continue
+		}
+
+		state.checks[checkRow.Name] = checkRow
+		checkNames[checkRow.ID] = checkRow.Name
+	}
+
+	for _, checkRow := range state.checks {
+		check, err := newCheckSpec(checkRow, checkNames)
+		if err != nil {
+			return fmt.Errorf("Check %q: %v", checkRow.Name, err)
+		}
+
+		state.Spec.Checks = append(state.Spec.Checks, check)
+	}
+	sort.Slice(state.Spec.Checks, func(i, j int) bool { return state.Spec.Checks[i].Name < state.Spec.Checks[j].Name })
+
+	return nil
+}
+
+// newCheckSpec returns the declarative form of a check. checkNames maps the IDs of the cluster's checks to their names.
+func newCheckSpec(checkRow *pg.CheckRow, checkNames map[int64]string) (CheckSpec, error) {
+	check := CheckSpec{
+		Name:       checkRow.Name,
+		Interval:   checkRow.Interval,
+		HostsQuery: checkRow.HostsQuery,
+	}
+
+	hostsList, err := checkRow.GetHostsList()
+	if err != nil {
+		return check, err
+	}
+	check.HostsList = nonEmptyStrings(hostsList)
+
+	for _, parentID := range checkRow.GetParentIDs() {
+		// Parents that were deleted are left out.
+		if name, ok := checkNames[parentID]; ok {
+			check.DependsOn = append(check.DependsOn, name)
+		}
+	}
+	sort.Strings(check.DependsOn)
+
+	if checkRow.EscalationPolicyID != nil {
+		check.EscalationPolicyID = *checkRow.EscalationPolicyID
+	}
+
+	check.PerHostAlerting = checkRow.PerHostAlerting
+
+	if len(checkRow.Expressions) > 0 {
+		check.Expressions, err = canonicalExpressions(checkRow.Expressions)
+		if err != nil {
+			return check, err
+		}
+	}
+
+	for _, trigger := range checkRow.GetTriggers() {
+		check.Triggers = append(check.Triggers, NewTriggerSpec(trigger))
+	}
+
+	return check, nil
+}
+
+// Plan returns the changes needed to reach desired, which must be normalized.
+func (state *State) Plan(desired *Spec) *Plan {
+	return Diff(state.Spec, desired)
+}
+
+// Apply makes the cluster match desired, which must be normalized, and returns the plan it carried out.
+// Checks are written in a single transaction, so either all of them or none of them change.
+// Metrics and graphs are written afterwards. When one of them fails, Apply returns the changes
+// applied before the failure along with the error, and the state must be loaded again.
+func (state *State) Apply(ctx context.Context, desired *Spec) (*Plan, error) {
+	plan := state.Plan(desired)
+	applied := &Plan{Changes: make([]Change, 0)}
+
+	err := state.applyChecks(ctx, plan, desired)
+	if err != nil {
+		return applied, err
+	}
+
+	for _, change := range plan.Changes {
+		if change.Kind == KindCheck {
+			applied.Changes = append(applied.Changes, change)
+		}
+	}
+
+	graphs := make(map[string]GraphSpec)
+	for _, graph := range desired.Graphs {
+		graphs[graph.Name] = graph
+	}
+
+	metric := cassandra.NewMetric(ctx)
+	graph := cassandra.NewGraph(ctx)
+
+	// Metrics come first in a plan, because graphs refer to them.
+	for _, change := range plan.Changes {
+		if change.Action == ActionDelete {
+			continue
+		}
+
+		switch change.Kind {
+		case KindMetric:
+			metricRow, err := metric.CreateOrUpdate(state.ClusterID, change.Name)
+			if err != nil {
+				return applied, err
+			}
+			state.metricIDs[metricRow.Key] = metricRow.ID
+
+		case KindGraph:
+			err := state.saveGraph(graph, graphs[change.Name])
+			if err != nil {
+				return applied, fmt.Errorf("Graph %q: %v", change.Name, err)
+			}
+
+		default:
+			continue
+		}
+
+		applied.Changes = append(applied.Changes, change)
+	}
+
+	for _, change := range plan.Changes {
+		if change.Action != ActionDelete || change.Kind != KindGraph {
+			continue
+		}
+
+		err := graph.DeleteByClusterIDAndID(state.ClusterID, state.graphs[change.Name].ID)
+		if err != nil {
+			return applied, fmt.Errorf("Graph %q: %v", change.Name, err)
+		}
+		delete(state.graphs, change.Name)
+
+		applied.Changes = append(applied.Changes, change)
+	}
+
+	state.Spec = desired
+
+	return plan, nil
+}
+
+// applyChecks creates, updates and deletes the checks of plan in one transaction.
+// Checks are created before their parents are set, because parents may be created in the same plan.
+// Checks are deleted last so that every remaining check stops depending on them first.
+func (state *State) applyChecks(ctx context.Context, plan *Plan, desired *Spec) (err error) {
+	checks := make(map[string]CheckSpec)
+	for _, check := range desired.Checks {
+		checks[check.Name] = check
+	}
+
+	for name := range checks {
+		if _, ok := state.heartbeatChecks[name]; ok {
+			return fmt.Errorf("Check %q belongs to a heartbeat monitor, use another name", name)
+		}
+	}
+
+	err = state.validateCheckStates(plan, checks)
+	if err != nil {
+		return err
+	}
+
+	check := pg.NewCheck(ctx)
+
+	pgdb, err := check.GetPGDB()
+	if err != nil {
+		return err
+	}
+
+	tx, err := pgdb.Beginx()
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	checksToSave := make([]string, 0)
+
+	for _, change := range plan.Changes {
+		if change.Kind != KindCheck || change.Action == ActionDelete {
+			continue
+		}
+
+		if change.Action == ActionCreate {
+			data := map[string]interface{}{
+				"cluster_id":              state.ClusterID,
+				"name":                    change.Name,
+				"interval":                checks[change.Name].Interval,
+				"hosts_query":             "",
+				"hosts_list":              []byte("[]"),
+				"expressions":             []byte("[]"),
+				"parent_ids":              []byte("[]"),
+				"triggers":                []byte("[]"),
+				"last_result_hosts":       []byte("[]"),
+				"last_result_expressions": []byte("[]"),
+			}
+
+			sqlResult, err := check.InsertIntoTable(tx, data)
+			if err != nil {
+				return fmt.Errorf("Check %q: %v", change.Name, err)
+			}
+
+			// The row is not visible outside of tx until it commits, so it is not read back.
+			id, err := sqlResult.LastInsertId()
+			if err != nil {
+				return fmt.Errorf("Check %q: %v", change.Name, err)
+			}
+
+			state.checks[change.Name] = &pg.CheckRow{ID: id, ClusterID: state.ClusterID, Name: change.Name}
+		}
+
+		checksToSave = append(checksToSave, change.Name)
+	}
+
+	for _, name := range checksToSave {
+		data, err := state.checkData(checks[name])
+		if err != nil {
+			return fmt.Errorf("Check %q: %v", name, err)
+		}
+
+		_, err = check.UpdateByID(tx, data, state.checks[name].ID)
+		if err != nil {
+			return fmt.Errorf("Check %q: %v", name, err)
+		}
+	}
+
+	for _, change := range plan.Changes {
+		if change.Kind != KindCheck || change.Action != ActionDelete {
+			continue
+		}
+
+		_, err = check.DeleteByClusterIDAndID(tx, state.ClusterID, state.checks[change.Name].ID)
+		if err != nil {
+			return fmt.Errorf("Check %q: %v", change.Name, err)
+		}
+		delete(state.checks, change.Name)
+	}
+
+	return tx.Commit()
+}
+
+// validateCheckStates rejects CheckState expressions of plan that use checks outside of the cluster, their own check,
+// or checks that use them in turn. They are compared with the cluster as it is once plan is applied.
+func (state *State) validateCheckStates(plan *Plan, checks map[string]CheckSpec) error {
+	checkRows := make([]*pg.CheckRow, 0, len(state.checks)+len(state.heartbeatChecks))
+	for _, checkRow := range state.checks {
+		checkRows = append(checkRows, checkRow)
+	}
+	for _, checkRow := range state.heartbeatChecks {
+		checkRows = append(checkRows, checkRow)
+	}
+
+	componentsByCheckID := pg.CheckStateComponentsByCheckID(checkRows)
+
+	componentsByName := make(map[string][]int64)
+
+	for _, change := range plan.Changes {
+		if change.Kind != KindCheck {
+			continue
+		}
+
+		if change.Action == ActionDelete {
+			delete(componentsByCheckID, state.checks[change.Name].ID)
+			continue
+		}
+
+		expressionsJSON, err := state.expressionsJSON(checks[change.Name])
+		if err != nil {
+			return fmt.Errorf("Check %q: %v", change.Name, err)
+		}
+
+		components, err := pg.CheckStateCheckIDs(expressionsJSON)
+		if err != nil {
+			return fmt.Errorf("Check %q: %v", change.Name, err)
+		}
+		componentsByName[change.Name] = components
+
+		if checkRow, ok := state.checks[change.Name]; ok {
+			componentsByCheckID[checkRow.ID] = components
+		}
+	}
+
+	// Sorted for a stable error message.
+	names := make([]string, 0, len(componentsByName))
+	for name := range componentsByName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		var checkID int64
+		if checkRow, ok := state.checks[name]; ok {
+			checkID = checkRow.ID
+		}
+
+		err := pg.ValidateCheckStateReferences(componentsByCheckID, checkID, componentsByName[name])
+		if err != nil {
+			return fmt.Errorf("Check %q: %v", name, err)
+		}
+	}
+
+	return nil
+}
+
+// saveGraph creates or updates a graph. Its metrics must exist already.
+func (state *State) saveGraph(graph *cassandra.Graph, spec GraphSpec) error {
+	metricRows := make([]*cassandra.MetricRow, 0, len(spec.Metrics))
+	for _, key := range spec.Metrics {
+		metricRows = append(metricRows, &cassandra.MetricRow{ID: state.metricIDs[key], ClusterID: state.ClusterID, Key: key})
+	}
+
+	metricsJSON, err := json.Marshal(metricRows)
+	if err != nil {
+		return err
+	}
+
+	graphRow, ok := state.graphs[spec.Name]
+	if !ok {
+		data := map[string]interface{}{
+			"name":        spec.Name,
+			"description": spec.Description,
+			"range":       spec.Range,
+		}
+
+		graphRow, err = graph.Create(state.ClusterID, data)
+		if err != nil {
+			return err
+		}
+
+		graphRow, err = graph.UpdateMetricsByClusterIDAndID(state.ClusterID, graphRow.ID, metricsJSON)
+		if err != nil {
+			return err
+		}
+
+		state.graphs[spec.Name] = graphRow
+		return nil
+	}
+
+	data := map[string]string{
+		"name":        spec.Name,
+		"description": spec.Description,
+		"range":       spec.Range,
+		"metrics":     string(metricsJSON),
+	}
+
+	graphRow, err = graph.UpdateByID(graphRow.ID, data)
+	if err != nil {
+		return err
+	}
+
+	state.graphs[spec.Name] = graphRow
+	return nil
+}
+
+// checkData returns the columns of a check. Unchanged triggers keep their IDs, so their notification history stays attached.
+func (state *State) checkData(spec CheckSpec) (map[string]interface{}, error) {
+	hostsList := spec.HostsList
+	if hostsList == nil {
+		hostsList = make([]string, 0)
+	}
+
+	hostsListJSON, err := json.Marshal(hostsList)
+	if err != nil {
+		return nil, err
+	}
+
+	expressionsJSON, err := state.expressionsJSON(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	parentIDs := make([]int64, 0, len(spec.DependsOn))
+	for _, name := range spec.DependsOn {
+		parentRow, ok := state.checks[name]
+		if !ok {
+			return nil, fmt.Errorf("Parent check %q does not exist", name)
+		}
+		parentIDs = append(parentIDs, parentRow.ID)
+	}
+
+	// Heartbeat checks are not in the spec, parents among them are kept as they are.
+	if checkRow, ok := state.checks[spec.Name]; ok {
+		for _, parentID := range checkRow.GetParentIDs() {
+			if state.isHeartbeatCheckID(parentID) {
+				parentIDs = append(parentIDs, parentID)
+			}
+		}
+	}
+
+	parentIDsJSON, err := json.Marshal(parentIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	existingTriggers := make([]pg.CheckTrigger, 0)
+	if checkRow, ok := state.checks[spec.Name]; ok {
+		existingTriggers = checkRow.GetTriggers()
+	}
+
+	triggersJSON, err := json.Marshal(matchTriggers(existingTriggers, spec.Triggers))
+	if err != nil {
+		return nil, err
+	}
+
+	data := make(map[string]interface{})
+	data["name"] = spec.Name
+	data["interval"] = spec.Interval
+	data["hosts_query"] = spec.HostsQuery
+	data["hosts_list"] = hostsListJSON
+	data["expressions"] = expressionsJSON
+	data["parent_ids"] = parentIDsJSON
+	data["triggers"] = triggersJSON
+	data["per_host_alerting"] = spec.PerHostAlerting
+	data["escalation_policy_id"] = nil
+	if spec.EscalationPolicyID > 0 {
+		if !state.escalationPolicyIDs[spec.EscalationPolicyID] {
+			return nil, fmt.Errorf("Escalation policy with ID: %v does not exist in this cluster", spec.EscalationPolicyID)
+		}
+		data["escalation_policy_id"] = spec.EscalationPolicyID
+	}
+
+	return data, nil
+}
+
+// isHeartbeatCheckID returns true when a heartbeat monitor owns the check with id.
+func (state *State) isHeartbeatCheckID(id int64) bool {
+	for _, checkRow := range state.heartbeatChecks {
+		if checkRow.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// expressionsJSON returns the normalized expressions of a check.
+func (state *State) expressionsJSON(spec CheckSpec) ([]byte, error) {
+	expressions := spec.Expressions
+	if expressions == nil {
+		expressions = make([]map[string]interface{}, 0)
+	}
+
+	expressionsJSON, err := json.Marshal(expressions)
+	if err != nil {
+		return nil, err
+	}
+
+	return pg.NormalizeExpressionsJSON(expressionsJSON)
+}
+
+// matchTriggers turns trigger specs into triggers, reusing the ID of an identical existing trigger when there is one.
+func matchTriggers(existing []pg.CheckTrigger, specs []TriggerSpec) []pg.CheckTrigger {
+	used := make(map[int64]bool)
+	triggers := make([]pg.CheckTrigger, 0, len(specs))
+
+	var lastID int64
+
+	for _, spec := range specs {
+		var id int64
+
+		for _, trigger := range existing {
+			if !used[trigger.ID] && NewTriggerSpec(trigger) == spec {
+				id = trigger.ID
+				break
+			}
+		}
+
+		if id == 0 {
+			// IDs are timestamps in microseconds, which repeat within a tight loop.
+			id = pg.NewExplicitID()
+			if id <= lastID {
+				id = lastID + 1
+			}
+			lastID = id
+		}
+
+		used[id] = true
+		triggers = append(triggers, spec.CheckTrigger(id))
+	}
+
+	return triggers
+}
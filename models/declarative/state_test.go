@@ -0,0 +1,64 @@
+package declarative
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/resourced/resourced-master/models/cassandra"
+	"github.com/resourced/resourced-master/models/pg"
+)
+
+func newCheckRowForStateTest(id int64, name string, parentIDs ...int64) *pg.CheckRow {
+	parentIDsJSON, _ := json.Marshal(append([]int64{}, parentIDs...))
+
+	return &pg.CheckRow{
+		ID:          id,
+		Name:        name,
+		Interval:    "60s",
+		ParentIDs:   parentIDsJSON,
+		HostsList:   []byte(`[]`),
+		Expressions: []byte(`[{"Type": "RawHostData", "Metric": "/df.Disk.UsedPercent", "Operator": ">", "Value": 90, "MinHost": 1}]`),
+		Triggers:    []byte(`[]`),
+	}
+}
+
+func TestStateLeavesHeartbeatChecksOut(t *testing.T) {
+	state := &State{
+		Spec:                &Spec{},
+		checks:              make(map[string]*pg.CheckRow),
+		graphs:              make(map[string]*cassandra.GraphRow),
+		escalationPolicyIDs: make(map[int64]bool),
+		heartbeatChecks:     make(map[string]*pg.CheckRow),
+	}
+
+	heartbeatCheckID := int64(1)
+	checkRows := []*pg.CheckRow{
+		newCheckRowForStateTest(1, "nightly-backup"),
+		newCheckRowForStateTest(2, "backup-storage", heartbeatCheckID),
+	}
+	heartbeatRows := []*pg.HeartbeatRow{{ID: 10, Name: "nightly-backup", CheckID: &heartbeatCheckID}}
+
+	err := state.addChecks(checkRows, heartbeatRows)
+	if err != nil {
+		t.Fatalf("Adding checks should work. Error: %v", err)
+	}
+
+	if len(state.Spec.Checks) != 1 || state.Spec.Checks[0].Name != "backup-storage" {
+		t.Fatalf("Only the check without heartbeat should be exported. Checks: %+v", state.Spec.Checks)
+	}
+
+	plan := state.Plan(&Spec{})
+	for _, change := range plan.Changes {
+		if change.Name == "nightly-backup" {
+			t.Errorf("The plan should never touch heartbeat checks. Change: %+v", change)
+		}
+	}
+
+	data, err := state.checkData(state.Spec.Checks[0])
+	if err != nil {
+		t.Fatalf("Building check data should work. Error: %v", err)
+	}
+	if string(data["parent_ids"].([]byte)) != "[1]" {
+		t.Errorf("Heartbeat parents should be kept. Got: %s", data["parent_ids"])
+	}
+}
@@ -207,6 +207,81 @@ func (b *Base) InsertIntoTable(tx *sqlx.Tx, data map[string]interface{}) (sql.Re
 	return result, err
 }
 
+// UpsertIntoTable inserts data, or updates the row that has the same conflictColumns, which must be a unique key.
+func (b *Base) UpsertIntoTable(tx *sqlx.Tx, data map[string]interface{}, conflictColumns []string) (result sql.Result, err error) {
+	if b.table == "" {
+		return nil, errors.New("Table must not be empty.")
+	}
+
+	tx, wrapInSingleTransaction, err := b.newTransactionIfNeeded(tx)
+	if tx == nil {
+		return nil, errors.New("Transaction struct must not be empty.")
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err != nil {
+		return nil, err
+	}
+
+	isConflictColumn := make(map[string]bool)
+	for _, column := range conflictColumns {
+		isConflictColumn[column] = true
+	}
+
+	keys := make([]string, 0)
+	dollarMarks := make([]string, 0)
+	updates := make([]string, 0)
+	values := make([]interface{}, 0)
+
+	loopCounter := 1
+	for key, value := range data {
+		keys = append(keys, key)
+		dollarMarks = append(dollarMarks, fmt.Sprintf("$%v", loopCounter))
+		values = append(values, value)
+
+		if !isConflictColumn[key] {
+			updates = append(updates, fmt.Sprintf("%v=EXCLUDED.%v", key, key))
+		}
+
+		loopCounter++
+	}
+
+	query := fmt.Sprintf(
+		"INSERT INTO %v (%v) VALUES (%v) ON CONFLICT (%v)",
+		b.table,
+		strings.Join(keys, ","),
+		strings.Join(dollarMarks, ","),
+		strings.Join(conflictColumns, ","))
+
+	if len(updates) > 0 {
+		query = query + " DO UPDATE SET " + strings.Join(updates, ",")
+	} else {
+		query = query + " DO NOTHING"
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"Method": "Base.UpsertIntoTable",
+		"Query":  query,
+	}).Info("Upsert Query")
+
+	result, err = tx.Exec(query, values...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if wrapInSingleTransaction == true {
+		err = tx.Commit()
+	}
+
+	return result, err
+}
+
 func (b *Base) UpdateFromTable(tx *sqlx.Tx, data map[string]interface{}, where string) (result sql.Result, err error) {
 	if b.table == "" {
 		return nil, errors.New("Table must not be empty.")
@@ -1,14 +1,13 @@
 package pg
 
 import (
-	"bytes"
 	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
 	"os"
 	"strings"
-	"text/template"
+	"time"
 
 	"github.com/Sirupsen/logrus"
 	"github.com/jmoiron/sqlx"
@@ -17,6 +16,7 @@ import (
 
 	"github.com/resourced/resourced-master/contexthelper"
 	"github.com/resourced/resourced-master/libstring"
+	"github.com/resourced/resourced-master/sms"
 )
 
 func NewCheck(ctx context.Context) *Check {
@@ -40,6 +40,14 @@ type CheckRow struct {
 	Name                  string              `db:"name"`
 	Interval              string              `db:"interval"`
 	IsSilenced            bool                `db:"is_silenced"`
+	SilencedUntil         *time.Time          `db:"silenced_until"`
+	SilenceReason         string              `db:"silence_reason"`
+	EscalationPolicyID    *int64              `db:"escalation_policy_id"`
+	IsFlapping            bool                `db:"is_flapping"`
+	FlappingSince         *time.Time          `db:"flapping_since"`
+	FlapPercent           float64             `db:"flap_percent"`
+	PerHostAlerting       bool                `db:"per_host_alerting"`
+	ParentIDs             sqlx_types.JSONText `db:"parent_ids"`
 	HostsQuery            string              `db:"hosts_query"`
 	HostsList             sqlx_types.JSONText `db:"hosts_list"`
 	Expressions           sqlx_types.JSONText `db:"expressions"`
@@ -64,20 +72,122 @@ type CheckExpression struct {
 	Password   string
 	HTTPMethod string
 	HTTPBody   string
-	Result     struct {
+
+	// Timeout bounds network expressions, e.g. "5s".
+	Timeout string
+
+	// DNSName is resolved by DNS expressions, using each host as the nameserver.
+	// When empty, each hostname is resolved through the system resolver instead.
+	DNSName       string
+	DNSRecordType string
+	DNSExpected   string
+
+	// TLSServerName overrides the hostname used to verify certificates.
+	TLSServerName  string
+	CertExpiryDays int
+
+	// HTTPPath is appended to scheme://host:port, e.g. "/health".
+	HTTPPath string
+
+	// HTTPStatusCodes lists accepted status codes, e.g. "200,204", "2xx" or "200-299". Defaults to 200.
+	HTTPStatusCodes string
+
+	// HTTPBodyContains, HTTPBodyRegex and HTTPJSONPath assert on the response body.
+	// When HTTPJSONPathValue is empty, HTTPJSONPath only needs to exist.
+	HTTPBodyContains  string
+	HTTPBodyRegex     string
+	HTTPJSONPath      string
+	HTTPJSONPathValue string
+
+	// HTTPMaxResponseTime fails a host that responds slower than it, e.g. "500ms".
+	HTTPMaxResponseTime    string
+	HTTPDisableRedirects   bool
+	HTTPInsecureSkipVerify bool
+
+	// Percentile (0-100) is used when PrevAggr is "percentile".
+	Percentile float64
+
+	// AnomalyMethod is "zscore", "lastweek" or "holtwinters". PrevRange is the history, in minutes, the baseline is built from.
+	// Sensitivity is the width of the expected band in standard deviations.
+	AnomalyMethod      string
+	Sensitivity        float64
+	SeasonalityMinutes int
+
+	// ForecastHorizon is how far ahead, e.g. "24h", a Forecast expression projects the trend of the previous PrevRange minutes.
+	ForecastHorizon string
+
+	// NoDataPolicy is how RawHostData and RelativeHostData count hosts without fresh data for Metric, see NoDataPolicyOK.
+	// MaxDataAge, e.g. "10m", is how long ago a host may have last reported before its data counts as missing.
+	NoDataPolicy string `json:",omitempty"`
+	MaxDataAge   string `json:",omitempty"`
+
+	// EventTags are the tags an event must all have to be counted by an EventData expression.
+	// Search, when set, must be found in the event description.
+	EventTags []string `json:",omitempty"`
+
+	// CheckIDs are the component checks of a CheckState expression.
+	// MinHost is then the quorum: how many of them must be failing for the expression to fail.
+	CheckIDs []int64 `json:",omitempty"`
+
+	// HeartbeatID is the monitor a Heartbeat expression watches for missed pings.
+	HeartbeatID int64
+
+	// SyntheticSteps are the requests of a Synthetic expression.
+	SyntheticSteps []SyntheticStep `json:",omitempty"`
+
+	// Expressions are the operands of All, Any and Not groups.
+	Expressions []CheckExpression `json:",omitempty"`
+
+	Result struct {
 		Value         bool
 		Message       string
 		BadHostnames  []string
 		GoodHostnames []string
+
+		// NoDataHostnames are the hosts without fresh data. Depending on NoDataPolicy, they are also good or bad.
+		NoDataHostnames []string `json:",omitempty"`
+
+		// Latencies are measured in milliseconds, keyed by hostname.
+		Latencies map[string]float64
+
+		// Steps are the step results of a Synthetic expression, keyed by hostname.
+		Steps map[string][]SyntheticStepResult `json:",omitempty"`
 	}
 }
 
 type CheckTrigger struct {
-	ID                    int64
-	LowViolationsCount    int64
-	HighViolationsCount   int64
-	CreatedIntervalMinute int64
-	Action                CheckTriggerAction
+	ID                     int64
+	LowViolationsCount     int64
+	HighViolationsCount    int64
+	CreatedIntervalMinute  int64
+	RenotifyIntervalMinute int64
+	Digest                 bool
+	Action                 CheckTriggerAction
+}
+
+// ShouldRenotify decides if a trigger that last notified at lastNotified may notify again at now.
+// violationStarted is when the current streak of violations started, see CheckRow.ViolationStarted.
+func (trigger CheckTrigger) ShouldRenotify(lastNotified, violationStarted, now time.Time) bool {
+	// The check recovered and started failing again since the last notification.
+	if lastNotified.Before(violationStarted) {
+		return true
+	}
+
+	if trigger.RenotifyIntervalMinute <= 0 {
+		return true
+	}
+
+	return now.Sub(lastNotified) >= time.Duration(trigger.RenotifyIntervalMinute)*time.Minute
+}
+
+// NextSendTime returns when a notification produced at now should be delivered.
+// Digest triggers wait until the next digest boundary, the rest wait for the next grouping flush.
+func (trigger CheckTrigger) NextSendTime(now time.Time, digestInterval time.Duration) time.Time {
+	if !trigger.Digest || digestInterval <= 0 {
+		return now
+	}
+
+	return now.Truncate(digestInterval).Add(digestInterval)
 }
 
 type CheckTriggerAction struct {
@@ -88,6 +198,7 @@ type CheckTriggerAction struct {
 	PagerDutyServiceKey  string
 	PagerDutyIncidentKey string
 	PagerDutyDescription string
+	OnCallScheduleID     int64
 }
 
 type Check struct {
@@ -293,6 +404,7 @@ func (checkRow *CheckRow) GetHostsList() ([]string, error) {
 	return container, nil
 }
 
+// GetExpressions returns the expression tree of a check. Legacy flat lists are nested on the fly.
 func (checkRow *CheckRow) GetExpressions() ([]CheckExpression, error) {
 	var expressions []CheckExpression
 
@@ -301,12 +413,113 @@ func (checkRow *CheckRow) GetExpressions() ([]CheckExpression, error) {
 		return expressions, err
 	}
 
-	return expressions, nil
+	return NestFlatExpressions(expressions), nil
+}
+
+// IsSilencedAt checks if the check is silenced at t.
+// A silence without SilencedUntil lasts until it is removed by hand.
+func (checkRow *CheckRow) IsSilencedAt(t time.Time) bool {
+	if !checkRow.IsSilenced {
+		return false
+	}
+
+	return checkRow.SilencedUntil == nil || t.Before(*checkRow.SilencedUntil)
+}
+
+// IsSilencedNow checks if the check is silenced right now.
+func (checkRow *CheckRow) IsSilencedNow() bool {
+	return checkRow.IsSilencedAt(time.Now().UTC())
+}
+
+// FailingHostnames returns the hosts that make a ts_checks row fail, see FailingHostnames.
+func (tsCheckRow *TSCheckRow) FailingHostnames() []string {
+	return FailingHostnames(tsCheckRow.GetExpressionsWithoutError(), tsCheckRow.Result)
+}
+
+// BadHostnames returns every bad hostname reported by the expressions of a ts_checks row.
+func (tsCheckRow *TSCheckRow) BadHostnames() []string {
+	return BadHostnames(tsCheckRow.GetExpressionsWithoutError())
+}
+
+// BadHostnames returns every bad hostname reported by the leaves of evaluated expressions.
+func BadHostnames(expressions []CheckExpression) []string {
+	seen := make(map[string]bool)
+	hostnames := make([]string, 0)
+
+	for _, expression := range LeafExpressions(expressions) {
+		for _, hostname := range expression.Result.BadHostnames {
+			if !seen[hostname] {
+				seen[hostname] = true
+				hostnames = append(hostnames, hostname)
+			}
+		}
+	}
+
+	return hostnames
+}
+
+// InMaintenance checks if any active maintenance window covers the last violation.
+// Hosts query scoped windows only apply when every bad host matches the window's query.
+func (checkRow *CheckRow) InMaintenance(ctx context.Context, lastViolation *TSCheckRow) (*MaintenanceWindowRow, error) {
+	var badHostnames []string
+	if lastViolation != nil {
+		badHostnames = lastViolation.BadHostnames()
+	}
+
+	return checkRow.InMaintenanceForHostnames(ctx, badHostnames)
+}
+
+// InMaintenanceForHostnames checks if any active maintenance window covers the check, or every one of badHostnames.
+func (checkRow *CheckRow) InMaintenanceForHostnames(ctx context.Context, badHostnames []string) (*MaintenanceWindowRow, error) {
+	windows, err := NewMaintenanceWindow(ctx).AllActiveByClusterID(nil, checkRow.ClusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, window := range windows {
+		if window.CoversCheck(checkRow) {
+			return window, nil
+		}
+
+		if window.Scope != "hosts_query" || len(badHostnames) == 0 {
+			continue
+		}
+
+		// Hosts under maintenance are often down, so look further back than the checker does.
+		hosts, err := NewHost(ctx, checkRow.ClusterID).AllCompactByClusterIDQueryAndUpdatedInterval(nil, checkRow.ClusterID, window.HostsQuery, "30 days")
+		if err != nil {
+			return nil, err
+		}
+
+		hostnamesInWindow := make(map[string]bool)
+		for _, host := range hosts {
+			hostnamesInWindow[host.Hostname] = true
+		}
+
+		allCovered := true
+		for _, hostname := range badHostnames {
+			if !hostnamesInWindow[hostname] {
+				allCovered = false
+				break
+			}
+		}
+
+		if allCovered {
+			return window, nil
+		}
+	}
+
+	return nil, nil
 }
 
 // func (checkRow *CheckRow) RunTriggers(appConfig config.GeneralConfig, coreDB *sqlx.DB, tsCheckDB *sqlx.DB, mailr *mailer.Mailer) error {
 func (checkRow *CheckRow) RunTriggers(ctx context.Context) error {
-	if checkRow.IsSilenced {
+	if checkRow.IsSilencedAt(time.Now().UTC()) {
+		return nil
+	}
+
+	// Flapping checks only notify when they start and stop flapping, see DetectFlapping.
+	if checkRow.IsFlapping {
 		return nil
 	}
 
@@ -324,6 +537,10 @@ func (checkRow *CheckRow) RunTriggers(ctx context.Context) error {
 
 	deletedFrom := clusterRow.GetDeletedFromUNIXTimestampForSelect("ts_checks")
 
+	if checkRow.PerHostAlerting {
+		return checkRow.RunHostTriggers(ctx, triggers, deletedFrom)
+	}
+
 	for _, trigger := range triggers {
 		tsCheckRows, err := NewTSCheck(ctx, checkRow.ClusterID).AllViolationsByClusterIDCheckIDAndInterval(nil, checkRow.ClusterID, checkRow.ID, trigger.CreatedIntervalMinute, deletedFrom)
 		if err != nil {
@@ -337,30 +554,38 @@ func (checkRow *CheckRow) RunTriggers(ctx context.Context) error {
 		lastViolation := tsCheckRows[0]
 		violationsCount := len(tsCheckRows)
 
+		window, err := checkRow.InMaintenance(ctx, lastViolation)
+		if err != nil {
+			logrus.Error(err)
+		}
+		if window != nil {
+			logrus.WithFields(logrus.Fields{
+				"Method":            "CheckRow.RunTriggers",
+				"CheckID":           checkRow.ID,
+				"MaintenanceWindow": window.Name,
+			}).Info("Trigger muted by maintenance window")
+			continue
+		}
+
+		if lastViolation.SuppressedByParentID != nil {
+			logrus.WithFields(logrus.Fields{
+				"Method":   "CheckRow.RunTriggers",
+				"CheckID":  checkRow.ID,
+				"ParentID": *lastViolation.SuppressedByParentID,
+			}).Info("Trigger suppressed by parent")
+			continue
+		}
+
 		if int64(violationsCount) >= trigger.LowViolationsCount && int64(violationsCount) <= trigger.HighViolationsCount {
-			if trigger.Action.Transport == "nothing" {
-				// Do nothing
-
-			} else if trigger.Action.Transport == "email" {
-				err = checkRow.RunEmailTrigger(ctx, trigger, lastViolation, violationsCount)
-				if err != nil {
-					logrus.Error(err)
-					continue
-				}
-
-			} else if trigger.Action.Transport == "sms" {
-				err = checkRow.RunSMSTrigger(ctx, trigger, lastViolation, violationsCount)
-				if err != nil {
-					logrus.Error(err)
-					continue
-				}
-
-			} else if trigger.Action.Transport == "pagerduty" {
-				err = checkRow.RunPagerDutyTrigger(ctx, trigger, lastViolation)
-				if err != nil {
-					logrus.Error(err)
-					continue
-				}
+			err = checkRow.RunTrigger(ctx, trigger, "", lastViolation, violationsCount)
+			if err != nil {
+				logrus.WithFields(logrus.Fields{
+					"Method":    "CheckRow.RunTriggers",
+					"CheckID":   checkRow.ID,
+					"TriggerID": trigger.ID,
+					"Transport": trigger.Action.Transport,
+				}).Error(err)
+				continue
 			}
 		}
 	}
@@ -368,122 +593,269 @@ func (checkRow *CheckRow) RunTriggers(ctx context.Context) error {
 	return nil
 }
 
-func (checkRow *CheckRow) BuildEmailTriggerContent(lastViolation *TSCheckRow, templateRoot string) (string, error) {
-	funcMap := template.FuncMap{
-		"addInt": func(left, right int) int {
-			return left + right
-		},
+// ViolationStarted returns when the current streak of violations started: when the check last passed,
+// or when hostname started failing if the check alerts per host. It is zero when the check never passed.
+// Unlike the violations a trigger counts, it does not move forward while an outage outlasts trigger.CreatedIntervalMinute.
+func (checkRow *CheckRow) ViolationStarted(ctx context.Context, hostname string) (time.Time, error) {
+	if hostname != "" {
+		state, err := NewCheckHostState(ctx).GetByCheckIDAndHostname(nil, checkRow.ID, hostname)
+		if err != nil {
+			if strings.Contains(err.Error(), "no rows in result set") {
+				return time.Time{}, nil
+			}
+			return time.Time{}, err
+		}
+
+		if state.FailingSince == nil {
+			return time.Time{}, nil
+		}
+		return *state.FailingSince, nil
 	}
 
-	t, err := template.New("email-trigger.txt.tmpl").Funcs(funcMap).ParseFiles(libstring.ExpandTildeAndEnv(templateRoot + "/templates/checks/email-trigger.txt.tmpl"))
+	lastGood, err := NewTSCheck(ctx, checkRow.ClusterID).LastByClusterIDCheckIDAndResult(nil, checkRow.ClusterID, checkRow.ID, false)
 	if err != nil {
-		return "", err
+		if strings.Contains(err.Error(), "no rows in result set") {
+			return time.Time{}, nil
+		}
+		return time.Time{}, err
 	}
 
-	var contentBuffer bytes.Buffer
+	return lastGood.Created, nil
+}
 
-	vars := struct {
-		Check         *CheckRow
-		LastViolation *TSCheckRow
-	}{
-		checkRow,
-		lastViolation,
+// RunTrigger applies the notification policy of a trigger before handing it over to its transport.
+// hostname is the failing host when the check alerts per host, empty otherwise.
+func (checkRow *CheckRow) RunTrigger(ctx context.Context, trigger CheckTrigger, hostname string, lastViolation *TSCheckRow, violationsCount int) (err error) {
+	if trigger.Action.Transport == "nothing" {
+		return nil
 	}
 
-	err = t.Execute(&contentBuffer, vars)
+	violationStarted, err := checkRow.ViolationStarted(ctx, hostname)
 	if err != nil {
-		return "", err
+		return err
 	}
 
-	return contentBuffer.String(), nil
+	notification := NewCheckNotification(ctx)
+
+	shouldNotify, err := notification.ShouldNotify(nil, checkRow.ID, trigger, hostname, violationStarted)
+	if err != nil {
+		return err
+	}
+	if !shouldNotify {
+		return nil
+	}
+
+	if trigger.Action.Transport == "email" {
+		err = checkRow.RunEmailTrigger(ctx, trigger, hostname, lastViolation, violationsCount)
+
+	} else if trigger.Action.Transport == "sms" {
+		err = checkRow.RunSMSTrigger(ctx, trigger, hostname, lastViolation, violationsCount)
+
+	} else if trigger.Action.Transport == "pagerduty" {
+		err = checkRow.RunPagerDutyTrigger(ctx, trigger, hostname, lastViolation)
+
+	} else if trigger.Action.Transport == "escalation" {
+		err = checkRow.RunEscalationTrigger(ctx, lastViolation)
+	}
+	if err != nil {
+		// Email and SMS that could not even be queued, e.g. because of a malformed recipient.
+		if trigger.Action.Transport == "email" || trigger.Action.Transport == "sms" {
+			// The phone number is recorded as configured, since it may be why the SMS was not queued.
+			recipient := trigger.Action.Email
+			if trigger.Action.Transport == "sms" {
+				recipient = trigger.Action.SMSPhone
+			}
+
+			recordNotificationDelivery(ctx, &CheckNotificationDeliveryRow{
+				ClusterID: checkRow.ClusterID,
+				CheckID:   checkRow.ID,
+				TriggerID: trigger.ID,
+				Transport: trigger.Action.Transport,
+				Recipient: recipient,
+				Status:    NotificationDeliveryFailed,
+				Error:     err.Error(),
+				Attempt:   1,
+			})
+		}
+		return err
+	}
+
+	return notification.MarkNotified(nil, checkRow.ClusterID, checkRow.ID, trigger.ID, hostname)
+}
+
+// enqueueTriggerNotification hands a notification to the queue which groups and delivers them.
+// htmlBody is optional, emails without it are sent as plain text.
+func (checkRow *CheckRow) enqueueTriggerNotification(ctx context.Context, trigger CheckTrigger, recipient, subject, body, htmlBody string) error {
+	generalConfig, err := contexthelper.GetGeneralConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	digestInterval, err := time.ParseDuration(generalConfig.Checks.Notifications.DigestInterval)
+	if err != nil {
+		digestInterval = time.Hour
+	}
+
+	sendAfter := trigger.NextSendTime(time.Now().UTC(), digestInterval)
+
+	_, err = NewCheckNotificationQueue(ctx).Create(nil, checkRow.ClusterID, checkRow.ID, trigger.ID, trigger.Action.Transport, recipient, subject, body, htmlBody, 0, sendAfter)
+	return err
+}
+
+// BuildEmailTriggerContent renders an alert email with the templates of the cluster, or the default ones.
+func (checkRow *CheckRow) BuildEmailTriggerContent(ctx context.Context, lastViolation *TSCheckRow, violationsCount int) (*EmailTriggerContent, error) {
+	return checkRow.renderEmailTriggerContent(ctx, EmailTriggerData{Check: checkRow, LastViolation: lastViolation, ViolationsCount: violationsCount})
 }
 
-func (checkRow *CheckRow) RunEmailTrigger(ctx context.Context, trigger CheckTrigger, lastViolation *TSCheckRow, violationsCount int) (err error) {
+// renderEmailTriggerContent renders data with the templates of the cluster, or the default ones.
+func (checkRow *CheckRow) renderEmailTriggerContent(ctx context.Context, data EmailTriggerData) (*EmailTriggerContent, error) {
+	emailTemplateRow, err := NewEmailTemplate(ctx).GetOrDefaultByClusterID(nil, checkRow.ClusterID)
+	if err != nil {
+		// Alerts still go out with the default templates.
+		logrus.WithFields(logrus.Fields{
+			"Method":    "checkRow.BuildEmailTriggerContent",
+			"ClusterID": checkRow.ClusterID,
+		}).Error(err)
+		emailTemplateRow = nil
+	}
+
+	return emailTemplateRow.Render(data)
+}
+
+// RunEmailTrigger queues an email notification.
+func (checkRow *CheckRow) RunEmailTrigger(ctx context.Context, trigger CheckTrigger, hostname string, lastViolation *TSCheckRow, violationsCount int) (err error) {
 	if trigger.Action.Email == "" {
 		return fmt.Errorf("Unable to send email because trigger.Action.Email is empty")
 	}
 
-	mailr, err := contexthelper.GetMailer(ctx, "GeneralConfig.Checks")
+	to := trigger.Action.Email
+
+	content, err := checkRow.renderEmailTriggerContent(ctx, EmailTriggerData{Check: checkRow, Hostname: hostname, LastViolation: lastViolation, ViolationsCount: violationsCount})
 	if err != nil {
-		logrus.Error(err)
-		return err
+		return fmt.Errorf("Unable to send email because of malformed email content. Error: %v", err)
 	}
 
-	to := trigger.Action.Email
-	subject := fmt.Sprintf(`Check(ID: %v): %v, failed %v times`, checkRow.ID, checkRow.Name, violationsCount)
-	body := ""
+	err = checkRow.enqueueTriggerNotification(ctx, trigger, to, content.Subject, content.TextBody, content.HTMLBody)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Method":    "checkRow.RunEmailTrigger",
+			"Transport": trigger.Action.Transport,
+			"To":        to,
+			"Subject":   content.Subject,
+		}).Error(err)
+	}
 
-	if lastViolation != nil {
-		body, err = checkRow.BuildEmailTriggerContent(lastViolation, ".")
-		if err != nil {
-			return fmt.Errorf("Unable to send email because of malformed email content. Error: %v", err)
-		}
+	return err
+}
+
+// RunSMSTrigger queues an SMS notification. It is delivered by the SMS provider when one is configured,
+// to an E.164 phone number, otherwise through the carrier email gateway. See smsRecipient.
+func (checkRow *CheckRow) RunSMSTrigger(ctx context.Context, trigger CheckTrigger, hostname string, lastViolation *TSCheckRow, violationsCount int) (err error) {
+	to, err := smsRecipient(ctx, trigger.Action)
+	if err != nil {
+		return fmt.Errorf("Unable to send SMS. Error: %v", err)
 	}
 
-	err = mailr.Send(to, subject, body)
+	subject := fmt.Sprintf(`Check(ID: %v): %v, failed %v times`, checkRow.ID, checkRow.Name, violationsCount)
+	if hostname != "" {
+		subject = fmt.Sprintf(`Check(ID: %v): %v, failed %v times on %v`, checkRow.ID, checkRow.Name, violationsCount, hostname)
+	}
+
+	err = checkRow.enqueueTriggerNotification(ctx, trigger, to, subject, "", "")
 	if err != nil {
 		logrus.WithFields(logrus.Fields{
-			"Method":      "checkRow.RunEmailTrigger",
-			"Transport":   trigger.Action.Transport,
-			"HostAndPort": mailr.HostAndPort,
-			"From":        mailr.From,
-			"To":          to,
-			"Subject":     subject,
+			"Method":    "checkRow.RunSMSTrigger",
+			"Transport": trigger.Action.Transport,
+			"To":        to,
+			"Subject":   subject,
 		}).Error(err)
 	}
 
 	return err
 }
 
-func (checkRow *CheckRow) RunSMSTrigger(ctx context.Context, trigger CheckTrigger, lastViolation *TSCheckRow, violationsCount int) (err error) {
-	carrier := strings.ToLower(trigger.Action.SMSCarrier)
+// MaskPagerDutyServiceKey returns the last 4 characters of a service key, which identify it without letting anyone use it.
+func MaskPagerDutyServiceKey(serviceKey string) string {
+	if len(serviceKey) <= 4 {
+		return "****"
+	}
+	return "****" + serviceKey[len(serviceKey)-4:]
+}
 
-	generalConfig, err := contexthelper.GetGeneralConfig(ctx)
+// submitPagerDutyEventJSON submits an event queued by RunPagerDutyTrigger.
+func submitPagerDutyEventJSON(eventJSON string) error {
+	event := &pagerduty.Event{}
+
+	err := json.Unmarshal([]byte(eventJSON), event)
 	if err != nil {
-		logrus.Error(err)
 		return err
 	}
 
-	gateway, ok := generalConfig.Checks.SMSEmailGateway[carrier]
-	if !ok {
-		return fmt.Errorf("Unable to lookup SMS Gateway for carrier: %v", carrier)
+	_, _, err = pagerduty.Submit(event)
+	return err
+}
+
+// smsRecipient returns where an SMS action is delivered to.
+// That is an E.164 phone number when an SMS provider is configured, the carrier email gateway address otherwise.
+func smsRecipient(ctx context.Context, action CheckTriggerAction) (string, error) {
+	if _, err := contexthelper.GetSMSProvider(ctx, "GeneralConfig.Checks"); err == nil {
+		return sms.NormalizeE164(action.SMSPhone)
 	}
 
-	mailr, err := contexthelper.GetMailer(ctx, "GeneralConfig.Checks")
+	carrier := strings.ToLower(action.SMSCarrier)
+
+	generalConfig, err := contexthelper.GetGeneralConfig(ctx)
 	if err != nil {
 		logrus.Error(err)
-		return err
+		return "", err
 	}
 
-	flattenPhone := libstring.FlattenPhone(trigger.Action.SMSPhone)
+	gateway, ok := generalConfig.Checks.SMSEmailGateway[carrier]
+	if !ok {
+		return "", fmt.Errorf("Unable to lookup SMS Gateway for carrier: %v", carrier)
+	}
+
+	flattenPhone := libstring.FlattenPhone(action.SMSPhone)
 	if len(flattenPhone) != 10 {
-		logrus.Warningf("Length of phone number is not 10. Flatten phone number: %v. Length: %v", flattenPhone, len(flattenPhone))
-		return nil
+		return "", fmt.Errorf("Carrier SMS gateways require 10 digit phone numbers. Phone number: %v", action.SMSPhone)
 	}
 
-	to := fmt.Sprintf("%v@%v", flattenPhone, gateway)
-	subject := ""
-	body := fmt.Sprintf(`Check(ID: %v): %v, failed %v times`, checkRow.ID, checkRow.Name, violationsCount)
+	return fmt.Sprintf("%v@%v", flattenPhone, gateway), nil
+}
 
-	err = mailr.Send(to, subject, body)
-	if err != nil {
-		logrus.WithFields(logrus.Fields{
-			"Method":      "checkRow.RunSMSTrigger",
-			"Transport":   trigger.Action.Transport,
-			"HostAndPort": mailr.HostAndPort,
-			"From":        mailr.From,
-			"To":          to,
-			"Subject":     subject,
-		}).Error(err)
+// ValidateSMSAction makes sure the phone number of an SMS action can be delivered to.
+func ValidateSMSAction(ctx context.Context, action CheckTriggerAction) error {
+	_, err := smsRecipient(ctx, action)
+	return err
+}
+
+// deliverSMS sends body to a recipient returned by smsRecipient.
+func deliverSMS(ctx context.Context, to, body string) error {
+	if sms.IsE164(to) {
+		provider, err := contexthelper.GetSMSProvider(ctx, "GeneralConfig.Checks")
+		if err != nil {
+			return err
+		}
+		return provider.Send(to, body)
 	}
 
-	return err
+	mailr, err := contexthelper.GetMailer(ctx, "GeneralConfig.Checks")
+	if err != nil {
+		return err
+	}
+	return mailr.Send(to, "", body)
 }
 
-func (checkRow *CheckRow) RunPagerDutyTrigger(ctx context.Context, trigger CheckTrigger, lastViolation *TSCheckRow) (err error) {
+func (checkRow *CheckRow) RunPagerDutyTrigger(ctx context.Context, trigger CheckTrigger, hostname string, lastViolation *TSCheckRow) (err error) {
 	// Create a new PD "trigger" event
 	event := pagerduty.NewTriggerEvent(trigger.Action.PagerDutyServiceKey, trigger.Action.PagerDutyDescription)
 
+	// Every failing host gets its own incident.
+	if hostname != "" {
+		event.Description = fmt.Sprintf("%v on %v", event.Description, hostname)
+		event.IncidentKey = fmt.Sprintf("resourced-check-%v-%v", checkRow.ID, hostname)
+	}
+
 	// Add details to PD event
 	if lastViolation != nil {
 		err = lastViolation.Expressions.Unmarshal(&event.Details)
@@ -492,16 +864,53 @@ func (checkRow *CheckRow) RunPagerDutyTrigger(ctx context.Context, trigger Check
 		}
 	}
 
-	hostname, _ := os.Hostname()
+	masterHostname, _ := os.Hostname()
 
 	// Add Client to PD event
-	event.Client = fmt.Sprintf("ResourceD Master on: %v", hostname)
+	event.Client = fmt.Sprintf("ResourceD Master on: %v", masterHostname)
+
+	eventJSON, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	// The service key is a credential, it only travels inside the event.
+	recipient := MaskPagerDutyServiceKey(trigger.Action.PagerDutyServiceKey)
 
 	// Submit PD event
 	pdResponse, _, err := pagerduty.Submit(event)
 	if err != nil {
-		return err
+		// The event is retried from the notification queue.
+		_, queueErr := NewCheckNotificationQueue(ctx).Create(nil, checkRow.ClusterID, checkRow.ID, trigger.ID, "pagerduty", recipient, trigger.Action.PagerDutyDescription, string(eventJSON), "", 1, time.Now().UTC().Add(NotificationRetryBackoff(1)))
+		if queueErr != nil {
+			return err
+		}
+
+		recordNotificationDelivery(ctx, &CheckNotificationDeliveryRow{
+			ClusterID:   checkRow.ClusterID,
+			CheckID:     checkRow.ID,
+			TriggerID:   trigger.ID,
+			Transport:   "pagerduty",
+			Recipient:   recipient,
+			PayloadHash: NotificationPayloadHash(string(eventJSON)),
+			Status:      NotificationDeliveryRetrying,
+			Error:       err.Error(),
+			Attempt:     1,
+		})
+		return nil
 	}
+
+	recordNotificationDelivery(ctx, &CheckNotificationDeliveryRow{
+		ClusterID:   checkRow.ClusterID,
+		CheckID:     checkRow.ID,
+		TriggerID:   trigger.ID,
+		Transport:   "pagerduty",
+		Recipient:   recipient,
+		PayloadHash: NotificationPayloadHash(string(eventJSON)),
+		Status:      NotificationDeliverySent,
+		Attempt:     1,
+	})
+
 	if pdResponse == nil {
 		return nil
 	}
@@ -0,0 +1,232 @@
+package pg
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// CheckOutage is a stretch of time where a check kept failing.
+type CheckOutage struct {
+	Started         time.Time
+	Ended           time.Time
+	DurationSeconds float64
+}
+
+// CheckAvailability summarizes the results of a check between From and To.
+// Time not covered by any result, e.g. before the first evaluation or while the checker was down, is unknown
+// and left out of UptimePercent.
+type CheckAvailability struct {
+	Period string `json:",omitempty"`
+	From   time.Time
+	To     time.Time
+
+	UptimePercent        float64
+	UpSeconds            float64
+	DownSeconds          float64
+	UnknownSeconds       float64
+	OutagesCount         int
+	LongestOutageSeconds float64
+	MTTRSeconds          float64
+	MTBFSeconds          float64
+	Outages              []CheckOutage
+	Breakdown            []CheckAvailability `json:",omitempty"`
+}
+
+type checkResultSegment struct {
+	from time.Time
+	to   time.Time
+	bad  bool
+}
+
+// checkResultSegments turns results, sorted by Created, into segments where each result holds until the next one.
+// A result holds for at most staleAfter, 0 means forever.
+func checkResultSegments(rows []*TSCheckRow, from, to time.Time, staleAfter time.Duration) []checkResultSegment {
+	segments := make([]checkResultSegment, 0)
+
+	for i, row := range rows {
+		start := row.Created
+		end := to
+		if i+1 < len(rows) {
+			end = rows[i+1].Created
+		}
+		if staleAfter > 0 && end.Sub(start) > staleAfter {
+			end = start.Add(staleAfter)
+		}
+
+		if start.Before(from) {
+			start = from
+		}
+		if end.After(to) {
+			end = to
+		}
+		if !end.After(start) {
+			continue
+		}
+
+		// Merge with the previous segment when nothing changed in between.
+		if len(segments) > 0 {
+			last := &segments[len(segments)-1]
+			if last.bad == row.Result && last.to.Equal(start) {
+				last.to = end
+				continue
+			}
+		}
+
+		segments = append(segments, checkResultSegment{from: start, to: end, bad: row.Result})
+	}
+
+	return segments
+}
+
+// NewCheckAvailability computes availability between from and to from rows sorted by Created.
+// rows may start with the last result before from, which then holds from the start of the range.
+func NewCheckAvailability(rows []*TSCheckRow, from, to time.Time, staleAfter time.Duration) CheckAvailability {
+	availability := CheckAvailability{From: from, To: to, Outages: make([]CheckOutage, 0)}
+
+	previousBad := false
+
+	for _, segment := range checkResultSegments(rows, from, to, staleAfter) {
+		seconds := segment.to.Sub(segment.from).Seconds()
+
+		if !segment.bad {
+			availability.UpSeconds = availability.UpSeconds + seconds
+			previousBad = false
+			continue
+		}
+
+		availability.DownSeconds = availability.DownSeconds + seconds
+
+		// Bad segments separated only by unknown time are still one outage.
+		if previousBad {
+			last := &availability.Outages[len(availability.Outages)-1]
+			last.Ended = segment.to
+			last.DurationSeconds = last.DurationSeconds + seconds
+			continue
+		}
+		previousBad = true
+
+		availability.Outages = append(availability.Outages, CheckOutage{Started: segment.from, Ended: segment.to, DurationSeconds: seconds})
+	}
+
+	availability.UnknownSeconds = to.Sub(from).Seconds() - availability.UpSeconds - availability.DownSeconds
+
+	known := availability.UpSeconds + availability.DownSeconds
+	if known > 0 {
+		availability.UptimePercent = availability.UpSeconds / known * 100
+	}
+
+	availability.OutagesCount = len(availability.Outages)
+
+	for _, outage := range availability.Outages {
+		if outage.DurationSeconds > availability.LongestOutageSeconds {
+			availability.LongestOutageSeconds = outage.DurationSeconds
+		}
+	}
+
+	// MTTR and MTBF stay at 0 when there were no outages.
+	if availability.OutagesCount > 0 {
+		availability.MTTRSeconds = availability.DownSeconds / float64(availability.OutagesCount)
+		availability.MTBFSeconds = availability.UpSeconds / float64(availability.OutagesCount)
+	}
+
+	return availability
+}
+
+// AvailabilityPeriods splits from and to into UTC calendar days, weeks starting on Monday, or months.
+func AvailabilityPeriods(from, to time.Time, period string) ([][2]time.Time, error) {
+	from = from.UTC()
+	to = to.UTC()
+
+	var start time.Time
+	var next func(time.Time) time.Time
+
+	switch period {
+	case "day":
+		start = time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+		next = func(t time.Time) time.Time { return t.AddDate(0, 0, 1) }
+	case "week":
+		daysSinceMonday := (int(from.Weekday()) + 6) % 7
+		start = time.Date(from.Year(), from.Month(), from.Day()-daysSinceMonday, 0, 0, 0, 0, time.UTC)
+		next = func(t time.Time) time.Time { return t.AddDate(0, 0, 7) }
+	case "month":
+		start = time.Date(from.Year(), from.Month(), 1, 0, 0, 0, 0, time.UTC)
+		next = func(t time.Time) time.Time { return t.AddDate(0, 1, 0) }
+	default:
+		return nil, fmt.Errorf("Unrecognized breakdown: %v", period)
+	}
+
+	periods := make([][2]time.Time, 0)
+
+	for t := start; t.Before(to); t = next(t) {
+		periodFrom := t
+		if periodFrom.Before(from) {
+			periodFrom = from
+		}
+		periodTo := next(t)
+		if periodTo.After(to) {
+			periodTo = to
+		}
+
+		periods = append(periods, [2]time.Time{periodFrom, periodTo})
+	}
+
+	return periods, nil
+}
+
+// NewCheckAvailabilityWithBreakdown computes availability of the whole range, plus one entry per period: "day", "week" or "month".
+func NewCheckAvailabilityWithBreakdown(rows []*TSCheckRow, from, to time.Time, staleAfter time.Duration, period string) (CheckAvailability, error) {
+	availability := NewCheckAvailability(rows, from, to, staleAfter)
+
+	if period == "" {
+		return availability, nil
+	}
+
+	periods, err := AvailabilityPeriods(from, to, period)
+	if err != nil {
+		return availability, err
+	}
+
+	availability.Breakdown = make([]CheckAvailability, 0, len(periods))
+
+	for _, p := range periods {
+		periodAvailability := NewCheckAvailability(rows, p[0], p[1], staleAfter)
+		periodAvailability.Period = period
+		availability.Breakdown = append(availability.Breakdown, periodAvailability)
+	}
+
+	return availability, nil
+}
+
+// CSVRecords returns a header and one record per breakdown period, followed by the whole range.
+func (availability CheckAvailability) CSVRecords() [][]string {
+	records := [][]string{
+		{"period", "from", "to", "uptime_percent", "up_seconds", "down_seconds", "unknown_seconds", "outages", "longest_outage_seconds", "mttr_seconds", "mtbf_seconds"},
+	}
+
+	record := func(period string, a CheckAvailability) []string {
+		formatFloat := func(f float64) string {
+			return strconv.FormatFloat(f, 'f', 3, 64)
+		}
+
+		return []string{
+			period,
+			a.From.UTC().Format(time.RFC3339),
+			a.To.UTC().Format(time.RFC3339),
+			formatFloat(a.UptimePercent),
+			formatFloat(a.UpSeconds),
+			formatFloat(a.DownSeconds),
+			formatFloat(a.UnknownSeconds),
+			strconv.Itoa(a.OutagesCount),
+			formatFloat(a.LongestOutageSeconds),
+			formatFloat(a.MTTRSeconds),
+			formatFloat(a.MTBFSeconds),
+		}
+	}
+
+	for _, periodAvailability := range availability.Breakdown {
+		records = append(records, record(periodAvailability.Period, periodAvailability))
+	}
+
+	return append(records, record("total", availability))
+}
@@ -0,0 +1,101 @@
+package pg
+
+import (
+	"testing"
+	"time"
+)
+
+func tsCheckRowsForTest(start time.Time, step time.Duration, results ...bool) []*TSCheckRow {
+	rows := make([]*TSCheckRow, 0, len(results))
+	for i, result := range results {
+		rows = append(rows, &TSCheckRow{Created: start.Add(time.Duration(i) * step), Result: result})
+	}
+	return rows
+}
+
+func TestNewCheckAvailability(t *testing.T) {
+	start := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// Good for 4 minutes, bad for 2, good for 2, bad for 1, good for 1.
+	rows := tsCheckRowsForTest(start, time.Minute, false, false, false, false, true, true, false, false, true, false)
+
+	availability := NewCheckAvailability(rows, start, start.Add(10*time.Minute), 0)
+
+	if availability.UpSeconds != 7*60 || availability.DownSeconds != 3*60 {
+		t.Fatalf("Expected 7 minutes up and 3 minutes down. Got: %v up, %v down", availability.UpSeconds, availability.DownSeconds)
+	}
+	if availability.UptimePercent != 70 {
+		t.Errorf("Uptime should be 70%%. Got: %v", availability.UptimePercent)
+	}
+	if availability.OutagesCount != 2 || availability.LongestOutageSeconds != 120 {
+		t.Errorf("Expected 2 outages, the longest of 2 minutes. Got: %v", availability.Outages)
+	}
+	if availability.MTTRSeconds != 90 || availability.MTBFSeconds != 210 {
+		t.Errorf("Expected MTTR of 90s and MTBF of 210s. Got: %v and %v", availability.MTTRSeconds, availability.MTBFSeconds)
+	}
+	if !availability.Outages[0].Started.Equal(start.Add(4*time.Minute)) || !availability.Outages[0].Ended.Equal(start.Add(6*time.Minute)) {
+		t.Errorf("First outage should be from minute 4 to 6. Got: %v", availability.Outages[0])
+	}
+}
+
+func TestNewCheckAvailabilityUnknownTime(t *testing.T) {
+	start := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// The result before the range holds when the range starts, the last one goes stale after 2 minutes.
+	rows := tsCheckRowsForTest(start.Add(-time.Minute), 2*time.Minute, true, false)
+
+	availability := NewCheckAvailability(rows, start, start.Add(10*time.Minute), 2*time.Minute)
+
+	if availability.DownSeconds != 60 || availability.UpSeconds != 120 || availability.UnknownSeconds != 7*60 {
+		t.Errorf("Expected 1 minute down, 2 up and 7 unknown. Got: %v down, %v up, %v unknown", availability.DownSeconds, availability.UpSeconds, availability.UnknownSeconds)
+	}
+
+	empty := NewCheckAvailability(nil, start, start.Add(time.Hour), 0)
+	if empty.UptimePercent != 0 || empty.UnknownSeconds != 3600 || empty.OutagesCount != 0 {
+		t.Errorf("Range without results should be unknown. Got: %v", empty)
+	}
+}
+
+func TestAvailabilityPeriods(t *testing.T) {
+	// Wednesday noon to the following Tuesday.
+	from := time.Date(2016, 1, 6, 12, 0, 0, 0, time.UTC)
+	to := time.Date(2016, 1, 12, 0, 0, 0, 0, time.UTC)
+
+	days, err := AvailabilityPeriods(from, to, "day")
+	if err != nil || len(days) != 6 || !days[0][0].Equal(from) || !days[1][0].Equal(time.Date(2016, 1, 7, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected 6 days starting at from. Got: %v, %v", days, err)
+	}
+
+	weeks, err := AvailabilityPeriods(from, to, "week")
+	if err != nil || len(weeks) != 2 || !weeks[1][0].Equal(time.Date(2016, 1, 11, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected 2 weeks, the second starting on Monday. Got: %v, %v", weeks, err)
+	}
+
+	months, err := AvailabilityPeriods(from, time.Date(2016, 3, 1, 0, 0, 0, 0, time.UTC), "month")
+	if err != nil || len(months) != 2 || !months[1][1].Equal(time.Date(2016, 3, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected January and February. Got: %v, %v", months, err)
+	}
+
+	_, err = AvailabilityPeriods(from, to, "year")
+	if err == nil {
+		t.Errorf("Unknown breakdown should fail")
+	}
+}
+
+func TestCheckAvailabilityCSVRecords(t *testing.T) {
+	start := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+	rows := tsCheckRowsForTest(start, 12*time.Hour, false, true, false, false)
+
+	availability, err := NewCheckAvailabilityWithBreakdown(rows, start, start.Add(48*time.Hour), 0, "day")
+	if err != nil {
+		t.Fatalf("Computing availability should work. Error: %v", err)
+	}
+
+	records := availability.CSVRecords()
+	if len(records) != 4 {
+		t.Fatalf("Expected a header, 2 days and a total. Got: %v", records)
+	}
+	if records[1][0] != "day" || records[1][3] != "50.000" || records[2][3] != "100.000" || records[3][0] != "total" || records[3][3] != "75.000" {
+		t.Errorf("Unexpected records: %v", records)
+	}
+}
@@ -0,0 +1,204 @@
+package pg
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// GetParentIDs returns the checks this check depends on.
+func (checkRow *CheckRow) GetParentIDs() []int64 {
+	parentIDs := make([]int64, 0)
+	json.Unmarshal(checkRow.ParentIDs, &parentIDs)
+
+	return parentIDs
+}
+
+// FindCheckDependencyCycle returns a cycle in parentsByCheckID, e.g. [1, 2, 1], or nil when there is none.
+func FindCheckDependencyCycle(parentsByCheckID map[int64][]int64) []int64 {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+
+	states := make(map[int64]int)
+	path := make([]int64, 0)
+
+	var visit func(checkID int64) []int64
+	visit = func(checkID int64) []int64 {
+		states[checkID] = visiting
+		path = append(path, checkID)
+
+		for _, parentID := range parentsByCheckID[checkID] {
+			switch states[parentID] {
+			case visiting:
+				for i, id := range path {
+					if id == parentID {
+						return append(append([]int64{}, path[i:]...), parentID)
+					}
+				}
+			case unvisited:
+				if cycle := visit(parentID); cycle != nil {
+					return cycle
+				}
+			}
+		}
+
+		path = path[:len(path)-1]
+		states[checkID] = visited
+		return nil
+	}
+
+	// Sorted for a stable error message.
+	checkIDs := make([]int64, 0, len(parentsByCheckID))
+	for checkID := range parentsByCheckID {
+		checkIDs = append(checkIDs, checkID)
+	}
+	sort.Slice(checkIDs, func(i, j int) bool { return checkIDs[i] < checkIDs[j] })
+
+	for _, checkID := range checkIDs {
+		if states[checkID] == unvisited {
+			if cycle := visit(checkID); cycle != nil {
+				return cycle
+			}
+		}
+	}
+
+	return nil
+}
+
+// ValidateParentIDs makes sure the parents of a check exist in the same cluster and do not create a cycle.
+// checkID is 0 for checks that are not created yet.
+func (c *Check) ValidateParentIDs(tx *sqlx.Tx, clusterID, checkID int64, parentIDs []int64) error {
+	if len(parentIDs) == 0 {
+		return nil
+	}
+
+	checkRows, err := c.AllByClusterID(tx, clusterID)
+	if err != nil {
+		return err
+	}
+
+	parentsByCheckID := make(map[int64][]int64)
+	for _, checkRow := range checkRows {
+		parentsByCheckID[checkRow.ID] = checkRow.GetParentIDs()
+	}
+
+	for _, parentID := range parentIDs {
+		if parentID == checkID {
+			return fmt.Errorf("Check cannot depend on itself")
+		}
+		if _, ok := parentsByCheckID[parentID]; !ok {
+			return fmt.Errorf("Parent check with ID: %v does not exist in this cluster", parentID)
+		}
+	}
+
+	if checkID == 0 {
+		return nil
+	}
+
+	parentsByCheckID[checkID] = parentIDs
+
+	cycle := FindCheckDependencyCycle(parentsByCheckID)
+	if cycle != nil {
+		ids := make([]string, len(cycle))
+		for i, id := range cycle {
+			ids[i] = fmt.Sprintf("%v", id)
+		}
+		return fmt.Errorf("Check dependencies cannot form a cycle: %v", strings.Join(ids, " -> "))
+	}
+
+	return nil
+}
+
+// FailingParent returns the first parent whose latest result is bad, or nil when every parent is fine.
+func (checkRow *CheckRow) FailingParent(ctx context.Context) (*CheckRow, error) {
+	for _, parentID := range checkRow.GetParentIDs() {
+		parentRow, err := NewCheck(ctx).GetByID(nil, parentID)
+		if err != nil {
+			// The parent was deleted.
+			if strings.Contains(err.Error(), "no rows in result set") {
+				continue
+			}
+			return nil, err
+		}
+
+		tsCheckRows, err := NewTSCheck(ctx, parentRow.ClusterID).LastByClusterIDCheckIDAndLimit(nil, parentRow.ClusterID, parentRow.ID, 1)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(tsCheckRows) > 0 && tsCheckRows[0].Result {
+			return parentRow, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// CheckDependencyNode is a check and the checks depending on it.
+type CheckDependencyNode struct {
+	Check    *CheckRow
+	Children []*CheckDependencyNode
+}
+
+// BuildCheckDependencyTree returns the checks that have no parents but have children, with their descendants.
+// A check with several parents shows up under each of them. Checks without any dependency are left out.
+func BuildCheckDependencyTree(checkRows []*CheckRow) []*CheckDependencyNode {
+	checksByID := make(map[int64]*CheckRow)
+	childrenByID := make(map[int64][]*CheckRow)
+
+	for _, checkRow := range checkRows {
+		checksByID[checkRow.ID] = checkRow
+	}
+
+	for _, checkRow := range checkRows {
+		for _, parentID := range checkRow.GetParentIDs() {
+			if _, ok := checksByID[parentID]; ok {
+				childrenByID[parentID] = append(childrenByID[parentID], checkRow)
+			}
+		}
+	}
+
+	var build func(checkRow *CheckRow, ancestors map[int64]bool) *CheckDependencyNode
+	build = func(checkRow *CheckRow, ancestors map[int64]bool) *CheckDependencyNode {
+		node := &CheckDependencyNode{Check: checkRow, Children: make([]*CheckDependencyNode, 0)}
+
+		// Cycles are rejected on save, this only guards against rows edited by hand.
+		ancestors[checkRow.ID] = true
+		for _, child := range childrenByID[checkRow.ID] {
+			if !ancestors[child.ID] {
+				node.Children = append(node.Children, build(child, ancestors))
+			}
+		}
+		delete(ancestors, checkRow.ID)
+
+		return node
+	}
+
+	roots := make([]*CheckDependencyNode, 0)
+
+	for _, checkRow := range checkRows {
+		if len(childrenByID[checkRow.ID]) == 0 {
+			continue
+		}
+
+		hasParent := false
+		for _, parentID := range checkRow.GetParentIDs() {
+			if _, ok := checksByID[parentID]; ok {
+				hasParent = true
+			}
+		}
+
+		if !hasParent {
+			roots = append(roots, build(checkRow, make(map[int64]bool)))
+		}
+	}
+
+	return roots
+}
@@ -0,0 +1,48 @@
+package pg
+
+import (
+	"testing"
+)
+
+func TestFindCheckDependencyCycle(t *testing.T) {
+	acyclic := map[int64][]int64{
+		1: []int64{},
+		2: []int64{1},
+		3: []int64{1, 2},
+	}
+	if cycle := FindCheckDependencyCycle(acyclic); cycle != nil {
+		t.Errorf("Diamond shaped dependencies are not a cycle. Got: %v", cycle)
+	}
+
+	cyclic := map[int64][]int64{
+		1: []int64{3},
+		2: []int64{1},
+		3: []int64{2},
+	}
+	cycle := FindCheckDependencyCycle(cyclic)
+	if len(cycle) != 4 || cycle[0] != cycle[len(cycle)-1] {
+		t.Errorf("Cycle should start and end on the same check. Got: %v", cycle)
+	}
+}
+
+func TestBuildCheckDependencyTree(t *testing.T) {
+	checkRows := []*CheckRow{
+		&CheckRow{ID: 1, Name: "core-switch", ParentIDs: []byte(`[]`)},
+		&CheckRow{ID: 2, Name: "database", ParentIDs: []byte(`[1]`)},
+		&CheckRow{ID: 3, Name: "app", ParentIDs: []byte(`[2]`)},
+		&CheckRow{ID: 4, Name: "standalone", ParentIDs: []byte(`[]`)},
+	}
+
+	roots := BuildCheckDependencyTree(checkRows)
+	if len(roots) != 1 || roots[0].Check.ID != 1 {
+		t.Fatalf("Only core-switch should be a root. Got: %v", roots)
+	}
+
+	if len(roots[0].Children) != 1 || roots[0].Children[0].Check.ID != 2 {
+		t.Fatalf("database should depend on core-switch")
+	}
+
+	if len(roots[0].Children[0].Children) != 1 || roots[0].Children[0].Children[0].Check.ID != 3 {
+		t.Errorf("app should depend on database")
+	}
+}
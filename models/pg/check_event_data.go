@@ -0,0 +1,19 @@
+package pg
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ValidateEventData checks the window and the operator of an EventData expression.
+func ValidateEventData(expression CheckExpression) error {
+	if expression.PrevRange <= 0 {
+		return errors.New("EventData expression needs a positive PrevRange")
+	}
+
+	if expression.Operator != ">" && expression.Operator != "<" {
+		return fmt.Errorf("Unrecognized EventData operator: %v", expression.Operator)
+	}
+
+	return nil
+}
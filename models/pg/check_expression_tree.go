@@ -0,0 +1,285 @@
+package pg
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// A BooleanGroup expression combines its Expressions with its Operator:
+// "all" is bad when every child is bad, "any" when at least one child is bad,
+// and "not" inverts its only child.
+const (
+	BooleanGroupAll = "all"
+	BooleanGroupAny = "any"
+	BooleanGroupNot = "not"
+)
+
+// IsBooleanGroup returns true when expression holds child expressions instead of checking anything itself.
+func (expression CheckExpression) IsBooleanGroup() bool {
+	return expression.Type == "BooleanGroup"
+}
+
+// IsFlatExpressions returns true when expressions is a legacy list joined by BooleanOperator entries.
+func IsFlatExpressions(expressions []CheckExpression) bool {
+	for _, expression := range expressions {
+		if expression.Type == "BooleanOperator" {
+			return true
+		}
+	}
+	return false
+}
+
+// NestFlatExpressions turns a legacy list, e.g. [A, or, B, and, C], into a single tree.
+// Legacy lists were folded left to right, so the tree is ((A or B) and C) to keep their meaning.
+// Lists without BooleanOperator entries are returned as is.
+func NestFlatExpressions(expressions []CheckExpression) []CheckExpression {
+	if !IsFlatExpressions(expressions) {
+		return expressions
+	}
+
+	var root *CheckExpression
+	operator := ""
+
+	// nested is true once root is a group created here, which later operands of the same operator can join.
+	nested := false
+
+	for _, expression := range expressions {
+		if expression.Type == "BooleanOperator" {
+			operator = expression.Operator
+			continue
+		}
+
+		if root == nil {
+			node := expression
+			root = &node
+			continue
+		}
+
+		group := CheckExpression{}
+		group.Type = "BooleanGroup"
+		group.Operator = BooleanGroupAll
+		if operator == "or" {
+			group.Operator = BooleanGroupAny
+		}
+
+		if nested && root.Operator == group.Operator {
+			root.Expressions = append(root.Expressions, expression)
+			continue
+		}
+
+		group.Expressions = []CheckExpression{*root, expression}
+		root = &group
+		nested = true
+	}
+
+	if root == nil {
+		return []CheckExpression{}
+	}
+
+	return []CheckExpression{*root}
+}
+
+// ValidateExpressions rejects trees with malformed groups.
+func ValidateExpressions(expressions []CheckExpression) error {
+	if len(expressions) == 0 {
+		return errors.New("A check needs at least one expression")
+	}
+
+	for _, expression := range expressions {
+		if expression.Type == "BooleanOperator" {
+			return errors.New("BooleanOperator cannot be mixed with nested expressions")
+		}
+
+		if !expression.IsBooleanGroup() {
+			if len(expression.Expressions) > 0 {
+				return fmt.Errorf("%v expression cannot have child expressions", expression.Type)
+			}
+			if expression.Type == "Synthetic" {
+				err := ValidateSyntheticSteps(expression.SyntheticSteps)
+				if err != nil {
+					return err
+				}
+			}
+			if expression.Type == "CheckState" {
+				err := ValidateCheckState(expression)
+				if err != nil {
+					return err
+				}
+			}
+			if expression.Type == "EventData" {
+				err := ValidateEventData(expression)
+				if err != nil {
+					return err
+				}
+			}
+			if expression.Type == "RawHostData" || expression.Type == "RelativeHostData" {
+				err := ValidateNoDataPolicy(expression)
+				if err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		switch expression.Operator {
+		case BooleanGroupAll, BooleanGroupAny:
+			if len(expression.Expressions) == 0 {
+				return fmt.Errorf("%v group needs at least one expression", expression.Operator)
+			}
+		case BooleanGroupNot:
+			if len(expression.Expressions) != 1 {
+				return errors.New("not group needs exactly one expression")
+			}
+		default:
+			return fmt.Errorf("Unrecognized boolean group: %v", expression.Operator)
+		}
+
+		err := ValidateExpressions(expression.Expressions)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// LeafExpressions returns every expression that is not a group, depth first.
+func LeafExpressions(expressions []CheckExpression) []CheckExpression {
+	leaves := make([]CheckExpression, 0)
+
+	for _, expression := range expressions {
+		if expression.IsBooleanGroup() {
+			leaves = append(leaves, LeafExpressions(expression.Expressions)...)
+		} else if expression.Type != "BooleanOperator" {
+			leaves = append(leaves, expression)
+		}
+	}
+
+	return leaves
+}
+
+// NormalizeExpressionsJSON parses expressions submitted by the UI or the API,
+// nests legacy flat lists, validates the tree and returns it as JSON.
+func NormalizeExpressionsJSON(data []byte) ([]byte, error) {
+	var expressions []CheckExpression
+
+	err := json.Unmarshal(data, &expressions)
+	if err != nil {
+		return nil, err
+	}
+
+	expressions = NestFlatExpressions(expressions)
+
+	err = ValidateExpressions(expressions)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(expressions)
+}
+
+// EvalBooleanGroup combines the values of the children of a group. true means bad.
+func EvalBooleanGroup(operator string, values []bool) bool {
+	switch operator {
+	case BooleanGroupAny:
+		for _, value := range values {
+			if value {
+				return true
+			}
+		}
+		return false
+
+	case BooleanGroupNot:
+		return len(values) == 1 && !values[0]
+	}
+
+	if len(values) == 0 {
+		return false
+	}
+
+	for _, value := range values {
+		if !value {
+			return false
+		}
+	}
+	return true
+}
+
+// FailingHostnames returns the hosts that make evaluated expressions fail, in the order leaves report them.
+// Every host is run through the whole tree, so all, any and not groups apply to it, and so does MinHost:
+// a host only fails a leaf that is bad as a whole, and only a leaf that is good as a whole keeps it from failing a not group.
+// Leaves that do not judge hosts count with their own result.
+// failing is the result of the whole check. A passing check has no failing host.
+func FailingHostnames(expressions []CheckExpression, failing bool) []string {
+	hostnames := make([]string, 0)
+	if !failing {
+		return hostnames
+	}
+
+	root := CheckExpression{}
+	root.Type = "BooleanGroup"
+	root.Operator = BooleanGroupAll
+	root.Expressions = NestFlatExpressions(expressions)
+
+	seen := make(map[string]bool)
+
+	for _, leaf := range LeafExpressions(root.Expressions) {
+		for _, hostname := range leaf.judgedHostnames() {
+			if seen[hostname] {
+				continue
+			}
+			seen[hostname] = true
+
+			if root.failsHost(hostname, false) {
+				hostnames = append(hostnames, hostname)
+			}
+		}
+	}
+
+	return hostnames
+}
+
+// judgedHostnames returns every host an evaluated leaf reports on, good, bad or without data.
+func (expression CheckExpression) judgedHostnames() []string {
+	hostnames := make([]string, 0, len(expression.Result.BadHostnames)+len(expression.Result.GoodHostnames)+len(expression.Result.NoDataHostnames))
+	hostnames = append(hostnames, expression.Result.BadHostnames...)
+	hostnames = append(hostnames, expression.Result.GoodHostnames...)
+	hostnames = append(hostnames, expression.Result.NoDataHostnames...)
+
+	return hostnames
+}
+
+// failsHost evaluates an evaluated expression for hostname alone. negated is true under an odd number of not groups,
+// where a leaf counts as bad unless both the leaf and the host are good.
+func (expression CheckExpression) failsHost(hostname string, negated bool) bool {
+	if expression.IsBooleanGroup() {
+		childNegated := negated
+		if expression.Operator == BooleanGroupNot {
+			childNegated = !negated
+		}
+
+		values := make([]bool, 0, len(expression.Expressions))
+		for _, child := range expression.Expressions {
+			values = append(values, child.failsHost(hostname, childNegated))
+		}
+		return EvalBooleanGroup(expression.Operator, values)
+	}
+
+	if len(expression.judgedHostnames()) == 0 {
+		return expression.Result.Value
+	}
+
+	hostIsBad := false
+	for _, badHostname := range expression.Result.BadHostnames {
+		if badHostname == hostname {
+			hostIsBad = true
+			break
+		}
+	}
+
+	if negated {
+		return expression.Result.Value || hostIsBad
+	}
+	return expression.Result.Value && hostIsBad
+}
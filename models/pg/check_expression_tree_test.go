@@ -0,0 +1,181 @@
+package pg
+
+import (
+	"testing"
+)
+
+func leafExpressionForTest(metric string) CheckExpression {
+	expression := CheckExpression{}
+	expression.Type = "RawHostData"
+	expression.Metric = metric
+	return expression
+}
+
+func booleanOperatorForTest(operator string) CheckExpression {
+	expression := CheckExpression{}
+	expression.Type = "BooleanOperator"
+	expression.Operator = operator
+	return expression
+}
+
+func TestNestFlatExpressions(t *testing.T) {
+	flat := []CheckExpression{
+		leafExpressionForTest("a"),
+		booleanOperatorForTest("or"),
+		leafExpressionForTest("b"),
+		booleanOperatorForTest("or"),
+		leafExpressionForTest("c"),
+		booleanOperatorForTest("and"),
+		leafExpressionForTest("d"),
+	}
+
+	// Legacy lists were folded left to right: ((a or b or c) and d).
+	nested := NestFlatExpressions(flat)
+	if len(nested) != 1 {
+		t.Fatalf("Flat list should nest into a single root. Got: %v", nested)
+	}
+
+	root := nested[0]
+	if !root.IsBooleanGroup() || root.Operator != BooleanGroupAll || len(root.Expressions) != 2 {
+		t.Fatalf("Root should be an all group of 2 expressions. Got: %v", root)
+	}
+
+	anyGroup := root.Expressions[0]
+	if !anyGroup.IsBooleanGroup() || anyGroup.Operator != BooleanGroupAny || len(anyGroup.Expressions) != 3 {
+		t.Errorf("First operand should be an any group of a, b and c. Got: %v", anyGroup)
+	}
+	if root.Expressions[1].Metric != "d" {
+		t.Errorf("Second operand should be d. Got: %v", root.Expressions[1])
+	}
+
+	err := ValidateExpressions(nested)
+	if err != nil {
+		t.Errorf("Nested expressions should be valid. Error: %v", err)
+	}
+
+	single := []CheckExpression{leafExpressionForTest("a")}
+	if len(NestFlatExpressions(single)) != 1 || NestFlatExpressions(single)[0].Metric != "a" {
+		t.Errorf("Lists without BooleanOperator should not change")
+	}
+}
+
+func TestValidateExpressions(t *testing.T) {
+	not := CheckExpression{}
+	not.Type = "BooleanGroup"
+	not.Operator = BooleanGroupNot
+	not.Expressions = []CheckExpression{leafExpressionForTest("a"), leafExpressionForTest("b")}
+
+	err := ValidateExpressions([]CheckExpression{not})
+	if err == nil {
+		t.Errorf("not group with 2 expressions should be invalid")
+	}
+
+	empty := CheckExpression{}
+	empty.Type = "BooleanGroup"
+	empty.Operator = BooleanGroupAny
+
+	err = ValidateExpressions([]CheckExpression{empty})
+	if err == nil {
+		t.Errorf("Empty any group should be invalid")
+	}
+
+	err = ValidateExpressions([]CheckExpression{leafExpressionForTest("a"), booleanOperatorForTest("and"), leafExpressionForTest("b")})
+	if err == nil {
+		t.Errorf("BooleanOperator should be rejected in a tree")
+	}
+
+	not.Expressions = not.Expressions[:1]
+	group := CheckExpression{}
+	group.Type = "BooleanGroup"
+	group.Operator = BooleanGroupAll
+	group.Expressions = []CheckExpression{not, leafExpressionForTest("c")}
+
+	err = ValidateExpressions([]CheckExpression{group})
+	if err != nil {
+		t.Errorf("all(not(a), c) should be valid. Error: %v", err)
+	}
+
+	leaves := LeafExpressions([]CheckExpression{group})
+	if len(leaves) != 2 || leaves[0].Metric != "a" || leaves[1].Metric != "c" {
+		t.Errorf("Leaves should be a and c. Got: %v", leaves)
+	}
+}
+
+func TestNormalizeExpressionsJSON(t *testing.T) {
+	normalized, err := NormalizeExpressionsJSON([]byte(`[{"Type": "RawHostData"}, {"Type": "BooleanOperator", "Operator": "or"}, {"Type": "LogData"}]`))
+	if err != nil {
+		t.Fatalf("Normalizing a flat list should work. Error: %v", err)
+	}
+
+	checkRow := &CheckRow{Expressions: normalized}
+
+	expressions, err := checkRow.GetExpressions()
+	if err != nil {
+		t.Fatalf("Normalized expressions should be parseable. Error: %v", err)
+	}
+	if len(expressions) != 1 || expressions[0].Operator != BooleanGroupAny {
+		t.Errorf("Flat list should be stored as an any group. Got: %s", normalized)
+	}
+
+	_, err = NormalizeExpressionsJSON([]byte(`[{"Type": "BooleanGroup", "Operator": "xor", "Expressions": [{"Type": "RawHostData"}]}]`))
+	if err == nil {
+		t.Errorf("Unknown boolean group should be rejected")
+	}
+}
+
+func evaluatedLeaf(value bool, minHost int, badHostnames, goodHostnames []string) CheckExpression {
+	expression := CheckExpression{}
+	expression.Type = "RawHostData"
+	expression.MinHost = minHost
+	expression.Result.Value = value
+	expression.Result.BadHostnames = badHostnames
+	expression.Result.GoodHostnames = goodHostnames
+	return expression
+}
+
+func TestFailingHostnames(t *testing.T) {
+	// web-1 is bad in the leaf of a not group, which means it is fine.
+	notGroup := CheckExpression{}
+	notGroup.Type = "BooleanGroup"
+	notGroup.Operator = BooleanGroupNot
+	notGroup.Expressions = []CheckExpression{evaluatedLeaf(false, 2, []string{"web-1"}, []string{"web-2"})}
+
+	hostnames := FailingHostnames([]CheckExpression{notGroup}, true)
+	if len(hostnames) != 1 || hostnames[0] != "web-2" {
+		t.Errorf("Only the host that is not bad under the not group should fail. Got: %v", hostnames)
+	}
+
+	// MinHost is 2, so a single bad host does not fail the leaf.
+	minHost := evaluatedLeaf(false, 2, []string{"web-1"}, []string{"web-2"})
+	if hostnames := FailingHostnames([]CheckExpression{minHost}, false); len(hostnames) != 0 {
+		t.Errorf("A host below MinHost should not fail. Got: %v", hostnames)
+	}
+
+	// The check fails through the other branch of an any group, web-1 alone does not reach MinHost.
+	anyGroup := CheckExpression{}
+	anyGroup.Type = "BooleanGroup"
+	anyGroup.Operator = BooleanGroupAny
+	anyGroup.Expressions = []CheckExpression{
+		minHost,
+		evaluatedLeaf(true, 1, []string{"web-2"}, []string{"web-1"}),
+	}
+
+	hostnames = FailingHostnames([]CheckExpression{anyGroup}, true)
+	if len(hostnames) != 1 || hostnames[0] != "web-2" {
+		t.Errorf("Only the host failing a bad leaf should fail. Got: %v", hostnames)
+	}
+
+	// Both leaves of an all group must be bad for the same host.
+	allGroup := CheckExpression{}
+	allGroup.Type = "BooleanGroup"
+	allGroup.Operator = BooleanGroupAll
+	allGroup.Expressions = []CheckExpression{
+		evaluatedLeaf(true, 1, []string{"web-1", "web-2"}, nil),
+		evaluatedLeaf(true, 1, []string{"web-2"}, []string{"web-1"}),
+	}
+
+	hostnames = FailingHostnames([]CheckExpression{allGroup}, true)
+	if len(hostnames) != 1 || hostnames[0] != "web-2" {
+		t.Errorf("Only hosts bad in every leaf of an all group should fail. Got: %v", hostnames)
+	}
+}
@@ -0,0 +1,152 @@
+package pg
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+const (
+	// FlapHistorySize is how many of the latest results flap detection looks at, giving 20 possible state changes as in Nagios.
+	FlapHistorySize = 21
+
+	// A check starts flapping above FlapHighThreshold percent of state changes, and stops flapping below FlapLowThreshold.
+	FlapHighThreshold = 20.0
+	FlapLowThreshold  = 5.0
+)
+
+// FlapStateChangePercent returns the weighted percentage of state changes between results, oldest first.
+// Like Nagios, recent changes weigh more: from 0.75 for the oldest possible change to 1.25 for the newest.
+func FlapStateChangePercent(results []bool) float64 {
+	changes := len(results) - 1
+	if changes <= 0 {
+		return 0
+	}
+
+	weightedChanges := 0.0
+
+	for i := 1; i < len(results); i++ {
+		if results[i] == results[i-1] {
+			continue
+		}
+
+		weight := 0.75
+		if changes > 1 {
+			weight = weight + float64(i-1)*0.5/float64(changes-1)
+		}
+
+		weightedChanges = weightedChanges + weight
+	}
+
+	return weightedChanges * 100 / float64(changes)
+}
+
+// IsFlappingAfter decides if a check flaps given its state change percent.
+// The gap between the two thresholds keeps a check from flapping in and out of flapping.
+func IsFlappingAfter(wasFlapping bool, percent float64) bool {
+	if wasFlapping {
+		return percent >= FlapLowThreshold
+	}
+	return percent > FlapHighThreshold
+}
+
+// DetectFlapping updates the flapping state of a check from its latest results.
+// Instead of every violation, the recipients of its triggers get one notification when it starts flapping and one when it stops.
+func (checkRow *CheckRow) DetectFlapping(ctx context.Context) error {
+	tsCheckRows, err := NewTSCheck(ctx, checkRow.ClusterID).LastByClusterIDCheckIDAndLimit(nil, checkRow.ClusterID, checkRow.ID, FlapHistorySize)
+	if err != nil {
+		return err
+	}
+
+	// Not enough history to tell yet.
+	if len(tsCheckRows) < FlapHistorySize {
+		return nil
+	}
+
+	// tsCheckRows are newest first.
+	results := make([]bool, len(tsCheckRows))
+	for i, tsCheckRow := range tsCheckRows {
+		results[len(tsCheckRows)-1-i] = tsCheckRow.Result
+	}
+
+	percent := FlapStateChangePercent(results)
+	isFlapping := IsFlappingAfter(checkRow.IsFlapping, percent)
+	changed := isFlapping != checkRow.IsFlapping
+
+	data := make(map[string]interface{})
+	data["flap_percent"] = percent
+
+	if changed {
+		data["is_flapping"] = isFlapping
+
+		if isFlapping {
+			now := time.Now().UTC()
+			checkRow.FlappingSince = &now
+			data["flapping_since"] = now
+		} else {
+			checkRow.FlappingSince = nil
+			data["flapping_since"] = nil
+		}
+	}
+
+	_, err = NewCheck(ctx).UpdateByID(nil, data, checkRow.ID)
+	if err != nil {
+		return err
+	}
+
+	checkRow.FlapPercent = percent
+	checkRow.IsFlapping = isFlapping
+
+	if !changed || checkRow.IsSilencedNow() {
+		return nil
+	}
+
+	return checkRow.RunFlappingNotifications(ctx)
+}
+
+// RunFlappingNotifications tells every email and SMS recipient of the check's triggers, once each, that it started or stopped flapping.
+func (checkRow *CheckRow) RunFlappingNotifications(ctx context.Context) error {
+	subject := fmt.Sprintf(`Check(ID: %v): %v, stopped flapping`, checkRow.ID, checkRow.Name)
+	if checkRow.IsFlapping {
+		subject = fmt.Sprintf(`Check(ID: %v): %v, started flapping, %.1f%% state changes. Triggers are suppressed until it stops`, checkRow.ID, checkRow.Name, checkRow.FlapPercent)
+	}
+
+	notified := make(map[string]bool)
+
+	var lastErr error
+
+	for _, trigger := range checkRow.GetTriggers() {
+		var to string
+		var err error
+
+		switch trigger.Action.Transport {
+		case "email":
+			to = trigger.Action.Email
+		case "sms":
+			to, err = smsRecipient(ctx, trigger.Action)
+		default:
+			continue
+		}
+
+		key := trigger.Action.Transport + ":" + to
+		if err != nil || to == "" || notified[key] {
+			continue
+		}
+		notified[key] = true
+
+		err = checkRow.enqueueTriggerNotification(ctx, trigger, to, subject, "", "")
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"Method":    "checkRow.RunFlappingNotifications",
+				"Transport": trigger.Action.Transport,
+				"To":        to,
+				"Subject":   subject,
+			}).Error(err)
+			lastErr = err
+		}
+	}
+
+	return lastErr
+}
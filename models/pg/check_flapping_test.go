@@ -0,0 +1,46 @@
+package pg
+
+import (
+	"math"
+	"testing"
+)
+
+func TestFlapStateChangePercent(t *testing.T) {
+	steady := make([]bool, FlapHistorySize)
+	if percent := FlapStateChangePercent(steady); percent != 0 {
+		t.Errorf("Steady results should not change state. Got: %v", percent)
+	}
+
+	alternating := make([]bool, FlapHistorySize)
+	for i := range alternating {
+		alternating[i] = i%2 == 0
+	}
+	if percent := FlapStateChangePercent(alternating); math.Abs(percent-100) > 0.0001 {
+		t.Errorf("Alternating results should change state 100%% of the time. Got: %v", percent)
+	}
+
+	oldChange := make([]bool, FlapHistorySize)
+	oldChange[0] = true
+
+	newChange := make([]bool, FlapHistorySize)
+	newChange[FlapHistorySize-1] = true
+
+	if FlapStateChangePercent(oldChange) >= FlapStateChangePercent(newChange) {
+		t.Errorf("Recent state changes should weigh more than old ones")
+	}
+}
+
+func TestIsFlappingAfter(t *testing.T) {
+	if IsFlappingAfter(false, 15) {
+		t.Errorf("Check should not start flapping below the high threshold")
+	}
+	if !IsFlappingAfter(false, 25) {
+		t.Errorf("Check should start flapping above the high threshold")
+	}
+	if !IsFlappingAfter(true, 15) {
+		t.Errorf("Flapping check should keep flapping above the low threshold")
+	}
+	if IsFlappingAfter(true, 4) {
+		t.Errorf("Flapping check should stop flapping below the low threshold")
+	}
+}
@@ -0,0 +1,238 @@
+package pg
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/jmoiron/sqlx"
+)
+
+func NewCheckHostState(ctx context.Context) *CheckHostState {
+	chs := &CheckHostState{}
+	chs.AppContext = ctx
+	chs.table = "check_host_states"
+	chs.i = chs
+
+	return chs
+}
+
+// CheckHostStateRow is the alert state of one host of a check that alerts per host.
+// Only hosts that failed at least once have a state.
+type CheckHostStateRow struct {
+	ClusterID    int64      `db:"cluster_id"`
+	CheckID      int64      `db:"check_id"`
+	Hostname     string     `db:"hostname"`
+	IsFailing    bool       `db:"is_failing"`
+	FailingSince *time.Time `db:"failing_since"`
+	RecoveredAt  *time.Time `db:"recovered_at"`
+	Updated      time.Time  `db:"updated"`
+}
+
+// Transition moves the host to failing or passing at now. It returns true when the state changed.
+func (row *CheckHostStateRow) Transition(failing bool, now time.Time) bool {
+	if row.IsFailing == failing {
+		return false
+	}
+
+	if failing {
+		row.FailingSince = &now
+	} else {
+		row.RecoveredAt = &now
+	}
+
+	row.IsFailing = failing
+	row.Updated = now
+
+	return true
+}
+
+// NextCheckHostStates applies the bad hosts of the newest result to the states of a check's hosts.
+// Hosts that are not bad anymore recover, including hosts the check no longer selects.
+// It returns the states that changed, sorted by hostname.
+func NextCheckHostStates(clusterID, checkID int64, states []*CheckHostStateRow, badHostnames []string, now time.Time) []*CheckHostStateRow {
+	isBad := make(map[string]bool)
+	for _, hostname := range badHostnames {
+		isBad[hostname] = true
+	}
+
+	changed := make([]*CheckHostStateRow, 0)
+	known := make(map[string]bool)
+
+	for _, state := range states {
+		known[state.Hostname] = true
+
+		if state.Transition(isBad[state.Hostname], now) {
+			changed = append(changed, state)
+		}
+	}
+
+	for hostname := range isBad {
+		if known[hostname] {
+			continue
+		}
+
+		state := &CheckHostStateRow{ClusterID: clusterID, CheckID: checkID, Hostname: hostname}
+		state.Transition(true, now)
+
+		changed = append(changed, state)
+	}
+
+	sort.Slice(changed, func(i, j int) bool { return changed[i].Hostname < changed[j].Hostname })
+
+	return changed
+}
+
+type CheckHostState struct {
+	Base
+}
+
+// AllByCheckID returns the host states of a check, sorted by hostname.
+func (chs *CheckHostState) AllByCheckID(tx *sqlx.Tx, checkID int64) ([]*CheckHostStateRow, error) {
+	pgdb, err := chs.GetPGDB()
+	if err != nil {
+		return nil, err
+	}
+
+	rows := []*CheckHostStateRow{}
+	query := fmt.Sprintf("SELECT * FROM %v WHERE check_id=$1 ORDER BY hostname ASC", chs.table)
+	err = pgdb.Select(&rows, query, checkID)
+
+	return rows, err
+}
+
+// GetByCheckIDAndHostname returns one record by check_id and hostname.
+func (chs *CheckHostState) GetByCheckIDAndHostname(tx *sqlx.Tx, checkID int64, hostname string) (*CheckHostStateRow, error) {
+	pgdb, err := chs.GetPGDB()
+	if err != nil {
+		return nil, err
+	}
+
+	row := &CheckHostStateRow{}
+	query := fmt.Sprintf("SELECT * FROM %v WHERE check_id=$1 AND hostname=$2", chs.table)
+	err = pgdb.Get(row, query, checkID, hostname)
+
+	return row, err
+}
+
+// Save inserts or updates the state of a host.
+func (chs *CheckHostState) Save(tx *sqlx.Tx, row *CheckHostStateRow) error {
+	data := make(map[string]interface{})
+	data["cluster_id"] = row.ClusterID
+	data["check_id"] = row.CheckID
+	data["hostname"] = row.Hostname
+	data["is_failing"] = row.IsFailing
+	data["failing_since"] = row.FailingSince
+	data["recovered_at"] = row.RecoveredAt
+	data["updated"] = row.Updated
+
+	// Two daemons may evaluate the same check at once.
+	_, err := chs.UpsertIntoTable(tx, data, []string{"check_id", "hostname"})
+	return err
+}
+
+// UpdateHostStates records which hosts of a check that alerts per host started failing or recovered.
+// failing is the result of the whole check, see FailingHostnames.
+func (checkRow *CheckRow) UpdateHostStates(ctx context.Context, expressions []CheckExpression, failing bool) error {
+	checkHostState := NewCheckHostState(ctx)
+
+	states, err := checkHostState.AllByCheckID(nil, checkRow.ID)
+	if err != nil {
+		return err
+	}
+
+	changed := NextCheckHostStates(checkRow.ClusterID, checkRow.ID, states, FailingHostnames(expressions, failing), time.Now().UTC())
+
+	for _, state := range changed {
+		err = checkHostState.Save(nil, state)
+		if err != nil {
+			return err
+		}
+
+		status := "Host recovered"
+		if state.IsFailing {
+			status = "Host started failing"
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"Method":   "CheckRow.UpdateHostStates",
+			"CheckID":  checkRow.ID,
+			"Hostname": state.Hostname,
+		}).Info(status)
+	}
+
+	return nil
+}
+
+// RunHostTriggers runs every trigger once per failing host, counting only the violations of that host.
+// Parent suppression applies to every host, maintenance windows apply to hosts they cover.
+func (checkRow *CheckRow) RunHostTriggers(ctx context.Context, triggers []CheckTrigger, deletedFrom int64) error {
+	for _, trigger := range triggers {
+		tsCheckRows, err := NewTSCheck(ctx, checkRow.ClusterID).AllByClusterIDCheckIDAndInterval(nil, checkRow.ClusterID, checkRow.ID, trigger.CreatedIntervalMinute, deletedFrom)
+		if err != nil {
+			return err
+		}
+
+		if len(tsCheckRows) == 0 {
+			continue
+		}
+
+		if tsCheckRows[0].SuppressedByParentID != nil {
+			logrus.WithFields(logrus.Fields{
+				"Method":   "CheckRow.RunHostTriggers",
+				"CheckID":  checkRow.ID,
+				"ParentID": *tsCheckRows[0].SuppressedByParentID,
+			}).Info("Trigger suppressed by parent")
+			continue
+		}
+
+		hostViolations := HostViolations(tsCheckRows)
+
+		hostnames := make([]string, 0, len(hostViolations))
+		for hostname := range hostViolations {
+			hostnames = append(hostnames, hostname)
+		}
+		sort.Strings(hostnames)
+
+		for _, hostname := range hostnames {
+			violations := hostViolations[hostname]
+
+			lastViolation := violations[0]
+			violationsCount := len(violations)
+
+			if int64(violationsCount) < trigger.LowViolationsCount || int64(violationsCount) > trigger.HighViolationsCount {
+				continue
+			}
+
+			window, err := checkRow.InMaintenanceForHostnames(ctx, []string{hostname})
+			if err != nil {
+				logrus.Error(err)
+			}
+			if window != nil {
+				logrus.WithFields(logrus.Fields{
+					"Method":            "CheckRow.RunHostTriggers",
+					"CheckID":           checkRow.ID,
+					"Hostname":          hostname,
+					"MaintenanceWindow": window.Name,
+				}).Info("Trigger muted by maintenance window")
+				continue
+			}
+
+			err = checkRow.RunTrigger(ctx, trigger, hostname, lastViolation, violationsCount)
+			if err != nil {
+				logrus.WithFields(logrus.Fields{
+					"Method":    "CheckRow.RunHostTriggers",
+					"CheckID":   checkRow.ID,
+					"TriggerID": trigger.ID,
+					"Hostname":  hostname,
+					"Transport": trigger.Action.Transport,
+				}).Error(err)
+				continue
+			}
+		}
+	}
+
+	return nil
+}
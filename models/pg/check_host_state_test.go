@@ -0,0 +1,100 @@
+package pg
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func newHostTSCheckRow(t *testing.T, badHostnames ...string) *TSCheckRow {
+	expression := CheckExpression{}
+	expression.Type = "RawHostData"
+	expression.Result.Value = len(badHostnames) > 0
+	expression.Result.BadHostnames = badHostnames
+
+	expressionsJSON, err := json.Marshal([]CheckExpression{expression})
+	if err != nil {
+		t.Fatalf("Marshalling expressions should work. Error: %v", err)
+	}
+
+	return &TSCheckRow{Result: len(badHostnames) > 0, Expressions: expressionsJSON}
+}
+
+func TestCheckHostStateRowTransition(t *testing.T) {
+	now := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+	row := &CheckHostStateRow{Hostname: "web-1"}
+
+	if row.Transition(false, now) {
+		t.Errorf("A passing host should stay passing")
+	}
+
+	if !row.Transition(true, now) || !row.IsFailing || row.FailingSince == nil || !row.FailingSince.Equal(now) {
+		t.Errorf("Host should start failing. Row: %+v", row)
+	}
+
+	if row.Transition(true, now.Add(time.Minute)) || !row.FailingSince.Equal(now) {
+		t.Errorf("An ongoing failure should keep its start. Row: %+v", row)
+	}
+
+	if !row.Transition(false, now.Add(2*time.Minute)) || row.IsFailing || row.RecoveredAt == nil || !row.RecoveredAt.Equal(now.Add(2*time.Minute)) {
+		t.Errorf("Host should recover. Row: %+v", row)
+	}
+}
+
+func TestNextCheckHostStates(t *testing.T) {
+	now := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+	before := now.Add(-time.Hour)
+
+	states := []*CheckHostStateRow{
+		{ClusterID: 1, CheckID: 2, Hostname: "web-1", IsFailing: true, FailingSince: &before},
+		{ClusterID: 1, CheckID: 2, Hostname: "web-2", IsFailing: true, FailingSince: &before},
+		{ClusterID: 1, CheckID: 2, Hostname: "web-3"},
+	}
+
+	changed := NextCheckHostStates(1, 2, states, []string{"web-1", "web-4"}, now)
+	if len(changed) != 2 {
+		t.Fatalf("web-2 should recover and web-4 should start failing. Changed: %+v", changed)
+	}
+
+	if changed[0].Hostname != "web-2" || changed[0].IsFailing || changed[0].RecoveredAt == nil {
+		t.Errorf("web-2 should recover. State: %+v", changed[0])
+	}
+
+	if changed[1].Hostname != "web-4" || !changed[1].IsFailing || changed[1].ClusterID != 1 || changed[1].CheckID != 2 {
+		t.Errorf("web-4 should start failing. State: %+v", changed[1])
+	}
+
+	if !states[0].FailingSince.Equal(before) {
+		t.Errorf("web-1 should keep failing since its first violation. State: %+v", states[0])
+	}
+}
+
+func TestHostViolations(t *testing.T) {
+	// Newest first: web-1 fails 3 times in a row, web-2 fails twice since it last passed.
+	rows := []*TSCheckRow{
+		newHostTSCheckRow(t, "web-1", "web-2"),
+		newHostTSCheckRow(t, "web-1", "web-2"),
+		newHostTSCheckRow(t, "web-1"),
+		newHostTSCheckRow(t, "web-2"),
+		newHostTSCheckRow(t),
+	}
+
+	violations := HostViolations(rows)
+	if len(violations) != 2 {
+		t.Fatalf("Only hosts failing in the newest result should have violations. Violations: %v", violations)
+	}
+
+	if len(violations["web-1"]) != 3 {
+		t.Errorf("web-1 should have 3 violations. Got: %v", len(violations["web-1"]))
+	}
+	if len(violations["web-2"]) != 2 {
+		t.Errorf("web-2 should have 2 violations. Got: %v", len(violations["web-2"]))
+	}
+	if violations["web-1"][0] != rows[0] || violations["web-1"][2] != rows[2] {
+		t.Errorf("Violations should be newest first")
+	}
+
+	if len(HostViolations(nil)) != 0 {
+		t.Errorf("No results should have no violations")
+	}
+}
@@ -0,0 +1,67 @@
+package pg
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// No data policies decide how host data expressions count hosts that do not report Metric,
+// or whose data is older than MaxDataAge.
+const (
+	// NoDataPolicyOK counts such hosts as good. It is the default.
+	NoDataPolicyOK = "ok"
+
+	// NoDataPolicyFail counts such hosts as bad.
+	NoDataPolicyFail = "fail"
+
+	// NoDataPolicyUnknown counts such hosts as neither good nor bad.
+	NoDataPolicyUnknown = "unknown"
+
+	// NoDataPolicyLastValue judges such hosts by the last value they reported, and counts them as unknown when there is none.
+	NoDataPolicyLastValue = "last_value"
+)
+
+// GetNoDataPolicy returns the no data policy of an expression, NoDataPolicyOK when it has none.
+func (expression CheckExpression) GetNoDataPolicy() string {
+	if expression.NoDataPolicy == "" {
+		return NoDataPolicyOK
+	}
+	return expression.NoDataPolicy
+}
+
+// IsHostDataStale tells if data updated at updated is older than the MaxDataAge of the expression at now.
+// Expressions without MaxDataAge never consider data stale.
+func (expression CheckExpression) IsHostDataStale(updated, now time.Time) bool {
+	if expression.MaxDataAge == "" {
+		return false
+	}
+
+	maxDataAge, err := time.ParseDuration(expression.MaxDataAge)
+	if err != nil || maxDataAge <= 0 {
+		return false
+	}
+
+	return now.Sub(updated) > maxDataAge
+}
+
+// ValidateNoDataPolicy checks the NoDataPolicy and MaxDataAge of an expression.
+func ValidateNoDataPolicy(expression CheckExpression) error {
+	switch expression.GetNoDataPolicy() {
+	case NoDataPolicyOK, NoDataPolicyFail, NoDataPolicyUnknown, NoDataPolicyLastValue:
+	default:
+		return fmt.Errorf("Unrecognized no data policy: %v", expression.NoDataPolicy)
+	}
+
+	if expression.MaxDataAge != "" {
+		maxDataAge, err := time.ParseDuration(expression.MaxDataAge)
+		if err != nil {
+			return fmt.Errorf("MaxDataAge is malformed. Error: %v", err)
+		}
+		if maxDataAge <= 0 {
+			return errors.New("MaxDataAge must be positive")
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,44 @@
+package pg
+
+import (
+	"testing"
+	"time"
+)
+
+func TestValidateNoDataPolicy(t *testing.T) {
+	for _, valid := range []CheckExpression{
+		{},
+		{NoDataPolicy: NoDataPolicyFail, MaxDataAge: "10m"},
+		{NoDataPolicy: NoDataPolicyLastValue},
+	} {
+		if err := ValidateNoDataPolicy(valid); err != nil {
+			t.Errorf("Expression should be valid: %+v. Error: %v", valid, err)
+		}
+	}
+
+	for _, invalid := range []CheckExpression{
+		{NoDataPolicy: "ignore"},
+		{MaxDataAge: "ten minutes"},
+		{MaxDataAge: "-1m"},
+	} {
+		if err := ValidateNoDataPolicy(invalid); err == nil {
+			t.Errorf("Expression should be invalid: %+v", invalid)
+		}
+	}
+}
+
+func TestCheckExpressionIsHostDataStale(t *testing.T) {
+	now := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	expression := CheckExpression{MaxDataAge: "10m"}
+	if expression.IsHostDataStale(now.Add(-5*time.Minute), now) {
+		t.Errorf("Data younger than MaxDataAge should be fresh")
+	}
+	if !expression.IsHostDataStale(now.Add(-15*time.Minute), now) {
+		t.Errorf("Data older than MaxDataAge should be stale")
+	}
+
+	if (CheckExpression{}).IsHostDataStale(now.Add(-24*time.Hour), now) {
+		t.Errorf("Data should never be stale without MaxDataAge")
+	}
+}
@@ -0,0 +1,83 @@
+package pg
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+func NewCheckNotification(ctx context.Context) *CheckNotification {
+	cn := &CheckNotification{}
+	cn.AppContext = ctx
+	cn.table = "check_notifications"
+	cn.i = cn
+
+	return cn
+}
+
+// CheckNotificationRow records the last time a trigger notified anyone.
+// It is persisted so the re-notify policy survives master restarts and check reassignment.
+// Hostname is empty, unless the check alerts per host.
+type CheckNotificationRow struct {
+	ClusterID    int64     `db:"cluster_id"`
+	CheckID      int64     `db:"check_id"`
+	TriggerID    int64     `db:"trigger_id"`
+	Hostname     string    `db:"hostname"`
+	LastNotified time.Time `db:"last_notified"`
+}
+
+type CheckNotification struct {
+	Base
+}
+
+// GetByCheckIDTriggerIDAndHostname returns one record by check_id, trigger_id and hostname.
+func (cn *CheckNotification) GetByCheckIDTriggerIDAndHostname(tx *sqlx.Tx, checkID, triggerID int64, hostname string) (*CheckNotificationRow, error) {
+	pgdb, err := cn.GetPGDB()
+	if err != nil {
+		return nil, err
+	}
+
+	row := &CheckNotificationRow{}
+	query := fmt.Sprintf("SELECT * FROM %v WHERE check_id=$1 AND trigger_id=$2 AND hostname=$3", cn.table)
+	err = pgdb.Get(row, query, checkID, triggerID, hostname)
+
+	return row, err
+}
+
+// ShouldNotify decides whether a trigger is allowed to notify again.
+// violationStarted is when the current streak of violations started, see CheckRow.ViolationStarted.
+// A new streak always notifies, an ongoing one notifies once every trigger.RenotifyIntervalMinute.
+// hostname is empty unless the check alerts per host, in which case every host has its own policy.
+func (cn *CheckNotification) ShouldNotify(tx *sqlx.Tx, checkID int64, trigger CheckTrigger, hostname string, violationStarted time.Time) (bool, error) {
+	row, err := cn.GetByCheckIDTriggerIDAndHostname(tx, checkID, trigger.ID, hostname)
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows in result set") {
+			return true, nil
+		}
+		return false, err
+	}
+
+	return trigger.ShouldRenotify(row.LastNotified, violationStarted, time.Now().UTC()), nil
+}
+
+// MarkNotified sets last_notified of a trigger, for one host or the whole check, to now.
+func (cn *CheckNotification) MarkNotified(tx *sqlx.Tx, clusterID, checkID, triggerID int64, hostname string) error {
+	data := make(map[string]interface{})
+	data["cluster_id"] = clusterID
+	data["check_id"] = checkID
+	data["trigger_id"] = triggerID
+	data["hostname"] = hostname
+	data["last_notified"] = time.Now().UTC()
+
+	_, err := cn.UpsertIntoTable(tx, data, []string{"check_id", "trigger_id", "hostname"})
+	return err
+}
+
+// DeleteByCheckIDAndTriggerID forgets when a trigger notified, for every host, so it notifies again on the next run.
+func (cn *CheckNotification) DeleteByCheckIDAndTriggerID(tx *sqlx.Tx, checkID, triggerID int64) error {
+	_, err := cn.DeleteFromTable(tx, fmt.Sprintf("check_id=%v AND trigger_id=%v", checkID, triggerID))
+	return err
+}
@@ -0,0 +1,188 @@
+package pg
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	// NotificationDeliverySent means the transport accepted the notification.
+	NotificationDeliverySent = "sent"
+
+	// NotificationDeliveryRetrying means the attempt failed and the notification stays queued.
+	NotificationDeliveryRetrying = "retrying"
+
+	// NotificationDeliveryFailed means the notification is given up on.
+	NotificationDeliveryFailed = "failed"
+
+	// MaxNotificationAttempts is how many times a notification is tried before it is given up on.
+	MaxNotificationAttempts = 10
+)
+
+// NotificationRetryBackoff returns how long to wait after a failed attempt: 30s doubling every attempt, at most 1h.
+func NotificationRetryBackoff(attempt int) time.Duration {
+	backoff := 30 * time.Second
+
+	for i := 1; i < attempt && backoff < time.Hour; i++ {
+		backoff = backoff * 2
+	}
+
+	if backoff > time.Hour {
+		backoff = time.Hour
+	}
+
+	return backoff
+}
+
+// NotificationPayloadHash identifies the content of a notification without storing it.
+func NotificationPayloadHash(parts ...string) string {
+	hash := sha256.New()
+	for _, part := range parts {
+		hash.Write([]byte(part))
+		hash.Write([]byte{0})
+	}
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// recordNotificationDelivery persists a delivery attempt.
+// Failing to do so is only logged, it never holds back a notification.
+func recordNotificationDelivery(ctx context.Context, row *CheckNotificationDeliveryRow) {
+	_, err := NewCheckNotificationDelivery(ctx).Create(nil, row)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Method":    "recordNotificationDelivery",
+			"CheckID":   row.CheckID,
+			"SLOID":     row.SLOID,
+			"TriggerID": row.TriggerID,
+			"Status":    row.Status,
+		}).Error(err)
+	}
+}
+
+func NewCheckNotificationDelivery(ctx context.Context) *CheckNotificationDelivery {
+	d := &CheckNotificationDelivery{}
+	d.AppContext = ctx
+	d.table = "check_notification_deliveries"
+	d.hasID = true
+	d.i = d
+
+	return d
+}
+
+// checkNotificationDeliveryColumns reads deliveries of checks and of SLOs alike, the owner they do not have reads as 0.
+const checkNotificationDeliveryColumns = "id, cluster_id, COALESCE(check_id, 0) AS check_id, COALESCE(slo_id, 0) AS slo_id, trigger_id, transport, recipient, payload_hash, status, error, attempt, created"
+
+// CheckNotificationDeliveryRow is one attempt at delivering a notification of a check or of an SLO.
+type CheckNotificationDeliveryRow struct {
+	ID          int64     `db:"id"`
+	ClusterID   int64     `db:"cluster_id"`
+	CheckID     int64     `db:"check_id"`
+	SLOID       int64     `db:"slo_id"`
+	TriggerID   int64     `db:"trigger_id"`
+	Transport   string    `db:"transport"`
+	Recipient   string    `db:"recipient"`
+	PayloadHash string    `db:"payload_hash"`
+	Status      string    `db:"status"`
+	Error       string    `db:"error"`
+	Attempt     int       `db:"attempt"`
+	Created     time.Time `db:"created"`
+}
+
+type CheckNotificationDelivery struct {
+	Base
+}
+
+func (d *CheckNotificationDelivery) rowFromSqlResult(tx *sqlx.Tx, sqlResult sql.Result) (*CheckNotificationDeliveryRow, error) {
+	id, err := sqlResult.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	return d.GetByID(tx, id)
+}
+
+// GetByID returns one record by id.
+func (d *CheckNotificationDelivery) GetByID(tx *sqlx.Tx, id int64) (*CheckNotificationDeliveryRow, error) {
+	pgdb, err := d.GetPGDB()
+	if err != nil {
+		return nil, err
+	}
+
+	row := &CheckNotificationDeliveryRow{}
+	query := fmt.Sprintf("SELECT %v FROM %v WHERE id=$1", checkNotificationDeliveryColumns, d.table)
+	err = pgdb.Get(row, query, id)
+
+	return row, err
+}
+
+// Create records a delivery attempt.
+func (d *CheckNotificationDelivery) Create(tx *sqlx.Tx, row *CheckNotificationDeliveryRow) (*CheckNotificationDeliveryRow, error) {
+	data := make(map[string]interface{})
+	data["cluster_id"] = row.ClusterID
+	data["check_id"] = nullableID(row.CheckID)
+	data["slo_id"] = nullableID(row.SLOID)
+	data["trigger_id"] = row.TriggerID
+	data["transport"] = row.Transport
+	data["recipient"] = row.Recipient
+	data["payload_hash"] = row.PayloadHash
+	data["status"] = row.Status
+	data["error"] = row.Error
+	data["attempt"] = row.Attempt
+
+	sqlResult, err := d.InsertIntoTable(tx, data)
+	if err != nil {
+		return nil, err
+	}
+
+	return d.rowFromSqlResult(tx, sqlResult)
+}
+
+// AllByCheckIDAndLimit returns the latest delivery attempts of a check, newest first.
+func (d *CheckNotificationDelivery) AllByCheckIDAndLimit(tx *sqlx.Tx, checkID, limit int64) ([]*CheckNotificationDeliveryRow, error) {
+	pgdb, err := d.GetPGDB()
+	if err != nil {
+		return nil, err
+	}
+
+	rows := []*CheckNotificationDeliveryRow{}
+	query := fmt.Sprintf("SELECT %v FROM %v WHERE check_id=$1 ORDER BY created DESC, id DESC LIMIT $2", checkNotificationDeliveryColumns, d.table)
+	err = pgdb.Select(&rows, query, checkID, limit)
+
+	return rows, err
+}
+
+// AllBySLOIDAndLimit returns the latest delivery attempts of the burn-rate alerts of an SLO, newest first.
+func (d *CheckNotificationDelivery) AllBySLOIDAndLimit(tx *sqlx.Tx, sloID, limit int64) ([]*CheckNotificationDeliveryRow, error) {
+	pgdb, err := d.GetPGDB()
+	if err != nil {
+		return nil, err
+	}
+
+	rows := []*CheckNotificationDeliveryRow{}
+	query := fmt.Sprintf("SELECT %v FROM %v WHERE slo_id=$1 ORDER BY created DESC, id DESC LIMIT $2", checkNotificationDeliveryColumns, d.table)
+	err = pgdb.Select(&rows, query, sloID, limit)
+
+	return rows, err
+}
+
+// nullableID stores a missing check or SLO, 0, as NULL so that foreign keys hold.
+func nullableID(id int64) interface{} {
+	if id == 0 {
+		return nil
+	}
+	return id
+}
+
+// DeleteByClusterIDAndCreatedBefore prunes delivery attempts older than before.
+func (d *CheckNotificationDelivery) DeleteByClusterIDAndCreatedBefore(tx *sqlx.Tx, clusterID int64, before time.Time) error {
+	_, err := d.DeleteFromTable(tx, fmt.Sprintf("cluster_id=%v AND created < to_timestamp(%v) at time zone 'utc'", clusterID, before.UTC().Unix()))
+	return err
+}
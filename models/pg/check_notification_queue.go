@@ -0,0 +1,359 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"html"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/resourced/resourced-master/contexthelper"
+)
+
+func NewCheckNotificationQueue(ctx context.Context) *CheckNotificationQueue {
+	q := &CheckNotificationQueue{}
+	q.AppContext = ctx
+	q.table = "check_notifications_queue"
+	q.hasID = true
+	q.i = q
+
+	return q
+}
+
+// checkNotificationQueueColumns reads notifications of checks and of SLOs alike, the owner they do not have reads as 0.
+const checkNotificationQueueColumns = "id, cluster_id, COALESCE(check_id, 0) AS check_id, COALESCE(slo_id, 0) AS slo_id, trigger_id, transport, recipient, subject, body, html_body, attempts, created, send_after"
+
+// CheckNotificationQueueRow is a notification waiting to be delivered.
+// It belongs to either a check or an SLO, TriggerID is then the ID of the trigger or of the burn-rate alert.
+type CheckNotificationQueueRow struct {
+	ID        int64     `db:"id"`
+	ClusterID int64     `db:"cluster_id"`
+	CheckID   int64     `db:"check_id"`
+	SLOID     int64     `db:"slo_id"`
+	TriggerID int64     `db:"trigger_id"`
+	Transport string    `db:"transport"`
+	Recipient string    `db:"recipient"`
+	Subject   string    `db:"subject"`
+	Body      string    `db:"body"`
+	HTMLBody  string    `db:"html_body"`
+	Attempts  int       `db:"attempts"`
+	Created   time.Time `db:"created"`
+	SendAfter time.Time `db:"send_after"`
+}
+
+type CheckNotificationQueue struct {
+	Base
+}
+
+func (q *CheckNotificationQueue) rowFromSqlResult(tx *sqlx.Tx, sqlResult sql.Result) (*CheckNotificationQueueRow, error) {
+	id, err := sqlResult.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	return q.GetByID(tx, id)
+}
+
+// GetByID returns one record by id.
+func (q *CheckNotificationQueue) GetByID(tx *sqlx.Tx, id int64) (*CheckNotificationQueueRow, error) {
+	pgdb, err := q.GetPGDB()
+	if err != nil {
+		return nil, err
+	}
+
+	row := &CheckNotificationQueueRow{}
+	query := fmt.Sprintf("SELECT %v FROM %v WHERE id=$1", checkNotificationQueueColumns, q.table)
+	err = pgdb.Get(row, query, id)
+
+	return row, err
+}
+
+// Create enqueues a notification that will be delivered on or after sendAfter.
+// attempts is how many times delivering it already failed, usually 0.
+func (q *CheckNotificationQueue) Create(tx *sqlx.Tx, clusterID, checkID, triggerID int64, transport, recipient, subject, body, htmlBody string, attempts int, sendAfter time.Time) (*CheckNotificationQueueRow, error) {
+	return q.create(tx, &CheckNotificationQueueRow{ClusterID: clusterID, CheckID: checkID, TriggerID: triggerID, Transport: transport, Recipient: recipient, Subject: subject, Body: body, HTMLBody: htmlBody, Attempts: attempts, SendAfter: sendAfter})
+}
+
+// CreateForSLO enqueues a notification of an SLO burn-rate alert that will be delivered on or after sendAfter.
+func (q *CheckNotificationQueue) CreateForSLO(tx *sqlx.Tx, clusterID, sloID, alertID int64, transport, recipient, subject, body, htmlBody string, attempts int, sendAfter time.Time) (*CheckNotificationQueueRow, error) {
+	return q.create(tx, &CheckNotificationQueueRow{ClusterID: clusterID, SLOID: sloID, TriggerID: alertID, Transport: transport, Recipient: recipient, Subject: subject, Body: body, HTMLBody: htmlBody, Attempts: attempts, SendAfter: sendAfter})
+}
+
+func (q *CheckNotificationQueue) create(tx *sqlx.Tx, row *CheckNotificationQueueRow) (*CheckNotificationQueueRow, error) {
+	data := make(map[string]interface{})
+	data["cluster_id"] = row.ClusterID
+	data["check_id"] = nullableID(row.CheckID)
+	data["slo_id"] = nullableID(row.SLOID)
+	data["trigger_id"] = row.TriggerID
+	data["transport"] = row.Transport
+	data["recipient"] = row.Recipient
+	data["subject"] = row.Subject
+	data["body"] = row.Body
+	data["html_body"] = row.HTMLBody
+	data["attempts"] = row.Attempts
+	data["send_after"] = row.SendAfter.UTC()
+
+	sqlResult, err := q.InsertIntoTable(tx, data)
+	if err != nil {
+		return nil, err
+	}
+
+	return q.rowFromSqlResult(tx, sqlResult)
+}
+
+// AllDueByClusterID returns all notifications of a cluster that are ready to be sent.
+func (q *CheckNotificationQueue) AllDueByClusterID(tx *sqlx.Tx, clusterID int64) ([]*CheckNotificationQueueRow, error) {
+	pgdb, err := q.GetPGDB()
+	if err != nil {
+		return nil, err
+	}
+
+	rows := []*CheckNotificationQueueRow{}
+	query := fmt.Sprintf("SELECT %v FROM %v WHERE cluster_id=$1 AND send_after <= to_timestamp($2) at time zone 'utc' ORDER BY created ASC", checkNotificationQueueColumns, q.table)
+	err = pgdb.Select(&rows, query, clusterID, time.Now().UTC().Unix())
+
+	return rows, err
+}
+
+// GroupCheckNotificationQueueRows groups notifications by transport and recipient,
+// so several checks failing at once reach a recipient as one message.
+func GroupCheckNotificationQueueRows(rows []*CheckNotificationQueueRow) [][]*CheckNotificationQueueRow {
+	groupsByKey := make(map[string][]*CheckNotificationQueueRow)
+	keys := make([]string, 0)
+
+	for _, row := range rows {
+		key := row.Transport + ":" + row.Recipient
+
+		if _, ok := groupsByKey[key]; !ok {
+			keys = append(keys, key)
+		}
+
+		groupsByKey[key] = append(groupsByKey[key], row)
+	}
+
+	sort.Strings(keys)
+
+	groups := make([][]*CheckNotificationQueueRow, len(keys))
+	for i, key := range keys {
+		groups[i] = groupsByKey[key]
+	}
+
+	return groups
+}
+
+// BuildGroupedMessage merges a group of notifications into a single subject and body.
+func BuildGroupedMessage(group []*CheckNotificationQueueRow) (string, string) {
+	if len(group) == 1 {
+		return group[0].Subject, group[0].Body
+	}
+
+	subjects := make([]string, len(group))
+	bodies := make([]string, len(group))
+
+	for i, row := range group {
+		subjects[i] = row.Subject
+
+		bodies[i] = row.Subject
+		if row.Body != "" {
+			bodies[i] = bodies[i] + "\n\n" + row.Body
+		}
+	}
+
+	subject := fmt.Sprintf("%v check notifications", len(group))
+	body := strings.Join(subjects, "\n") + "\n\n" + strings.Join(bodies, "\n\n--------------------\n\n")
+
+	return subject, body
+}
+
+// BuildGroupedHTMLMessage merges the HTML bodies of a group of notifications.
+// Empty string is returned when none of them has an HTML body.
+func BuildGroupedHTMLMessage(group []*CheckNotificationQueueRow) string {
+	hasHTML := false
+	for _, row := range group {
+		if row.HTMLBody != "" {
+			hasHTML = true
+		}
+	}
+	if !hasHTML {
+		return ""
+	}
+
+	if len(group) == 1 {
+		return group[0].HTMLBody
+	}
+
+	bodies := make([]string, len(group))
+
+	for i, row := range group {
+		bodies[i] = "<h3>" + html.EscapeString(row.Subject) + "</h3>"
+
+		if row.HTMLBody != "" {
+			bodies[i] = bodies[i] + row.HTMLBody
+		} else if row.Body != "" {
+			bodies[i] = bodies[i] + "<pre>" + html.EscapeString(row.Body) + "</pre>"
+		}
+	}
+
+	return strings.Join(bodies, "\n<hr>\n")
+}
+
+// SendGroup delivers a group of notifications sharing the same transport and recipient as one message.
+// Every attempt is recorded in the delivery log, failed ones stay queued and are retried with backoff.
+func (q *CheckNotificationQueue) SendGroup(tx *sqlx.Tx, group []*CheckNotificationQueueRow) error {
+	if len(group) == 0 {
+		return nil
+	}
+
+	transport := group[0].Transport
+	to := group[0].Recipient
+	subject, body := BuildGroupedMessage(group)
+	htmlBody := BuildGroupedHTMLMessage(group)
+
+	switch transport {
+	case "pagerduty":
+		// Each queued PagerDuty event belongs to its own incident.
+		var lastErr error
+
+		for _, row := range group {
+			err := submitPagerDutyEventJSON(row.Body)
+			if err != nil {
+				lastErr = q.failedGroup(tx, []*CheckNotificationQueueRow{row}, NotificationPayloadHash(row.Body), err)
+				continue
+			}
+
+			err = q.deliveredGroup(tx, []*CheckNotificationQueueRow{row}, NotificationPayloadHash(row.Body))
+			if err != nil {
+				lastErr = err
+			}
+		}
+
+		return lastErr
+
+	case "sms":
+		// SMS display the subject poorly, so one line per check goes into the body.
+		lines := make([]string, len(group))
+		for i, row := range group {
+			lines[i] = row.Subject
+		}
+
+		body = strings.Join(lines, "\n")
+		payloadHash := NotificationPayloadHash(body)
+
+		err := deliverSMS(q.AppContext, to, body)
+		if err != nil {
+			return q.failedGroup(tx, group, payloadHash, err)
+		}
+
+		return q.deliveredGroup(tx, group, payloadHash)
+	}
+
+	payloadHash := NotificationPayloadHash(subject, body, htmlBody)
+
+	mailr, err := contexthelper.GetMailer(q.AppContext, "GeneralConfig.Checks")
+	if err != nil {
+		return q.failedGroup(tx, group, payloadHash, err)
+	}
+
+	if htmlBody != "" {
+		err = mailr.SendMultipart(to, subject, body, htmlBody)
+	} else {
+		err = mailr.Send(to, subject, body)
+	}
+	if err != nil {
+		return q.failedGroup(tx, group, payloadHash, err)
+	}
+
+	return q.deliveredGroup(tx, group, payloadHash)
+}
+
+// deliveredGroup records the delivery of every notification of the group and removes them from the queue.
+func (q *CheckNotificationQueue) deliveredGroup(tx *sqlx.Tx, group []*CheckNotificationQueueRow, payloadHash string) error {
+	for _, row := range group {
+		recordNotificationDelivery(q.AppContext, &CheckNotificationDeliveryRow{
+			ClusterID:   row.ClusterID,
+			CheckID:     row.CheckID,
+			SLOID:       row.SLOID,
+			TriggerID:   row.TriggerID,
+			Transport:   row.Transport,
+			Recipient:   row.Recipient,
+			PayloadHash: payloadHash,
+			Status:      NotificationDeliverySent,
+			Attempt:     row.Attempts + 1,
+		})
+
+		_, err := q.DeleteByID(tx, row.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// failedGroup records the failed delivery of every notification of the group.
+// They are retried after NotificationRetryBackoff, and given up on after MaxNotificationAttempts.
+// deliveryErr is returned.
+func (q *CheckNotificationQueue) failedGroup(tx *sqlx.Tx, group []*CheckNotificationQueueRow, payloadHash string, deliveryErr error) error {
+	now := time.Now().UTC()
+
+	for _, row := range group {
+		attempt := row.Attempts + 1
+
+		status := NotificationDeliveryRetrying
+		if attempt >= MaxNotificationAttempts {
+			status = NotificationDeliveryFailed
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"Method":    "CheckNotificationQueue.SendGroup",
+			"CheckID":   row.CheckID,
+			"SLOID":     row.SLOID,
+			"TriggerID": row.TriggerID,
+			"Transport": row.Transport,
+			"To":        row.Recipient,
+			"Subject":   row.Subject,
+			"Attempt":   attempt,
+			"Status":    status,
+		}).Error(deliveryErr)
+
+		recordNotificationDelivery(q.AppContext, &CheckNotificationDeliveryRow{
+			ClusterID:   row.ClusterID,
+			CheckID:     row.CheckID,
+			SLOID:       row.SLOID,
+			TriggerID:   row.TriggerID,
+			Transport:   row.Transport,
+			Recipient:   row.Recipient,
+			PayloadHash: payloadHash,
+			Status:      status,
+			Error:       deliveryErr.Error(),
+			Attempt:     attempt,
+		})
+
+		var err error
+
+		if status == NotificationDeliveryFailed {
+			_, err = q.DeleteByID(tx, row.ID)
+
+			// The trigger was marked as notified when this was queued. Nobody was, so it may notify again.
+			if err == nil && row.CheckID > 0 {
+				err = NewCheckNotification(q.AppContext).DeleteByCheckIDAndTriggerID(tx, row.CheckID, row.TriggerID)
+			}
+		} else {
+			data := make(map[string]interface{})
+			data["attempts"] = attempt
+			data["send_after"] = now.Add(NotificationRetryBackoff(attempt))
+
+			_, err = q.UpdateByID(tx, data, row.ID)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return deliveryErr
+}
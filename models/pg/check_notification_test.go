@@ -0,0 +1,142 @@
+package pg
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckTriggerShouldRenotify(t *testing.T) {
+	now := time.Now().UTC()
+
+	trigger := CheckTrigger{}
+	trigger.RenotifyIntervalMinute = 30
+
+	// Ongoing violation, notified recently.
+	if trigger.ShouldRenotify(now.Add(-10*time.Minute), now.Add(-time.Hour), now) {
+		t.Errorf("Trigger should not renotify before RenotifyIntervalMinute elapsed")
+	}
+
+	// Ongoing violation, notified long ago.
+	if !trigger.ShouldRenotify(now.Add(-31*time.Minute), now.Add(-time.Hour), now) {
+		t.Errorf("Trigger should renotify after RenotifyIntervalMinute elapsed")
+	}
+
+	// New violation streak since the last notification.
+	if !trigger.ShouldRenotify(now.Add(-10*time.Minute), now.Add(-5*time.Minute), now) {
+		t.Errorf("Trigger should always notify on a new violation streak")
+	}
+
+	// Zero interval keeps the old behavior of notifying on every interval.
+	trigger.RenotifyIntervalMinute = 0
+	if !trigger.ShouldRenotify(now.Add(-1*time.Minute), now.Add(-time.Hour), now) {
+		t.Errorf("Trigger without RenotifyIntervalMinute should always notify")
+	}
+}
+
+func TestCheckTriggerNextSendTime(t *testing.T) {
+	now := time.Date(2016, 1, 1, 10, 25, 0, 0, time.UTC)
+
+	trigger := CheckTrigger{}
+
+	if !trigger.NextSendTime(now, time.Hour).Equal(now) {
+		t.Errorf("Non digest trigger should be sent right away")
+	}
+
+	trigger.Digest = true
+
+	expected := time.Date(2016, 1, 1, 11, 0, 0, 0, time.UTC)
+	if !trigger.NextSendTime(now, time.Hour).Equal(expected) {
+		t.Errorf("Digest trigger should be sent on the next hour. Got: %v", trigger.NextSendTime(now, time.Hour))
+	}
+}
+
+func TestGroupCheckNotificationQueueRows(t *testing.T) {
+	rows := []*CheckNotificationQueueRow{
+		&CheckNotificationQueueRow{ID: 1, Transport: "email", Recipient: "bob@example.com", Subject: "Check(ID: 1): a, failed 1 times"},
+		&CheckNotificationQueueRow{ID: 2, Transport: "email", Recipient: "alice@example.com", Subject: "Check(ID: 2): b, failed 1 times"},
+		&CheckNotificationQueueRow{ID: 3, Transport: "email", Recipient: "bob@example.com", Subject: "Check(ID: 3): c, failed 2 times"},
+	}
+
+	groups := GroupCheckNotificationQueueRows(rows)
+	if len(groups) != 2 {
+		t.Fatalf("Notifications should be grouped by recipient. Groups: %v", len(groups))
+	}
+
+	for _, group := range groups {
+		if group[0].Recipient == "bob@example.com" && len(group) != 2 {
+			t.Errorf("bob@example.com should receive 2 notifications in one message. Got: %v", len(group))
+		}
+	}
+
+	subject, _ := BuildGroupedMessage(groups[1])
+	if subject != "2 check notifications" {
+		t.Errorf("Grouped subject is incorrect. Subject: %v", subject)
+	}
+
+	subject, _ = BuildGroupedMessage(groups[0])
+	if subject != rows[1].Subject {
+		t.Errorf("Single notification should keep its subject. Subject: %v", subject)
+	}
+}
+
+func TestBuildGroupedHTMLMessage(t *testing.T) {
+	rows := []*CheckNotificationQueueRow{
+		&CheckNotificationQueueRow{ID: 1, Transport: "email", Subject: "Check(ID: 1): a & b, failed 1 times", Body: "plain <body>"},
+		&CheckNotificationQueueRow{ID: 2, Transport: "email", Subject: "Check(ID: 2): c, failed 1 times", Body: "plain", HTMLBody: "<p>rich</p>"},
+	}
+
+	if BuildGroupedHTMLMessage(rows[:1]) != "" {
+		t.Errorf("Notifications without HTML body should not produce HTML")
+	}
+
+	if BuildGroupedHTMLMessage(rows[1:]) != "<p>rich</p>" {
+		t.Errorf("Single notification should keep its HTML body")
+	}
+
+	htmlBody := BuildGroupedHTMLMessage(rows)
+	if !strings.Contains(htmlBody, "<p>rich</p>") {
+		t.Errorf("Grouped HTML should contain the HTML body. HTML: %v", htmlBody)
+	}
+	if !strings.Contains(htmlBody, "<pre>plain &lt;body&gt;</pre>") || !strings.Contains(htmlBody, "a &amp; b") {
+		t.Errorf("Plain text bodies and subjects should be escaped. HTML: %v", htmlBody)
+	}
+}
+
+func TestNotificationRetryBackoff(t *testing.T) {
+	expected := map[int]time.Duration{
+		1:  30 * time.Second,
+		2:  time.Minute,
+		3:  2 * time.Minute,
+		7:  32 * time.Minute,
+		8:  time.Hour,
+		20: time.Hour,
+	}
+
+	for attempt, backoff := range expected {
+		if NotificationRetryBackoff(attempt) != backoff {
+			t.Errorf("Backoff of attempt %v is incorrect. Expected: %v, Got: %v", attempt, backoff, NotificationRetryBackoff(attempt))
+		}
+	}
+}
+
+func TestNotificationPayloadHash(t *testing.T) {
+	if NotificationPayloadHash("subject", "body") != NotificationPayloadHash("subject", "body") {
+		t.Errorf("Same payloads should have the same hash")
+	}
+	if NotificationPayloadHash("subject", "body") == NotificationPayloadHash("subjectbody", "") {
+		t.Errorf("Moving content between parts should change the hash")
+	}
+	if len(NotificationPayloadHash("subject")) != 64 {
+		t.Errorf("Payload hash should be hex encoded SHA-256")
+	}
+}
+
+func TestMaskPagerDutyServiceKey(t *testing.T) {
+	if masked := MaskPagerDutyServiceKey("e93facc04764012d7bfb002500d5d1a6"); masked != "****d1a6" {
+		t.Errorf("Only the last 4 characters should be kept. Got: %v", masked)
+	}
+	if masked := MaskPagerDutyServiceKey("abc"); masked != "****" {
+		t.Errorf("Short keys should be masked entirely. Got: %v", masked)
+	}
+}
@@ -0,0 +1,126 @@
+package pg
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// ValidateCheckState checks the component checks and the quorum of a CheckState expression.
+func ValidateCheckState(expression CheckExpression) error {
+	if len(expression.CheckIDs) == 0 {
+		return errors.New("CheckState expression needs at least one check")
+	}
+
+	seen := make(map[int64]bool)
+	for _, checkID := range expression.CheckIDs {
+		if seen[checkID] {
+			return fmt.Errorf("CheckState expression lists check with ID: %v more than once", checkID)
+		}
+		seen[checkID] = true
+	}
+
+	if expression.MinHost < 1 || expression.MinHost > len(expression.CheckIDs) {
+		return fmt.Errorf("CheckState quorum must be between 1 and %v", len(expression.CheckIDs))
+	}
+
+	return nil
+}
+
+// CheckStateCheckIDs returns the component checks of every CheckState expression in expressionsJSON.
+func CheckStateCheckIDs(expressionsJSON []byte) ([]int64, error) {
+	var expressions []CheckExpression
+
+	err := json.Unmarshal(expressionsJSON, &expressions)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[int64]bool)
+	checkIDs := make([]int64, 0)
+
+	for _, expression := range LeafExpressions(NestFlatExpressions(expressions)) {
+		if expression.Type != "CheckState" {
+			continue
+		}
+
+		for _, checkID := range expression.CheckIDs {
+			if !seen[checkID] {
+				seen[checkID] = true
+				checkIDs = append(checkIDs, checkID)
+			}
+		}
+	}
+
+	return checkIDs, nil
+}
+
+// ValidateCheckStateReferences makes sure the CheckState expressions of a check only use other checks of the cluster,
+// and that no check ends up depending on its own state. componentsByCheckID holds the component checks of every check
+// in the cluster, it is updated with components. checkID is 0 for checks that are not created yet.
+func ValidateCheckStateReferences(componentsByCheckID map[int64][]int64, checkID int64, components []int64) error {
+	for _, componentID := range components {
+		if componentID == checkID {
+			return errors.New("CheckState expression cannot use its own check")
+		}
+		if _, ok := componentsByCheckID[componentID]; !ok {
+			return fmt.Errorf("CheckState expression uses check with ID: %v which does not exist in this cluster", componentID)
+		}
+	}
+
+	if checkID == 0 {
+		return nil
+	}
+
+	componentsByCheckID[checkID] = components
+
+	cycle := FindCheckDependencyCycle(componentsByCheckID)
+	if cycle != nil {
+		ids := make([]string, len(cycle))
+		for i, id := range cycle {
+			ids[i] = fmt.Sprintf("%v", id)
+		}
+		return fmt.Errorf("CheckState expressions cannot form a cycle: %v", strings.Join(ids, " -> "))
+	}
+
+	return nil
+}
+
+// CheckStateComponentsByCheckID returns the component checks of every check in checkRows.
+func CheckStateComponentsByCheckID(checkRows []*CheckRow) map[int64][]int64 {
+	componentsByCheckID := make(map[int64][]int64)
+
+	for _, checkRow := range checkRows {
+		components, err := CheckStateCheckIDs(checkRow.Expressions)
+		if err != nil {
+			// Malformed expressions are reported when the check runs.
+			components = make([]int64, 0)
+		}
+		componentsByCheckID[checkRow.ID] = components
+	}
+
+	return componentsByCheckID
+}
+
+// ValidateCheckStateCheckIDs runs ValidateCheckStateReferences against the checks of a cluster.
+// checkID is 0 for checks that are not created yet.
+func (c *Check) ValidateCheckStateCheckIDs(tx *sqlx.Tx, clusterID, checkID int64, expressionsJSON []byte) error {
+	components, err := CheckStateCheckIDs(expressionsJSON)
+	if err != nil {
+		return err
+	}
+
+	if len(components) == 0 {
+		return nil
+	}
+
+	checkRows, err := c.AllByClusterID(tx, clusterID)
+	if err != nil {
+		return err
+	}
+
+	return ValidateCheckStateReferences(CheckStateComponentsByCheckID(checkRows), checkID, components)
+}
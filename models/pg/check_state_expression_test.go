@@ -0,0 +1,46 @@
+package pg
+
+import (
+	"testing"
+)
+
+func TestValidateCheckStateReferences(t *testing.T) {
+	componentsByCheckID := func() map[int64][]int64 {
+		return map[int64][]int64{
+			1: []int64{},
+			2: []int64{1},
+			3: []int64{},
+		}
+	}
+
+	if err := ValidateCheckStateReferences(componentsByCheckID(), 3, []int64{1, 2}); err != nil {
+		t.Errorf("Components of other checks should be valid. Error: %v", err)
+	}
+
+	if err := ValidateCheckStateReferences(componentsByCheckID(), 3, []int64{3}); err == nil {
+		t.Errorf("A check should not be able to use its own state")
+	}
+
+	if err := ValidateCheckStateReferences(componentsByCheckID(), 0, []int64{4}); err == nil {
+		t.Errorf("Checks outside of the cluster should be rejected")
+	}
+
+	if err := ValidateCheckStateReferences(componentsByCheckID(), 1, []int64{2}); err == nil {
+		t.Errorf("Checks using each other's state should be rejected")
+	}
+}
+
+func TestCheckStateCheckIDs(t *testing.T) {
+	expressionsJSON := []byte(`[{"Type": "BooleanGroup", "Operator": "or", "Expressions": [
+		{"Type": "CheckState", "CheckIDs": [1, 2], "MinHost": 1},
+		{"Type": "CheckState", "CheckIDs": [2, 3], "MinHost": 2}
+	]}]`)
+
+	checkIDs, err := CheckStateCheckIDs(expressionsJSON)
+	if err != nil {
+		t.Fatalf("Parsing expressions should work. Error: %v", err)
+	}
+	if len(checkIDs) != 3 {
+		t.Errorf("Every component check should be listed once. Got: %v", checkIDs)
+	}
+}
@@ -0,0 +1,99 @@
+package pg
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// SyntheticStep is one request of a Synthetic expression. Steps run in order and share a cookie jar.
+// URL, Headers and HTTPBody may refer to ${variable}, extracted by a previous step, or to ${hostname}.
+// A URL starting with "/" is sent to the host being checked, using the Protocol and Port of the expression.
+type SyntheticStep struct {
+	Name       string
+	HTTPMethod string
+	URL        string
+	Headers    string
+	HTTPBody   string
+
+	// Assertions behave like those of HTTP expressions.
+	HTTPStatusCodes     string
+	HTTPBodyContains    string
+	HTTPBodyRegex       string
+	HTTPJSONPath        string
+	HTTPJSONPathValue   string
+	HTTPMaxResponseTime string
+
+	Extract []SyntheticExtract `json:",omitempty"`
+}
+
+// SyntheticExtract saves part of a response into Variable: the value at JSONPath,
+// the first group of Regex or its whole match when it has no group, or the value of a response Header.
+type SyntheticExtract struct {
+	Variable string
+	JSONPath string
+	Regex    string
+	Header   string
+}
+
+// SyntheticStepResult is how one step went. Latency is in milliseconds.
+type SyntheticStepResult struct {
+	Name       string
+	StatusCode int
+	Latency    float64
+	Error      string `json:",omitempty"`
+}
+
+// DisplayName returns the name of the step, or its position when it has none.
+func (step SyntheticStep) DisplayName(index int) string {
+	if step.Name != "" {
+		return step.Name
+	}
+	return fmt.Sprintf("Step %v", index+1)
+}
+
+// ValidateSyntheticSteps checks that every step has a URL, and that regexes and extractions are well formed.
+func ValidateSyntheticSteps(steps []SyntheticStep) error {
+	if len(steps) == 0 {
+		return errors.New("Synthetic expression needs at least one step")
+	}
+
+	for i, step := range steps {
+		if strings.TrimSpace(step.URL) == "" {
+			return fmt.Errorf("%v: URL cannot be empty", step.DisplayName(i))
+		}
+
+		if step.HTTPBodyRegex != "" {
+			_, err := regexp.Compile(step.HTTPBodyRegex)
+			if err != nil {
+				return fmt.Errorf("%v: %v", step.DisplayName(i), err)
+			}
+		}
+
+		for _, extract := range step.Extract {
+			if extract.Variable == "" {
+				return fmt.Errorf("%v: extracted variable needs a name", step.DisplayName(i))
+			}
+
+			sources := 0
+			for _, source := range []string{extract.JSONPath, extract.Regex, extract.Header} {
+				if source != "" {
+					sources++
+				}
+			}
+			if sources != 1 {
+				return fmt.Errorf("%v: %v must be extracted from exactly one of JSONPath, Regex or Header", step.DisplayName(i), extract.Variable)
+			}
+
+			if extract.Regex != "" {
+				_, err := regexp.Compile(extract.Regex)
+				if err != nil {
+					return fmt.Errorf("%v: %v", step.DisplayName(i), err)
+				}
+			}
+		}
+	}
+
+	return nil
+}
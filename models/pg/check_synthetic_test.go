@@ -0,0 +1,46 @@
+package pg
+
+import (
+	"testing"
+)
+
+func TestValidateSyntheticSteps(t *testing.T) {
+	steps := []SyntheticStep{
+		{
+			Name:       "login",
+			HTTPMethod: "POST",
+			URL:        "/login",
+			Extract:    []SyntheticExtract{{Variable: "token", JSONPath: "$.token"}},
+		},
+		{URL: "/me", Headers: "Authorization=Bearer ${token}"},
+	}
+
+	if err := ValidateSyntheticSteps(steps); err != nil {
+		t.Fatalf("Steps should be valid. Error: %v", err)
+	}
+
+	if err := ValidateSyntheticSteps(nil); err == nil {
+		t.Errorf("Synthetic expression without steps should be invalid")
+	}
+
+	invalid := [][]SyntheticStep{
+		{{Name: "no url"}},
+		{{URL: "/", HTTPBodyRegex: "("}},
+		{{URL: "/", Extract: []SyntheticExtract{{JSONPath: "$.token"}}}},
+		{{URL: "/", Extract: []SyntheticExtract{{Variable: "token"}}}},
+		{{URL: "/", Extract: []SyntheticExtract{{Variable: "token", JSONPath: "$.token", Header: "X-Token"}}}},
+	}
+
+	for _, steps := range invalid {
+		if err := ValidateSyntheticSteps(steps); err == nil {
+			t.Errorf("Steps should be invalid: %+v", steps)
+		}
+	}
+
+	expression := CheckExpression{}
+	expression.Type = "Synthetic"
+
+	if err := ValidateExpressions([]CheckExpression{expression}); err == nil {
+		t.Errorf("ValidateExpressions should validate synthetic steps")
+	}
+}
@@ -262,7 +262,7 @@ func TestBuildEmailTriggerContent(t *testing.T) {
 
 	tsCheck := NewTSCheck(appContext, checkRow.ClusterID)
 
-	err = tsCheck.Create(nil, checkRow.ClusterID, checkRow.ID, true, expressionResults, time.Now().Unix()+int64(900))
+	err = tsCheck.Create(nil, checkRow.ClusterID, checkRow.ID, true, expressionResults, nil, time.Now().Unix()+int64(900))
 	if err != nil {
 		t.Fatalf("Creating a TSCheck should not fail. Error: %v", err)
 	}
@@ -272,7 +272,7 @@ func TestBuildEmailTriggerContent(t *testing.T) {
 		t.Fatalf("Fetching a TSCheck should not fail. Error: %v", err)
 	}
 
-	_, err = checkRow.BuildEmailTriggerContent(lastViolation, "$GOPATH/src/github.com/resourced/resourced-master")
+	_, err = checkRow.BuildEmailTriggerContent(appContext, lastViolation, 1)
 	if err != nil {
 		t.Fatalf("Generating the content of email alert should not fail. Error: %v", err)
 	}
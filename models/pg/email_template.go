@@ -0,0 +1,279 @@
+package pg
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	html_template "html/template"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/resourced/resourced-master/templates"
+)
+
+// DefaultEmailTriggerSubject is the subject of alert emails when the cluster does not override it.
+const DefaultEmailTriggerSubject = `Check(ID: {{ .Check.ID }}): {{ .Check.Name }}, failed {{ .ViolationsCount }} times{{ if .Hostname }} on {{ .Hostname }}{{ end }}`
+
+func NewEmailTemplate(ctx context.Context) *EmailTemplate {
+	et := &EmailTemplate{}
+	et.AppContext = ctx
+	et.table = "email_templates"
+	et.i = et
+
+	return et
+}
+
+// EmailTemplateRow holds the alert email templates of a cluster.
+// Empty templates fall back to the defaults.
+type EmailTemplateRow struct {
+	ClusterID int64     `db:"cluster_id"`
+	Subject   string    `db:"subject"`
+	TextBody  string    `db:"text_body"`
+	HTMLBody  string    `db:"html_body"`
+	Updated   time.Time `db:"updated"`
+}
+
+// EmailTriggerData is what alert email templates are rendered with.
+// Hostname is the failing host of checks that alert per host, empty otherwise.
+type EmailTriggerData struct {
+	Check           *CheckRow
+	Hostname        string
+	LastViolation   *TSCheckRow
+	ViolationsCount int
+}
+
+// EmailTriggerContent is a rendered alert email.
+type EmailTriggerContent struct {
+	Subject  string
+	TextBody string
+	HTMLBody string
+}
+
+var emailTriggerFuncMap = map[string]interface{}{
+	"addInt": func(left, right int) int {
+		return left + right
+	},
+}
+
+// defaultEmailTriggerBody reads a default body template from the embedded assets.
+func defaultEmailTriggerBody(name string) (string, error) {
+	content, err := templates.Email.ReadFile("checks/" + name)
+	if err != nil {
+		return "", err
+	}
+	return string(content), nil
+}
+
+// DefaultEmailTemplate returns the templates alert emails use unless a cluster overrides them.
+func DefaultEmailTemplate() (*EmailTemplateRow, error) {
+	row := &EmailTemplateRow{Subject: DefaultEmailTriggerSubject}
+
+	textBody, err := defaultEmailTriggerBody("email-trigger.txt.tmpl")
+	if err != nil {
+		return nil, err
+	}
+	row.TextBody = textBody
+
+	htmlBody, err := defaultEmailTriggerBody("email-trigger.html.tmpl")
+	if err != nil {
+		return nil, err
+	}
+	row.HTMLBody = htmlBody
+
+	return row, nil
+}
+
+// GetSubject returns the subject template, or the default one.
+func (row *EmailTemplateRow) GetSubject() string {
+	if row == nil || row.Subject == "" {
+		return DefaultEmailTriggerSubject
+	}
+	return row.Subject
+}
+
+// GetTextBody returns the plain text body template, or the default one.
+func (row *EmailTemplateRow) GetTextBody() (string, error) {
+	if row == nil || row.TextBody == "" {
+		return defaultEmailTriggerBody("email-trigger.txt.tmpl")
+	}
+	return row.TextBody, nil
+}
+
+// GetHTMLBody returns the HTML body template, or the default one.
+func (row *EmailTemplateRow) GetHTMLBody() (string, error) {
+	if row == nil || row.HTMLBody == "" {
+		return defaultEmailTriggerBody("email-trigger.html.tmpl")
+	}
+	return row.HTMLBody, nil
+}
+
+// Render renders the subject and both bodies of an alert email.
+// The bodies are left empty when there is no violation to describe.
+// A nil row renders the defaults.
+func (row *EmailTemplateRow) Render(data EmailTriggerData) (*EmailTriggerContent, error) {
+	content := &EmailTriggerContent{}
+
+	subjectTmpl, err := template.New("subject").Funcs(emailTriggerFuncMap).Parse(row.GetSubject())
+	if err != nil {
+		return nil, fmt.Errorf("Subject template is malformed. Error: %v", err)
+	}
+
+	var subjectBuffer bytes.Buffer
+
+	err = subjectTmpl.Execute(&subjectBuffer, data)
+	if err != nil {
+		return nil, fmt.Errorf("Subject template failed to render. Error: %v", err)
+	}
+
+	// Subject goes into a mail header, so it has to stay on one line.
+	content.Subject = strings.Join(strings.Fields(subjectBuffer.String()), " ")
+
+	textBody, err := row.GetTextBody()
+	if err != nil {
+		return nil, err
+	}
+
+	textTmpl, err := template.New("text").Funcs(emailTriggerFuncMap).Parse(textBody)
+	if err != nil {
+		return nil, fmt.Errorf("Text body template is malformed. Error: %v", err)
+	}
+
+	htmlBody, err := row.GetHTMLBody()
+	if err != nil {
+		return nil, err
+	}
+
+	htmlTmpl, err := html_template.New("html").Funcs(emailTriggerFuncMap).Parse(htmlBody)
+	if err != nil {
+		return nil, fmt.Errorf("HTML body template is malformed. Error: %v", err)
+	}
+
+	if data.LastViolation == nil {
+		return content, nil
+	}
+
+	var textBuffer bytes.Buffer
+
+	err = textTmpl.Execute(&textBuffer, data)
+	if err != nil {
+		return nil, fmt.Errorf("Text body template failed to render. Error: %v", err)
+	}
+
+	var htmlBuffer bytes.Buffer
+
+	err = htmlTmpl.Execute(&htmlBuffer, data)
+	if err != nil {
+		return nil, fmt.Errorf("HTML body template failed to render. Error: %v", err)
+	}
+
+	content.TextBody = textBuffer.String()
+	content.HTMLBody = htmlBuffer.String()
+
+	return content, nil
+}
+
+// Validate makes sure the templates parse and render against sample data.
+func (row *EmailTemplateRow) Validate() error {
+	_, err := row.Render(SampleEmailTriggerData())
+	return err
+}
+
+// SampleEmailTriggerData returns a made up violation, used to validate and preview templates.
+func SampleEmailTriggerData() EmailTriggerData {
+	expression := CheckExpression{}
+	expression.Type = "RawHostData"
+	expression.Metric = "/load-avg.LoadAvg1m"
+	expression.Operator = ">"
+	expression.Value = 4
+	expression.MinHost = 1
+	expression.Result.Value = true
+	expression.Result.BadHostnames = []string{"web-1.example.com", "web-2.example.com"}
+	expression.Result.GoodHostnames = []string{"web-3.example.com"}
+
+	expressionsJSON, _ := json.Marshal([]CheckExpression{expression})
+
+	return EmailTriggerData{
+		Check: &CheckRow{
+			ID:   1,
+			Name: "High load average",
+		},
+		LastViolation: &TSCheckRow{
+			CheckID:     1,
+			Created:     time.Now().UTC(),
+			Result:      true,
+			Expressions: expressionsJSON,
+		},
+		ViolationsCount: 3,
+	}
+}
+
+type EmailTemplate struct {
+	Base
+}
+
+// GetByClusterID returns the email templates of a cluster.
+func (et *EmailTemplate) GetByClusterID(tx *sqlx.Tx, clusterID int64) (*EmailTemplateRow, error) {
+	pgdb, err := et.GetPGDB()
+	if err != nil {
+		return nil, err
+	}
+
+	row := &EmailTemplateRow{}
+	query := fmt.Sprintf("SELECT * FROM %v WHERE cluster_id=$1", et.table)
+	err = pgdb.Get(row, query, clusterID)
+
+	return row, err
+}
+
+// GetOrDefaultByClusterID returns the email templates of a cluster, or nil when it uses the defaults.
+func (et *EmailTemplate) GetOrDefaultByClusterID(tx *sqlx.Tx, clusterID int64) (*EmailTemplateRow, error) {
+	row, err := et.GetByClusterID(tx, clusterID)
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows in result set") {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return row, nil
+}
+
+// Save creates or updates the email templates of a cluster.
+func (et *EmailTemplate) Save(tx *sqlx.Tx, clusterID int64, data map[string]interface{}) (*EmailTemplateRow, error) {
+	data["cluster_id"] = clusterID
+	data["updated"] = time.Now().UTC()
+
+	_, err := et.GetByClusterID(tx, clusterID)
+
+	// Perform INSERT
+	if err != nil {
+		if !strings.Contains(err.Error(), "no rows in result set") {
+			return nil, err
+		}
+
+		_, err = et.InsertIntoTable(tx, data)
+		if err != nil {
+			return nil, err
+		}
+
+		return et.GetByClusterID(tx, clusterID)
+	}
+
+	// Perform UPDATE
+	_, err = et.UpdateFromTable(tx, data, fmt.Sprintf("cluster_id=%v", clusterID))
+	if err != nil {
+		return nil, err
+	}
+
+	return et.GetByClusterID(tx, clusterID)
+}
+
+// DeleteByClusterID resets the email templates of a cluster to the defaults.
+func (et *EmailTemplate) DeleteByClusterID(tx *sqlx.Tx, clusterID int64) error {
+	_, err := et.DeleteFromTable(tx, fmt.Sprintf("cluster_id=%v", clusterID))
+	return err
+}
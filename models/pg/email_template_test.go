@@ -0,0 +1,79 @@
+package pg
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEmailTemplateRenderDefaults(t *testing.T) {
+	var row *EmailTemplateRow
+
+	content, err := row.Render(SampleEmailTriggerData())
+	if err != nil {
+		t.Fatalf("Rendering the default templates should not fail. Error: %v", err)
+	}
+
+	if content.Subject != "Check(ID: 1): High load average, failed 3 times" {
+		t.Errorf("Default subject is incorrect. Subject: %v", content.Subject)
+	}
+	if !strings.Contains(content.TextBody, "- web-1.example.com") {
+		t.Errorf("Text body should list bad hostnames. Body: %v", content.TextBody)
+	}
+	if !strings.Contains(content.HTMLBody, "<li>web-1.example.com</li>") {
+		t.Errorf("HTML body should list bad hostnames. Body: %v", content.HTMLBody)
+	}
+
+	data := SampleEmailTriggerData()
+	data.LastViolation = nil
+
+	content, err = row.Render(data)
+	if err != nil {
+		t.Fatalf("Rendering without violation should not fail. Error: %v", err)
+	}
+	if content.TextBody != "" || content.HTMLBody != "" {
+		t.Errorf("Bodies should be empty without violation")
+	}
+}
+
+func TestEmailTemplateRenderOverrides(t *testing.T) {
+	row := &EmailTemplateRow{
+		Subject:  "[{{ .ViolationsCount }}x]\n{{ .Check.Name }}",
+		HTMLBody: "<p>{{ .Check.Name }}</p>",
+	}
+
+	data := SampleEmailTriggerData()
+	data.Check.Name = "<script>"
+
+	content, err := row.Render(data)
+	if err != nil {
+		t.Fatalf("Rendering overrides should not fail. Error: %v", err)
+	}
+
+	if content.Subject != "[3x] <script>" {
+		t.Errorf("Subject should be rendered on one line. Subject: %v", content.Subject)
+	}
+	if content.HTMLBody != "<p>&lt;script&gt;</p>" {
+		t.Errorf("HTML body should be escaped. Body: %v", content.HTMLBody)
+	}
+	if !strings.Contains(content.TextBody, "triggered an email alert") {
+		t.Errorf("Empty text body should fall back to the default. Body: %v", content.TextBody)
+	}
+}
+
+func TestEmailTemplateValidate(t *testing.T) {
+	if err := (&EmailTemplateRow{}).Validate(); err != nil {
+		t.Errorf("Default templates should be valid. Error: %v", err)
+	}
+
+	if err := (&EmailTemplateRow{Subject: "{{ .Check.Name"}).Validate(); err == nil {
+		t.Errorf("Malformed subject should be invalid")
+	}
+
+	if err := (&EmailTemplateRow{TextBody: "{{ .Check.DoesNotExist }}"}).Validate(); err == nil {
+		t.Errorf("Unknown fields should be invalid")
+	}
+
+	if err := (&EmailTemplateRow{HTMLBody: "{{ range .Check }}{{ end }}"}).Validate(); err == nil {
+		t.Errorf("HTML body failing to render should be invalid")
+	}
+}
@@ -0,0 +1,380 @@
+package pg
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/resourced/resourced-master/contexthelper"
+)
+
+func NewEscalation(ctx context.Context) *Escalation {
+	e := &Escalation{}
+	e.AppContext = ctx
+	e.table = "escalations"
+	e.hasID = true
+	e.i = e
+
+	return e
+}
+
+// EscalationRow tracks how far a failing check has escalated through its policy.
+type EscalationRow struct {
+	ID                 int64      `db:"id"`
+	ClusterID          int64      `db:"cluster_id"`
+	CheckID            int64      `db:"check_id"`
+	EscalationPolicyID int64      `db:"escalation_policy_id"`
+	Step               int64      `db:"step"`
+	Created            time.Time  `db:"created"`
+	LastEscalated      time.Time  `db:"last_escalated"`
+	AcknowledgedAt     *time.Time `db:"acknowledged_at"`
+	AcknowledgedBy     string     `db:"acknowledged_by"`
+	ResolvedAt         *time.Time `db:"resolved_at"`
+}
+
+// IsAcknowledged checks if someone has taken over the escalation.
+func (row *EscalationRow) IsAcknowledged() bool {
+	return row.AcknowledgedAt != nil
+}
+
+// ShouldEscalate checks if the escalation must move on to the next step at now.
+// Steps with EscalateAfterMinutes of 0 never hand over.
+func (row *EscalationRow) ShouldEscalate(steps []EscalationStep, now time.Time) bool {
+	if row.IsAcknowledged() || row.ResolvedAt != nil {
+		return false
+	}
+
+	if row.Step < 0 || row.Step+1 >= int64(len(steps)) {
+		return false
+	}
+
+	escalateAfter := steps[row.Step].EscalateAfterMinutes
+	if escalateAfter <= 0 {
+		return false
+	}
+
+	return now.Sub(row.LastEscalated) >= time.Duration(escalateAfter)*time.Minute
+}
+
+// SignEscalationAck returns the signature that authorizes acknowledging an escalation without logging in.
+func SignEscalationAck(secret string, id int64) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(id, 10)))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyEscalationAck checks a signature produced by SignEscalationAck.
+func VerifyEscalationAck(secret string, id int64, signature string) bool {
+	expected, err := hex.DecodeString(SignEscalationAck(secret, id))
+	if err != nil {
+		return false
+	}
+
+	given, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+
+	return hmac.Equal(expected, given)
+}
+
+type Escalation struct {
+	Base
+}
+
+func (e *Escalation) rowFromSqlResult(tx *sqlx.Tx, sqlResult sql.Result) (*EscalationRow, error) {
+	id, err := sqlResult.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	return e.GetByID(tx, id)
+}
+
+// GetByID returns one record by id.
+func (e *Escalation) GetByID(tx *sqlx.Tx, id int64) (*EscalationRow, error) {
+	pgdb, err := e.GetPGDB()
+	if err != nil {
+		return nil, err
+	}
+
+	row := &EscalationRow{}
+	query := fmt.Sprintf("SELECT * FROM %v WHERE id=$1", e.table)
+	err = pgdb.Get(row, query, id)
+
+	return row, err
+}
+
+// GetOpenByCheckID returns the unresolved escalation of a check.
+func (e *Escalation) GetOpenByCheckID(tx *sqlx.Tx, checkID int64) (*EscalationRow, error) {
+	pgdb, err := e.GetPGDB()
+	if err != nil {
+		return nil, err
+	}
+
+	row := &EscalationRow{}
+	query := fmt.Sprintf("SELECT * FROM %v WHERE check_id=$1 AND resolved_at IS NULL ORDER BY id DESC LIMIT 1", e.table)
+	err = pgdb.Get(row, query, checkID)
+
+	return row, err
+}
+
+// AllOpenByClusterID returns all unresolved escalations of a cluster.
+func (e *Escalation) AllOpenByClusterID(tx *sqlx.Tx, clusterID int64) ([]*EscalationRow, error) {
+	pgdb, err := e.GetPGDB()
+	if err != nil {
+		return nil, err
+	}
+
+	rows := []*EscalationRow{}
+	query := fmt.Sprintf("SELECT * FROM %v WHERE cluster_id=$1 AND resolved_at IS NULL ORDER BY id ASC", e.table)
+	err = pgdb.Select(&rows, query, clusterID)
+
+	return rows, err
+}
+
+// Create starts a new escalation on the first step of a policy.
+func (e *Escalation) Create(tx *sqlx.Tx, clusterID, checkID, escalationPolicyID int64) (*EscalationRow, error) {
+	data := make(map[string]interface{})
+	data["cluster_id"] = clusterID
+	data["check_id"] = checkID
+	data["escalation_policy_id"] = escalationPolicyID
+	data["step"] = 0
+
+	sqlResult, err := e.InsertIntoTable(tx, data)
+	if err != nil {
+		return nil, err
+	}
+
+	return e.rowFromSqlResult(tx, sqlResult)
+}
+
+// Acknowledge halts further escalation.
+func (e *Escalation) Acknowledge(tx *sqlx.Tx, id int64, acknowledgedBy string) error {
+	data := make(map[string]interface{})
+	data["acknowledged_at"] = time.Now().UTC()
+	data["acknowledged_by"] = acknowledgedBy
+
+	_, err := e.UpdateByID(tx, data, id)
+	return err
+}
+
+// Resolve closes an escalation once its check recovers.
+func (e *Escalation) Resolve(tx *sqlx.Tx, id int64) error {
+	data := make(map[string]interface{})
+	data["resolved_at"] = time.Now().UTC()
+
+	_, err := e.UpdateByID(tx, data, id)
+	return err
+}
+
+// Advance moves an escalation to the given step.
+func (e *Escalation) Advance(tx *sqlx.Tx, id, step int64) error {
+	data := make(map[string]interface{})
+	data["step"] = step
+	data["last_escalated"] = time.Now().UTC()
+
+	_, err := e.UpdateByID(tx, data, id)
+	return err
+}
+
+// Process resolves an escalation when its check recovered, or moves it to the next step when nobody acknowledged it in time.
+func (e *Escalation) Process(tx *sqlx.Tx, row *EscalationRow) error {
+	checkRow, err := NewCheck(e.AppContext).GetByID(tx, row.CheckID)
+	if err != nil {
+		return err
+	}
+
+	tsCheckRows, err := NewTSCheck(e.AppContext, row.ClusterID).LastByClusterIDCheckIDAndLimit(tx, row.ClusterID, row.CheckID, 1)
+	if err != nil {
+		return err
+	}
+
+	var lastResult *TSCheckRow
+	if len(tsCheckRows) > 0 {
+		lastResult = tsCheckRows[0]
+	}
+
+	// Result true means the check is failing.
+	if lastResult != nil && !lastResult.Result {
+		return e.Resolve(tx, row.ID)
+	}
+
+	if row.IsAcknowledged() || checkRow.IsSilencedNow() {
+		return nil
+	}
+
+	policyRow, err := NewEscalationPolicy(e.AppContext).GetByID(tx, row.EscalationPolicyID)
+	if err != nil {
+		return err
+	}
+
+	steps := policyRow.GetSteps()
+
+	if !row.ShouldEscalate(steps, time.Now().UTC()) {
+		return nil
+	}
+
+	row.Step = row.Step + 1
+
+	err = e.Advance(tx, row.ID, row.Step)
+	if err != nil {
+		return err
+	}
+
+	return checkRow.RunEscalationStep(e.AppContext, row, steps[row.Step], lastResult)
+}
+
+// RunEscalationTrigger starts escalating through the check's policy, unless an escalation is already underway.
+func (checkRow *CheckRow) RunEscalationTrigger(ctx context.Context, lastViolation *TSCheckRow) error {
+	if checkRow.EscalationPolicyID == nil {
+		return fmt.Errorf("Unable to escalate because check(ID: %v) has no escalation policy", checkRow.ID)
+	}
+
+	escalation := NewEscalation(ctx)
+
+	_, err := escalation.GetOpenByCheckID(nil, checkRow.ID)
+	if err == nil {
+		return nil
+	}
+	if !strings.Contains(err.Error(), "no rows in result set") {
+		return err
+	}
+
+	policyRow, err := NewEscalationPolicy(ctx).GetByID(nil, *checkRow.EscalationPolicyID)
+	if err != nil {
+		return err
+	}
+
+	if policyRow.ClusterID != checkRow.ClusterID {
+		return errors.New("Escalation policy belongs to a different cluster")
+	}
+
+	steps := policyRow.GetSteps()
+	if len(steps) == 0 {
+		return fmt.Errorf("Unable to escalate because escalation policy(ID: %v) has no steps", policyRow.ID)
+	}
+
+	escalationRow, err := escalation.Create(nil, checkRow.ClusterID, checkRow.ID, policyRow.ID)
+	if err != nil {
+		return err
+	}
+
+	return checkRow.RunEscalationStep(ctx, escalationRow, steps[0], lastViolation)
+}
+
+// RunEscalationStep notifies every action of an escalation step.
+// Emails carry a signed link that acknowledges the escalation.
+func (checkRow *CheckRow) RunEscalationStep(ctx context.Context, escalationRow *EscalationRow, step EscalationStep, lastViolation *TSCheckRow) error {
+	generalConfig, err := contexthelper.GetGeneralConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	ackURL := fmt.Sprintf("%v://%v/escalations/%v/ack?signature=%v", generalConfig.VIPProtocol, generalConfig.VIPAddr, escalationRow.ID, SignEscalationAck(generalConfig.CookieSecret, escalationRow.ID))
+
+	actions, err := checkRow.resolveEscalationActions(ctx, step.Actions)
+	if err != nil {
+		return err
+	}
+
+	var lastErr error
+
+	for _, action := range actions {
+		trigger := CheckTrigger{Action: action}
+		subject := fmt.Sprintf(`Check(ID: %v): %v, escalation step %v`, checkRow.ID, checkRow.Name, escalationRow.Step+1)
+
+		switch action.Transport {
+		case "email":
+			var content *EmailTriggerContent
+
+			// Escalations keep their own subject, only the bodies are rendered.
+			content, err = checkRow.BuildEmailTriggerContent(ctx, lastViolation, 0)
+			if err != nil {
+				lastErr = err
+				continue
+			}
+
+			body := content.TextBody + "\n\nAcknowledge to stop escalating: " + ackURL
+			htmlBody := ""
+			if content.HTMLBody != "" {
+				htmlBody = content.HTMLBody + fmt.Sprintf(`<p><a href="%v">Acknowledge to stop escalating</a></p>`, html.EscapeString(ackURL))
+			}
+
+			err = checkRow.enqueueTriggerNotification(ctx, trigger, action.Email, subject, body, htmlBody)
+
+		case "sms":
+			var to string
+
+			to, err = smsRecipient(ctx, action)
+			if err != nil {
+				lastErr = err
+				continue
+			}
+
+			err = checkRow.enqueueTriggerNotification(ctx, trigger, to, subject, "", "")
+
+		case "pagerduty":
+			err = checkRow.RunPagerDutyTrigger(ctx, trigger, "", lastViolation)
+		}
+
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"Method":       "checkRow.RunEscalationStep",
+				"EscalationID": escalationRow.ID,
+				"Step":         escalationRow.Step,
+				"Transport":    action.Transport,
+			}).Error(err)
+			lastErr = err
+		}
+	}
+
+	return lastErr
+}
+
+// resolveEscalationActions replaces "oncall" actions with the email and SMS actions of whoever is on-call right now.
+func (checkRow *CheckRow) resolveEscalationActions(ctx context.Context, actions []CheckTriggerAction) ([]CheckTriggerAction, error) {
+	resolved := make([]CheckTriggerAction, 0)
+
+	for _, action := range actions {
+		if action.Transport != "oncall" {
+			resolved = append(resolved, action)
+			continue
+		}
+
+		scheduleRow, err := NewOnCallSchedule(ctx).GetByID(nil, action.OnCallScheduleID)
+		if err != nil {
+			return nil, err
+		}
+
+		if scheduleRow.ClusterID != checkRow.ClusterID {
+			return nil, errors.New("On-call schedule belongs to a different cluster")
+		}
+
+		member, ok := scheduleRow.OnCallAt(time.Now().UTC())
+		if !ok {
+			return nil, fmt.Errorf("Nobody is on-call in schedule: %v", scheduleRow.Name)
+		}
+
+		if member.Email != "" {
+			resolved = append(resolved, CheckTriggerAction{Transport: "email", Email: member.Email})
+		}
+		if member.SMSPhone != "" {
+			resolved = append(resolved, CheckTriggerAction{Transport: "sms", SMSPhone: member.SMSPhone, SMSCarrier: member.SMSCarrier})
+		}
+	}
+
+	return resolved, nil
+}
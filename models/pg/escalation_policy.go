@@ -0,0 +1,122 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	sqlx_types "github.com/jmoiron/sqlx/types"
+)
+
+func NewEscalationPolicy(ctx context.Context) *EscalationPolicy {
+	p := &EscalationPolicy{}
+	p.AppContext = ctx
+	p.table = "escalation_policies"
+	p.hasID = true
+	p.i = p
+
+	return p
+}
+
+type EscalationPolicyRowsWithError struct {
+	EscalationPolicies []*EscalationPolicyRow
+	Error              error
+}
+
+// EscalationStep notifies its Actions, then hands over to the next step
+// when nobody acknowledges within EscalateAfterMinutes.
+// Actions use the same transports as triggers, plus "oncall" which notifies
+// whoever is on-call in OnCallScheduleID.
+type EscalationStep struct {
+	EscalateAfterMinutes int64
+	Actions              []CheckTriggerAction
+}
+
+// EscalationPolicyRow is an ordered list of escalation steps.
+type EscalationPolicyRow struct {
+	ID        int64               `db:"id"`
+	ClusterID int64               `db:"cluster_id"`
+	Name      string              `db:"name"`
+	Steps     sqlx_types.JSONText `db:"steps"`
+}
+
+func (row *EscalationPolicyRow) GetSteps() []EscalationStep {
+	var steps []EscalationStep
+	json.Unmarshal(row.Steps, &steps)
+
+	return steps
+}
+
+type EscalationPolicy struct {
+	Base
+}
+
+func (p *EscalationPolicy) rowFromSqlResult(tx *sqlx.Tx, sqlResult sql.Result) (*EscalationPolicyRow, error) {
+	id, err := sqlResult.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	return p.GetByID(tx, id)
+}
+
+// GetByID returns one record by id.
+func (p *EscalationPolicy) GetByID(tx *sqlx.Tx, id int64) (*EscalationPolicyRow, error) {
+	pgdb, err := p.GetPGDB()
+	if err != nil {
+		return nil, err
+	}
+
+	row := &EscalationPolicyRow{}
+	query := fmt.Sprintf("SELECT * FROM %v WHERE id=$1", p.table)
+	err = pgdb.Get(row, query, id)
+
+	return row, err
+}
+
+// ValidateClusterID makes sure the escalation policy exists in the cluster, so that checks cannot page another cluster's people.
+func (p *EscalationPolicy) ValidateClusterID(tx *sqlx.Tx, clusterID, id int64) error {
+	policyRow, err := p.GetByID(tx, id)
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows in result set") {
+			return fmt.Errorf("Escalation policy with ID: %v does not exist in this cluster", id)
+		}
+		return err
+	}
+
+	if policyRow.ClusterID != clusterID {
+		return errors.New("Escalation policy belongs to a different cluster")
+	}
+
+	return nil
+}
+
+// Create inserts a new escalation policy.
+func (p *EscalationPolicy) Create(tx *sqlx.Tx, clusterID int64, data map[string]interface{}) (*EscalationPolicyRow, error) {
+	data["cluster_id"] = clusterID
+
+	sqlResult, err := p.InsertIntoTable(tx, data)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.rowFromSqlResult(tx, sqlResult)
+}
+
+// AllByClusterID returns all rows by cluster_id.
+func (p *EscalationPolicy) AllByClusterID(tx *sqlx.Tx, clusterID int64) ([]*EscalationPolicyRow, error) {
+	pgdb, err := p.GetPGDB()
+	if err != nil {
+		return nil, err
+	}
+
+	rows := []*EscalationPolicyRow{}
+	query := fmt.Sprintf("SELECT * FROM %v WHERE cluster_id=$1 ORDER BY id ASC", p.table)
+	err = pgdb.Select(&rows, query, clusterID)
+
+	return rows, err
+}
@@ -0,0 +1,88 @@
+package pg
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOnCallScheduleOnCallAt(t *testing.T) {
+	start := time.Date(2016, 1, 4, 9, 0, 0, 0, time.UTC)
+
+	row := &OnCallScheduleRow{
+		RotationStart:          start,
+		HandoffIntervalMinutes: 7 * 24 * 60,
+		Members:                []byte(`[{"Name": "alice", "Email": "alice@example.com"}, {"Name": "bob", "Email": "bob@example.com"}]`),
+		Overrides:              []byte(`[{"StartsAt": "2016-01-12T00:00:00Z", "EndsAt": "2016-01-13T00:00:00Z", "Member": {"Name": "carol"}}]`),
+	}
+
+	member, ok := row.OnCallAt(start.Add(time.Hour))
+	if !ok || member.Name != "alice" {
+		t.Errorf("alice should be on-call during the first week. Got: %v", member.Name)
+	}
+
+	member, _ = row.OnCallAt(start.AddDate(0, 0, 7))
+	if member.Name != "bob" {
+		t.Errorf("bob should be on-call after the first handoff. Got: %v", member.Name)
+	}
+
+	member, _ = row.OnCallAt(start.AddDate(0, 0, 14))
+	if member.Name != "alice" {
+		t.Errorf("Rotation should wrap around to alice. Got: %v", member.Name)
+	}
+
+	member, _ = row.OnCallAt(time.Date(2016, 1, 12, 10, 0, 0, 0, time.UTC))
+	if member.Name != "carol" {
+		t.Errorf("Override should win over the rotation. Got: %v", member.Name)
+	}
+
+	member, _ = row.OnCallAt(start.Add(-time.Hour))
+	if member.Name != "bob" {
+		t.Errorf("Rotation should extend backwards before RotationStart. Got: %v", member.Name)
+	}
+}
+
+func TestEscalationRowShouldEscalate(t *testing.T) {
+	now := time.Now().UTC()
+
+	steps := []EscalationStep{
+		EscalationStep{EscalateAfterMinutes: 15},
+		EscalationStep{EscalateAfterMinutes: 0},
+	}
+
+	row := &EscalationRow{Step: 0, LastEscalated: now.Add(-10 * time.Minute)}
+	if row.ShouldEscalate(steps, now) {
+		t.Errorf("Escalation should wait 15 minutes before the next step")
+	}
+
+	row.LastEscalated = now.Add(-16 * time.Minute)
+	if !row.ShouldEscalate(steps, now) {
+		t.Errorf("Escalation should move on after 15 minutes")
+	}
+
+	acknowledgedAt := now
+	row.AcknowledgedAt = &acknowledgedAt
+	if row.ShouldEscalate(steps, now) {
+		t.Errorf("Acknowledged escalation should not move on")
+	}
+
+	row = &EscalationRow{Step: 1, LastEscalated: now.Add(-time.Hour)}
+	if row.ShouldEscalate(steps, now) {
+		t.Errorf("Escalation should stop at the last step")
+	}
+}
+
+func TestEscalationAckSignature(t *testing.T) {
+	signature := SignEscalationAck("secret", 42)
+
+	if !VerifyEscalationAck("secret", 42, signature) {
+		t.Errorf("Signature should be valid")
+	}
+
+	if VerifyEscalationAck("secret", 43, signature) {
+		t.Errorf("Signature should not be valid for another escalation")
+	}
+
+	if VerifyEscalationAck("another secret", 42, signature) {
+		t.Errorf("Signature should not be valid with another secret")
+	}
+}
@@ -0,0 +1,226 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/resourced/resourced-master/libstring"
+)
+
+// HeartbeatCheckInterval is how often the check backing a heartbeat monitor looks for missed pings.
+const HeartbeatCheckInterval = "1m"
+
+func NewHeartbeat(ctx context.Context) *Heartbeat {
+	h := &Heartbeat{}
+	h.AppContext = ctx
+	h.table = "heartbeats"
+	h.hasID = true
+	h.i = h
+
+	return h
+}
+
+// HeartbeatRow is a dead man's switch: a job pings /api/heartbeats/:token every Period,
+// and its check fails when no ping arrives within Period plus Grace, or when the last run exited with a non-zero status.
+type HeartbeatRow struct {
+	ID                  int64      `db:"id"`
+	ClusterID           int64      `db:"cluster_id"`
+	CheckID             *int64     `db:"check_id"`
+	Name                string     `db:"name"`
+	Token               string     `db:"token"`
+	Period              string     `db:"period"`
+	Grace               string     `db:"grace"`
+	Created             time.Time  `db:"created"`
+	LastPingAt          *time.Time `db:"last_ping_at"`
+	LastDurationSeconds *float64   `db:"last_duration_seconds"`
+	LastExitStatus      *int64     `db:"last_exit_status"`
+}
+
+// Validate checks that the monitor is well formed.
+func (row *HeartbeatRow) Validate() error {
+	if row.Name == "" {
+		return errors.New("Heartbeat name cannot be empty")
+	}
+
+	period, err := time.ParseDuration(row.Period)
+	if err != nil {
+		return fmt.Errorf("Heartbeat period is malformed. Error: %v", err)
+	}
+	if period <= 0 {
+		return errors.New("Heartbeat period must be positive")
+	}
+
+	grace, err := time.ParseDuration(row.Grace)
+	if err != nil {
+		return fmt.Errorf("Heartbeat grace is malformed. Error: %v", err)
+	}
+	if grace < 0 {
+		return errors.New("Heartbeat grace cannot be negative")
+	}
+
+	return nil
+}
+
+// Deadline returns when the next ping is due at the latest. Monitors that were never pinged count from their creation.
+func (row *HeartbeatRow) Deadline() (time.Time, error) {
+	period, err := time.ParseDuration(row.Period)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	grace, err := time.ParseDuration(row.Grace)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	from := row.Created
+	if row.LastPingAt != nil {
+		from = *row.LastPingAt
+	}
+
+	return from.Add(period).Add(grace), nil
+}
+
+// StatusAt tells if the monitor is failing at now, and why.
+func (row *HeartbeatRow) StatusAt(now time.Time) (bool, string, error) {
+	deadline, err := row.Deadline()
+	if err != nil {
+		return false, "", err
+	}
+
+	if now.After(deadline) {
+		if row.LastPingAt == nil {
+			return true, fmt.Sprintf("No ping received since the monitor was created, expected every %v with %v of grace", row.Period, row.Grace), nil
+		}
+		return true, fmt.Sprintf("Last ping was %v ago, expected every %v with %v of grace", now.Sub(*row.LastPingAt).Truncate(time.Second), row.Period, row.Grace), nil
+	}
+
+	if row.LastPingAt == nil {
+		return false, "Waiting for the first ping", nil
+	}
+
+	if row.LastExitStatus != nil && *row.LastExitStatus != 0 {
+		return true, fmt.Sprintf("Last run exited with status %v", *row.LastExitStatus), nil
+	}
+
+	message := fmt.Sprintf("Last ping was %v ago", now.Sub(*row.LastPingAt).Truncate(time.Second))
+	if row.LastDurationSeconds != nil {
+		message = message + fmt.Sprintf(", the run took %vs", *row.LastDurationSeconds)
+	}
+
+	return false, message, nil
+}
+
+type Heartbeat struct {
+	Base
+}
+
+func (h *Heartbeat) rowFromSqlResult(tx *sqlx.Tx, sqlResult sql.Result) (*HeartbeatRow, error) {
+	id, err := sqlResult.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	return h.GetByID(tx, id)
+}
+
+// GetByID returns one record by id.
+func (h *Heartbeat) GetByID(tx *sqlx.Tx, id int64) (*HeartbeatRow, error) {
+	pgdb, err := h.GetPGDB()
+	if err != nil {
+		return nil, err
+	}
+
+	row := &HeartbeatRow{}
+	query := fmt.Sprintf("SELECT * FROM %v WHERE id=$1", h.table)
+	err = pgdb.Get(row, query, id)
+
+	return row, err
+}
+
+// GetByToken returns one record by its ping token.
+func (h *Heartbeat) GetByToken(tx *sqlx.Tx, token string) (*HeartbeatRow, error) {
+	pgdb, err := h.GetPGDB()
+	if err != nil {
+		return nil, err
+	}
+
+	row := &HeartbeatRow{}
+	query := fmt.Sprintf("SELECT * FROM %v WHERE token=$1", h.table)
+	err = pgdb.Get(row, query, token)
+
+	return row, err
+}
+
+// AllByClusterID returns all monitors of a cluster.
+func (h *Heartbeat) AllByClusterID(tx *sqlx.Tx, clusterID int64) ([]*HeartbeatRow, error) {
+	pgdb, err := h.GetPGDB()
+	if err != nil {
+		return nil, err
+	}
+
+	rows := []*HeartbeatRow{}
+	query := fmt.Sprintf("SELECT * FROM %v WHERE cluster_id=$1 ORDER BY name ASC", h.table)
+	err = pgdb.Select(&rows, query, clusterID)
+
+	return rows, err
+}
+
+// Create validates and inserts a new monitor with a random ping token.
+func (h *Heartbeat) Create(tx *sqlx.Tx, row *HeartbeatRow) (*HeartbeatRow, error) {
+	err := row.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := libstring.GeneratePassword(32)
+	if err != nil {
+		return nil, err
+	}
+
+	data := make(map[string]interface{})
+	data["cluster_id"] = row.ClusterID
+	data["name"] = row.Name
+	data["token"] = strings.TrimRight(token, "=")
+	data["period"] = row.Period
+	data["grace"] = row.Grace
+
+	if row.CheckID != nil {
+		data["check_id"] = *row.CheckID
+	}
+
+	sqlResult, err := h.InsertIntoTable(tx, data)
+	if err != nil {
+		return nil, err
+	}
+
+	return h.rowFromSqlResult(tx, sqlResult)
+}
+
+// Ping records a run of the job. durationSeconds and exitStatus are optional.
+func (h *Heartbeat) Ping(tx *sqlx.Tx, token string, durationSeconds *float64, exitStatus *int64) (*HeartbeatRow, error) {
+	data := make(map[string]interface{})
+	data["last_ping_at"] = time.Now().UTC()
+	data["last_duration_seconds"] = nil
+	data["last_exit_status"] = nil
+
+	if durationSeconds != nil {
+		data["last_duration_seconds"] = *durationSeconds
+	}
+	if exitStatus != nil {
+		data["last_exit_status"] = *exitStatus
+	}
+
+	_, err := h.UpdateByKeyValueString(tx, data, "token", token)
+	if err != nil {
+		return nil, err
+	}
+
+	return h.GetByToken(tx, token)
+}
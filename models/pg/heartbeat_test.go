@@ -0,0 +1,63 @@
+package pg
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHeartbeatValidate(t *testing.T) {
+	row := &HeartbeatRow{Name: "nightly backup", Period: "24h", Grace: "1h"}
+	if err := row.Validate(); err != nil {
+		t.Fatalf("Heartbeat should be valid. Error: %v", err)
+	}
+
+	for _, invalid := range []*HeartbeatRow{
+		{Period: "24h", Grace: "1h"},
+		{Name: "backup", Period: "daily", Grace: "1h"},
+		{Name: "backup", Period: "0s", Grace: "1h"},
+		{Name: "backup", Period: "24h", Grace: "-1h"},
+	} {
+		if err := invalid.Validate(); err == nil {
+			t.Errorf("Heartbeat should be invalid: %+v", invalid)
+		}
+	}
+}
+
+func TestHeartbeatStatusAt(t *testing.T) {
+	created := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+	row := &HeartbeatRow{Name: "nightly backup", Period: "24h", Grace: "1h", Created: created}
+
+	bad, _, err := row.StatusAt(created.Add(24 * time.Hour))
+	if err != nil || bad {
+		t.Errorf("Heartbeat should wait for the first ping within period and grace. Error: %v", err)
+	}
+
+	bad, message, _ := row.StatusAt(created.Add(26 * time.Hour))
+	if !bad || !strings.Contains(message, "No ping received") {
+		t.Errorf("Heartbeat should fail without a first ping. Message: %v", message)
+	}
+
+	lastPingAt := created.Add(30 * time.Hour)
+	duration := 12.5
+	row.LastPingAt = &lastPingAt
+	row.LastDurationSeconds = &duration
+
+	bad, message, _ = row.StatusAt(lastPingAt.Add(25 * time.Hour))
+	if bad {
+		t.Errorf("Heartbeat should pass within the grace time. Message: %v", message)
+	}
+
+	bad, message, _ = row.StatusAt(lastPingAt.Add(25*time.Hour + time.Minute))
+	if !bad || !strings.Contains(message, "Last ping was 25h1m0s ago") {
+		t.Errorf("Heartbeat should fail after a missed ping. Message: %v", message)
+	}
+
+	exitStatus := int64(2)
+	row.LastExitStatus = &exitStatus
+
+	bad, message, _ = row.StatusAt(lastPingAt.Add(time.Minute))
+	if !bad || !strings.Contains(message, "status 2") {
+		t.Errorf("Heartbeat should fail when the last run failed. Message: %v", message)
+	}
+}
@@ -0,0 +1,271 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/resourced/resourced-master/libtime"
+)
+
+func NewMaintenanceWindow(ctx context.Context) *MaintenanceWindow {
+	mw := &MaintenanceWindow{}
+	mw.AppContext = ctx
+	mw.table = "maintenance_windows"
+	mw.hasID = true
+	mw.i = mw
+
+	return mw
+}
+
+type MaintenanceWindowRowsWithError struct {
+	MaintenanceWindows []*MaintenanceWindowRow
+	Error              error
+}
+
+// MaintenanceWindowRow mutes triggers during a one-off or recurring period of time.
+// Scope is one of: check, hosts_query or cluster.
+// One-off windows run from StartsAt to EndsAt.
+// Recurring windows start on every Cron occurrence between StartsAt and EndsAt, and last DurationMinutes.
+type MaintenanceWindowRow struct {
+	ID              int64     `db:"id"`
+	ClusterID       int64     `db:"cluster_id"`
+	Name            string    `db:"name"`
+	Reason          string    `db:"reason"`
+	Scope           string    `db:"scope"`
+	CheckID         *int64    `db:"check_id"`
+	HostsQuery      string    `db:"hosts_query"`
+	StartsAt        time.Time `db:"starts_at"`
+	EndsAt          time.Time `db:"ends_at"`
+	Cron            string    `db:"cron"`
+	DurationMinutes int64     `db:"duration_minutes"`
+}
+
+// IsRecurring checks if the window repeats on a cron schedule.
+func (mwr *MaintenanceWindowRow) IsRecurring() bool {
+	return mwr.Cron != ""
+}
+
+// Validate checks that the window is well formed.
+func (mwr *MaintenanceWindowRow) Validate() error {
+	if mwr.Name == "" {
+		return errors.New("Maintenance window name cannot be empty")
+	}
+
+	if mwr.Scope == "check" && mwr.CheckID == nil {
+		return errors.New("Maintenance window scoped to a check must have a CheckID")
+	} else if mwr.Scope == "hosts_query" && mwr.HostsQuery == "" {
+		return errors.New("Maintenance window scoped to a hosts query must have a HostsQuery")
+	} else if mwr.Scope != "check" && mwr.Scope != "hosts_query" && mwr.Scope != "cluster" {
+		return fmt.Errorf("Unrecognized maintenance window scope: %v. Valid options are: check, hosts_query or cluster", mwr.Scope)
+	}
+
+	if !mwr.EndsAt.After(mwr.StartsAt) {
+		return errors.New("Maintenance window must end after it starts")
+	}
+
+	if mwr.IsRecurring() {
+		_, err := libtime.ParseCron(mwr.Cron)
+		if err != nil {
+			return err
+		}
+
+		if mwr.DurationMinutes <= 0 {
+			return errors.New("Recurring maintenance window must have a positive DurationMinutes")
+		}
+	}
+
+	return nil
+}
+
+// ActivePeriodAt returns the start and end of the occurrence that covers t.
+// The boolean is false when the window is not active at t.
+func (mwr *MaintenanceWindowRow) ActivePeriodAt(t time.Time) (time.Time, time.Time, bool) {
+	if t.Before(mwr.StartsAt) || !t.Before(mwr.EndsAt) {
+		return time.Time{}, time.Time{}, false
+	}
+
+	if !mwr.IsRecurring() {
+		return mwr.StartsAt, mwr.EndsAt, true
+	}
+
+	schedule, err := libtime.ParseCron(mwr.Cron)
+	if err != nil {
+		return time.Time{}, time.Time{}, false
+	}
+
+	duration := time.Duration(mwr.DurationMinutes) * time.Minute
+
+	start, ok := schedule.LastStartWithin(t.UTC(), duration)
+	if !ok {
+		return time.Time{}, time.Time{}, false
+	}
+
+	return start, start.Add(duration), true
+}
+
+// IsActiveAt checks if the window is in effect at t.
+func (mwr *MaintenanceWindowRow) IsActiveAt(t time.Time) bool {
+	_, _, active := mwr.ActivePeriodAt(t)
+	return active
+}
+
+// NextPeriodAfter returns the start and end of the next occurrence after t.
+// The boolean is false when there are no more occurrences.
+func (mwr *MaintenanceWindowRow) NextPeriodAfter(t time.Time) (time.Time, time.Time, bool) {
+	if !mwr.IsRecurring() {
+		if mwr.StartsAt.After(t) {
+			return mwr.StartsAt, mwr.EndsAt, true
+		}
+		return time.Time{}, time.Time{}, false
+	}
+
+	schedule, err := libtime.ParseCron(mwr.Cron)
+	if err != nil {
+		return time.Time{}, time.Time{}, false
+	}
+
+	from := t.UTC()
+	if mwr.StartsAt.After(from) {
+		from = mwr.StartsAt.Add(-time.Minute)
+	}
+
+	start := schedule.Next(from)
+	if start.IsZero() || !start.Before(mwr.EndsAt) {
+		return time.Time{}, time.Time{}, false
+	}
+
+	return start, start.Add(time.Duration(mwr.DurationMinutes) * time.Minute), true
+}
+
+// CoversCheck checks if a check or cluster scoped window applies to a check.
+// Hosts query scoped windows need the list of hosts, see Check.InMaintenance.
+func (mwr *MaintenanceWindowRow) CoversCheck(checkRow *CheckRow) bool {
+	if mwr.ClusterID != checkRow.ClusterID {
+		return false
+	}
+
+	if mwr.Scope == "cluster" {
+		return true
+	}
+
+	return mwr.Scope == "check" && mwr.CheckID != nil && *mwr.CheckID == checkRow.ID
+}
+
+type MaintenanceWindow struct {
+	Base
+}
+
+func (mw *MaintenanceWindow) rowFromSqlResult(tx *sqlx.Tx, sqlResult sql.Result) (*MaintenanceWindowRow, error) {
+	id, err := sqlResult.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	return mw.GetByID(tx, id)
+}
+
+// GetByID returns one record by id.
+func (mw *MaintenanceWindow) GetByID(tx *sqlx.Tx, id int64) (*MaintenanceWindowRow, error) {
+	pgdb, err := mw.GetPGDB()
+	if err != nil {
+		return nil, err
+	}
+
+	row := &MaintenanceWindowRow{}
+	query := fmt.Sprintf("SELECT * FROM %v WHERE id=$1", mw.table)
+	err = pgdb.Get(row, query, id)
+
+	return row, err
+}
+
+// Create validates and inserts a new maintenance window.
+func (mw *MaintenanceWindow) Create(tx *sqlx.Tx, row *MaintenanceWindowRow) (*MaintenanceWindowRow, error) {
+	err := row.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	data := make(map[string]interface{})
+	data["cluster_id"] = row.ClusterID
+	data["name"] = row.Name
+	data["reason"] = row.Reason
+	data["scope"] = row.Scope
+	data["hosts_query"] = row.HostsQuery
+	data["starts_at"] = row.StartsAt.UTC()
+	data["ends_at"] = row.EndsAt.UTC()
+	data["cron"] = row.Cron
+	data["duration_minutes"] = row.DurationMinutes
+
+	if row.CheckID != nil {
+		data["check_id"] = *row.CheckID
+	}
+
+	sqlResult, err := mw.InsertIntoTable(tx, data)
+	if err != nil {
+		return nil, err
+	}
+
+	return mw.rowFromSqlResult(tx, sqlResult)
+}
+
+// AllUnexpiredByClusterID returns all windows of a cluster that have not ended yet.
+func (mw *MaintenanceWindow) AllUnexpiredByClusterID(tx *sqlx.Tx, clusterID int64) ([]*MaintenanceWindowRow, error) {
+	pgdb, err := mw.GetPGDB()
+	if err != nil {
+		return nil, err
+	}
+
+	rows := []*MaintenanceWindowRow{}
+	query := fmt.Sprintf("SELECT * FROM %v WHERE cluster_id=$1 AND ends_at > to_timestamp($2) at time zone 'utc' ORDER BY starts_at ASC", mw.table)
+	err = pgdb.Select(&rows, query, clusterID, time.Now().UTC().Unix())
+
+	return rows, err
+}
+
+// AllActiveByClusterID returns all windows of a cluster that are in effect right now.
+func (mw *MaintenanceWindow) AllActiveByClusterID(tx *sqlx.Tx, clusterID int64) ([]*MaintenanceWindowRow, error) {
+	rows, err := mw.AllUnexpiredByClusterID(tx, clusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	active := make([]*MaintenanceWindowRow, 0)
+
+	for _, row := range rows {
+		if row.IsActiveAt(now) {
+			active = append(active, row)
+		}
+	}
+
+	return active, nil
+}
+
+// AllUpcomingByClusterID returns all windows of a cluster that are not active now but start within the given duration.
+func (mw *MaintenanceWindow) AllUpcomingByClusterID(tx *sqlx.Tx, clusterID int64, within time.Duration) ([]*MaintenanceWindowRow, error) {
+	rows, err := mw.AllUnexpiredByClusterID(tx, clusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	upcoming := make([]*MaintenanceWindowRow, 0)
+
+	for _, row := range rows {
+		if row.IsActiveAt(now) {
+			continue
+		}
+
+		start, _, ok := row.NextPeriodAfter(now)
+		if ok && start.Before(now.Add(within)) {
+			upcoming = append(upcoming, row)
+		}
+	}
+
+	return upcoming, nil
+}
@@ -0,0 +1,99 @@
+package pg
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMaintenanceWindowOneOff(t *testing.T) {
+	row := &MaintenanceWindowRow{
+		Name:     "upgrade",
+		Scope:    "cluster",
+		StartsAt: time.Date(2016, 1, 1, 2, 0, 0, 0, time.UTC),
+		EndsAt:   time.Date(2016, 1, 1, 4, 0, 0, 0, time.UTC),
+	}
+
+	if err := row.Validate(); err != nil {
+		t.Fatalf("Maintenance window should be valid. Error: %v", err)
+	}
+
+	if !row.IsActiveAt(time.Date(2016, 1, 1, 3, 0, 0, 0, time.UTC)) {
+		t.Errorf("Maintenance window should be active at 03:00")
+	}
+
+	if row.IsActiveAt(time.Date(2016, 1, 1, 4, 0, 0, 0, time.UTC)) {
+		t.Errorf("Maintenance window should have ended at 04:00")
+	}
+
+	start, _, ok := row.NextPeriodAfter(time.Date(2016, 1, 1, 1, 0, 0, 0, time.UTC))
+	if !ok || !start.Equal(row.StartsAt) {
+		t.Errorf("Maintenance window should be upcoming. Got: %v", start)
+	}
+}
+
+func TestMaintenanceWindowRecurring(t *testing.T) {
+	// Every Saturday at 02:00 for an hour, during January 2016.
+	row := &MaintenanceWindowRow{
+		Name:            "weekly backup",
+		Scope:           "cluster",
+		StartsAt:        time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC),
+		EndsAt:          time.Date(2016, 2, 1, 0, 0, 0, 0, time.UTC),
+		Cron:            "0 2 * * 6",
+		DurationMinutes: 60,
+	}
+
+	if err := row.Validate(); err != nil {
+		t.Fatalf("Maintenance window should be valid. Error: %v", err)
+	}
+
+	// 2016-01-02 is a Saturday.
+	if !row.IsActiveAt(time.Date(2016, 1, 2, 2, 30, 0, 0, time.UTC)) {
+		t.Errorf("Maintenance window should be active on Saturday at 02:30")
+	}
+
+	if row.IsActiveAt(time.Date(2016, 1, 3, 2, 30, 0, 0, time.UTC)) {
+		t.Errorf("Maintenance window should not be active on Sunday")
+	}
+
+	start, end, ok := row.NextPeriodAfter(time.Date(2016, 1, 2, 5, 0, 0, 0, time.UTC))
+	if !ok || !start.Equal(time.Date(2016, 1, 9, 2, 0, 0, 0, time.UTC)) || !end.Equal(start.Add(time.Hour)) {
+		t.Errorf("Next occurrence should be the following Saturday. Got: %v - %v", start, end)
+	}
+
+	row.DurationMinutes = 0
+	if row.Validate() == nil {
+		t.Errorf("Recurring maintenance window without duration should be invalid")
+	}
+}
+
+func TestMaintenanceWindowCoversCheck(t *testing.T) {
+	checkID := int64(10)
+	checkRow := &CheckRow{ID: checkID, ClusterID: 1}
+
+	if !(&MaintenanceWindowRow{ClusterID: 1, Scope: "cluster"}).CoversCheck(checkRow) {
+		t.Errorf("Cluster scoped window should cover every check of the cluster")
+	}
+
+	if !(&MaintenanceWindowRow{ClusterID: 1, Scope: "check", CheckID: &checkID}).CoversCheck(checkRow) {
+		t.Errorf("Check scoped window should cover its check")
+	}
+
+	if (&MaintenanceWindowRow{ClusterID: 2, Scope: "cluster"}).CoversCheck(checkRow) {
+		t.Errorf("Window should not cover checks of another cluster")
+	}
+}
+
+func TestCheckRowIsSilencedAt(t *testing.T) {
+	now := time.Now().UTC()
+	until := now.Add(time.Hour)
+
+	checkRow := &CheckRow{IsSilenced: true}
+	if !checkRow.IsSilencedAt(now) {
+		t.Errorf("Silence without expiry should last forever")
+	}
+
+	checkRow.SilencedUntil = &until
+	if !checkRow.IsSilencedAt(now) || checkRow.IsSilencedAt(now.Add(2*time.Hour)) {
+		t.Errorf("Silence should expire at SilencedUntil")
+	}
+}
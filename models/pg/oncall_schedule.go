@@ -0,0 +1,154 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	sqlx_types "github.com/jmoiron/sqlx/types"
+)
+
+func NewOnCallSchedule(ctx context.Context) *OnCallSchedule {
+	s := &OnCallSchedule{}
+	s.AppContext = ctx
+	s.table = "oncall_schedules"
+	s.hasID = true
+	s.i = s
+
+	return s
+}
+
+type OnCallScheduleRowsWithError struct {
+	OnCallSchedules []*OnCallScheduleRow
+	Error           error
+}
+
+// OnCallMember is a person in an on-call rotation.
+type OnCallMember struct {
+	Name       string
+	Email      string
+	SMSPhone   string
+	SMSCarrier string
+}
+
+// OnCallOverride replaces whoever is on-call between StartsAt and EndsAt.
+type OnCallOverride struct {
+	StartsAt time.Time
+	EndsAt   time.Time
+	Member   OnCallMember
+}
+
+// OnCallScheduleRow is a rotation of members, handing off every HandoffIntervalMinutes starting at RotationStart.
+type OnCallScheduleRow struct {
+	ID                     int64               `db:"id"`
+	ClusterID              int64               `db:"cluster_id"`
+	Name                   string              `db:"name"`
+	RotationStart          time.Time           `db:"rotation_start"`
+	HandoffIntervalMinutes int64               `db:"handoff_interval_minutes"`
+	Members                sqlx_types.JSONText `db:"members"`
+	Overrides              sqlx_types.JSONText `db:"overrides"`
+}
+
+func (row *OnCallScheduleRow) GetMembers() []OnCallMember {
+	var members []OnCallMember
+	json.Unmarshal(row.Members, &members)
+
+	return members
+}
+
+func (row *OnCallScheduleRow) GetOverrides() []OnCallOverride {
+	var overrides []OnCallOverride
+	json.Unmarshal(row.Overrides, &overrides)
+
+	return overrides
+}
+
+// OnCallAt returns the member who is on-call at t.
+// Overrides win over the rotation, later overrides win over earlier ones.
+func (row *OnCallScheduleRow) OnCallAt(t time.Time) (OnCallMember, bool) {
+	overrides := row.GetOverrides()
+	for i := len(overrides) - 1; i >= 0; i-- {
+		if !t.Before(overrides[i].StartsAt) && t.Before(overrides[i].EndsAt) {
+			return overrides[i].Member, true
+		}
+	}
+
+	members := row.GetMembers()
+	if len(members) == 0 {
+		return OnCallMember{}, false
+	}
+
+	if row.HandoffIntervalMinutes <= 0 {
+		return members[0], true
+	}
+
+	handoffInterval := time.Duration(row.HandoffIntervalMinutes) * time.Minute
+
+	shifts := int64(t.Sub(row.RotationStart) / handoffInterval)
+	if t.Before(row.RotationStart) {
+		shifts = shifts - 1
+	}
+
+	index := shifts % int64(len(members))
+	if index < 0 {
+		index = index + int64(len(members))
+	}
+
+	return members[index], true
+}
+
+type OnCallSchedule struct {
+	Base
+}
+
+func (s *OnCallSchedule) rowFromSqlResult(tx *sqlx.Tx, sqlResult sql.Result) (*OnCallScheduleRow, error) {
+	id, err := sqlResult.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetByID(tx, id)
+}
+
+// GetByID returns one record by id.
+func (s *OnCallSchedule) GetByID(tx *sqlx.Tx, id int64) (*OnCallScheduleRow, error) {
+	pgdb, err := s.GetPGDB()
+	if err != nil {
+		return nil, err
+	}
+
+	row := &OnCallScheduleRow{}
+	query := fmt.Sprintf("SELECT * FROM %v WHERE id=$1", s.table)
+	err = pgdb.Get(row, query, id)
+
+	return row, err
+}
+
+// Create inserts a new on-call schedule.
+func (s *OnCallSchedule) Create(tx *sqlx.Tx, clusterID int64, data map[string]interface{}) (*OnCallScheduleRow, error) {
+	data["cluster_id"] = clusterID
+
+	sqlResult, err := s.InsertIntoTable(tx, data)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.rowFromSqlResult(tx, sqlResult)
+}
+
+// AllByClusterID returns all rows by cluster_id.
+func (s *OnCallSchedule) AllByClusterID(tx *sqlx.Tx, clusterID int64) ([]*OnCallScheduleRow, error) {
+	pgdb, err := s.GetPGDB()
+	if err != nil {
+		return nil, err
+	}
+
+	rows := []*OnCallScheduleRow{}
+	query := fmt.Sprintf("SELECT * FROM %v WHERE cluster_id=$1 ORDER BY id ASC", s.table)
+	err = pgdb.Select(&rows, query, clusterID)
+
+	return rows, err
+}
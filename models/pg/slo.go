@@ -0,0 +1,580 @@
+package pg
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	html_template "html/template"
+	"os"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/jmoiron/sqlx"
+	sqlx_types "github.com/jmoiron/sqlx/types"
+	"github.com/marcw/pagerduty"
+
+	"github.com/resourced/resourced-master/libtime"
+)
+
+func NewSLO(ctx context.Context) *SLO {
+	s := &SLO{}
+	s.AppContext = ctx
+	s.table = "slos"
+	s.hasID = true
+	s.i = s
+
+	return s
+}
+
+// SLOSource tells where the good and total events of an SLO come from, depending on SourceType:
+// "check" counts every result of CheckID, passing results are good.
+// "metric" sums the values of GoodMetric and TotalMetric across hosts, e.g. requests counted per interval.
+// "log" counts logs matching GoodSearch and TotalSearch.
+type SLOSource struct {
+	CheckID     int64  `json:",omitempty"`
+	GoodMetric  string `json:",omitempty"`
+	TotalMetric string `json:",omitempty"`
+	GoodSearch  string `json:",omitempty"`
+	TotalSearch string `json:",omitempty"`
+}
+
+// SLOBurnRateAlert fires when the error budget burns at least BurnRate times faster than sustainable,
+// over both the long and the short window. The short window lets the alert stop soon after the burn stops.
+type SLOBurnRateAlert struct {
+	ID                     int64
+	LongWindowMinutes      int64
+	ShortWindowMinutes     int64
+	BurnRate               float64
+	RenotifyIntervalMinute int64
+	Actions                []CheckTriggerAction
+}
+
+// IsFiring checks the burn rates measured over the long and the short window.
+func (alert SLOBurnRateAlert) IsFiring(longBurnRate, shortBurnRate float64) bool {
+	return longBurnRate >= alert.BurnRate && shortBurnRate >= alert.BurnRate
+}
+
+// ShouldNotify decides if a firing alert, which last notified at lastNotified, may notify again at now.
+// An alert that just started firing always notifies.
+func (alert SLOBurnRateAlert) ShouldNotify(wasFiring bool, lastNotified, now time.Time) bool {
+	if !wasFiring {
+		return true
+	}
+
+	if alert.RenotifyIntervalMinute <= 0 {
+		return true
+	}
+
+	return now.Sub(lastNotified) >= time.Duration(alert.RenotifyIntervalMinute)*time.Minute
+}
+
+// defaultSLOBurnRateWindows are the multi-window alerts from the Google SRE workbook:
+// page when 2% of the budget is spent in 1 hour or 5% in 6 hours, open a ticket when 10% is spent in 1 or 3 days.
+var defaultSLOBurnRateWindows = []struct {
+	LongWindowMinutes  int64
+	ShortWindowMinutes int64
+	BudgetPercent      float64
+}{
+	{60, 5, 2},
+	{360, 30, 5},
+	{1440, 120, 10},
+	{4320, 360, 10},
+}
+
+// DefaultSLOBurnRateAlerts returns the recommended burn-rate alerts for an SLO window, all notifying actions.
+// For a 30 days window, the burn rates are 14.4, 6, 3 and 1.
+func DefaultSLOBurnRateAlerts(window time.Duration, actions []CheckTriggerAction) []SLOBurnRateAlert {
+	alerts := make([]SLOBurnRateAlert, 0)
+
+	for _, defaultWindow := range defaultSLOBurnRateWindows {
+		if float64(defaultWindow.LongWindowMinutes) >= window.Minutes() {
+			continue
+		}
+
+		alerts = append(alerts, SLOBurnRateAlert{
+			LongWindowMinutes:      defaultWindow.LongWindowMinutes,
+			ShortWindowMinutes:     defaultWindow.ShortWindowMinutes,
+			BurnRate:               defaultWindow.BudgetPercent / 100 * window.Minutes() / float64(defaultWindow.LongWindowMinutes),
+			RenotifyIntervalMinute: 60,
+			Actions:                actions,
+		})
+	}
+
+	return alerts
+}
+
+// SLIPercent returns the percentage of good events. No events at all counts as 100%.
+func SLIPercent(good, total float64) float64 {
+	if total <= 0 {
+		return 100
+	}
+	return good / total * 100
+}
+
+// BurnRate returns how many times faster than sustainable the error budget is being spent.
+// At 1, the budget runs out exactly at the end of the SLO window.
+func BurnRate(good, total, targetPercent float64) float64 {
+	budget := 100 - targetPercent
+	if total <= 0 || budget <= 0 {
+		return 0
+	}
+
+	return (100 - SLIPercent(good, total)) / budget
+}
+
+// ErrorBudgetRemainingPercent returns how much of the error budget is left, given the events of a whole SLO window.
+// It goes below 0 when the budget is overspent.
+func ErrorBudgetRemainingPercent(good, total, targetPercent float64) float64 {
+	return 100 * (1 - BurnRate(good, total, targetPercent))
+}
+
+// SLORow is a service level objective: TargetPercent of events must be good over every Window, e.g. 99.9% over "30d".
+type SLORow struct {
+	ID             int64               `db:"id"`
+	ClusterID      int64               `db:"cluster_id"`
+	Name           string              `db:"name"`
+	TargetPercent  float64             `db:"target_percent"`
+	Window         string              `db:"time_window"`
+	SourceType     string              `db:"source_type"`
+	Source         sqlx_types.JSONText `db:"source"`
+	BurnRateAlerts sqlx_types.JSONText `db:"burn_rate_alerts"`
+}
+
+func (row *SLORow) GetSource() SLOSource {
+	source := SLOSource{}
+	json.Unmarshal(row.Source, &source)
+
+	return source
+}
+
+func (row *SLORow) GetBurnRateAlerts() []SLOBurnRateAlert {
+	var alerts []SLOBurnRateAlert
+	json.Unmarshal(row.BurnRateAlerts, &alerts)
+
+	return alerts
+}
+
+// WindowDuration parses Window, e.g. "30d" or "720h".
+func (row *SLORow) WindowDuration() (time.Duration, error) {
+	return libtime.ParseDurationWithDays(row.Window)
+}
+
+// SourceTable returns the timeseries table the SLO counts its events from.
+func (row *SLORow) SourceTable() string {
+	switch row.SourceType {
+	case "check":
+		return "ts_checks"
+	case "metric":
+		return "ts_metrics"
+	case "log":
+		return "ts_logs"
+	}
+	return ""
+}
+
+// Validate checks that the SLO is well formed.
+// dataRetention is the cluster data retention in days, keyed by table. The window must fit inside the retention of its source table,
+// or the error budget would be computed over truncated data.
+func (row *SLORow) Validate(dataRetention map[string]int) error {
+	if row.Name == "" {
+		return errors.New("SLO name cannot be empty")
+	}
+
+	if row.TargetPercent <= 0 || row.TargetPercent >= 100 {
+		return errors.New("SLO target must be between 0 and 100, exclusive")
+	}
+
+	window, err := row.WindowDuration()
+	if err != nil {
+		return err
+	}
+	if window <= 0 {
+		return errors.New("SLO window must be positive")
+	}
+
+	source := row.GetSource()
+
+	switch row.SourceType {
+	case "check":
+		if source.CheckID <= 0 {
+			return errors.New("SLO based on check results must have a CheckID")
+		}
+	case "metric":
+		if source.GoodMetric == "" || source.TotalMetric == "" {
+			return errors.New("SLO based on metrics must have a GoodMetric and a TotalMetric")
+		}
+	case "log":
+		if source.GoodSearch == "" || source.TotalSearch == "" {
+			return errors.New("SLO based on logs must have a GoodSearch and a TotalSearch")
+		}
+	default:
+		return fmt.Errorf("Unrecognized SLO source type: %v. Valid options are: check, metric or log", row.SourceType)
+	}
+
+	retentionDays := dataRetention[row.SourceTable()]
+	if window > time.Duration(retentionDays)*24*time.Hour {
+		return fmt.Errorf("SLO window cannot be longer than the %v days of data kept in %v", retentionDays, row.SourceTable())
+	}
+
+	for i, alert := range row.GetBurnRateAlerts() {
+		if alert.ShortWindowMinutes <= 0 || alert.LongWindowMinutes <= alert.ShortWindowMinutes {
+			return fmt.Errorf("Burn-rate alert %v must have a long window longer than its short window", i+1)
+		}
+		if alert.BurnRate <= 0 {
+			return fmt.Errorf("Burn-rate alert %v must have a positive burn rate", i+1)
+		}
+
+		for _, action := range alert.Actions {
+			if action.Transport != "email" && action.Transport != "sms" && action.Transport != "pagerduty" && action.Transport != "nothing" {
+				return fmt.Errorf("Burn-rate alert %v cannot use transport: %v. Valid options are: email, sms, pagerduty or nothing", i+1, action.Transport)
+			}
+		}
+	}
+
+	return nil
+}
+
+// SLOEventSource reads the metrics and logs that metric and log SLOs count.
+// models/shims implements it for every database metrics and logs can be stored in.
+type SLOEventSource interface {
+	SumByMetricIDAndRange(clusterID, metricID, from, to, deletedFrom int64) (float64, error)
+	CountByClusterIDRangeAndQuery(clusterID, from, to int64, resourcedQuery string, deletedFrom int64) (int64, error)
+}
+
+type SLO struct {
+	Base
+
+	// EventSource defaults to the pg tables.
+	EventSource SLOEventSource
+}
+
+func (s *SLO) eventSource() SLOEventSource {
+	if s.EventSource != nil {
+		return s.EventSource
+	}
+	return &pgSLOEventSource{AppContext: s.AppContext}
+}
+
+// pgSLOEventSource reads metrics and logs from pg.
+type pgSLOEventSource struct {
+	AppContext context.Context
+}
+
+func (source *pgSLOEventSource) SumByMetricIDAndRange(clusterID, metricID, from, to, deletedFrom int64) (float64, error) {
+	return NewTSMetric(source.AppContext, clusterID).SumByMetricIDAndRange(nil, clusterID, metricID, from, to, deletedFrom)
+}
+
+func (source *pgSLOEventSource) CountByClusterIDRangeAndQuery(clusterID, from, to int64, resourcedQuery string, deletedFrom int64) (int64, error) {
+	return NewTSLog(source.AppContext, clusterID).CountByClusterIDRangeAndQuery(nil, clusterID, from, to, resourcedQuery, deletedFrom)
+}
+
+func (s *SLO) rowFromSqlResult(tx *sqlx.Tx, sqlResult sql.Result) (*SLORow, error) {
+	id, err := sqlResult.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetByID(tx, id)
+}
+
+// GetByID returns one record by id.
+func (s *SLO) GetByID(tx *sqlx.Tx, id int64) (*SLORow, error) {
+	pgdb, err := s.GetPGDB()
+	if err != nil {
+		return nil, err
+	}
+
+	row := &SLORow{}
+	query := fmt.Sprintf("SELECT * FROM %v WHERE id=$1", s.table)
+	err = pgdb.Get(row, query, id)
+
+	return row, err
+}
+
+// Create inserts a new SLO.
+func (s *SLO) Create(tx *sqlx.Tx, clusterID int64, data map[string]interface{}) (*SLORow, error) {
+	data["cluster_id"] = clusterID
+
+	sqlResult, err := s.InsertIntoTable(tx, data)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.rowFromSqlResult(tx, sqlResult)
+}
+
+// AllByClusterID returns all rows by cluster_id.
+func (s *SLO) AllByClusterID(tx *sqlx.Tx, clusterID int64) ([]*SLORow, error) {
+	pgdb, err := s.GetPGDB()
+	if err != nil {
+		return nil, err
+	}
+
+	rows := []*SLORow{}
+	query := fmt.Sprintf("SELECT * FROM %v WHERE cluster_id=$1 ORDER BY id ASC", s.table)
+	err = pgdb.Select(&rows, query, clusterID)
+
+	return rows, err
+}
+
+// CountEvents returns the good and total events of an SLO created between from and to.
+func (s *SLO) CountEvents(tx *sqlx.Tx, row *SLORow, from, to time.Time) (float64, float64, error) {
+	clusterRow, err := NewCluster(s.AppContext).GetByID(tx, row.ClusterID)
+	if err != nil {
+		return -1, -1, err
+	}
+
+	source := row.GetSource()
+
+	switch row.SourceType {
+	case "check":
+		deletedFrom := clusterRow.GetDeletedFromUNIXTimestampForSelect(row.SourceTable())
+
+		good, total, err := NewTSCheck(s.AppContext, row.ClusterID).CountByClusterIDCheckIDAndRange(tx, row.ClusterID, source.CheckID, from.Unix(), to.Unix(), deletedFrom)
+		return float64(good), float64(total), err
+
+	case "metric":
+		deletedFrom := clusterRow.GetDeletedFromUNIXTimestampForSelect(row.SourceTable())
+
+		sums := make([]float64, 2)
+
+		for i, key := range []string{source.GoodMetric, source.TotalMetric} {
+			metricRow, err := NewMetric(s.AppContext).GetByClusterIDAndKey(tx, row.ClusterID, key)
+			if err != nil {
+				return -1, -1, fmt.Errorf("%v. Metric: %v", err.Error(), key)
+			}
+
+			sums[i], err = s.eventSource().SumByMetricIDAndRange(row.ClusterID, metricRow.ID, from.Unix(), to.Unix(), deletedFrom)
+			if err != nil {
+				return -1, -1, err
+			}
+		}
+
+		return sums[0], sums[1], nil
+
+	case "log":
+		deletedFrom := clusterRow.GetDeletedFromUNIXTimestampForSelect(row.SourceTable())
+
+		eventSource := s.eventSource()
+
+		good, err := eventSource.CountByClusterIDRangeAndQuery(row.ClusterID, from.Unix(), to.Unix(), source.GoodSearch, deletedFrom)
+		if err != nil {
+			return -1, -1, err
+		}
+
+		total, err := eventSource.CountByClusterIDRangeAndQuery(row.ClusterID, from.Unix(), to.Unix(), source.TotalSearch, deletedFrom)
+		return float64(good), float64(total), err
+	}
+
+	return -1, -1, fmt.Errorf("Unrecognized SLO source type: %v", row.SourceType)
+}
+
+// Evaluate runs the burn-rate alerts of an SLO at now.
+// The error budget over the whole window is recounted and recorded once per SLOBudgetInterval, in between the latest recorded budget is used.
+func (s *SLO) Evaluate(tx *sqlx.Tx, row *SLORow, now time.Time) (*SLOBudgetRow, error) {
+	budgetRow, err := s.budget(tx, row, now)
+	if err != nil {
+		return nil, err
+	}
+
+	// Alerts commonly share windows, e.g. the 6 hours window is both long and short.
+	burnRates := make(map[int64]float64)
+
+	burnRate := func(minutes int64) (float64, error) {
+		if rate, ok := burnRates[minutes]; ok {
+			return rate, nil
+		}
+
+		good, total, err := s.CountEvents(tx, row, now.Add(-time.Duration(minutes)*time.Minute), now)
+		if err != nil {
+			return -1, err
+		}
+
+		burnRates[minutes] = BurnRate(good, total, row.TargetPercent)
+		return burnRates[minutes], nil
+	}
+
+	notification := NewSLONotification(s.AppContext)
+
+	for _, alert := range row.GetBurnRateAlerts() {
+		longBurnRate, err := burnRate(alert.LongWindowMinutes)
+		if err != nil {
+			return budgetRow, err
+		}
+
+		shortBurnRate, err := burnRate(alert.ShortWindowMinutes)
+		if err != nil {
+			return budgetRow, err
+		}
+
+		firing := alert.IsFiring(longBurnRate, shortBurnRate)
+
+		shouldNotify, err := notification.ShouldNotify(tx, row.ID, alert, firing, now)
+		if err != nil {
+			return budgetRow, err
+		}
+
+		if shouldNotify {
+			// Actions that fail are logged. The others are queued already, so the alert still counts as notified.
+			err = row.RunBurnRateAlert(s.AppContext, alert, budgetRow, longBurnRate, shortBurnRate)
+			if err != nil {
+				logrus.WithFields(logrus.Fields{
+					"Method":  "SLO.Evaluate",
+					"SLOID":   row.ID,
+					"AlertID": alert.ID,
+				}).Error(err)
+			}
+		}
+
+		err = notification.SetState(tx, row.ClusterID, row.ID, alert.ID, firing, shouldNotify, now)
+		if err != nil {
+			return budgetRow, err
+		}
+	}
+
+	return budgetRow, nil
+}
+
+// budget returns the latest recorded error budget of an SLO, recounting the whole window when it is older than SLOBudgetInterval.
+func (s *SLO) budget(tx *sqlx.Tx, row *SLORow, now time.Time) (*SLOBudgetRow, error) {
+	sloBudget := NewSLOBudget(s.AppContext)
+
+	latestRow, err := sloBudget.GetLatestBySLOID(tx, row.ID)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	if err == nil && latestRow.IsFresh(now) {
+		return latestRow, nil
+	}
+
+	window, err := row.WindowDuration()
+	if err != nil {
+		return nil, err
+	}
+
+	good, total, err := s.CountEvents(tx, row, now.Add(-window), now)
+	if err != nil {
+		return nil, err
+	}
+
+	return sloBudget.Create(tx, row, good, total, now)
+}
+
+// sloBurnRateAlertHTMLTemplate is the HTML body of burn-rate alert emails.
+var sloBurnRateAlertHTMLTemplate = html_template.Must(html_template.New("slo-burn-rate-alert").Parse(`<p>{{ .Subject }}</p>
+<table>
+<tr><td>Target</td><td>{{ .SLO.TargetPercent }}% over {{ .SLO.Window }}</td></tr>
+<tr><td>SLI</td><td>{{ printf "%.3f" .Budget.SLIPercent }}%</td></tr>
+<tr><td>Error budget remaining</td><td>{{ printf "%.1f" .Budget.BudgetRemainingPercent }}%</td></tr>
+<tr><td>Burn rate over the last {{ .Alert.LongWindowMinutes }} minutes</td><td>{{ printf "%.2f" .LongBurnRate }}</td></tr>
+<tr><td>Burn rate over the last {{ .Alert.ShortWindowMinutes }} minutes</td><td>{{ printf "%.2f" .ShortBurnRate }}</td></tr>
+<tr><td>Alert threshold</td><td>{{ printf "%.2f" .Alert.BurnRate }}</td></tr>
+</table>`))
+
+// BuildBurnRateAlertContent returns the subject, text body and HTML body of a burn-rate alert.
+func (row *SLORow) BuildBurnRateAlertContent(alert SLOBurnRateAlert, budgetRow *SLOBudgetRow, longBurnRate, shortBurnRate float64) (*EmailTriggerContent, error) {
+	subject := fmt.Sprintf(`SLO(ID: %v): %v, error budget burning %.1fx too fast`, row.ID, row.Name, longBurnRate)
+
+	body := fmt.Sprintf(`Target: %v%% over %v
+SLI: %.3f%%
+Error budget remaining: %.1f%%
+Burn rate over the last %v minutes: %.2f
+Burn rate over the last %v minutes: %.2f
+Alert threshold: %.2f`,
+		row.TargetPercent, row.Window,
+		budgetRow.SLIPercent,
+		budgetRow.BudgetRemainingPercent,
+		alert.LongWindowMinutes, longBurnRate,
+		alert.ShortWindowMinutes, shortBurnRate,
+		alert.BurnRate)
+
+	var htmlBuffer bytes.Buffer
+
+	err := sloBurnRateAlertHTMLTemplate.Execute(&htmlBuffer, map[string]interface{}{
+		"Subject":       subject,
+		"SLO":           row,
+		"Budget":        budgetRow,
+		"Alert":         alert,
+		"LongBurnRate":  longBurnRate,
+		"ShortBurnRate": shortBurnRate,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &EmailTriggerContent{Subject: subject, TextBody: body, HTMLBody: htmlBuffer.String()}, nil
+}
+
+// RunBurnRateAlert queues a notification for every action of a burn-rate alert.
+// The notification queue delivers them with retries and records every attempt in the delivery log.
+// Unlike check triggers, burn-rate alerts are not digested, they already fire on sustained burns only.
+func (row *SLORow) RunBurnRateAlert(ctx context.Context, alert SLOBurnRateAlert, budgetRow *SLOBudgetRow, longBurnRate, shortBurnRate float64) error {
+	content, err := row.BuildBurnRateAlertContent(alert, budgetRow, longBurnRate, shortBurnRate)
+	if err != nil {
+		return err
+	}
+
+	var lastErr error
+
+	for _, action := range alert.Actions {
+		var err error
+
+		switch action.Transport {
+		case "email":
+			if action.Email == "" {
+				err = errors.New("Unable to send email because recipient is empty")
+			} else {
+				err = row.enqueueBurnRateNotification(ctx, alert, action.Transport, action.Email, content.Subject, content.TextBody, content.HTMLBody)
+			}
+
+		case "sms":
+			var to string
+
+			to, err = smsRecipient(ctx, action)
+			if err == nil {
+				err = row.enqueueBurnRateNotification(ctx, alert, action.Transport, to, content.Subject, "", "")
+			}
+
+		case "pagerduty":
+			event := pagerduty.NewTriggerEvent(action.PagerDutyServiceKey, content.Subject)
+			event.IncidentKey = fmt.Sprintf("slo-%v-alert-%v", row.ID, alert.ID)
+			event.Details = map[string]interface{}{
+				"SLIPercent":             budgetRow.SLIPercent,
+				"BudgetRemainingPercent": budgetRow.BudgetRemainingPercent,
+				"LongBurnRate":           longBurnRate,
+				"ShortBurnRate":          shortBurnRate,
+			}
+
+			hostname, _ := os.Hostname()
+			event.Client = fmt.Sprintf("ResourceD Master on: %v", hostname)
+
+			var eventJSON []byte
+
+			eventJSON, err = json.Marshal(event)
+			if err == nil {
+				err = row.enqueueBurnRateNotification(ctx, alert, action.Transport, MaskPagerDutyServiceKey(action.PagerDutyServiceKey), content.Subject, string(eventJSON), "")
+			}
+		}
+
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"Method":    "sloRow.RunBurnRateAlert",
+				"SLOID":     row.ID,
+				"AlertID":   alert.ID,
+				"Transport": action.Transport,
+			}).Error(err)
+			lastErr = err
+		}
+	}
+
+	return lastErr
+}
+
+// enqueueBurnRateNotification hands a notification of a burn-rate alert to the queue, to be delivered right away.
+func (row *SLORow) enqueueBurnRateNotification(ctx context.Context, alert SLOBurnRateAlert, transport, recipient, subject, body, htmlBody string) error {
+	_, err := NewCheckNotificationQueue(ctx).CreateForSLO(nil, row.ClusterID, row.ID, alert.ID, transport, recipient, subject, body, htmlBody, 0, time.Now().UTC())
+	return err
+}
@@ -0,0 +1,118 @@
+package pg
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/jmoiron/sqlx"
+)
+
+func NewSLOBudget(ctx context.Context) *SLOBudget {
+	b := &SLOBudget{}
+	b.AppContext = ctx
+	b.table = "slo_budgets"
+	b.i = b
+
+	return b
+}
+
+// SLOBudgetRow is the state of an SLO over its whole window, as of Created.
+type SLOBudgetRow struct {
+	ClusterID              int64     `db:"cluster_id"`
+	SLOID                  int64     `db:"slo_id"`
+	Created                time.Time `db:"created"`
+	Good                   float64   `db:"good"`
+	Total                  float64   `db:"total"`
+	SLIPercent             float64   `db:"sli_percent"`
+	BudgetRemainingPercent float64   `db:"budget_remaining_percent"`
+}
+
+// SLOBudgetInterval is how often the error budget of an SLO is recounted over its whole window.
+// Burn-rate windows are still counted every evaluation.
+const SLOBudgetInterval = 15 * time.Minute
+
+// IsFresh tells whether the budget was recorded less than SLOBudgetInterval before now.
+func (row *SLOBudgetRow) IsFresh(now time.Time) bool {
+	return now.Sub(row.Created) < SLOBudgetInterval
+}
+
+type SLOBudget struct {
+	Base
+}
+
+// Create records the good and total events of an SLO window ending at created.
+func (b *SLOBudget) Create(tx *sqlx.Tx, sloRow *SLORow, good, total float64, created time.Time) (*SLOBudgetRow, error) {
+	row := &SLOBudgetRow{
+		ClusterID:              sloRow.ClusterID,
+		SLOID:                  sloRow.ID,
+		Created:                created.UTC(),
+		Good:                   good,
+		Total:                  total,
+		SLIPercent:             SLIPercent(good, total),
+		BudgetRemainingPercent: ErrorBudgetRemainingPercent(good, total, sloRow.TargetPercent),
+	}
+
+	data := make(map[string]interface{})
+	data["cluster_id"] = row.ClusterID
+	data["slo_id"] = row.SLOID
+	data["created"] = row.Created
+	data["good"] = row.Good
+	data["total"] = row.Total
+	data["sli_percent"] = row.SLIPercent
+	data["budget_remaining_percent"] = row.BudgetRemainingPercent
+
+	_, err := b.InsertIntoTable(tx, data)
+	if err != nil {
+		return nil, err
+	}
+
+	return row, nil
+}
+
+// GetLatestBySLOID returns the most recent error budget of an SLO.
+func (b *SLOBudget) GetLatestBySLOID(tx *sqlx.Tx, sloID int64) (*SLOBudgetRow, error) {
+	pgdb, err := b.GetPGDB()
+	if err != nil {
+		return nil, err
+	}
+
+	row := &SLOBudgetRow{}
+	query := fmt.Sprintf("SELECT * FROM %v WHERE slo_id=$1 ORDER BY created DESC LIMIT 1", b.table)
+	err = pgdb.Get(row, query, sloID)
+
+	return row, err
+}
+
+// AllBySLOIDAndRange returns the error budget history of an SLO between from and to, oldest first.
+func (b *SLOBudget) AllBySLOIDAndRange(tx *sqlx.Tx, sloID, from, to int64) ([]*SLOBudgetRow, error) {
+	pgdb, err := b.GetPGDB()
+	if err != nil {
+		return nil, err
+	}
+
+	rows := []*SLOBudgetRow{}
+	query := fmt.Sprintf(`SELECT * FROM %v WHERE slo_id=$1 AND
+created >= to_timestamp($2) at time zone 'utc' AND
+created < to_timestamp($3) at time zone 'utc'
+ORDER BY created ASC`, b.table)
+
+	err = pgdb.Select(&rows, query, sloID, from, to)
+
+	logrus.WithFields(logrus.Fields{
+		"Method": "SLOBudget.AllBySLOIDAndRange",
+		"SLOID":  sloID,
+		"From":   from,
+		"To":     to,
+		"Query":  query,
+	}).Info("Select Query")
+
+	return rows, err
+}
+
+// DeleteByClusterIDAndCreatedBefore prunes error budget history older than before.
+func (b *SLOBudget) DeleteByClusterIDAndCreatedBefore(tx *sqlx.Tx, clusterID int64, before time.Time) error {
+	_, err := b.DeleteFromTable(tx, fmt.Sprintf("cluster_id=%v AND created < to_timestamp(%v) at time zone 'utc'", clusterID, before.UTC().Unix()))
+	return err
+}
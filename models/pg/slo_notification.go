@@ -0,0 +1,96 @@
+package pg
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+func NewSLONotification(ctx context.Context) *SLONotification {
+	sn := &SLONotification{}
+	sn.AppContext = ctx
+	sn.table = "slo_notifications"
+	sn.i = sn
+
+	return sn
+}
+
+// SLONotificationRow records whether a burn-rate alert is firing and the last time it notified anyone.
+type SLONotificationRow struct {
+	ClusterID    int64     `db:"cluster_id"`
+	SLOID        int64     `db:"slo_id"`
+	AlertID      int64     `db:"alert_id"`
+	Firing       bool      `db:"firing"`
+	LastNotified time.Time `db:"last_notified"`
+}
+
+type SLONotification struct {
+	Base
+}
+
+// GetBySLOIDAndAlertID returns one record by slo_id and alert_id.
+func (sn *SLONotification) GetBySLOIDAndAlertID(tx *sqlx.Tx, sloID, alertID int64) (*SLONotificationRow, error) {
+	pgdb, err := sn.GetPGDB()
+	if err != nil {
+		return nil, err
+	}
+
+	row := &SLONotificationRow{}
+	query := fmt.Sprintf("SELECT * FROM %v WHERE slo_id=$1 AND alert_id=$2", sn.table)
+	err = pgdb.Get(row, query, sloID, alertID)
+
+	return row, err
+}
+
+// ShouldNotify decides whether a burn-rate alert notifies at now.
+func (sn *SLONotification) ShouldNotify(tx *sqlx.Tx, sloID int64, alert SLOBurnRateAlert, firing bool, now time.Time) (bool, error) {
+	if !firing {
+		return false, nil
+	}
+
+	row, err := sn.GetBySLOIDAndAlertID(tx, sloID, alert.ID)
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows in result set") {
+			return true, nil
+		}
+		return false, err
+	}
+
+	return alert.ShouldNotify(row.Firing, row.LastNotified, now), nil
+}
+
+// SetState saves whether a burn-rate alert is firing, and sets last_notified to now when it notified.
+func (sn *SLONotification) SetState(tx *sqlx.Tx, clusterID, sloID, alertID int64, firing, notified bool, now time.Time) error {
+	data := make(map[string]interface{})
+	data["cluster_id"] = clusterID
+	data["slo_id"] = sloID
+	data["alert_id"] = alertID
+	data["firing"] = firing
+	if notified {
+		data["last_notified"] = now.UTC()
+	}
+
+	_, err := sn.GetBySLOIDAndAlertID(tx, sloID, alertID)
+
+	// Perform INSERT
+	if err != nil {
+		if !strings.Contains(err.Error(), "no rows in result set") {
+			return err
+		}
+
+		// Nothing worth remembering about alerts that never fired.
+		if !firing {
+			return nil
+		}
+
+		_, err = sn.InsertIntoTable(tx, data)
+		return err
+	}
+
+	// Perform UPDATE
+	_, err = sn.UpdateFromTable(tx, data, fmt.Sprintf("slo_id=%v AND alert_id=%v", sloID, alertID))
+	return err
+}
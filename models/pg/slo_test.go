@@ -0,0 +1,140 @@
+package pg
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSLOBurnRateAndBudget(t *testing.T) {
+	if rate := BurnRate(999, 1000, 99.9); math.Abs(rate-1) > 0.0001 {
+		t.Errorf("Spending exactly the budget should burn at 1. Got: %v", rate)
+	}
+
+	if rate := BurnRate(980, 1000, 99.9); math.Abs(rate-20) > 0.0001 {
+		t.Errorf("2%% errors against a 0.1%% budget should burn at 20. Got: %v", rate)
+	}
+
+	if rate := BurnRate(0, 0, 99.9); rate != 0 {
+		t.Errorf("No events should not burn the budget. Got: %v", rate)
+	}
+
+	if remaining := ErrorBudgetRemainingPercent(9995, 10000, 99.9); math.Abs(remaining-50) > 0.0001 {
+		t.Errorf("Half of the budget should be left. Got: %v", remaining)
+	}
+
+	if remaining := ErrorBudgetRemainingPercent(990, 1000, 99.9); remaining >= 0 {
+		t.Errorf("Overspent budget should go below 0. Got: %v", remaining)
+	}
+}
+
+func TestDefaultSLOBurnRateAlerts(t *testing.T) {
+	alerts := DefaultSLOBurnRateAlerts(30*24*time.Hour, nil)
+	if len(alerts) != 4 {
+		t.Fatalf("30 days window should get 4 alerts. Got: %v", len(alerts))
+	}
+
+	for i, expected := range []float64{14.4, 6, 3, 1} {
+		if math.Abs(alerts[i].BurnRate-expected) > 0.0001 {
+			t.Errorf("Alert %v should burn at %v. Got: %v", i, expected, alerts[i].BurnRate)
+		}
+	}
+
+	alerts = DefaultSLOBurnRateAlerts(24*time.Hour, nil)
+	if len(alerts) != 2 {
+		t.Errorf("1 day window should skip alerts with windows of 1 day or longer. Got: %v", len(alerts))
+	}
+}
+
+func TestSLOBurnRateAlertFiringAndNotify(t *testing.T) {
+	alert := SLOBurnRateAlert{LongWindowMinutes: 60, ShortWindowMinutes: 5, BurnRate: 14.4, RenotifyIntervalMinute: 60}
+
+	if alert.IsFiring(20, 2) {
+		t.Errorf("Alert should not fire once the short window recovered")
+	}
+	if !alert.IsFiring(20, 30) {
+		t.Errorf("Alert should fire when both windows burn too fast")
+	}
+
+	now := time.Now().UTC()
+
+	if !alert.ShouldNotify(false, now.Add(-time.Minute), now) {
+		t.Errorf("Alert that just started firing should notify")
+	}
+	if alert.ShouldNotify(true, now.Add(-time.Minute), now) {
+		t.Errorf("Alert should wait RenotifyIntervalMinute before notifying again")
+	}
+	if !alert.ShouldNotify(true, now.Add(-time.Hour), now) {
+		t.Errorf("Alert should notify again after RenotifyIntervalMinute")
+	}
+}
+
+func TestSLORowValidate(t *testing.T) {
+	row := &SLORow{Name: "API", TargetPercent: 99.9, Window: "30d", SourceType: "metric", Source: []byte(`{"GoodMetric": "/api.ok", "TotalMetric": "/api.total"}`), BurnRateAlerts: []byte(`[]`)}
+	dataRetention := map[string]int{"ts_metrics": 30, "ts_logs": 1}
+
+	if err := row.Validate(dataRetention); err != nil {
+		t.Errorf("SLO should be valid. Error: %v", err)
+	}
+
+	row.Window = "31d"
+	if row.Validate(dataRetention) == nil {
+		t.Errorf("SLO window longer than the metrics retention should be invalid")
+	}
+	row.Window = "30d"
+
+	row.TargetPercent = 100
+	if row.Validate(dataRetention) == nil {
+		t.Errorf("100%% target leaves no error budget")
+	}
+	row.TargetPercent = 99.9
+
+	row.SourceType = "log"
+	if row.Validate(dataRetention) == nil {
+		t.Errorf("Log SLO without searches should be invalid")
+	}
+	row.Source = []byte(`{"GoodSearch": "status:200", "TotalSearch": "status:*"}`)
+	if row.Validate(dataRetention) == nil {
+		t.Errorf("30 days window should not fit in 1 day of logs")
+	}
+	row.SourceType = "metric"
+	row.Source = []byte(`{"GoodMetric": "/api.ok", "TotalMetric": "/api.total"}`)
+
+	row.BurnRateAlerts = []byte(`[{"LongWindowMinutes": 5, "ShortWindowMinutes": 60, "BurnRate": 14.4}]`)
+	if row.Validate(dataRetention) == nil {
+		t.Errorf("Long window must be longer than the short window")
+	}
+}
+
+func TestSLOBudgetRowIsFresh(t *testing.T) {
+	now := time.Now().UTC()
+
+	if !(&SLOBudgetRow{Created: now.Add(-time.Minute)}).IsFresh(now) {
+		t.Errorf("Budget recorded a minute ago should be reused")
+	}
+	if (&SLOBudgetRow{Created: now.Add(-SLOBudgetInterval)}).IsFresh(now) {
+		t.Errorf("Budget recorded SLOBudgetInterval ago should be recounted")
+	}
+}
+
+func TestSLOBuildBurnRateAlertContent(t *testing.T) {
+	row := &SLORow{ID: 3, Name: "API <availability>", TargetPercent: 99.9, Window: "30d"}
+	budgetRow := &SLOBudgetRow{SLIPercent: 99.5, BudgetRemainingPercent: 12.5}
+	alert := SLOBurnRateAlert{ID: 1, LongWindowMinutes: 60, ShortWindowMinutes: 5, BurnRate: 14.4}
+
+	content, err := row.BuildBurnRateAlertContent(alert, budgetRow, 20, 30)
+	if err != nil {
+		t.Fatalf("Rendering the alert should work. Error: %v", err)
+	}
+
+	if content.Subject != "SLO(ID: 3): API <availability>, error budget burning 20.0x too fast" {
+		t.Errorf("Unexpected subject: %v", content.Subject)
+	}
+	if !strings.Contains(content.TextBody, "Error budget remaining: 12.5%") {
+		t.Errorf("Text body should show the budget left. Body: %v", content.TextBody)
+	}
+	if !strings.Contains(content.HTMLBody, "API &lt;availability&gt;") || !strings.Contains(content.HTMLBody, "30.00") {
+		t.Errorf("HTML body should be escaped and show the burn rates. Body: %v", content.HTMLBody)
+	}
+}
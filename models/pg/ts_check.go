@@ -31,6 +31,9 @@ type TSCheckRow struct {
 	Deleted     time.Time           `db:"deleted"`
 	Result      bool                `db:"result"`
 	Expressions sqlx_types.JSONText `db:"expressions"`
+
+	// SuppressedByParentID is the failing parent check that kept this result from running triggers.
+	SuppressedByParentID *int64 `db:"suppressed_by_parent_id"`
 }
 
 func (tsCheckRow *TSCheckRow) GetExpressionsWithoutError() []CheckExpression {
@@ -41,6 +44,11 @@ func (tsCheckRow *TSCheckRow) GetExpressionsWithoutError() []CheckExpression {
 	return expressions
 }
 
+// GetLeafExpressionsWithoutError returns the expression results without their groups, depth first.
+func (tsCheckRow *TSCheckRow) GetLeafExpressionsWithoutError() []CheckExpression {
+	return LeafExpressions(tsCheckRow.GetExpressionsWithoutError())
+}
+
 type TSCheck struct {
 	TSBase
 }
@@ -146,8 +154,135 @@ ORDER BY cluster_id,check_id,created DESC`, ts.table)
 	return rows, err
 }
 
-// Create a new record.
-func (ts *TSCheck) Create(tx *sqlx.Tx, clusterID, CheckID int64, result bool, expressions []CheckExpression, deletedFrom int64) error {
+// AllByClusterIDCheckIDAndInterval returns every result, passing or failing, of the last createdIntervalMinute minutes, newest first.
+func (ts *TSCheck) AllByClusterIDCheckIDAndInterval(tx *sqlx.Tx, clusterID, checkID, createdIntervalMinute, deletedFrom int64) ([]*TSCheckRow, error) {
+	pgdb, err := ts.GetPGDB()
+	if err != nil {
+		return nil, err
+	}
+
+	from := time.Now().UTC().Add(-1 * time.Minute * time.Duration(createdIntervalMinute)).Unix()
+
+	rows := []*TSCheckRow{}
+	query := fmt.Sprintf(`SELECT * FROM %v WHERE cluster_id=$1 AND
+check_id=$2 AND
+created > to_timestamp($3) at time zone 'utc' AND
+deleted >= to_timestamp($4) at time zone 'utc'
+ORDER BY cluster_id,check_id,created DESC`, ts.table)
+
+	err = pgdb.Select(&rows, query, clusterID, checkID, from, deletedFrom)
+
+	return rows, err
+}
+
+// HostViolations returns the current streak of failing results, newest first, of every host that fails the newest result.
+// rows must be newest first. Like AllViolationsByClusterIDCheckIDAndInterval, a streak ends at the last result its host did not fail.
+// Hosts fail a result as FailingHostnames decides.
+func HostViolations(rows []*TSCheckRow) map[string][]*TSCheckRow {
+	violations := make(map[string][]*TSCheckRow)
+	if len(rows) == 0 {
+		return violations
+	}
+
+	for _, hostname := range rows[0].FailingHostnames() {
+		violations[hostname] = []*TSCheckRow{rows[0]}
+	}
+
+	ongoing := len(violations)
+	ended := make(map[string]bool)
+
+	for _, row := range rows[1:] {
+		if ongoing == 0 {
+			break
+		}
+
+		badHostnames := make(map[string]bool)
+		for _, hostname := range row.FailingHostnames() {
+			badHostnames[hostname] = true
+		}
+
+		for hostname := range violations {
+			if ended[hostname] {
+				continue
+			}
+
+			if !badHostnames[hostname] {
+				ended[hostname] = true
+				ongoing--
+				continue
+			}
+
+			violations[hostname] = append(violations[hostname], row)
+		}
+	}
+
+	return violations
+}
+
+// AllResultsByClusterIDCheckIDAndRange returns results, without expressions, created between from and to, oldest first.
+// The last result before from comes first, because it is still the state of the check when the range starts.
+func (ts *TSCheck) AllResultsByClusterIDCheckIDAndRange(tx *sqlx.Tx, clusterID, checkID, from, to, deletedFrom int64) ([]*TSCheckRow, error) {
+	pgdb, err := ts.GetPGDB()
+	if err != nil {
+		return nil, err
+	}
+
+	rows := []*TSCheckRow{}
+	query := fmt.Sprintf(`(SELECT cluster_id, check_id, created, deleted, result FROM %v WHERE cluster_id=$1 AND
+check_id=$2 AND
+created < to_timestamp($3) at time zone 'utc' AND
+deleted >= to_timestamp($5) at time zone 'utc'
+ORDER BY cluster_id,check_id,created DESC LIMIT 1)
+UNION ALL
+(SELECT cluster_id, check_id, created, deleted, result FROM %v WHERE cluster_id=$1 AND
+check_id=$2 AND
+created >= to_timestamp($3) at time zone 'utc' AND
+created < to_timestamp($4) at time zone 'utc' AND
+deleted >= to_timestamp($5) at time zone 'utc')
+ORDER BY created ASC`, ts.table, ts.table)
+
+	err = pgdb.Select(&rows, query, clusterID, checkID, from, to, deletedFrom)
+
+	logrus.WithFields(logrus.Fields{
+		"Method":    "TSCheck.AllResultsByClusterIDCheckIDAndRange",
+		"ClusterID": clusterID,
+		"CheckID":   checkID,
+		"From":      from,
+		"To":        to,
+		"Query":     query,
+	}).Info("Select Query")
+
+	return rows, err
+}
+
+// CountByClusterIDCheckIDAndRange returns how many results created between from and to passed, and how many there were in total.
+func (ts *TSCheck) CountByClusterIDCheckIDAndRange(tx *sqlx.Tx, clusterID, checkID, from, to, deletedFrom int64) (int64, int64, error) {
+	pgdb, err := ts.GetPGDB()
+	if err != nil {
+		return -1, -1, err
+	}
+
+	counts := struct {
+		Good  int64 `db:"good"`
+		Total int64 `db:"total"`
+	}{}
+
+	query := fmt.Sprintf(`SELECT count(*) FILTER (WHERE result = false) as good, count(*) as total FROM %v WHERE cluster_id=$1 AND
+check_id=$2 AND
+created >= to_timestamp($3) at time zone 'utc' AND
+created < to_timestamp($4) at time zone 'utc' AND
+deleted >= to_timestamp($5) at time zone 'utc'`, ts.table)
+
+	err = pgdb.Get(&counts, query, clusterID, checkID, from, to, deletedFrom)
+
+	if err != nil {
+		err = fmt.Errorf("%v. Query: %v, ClusterID: %v, CheckID: %v", err.Error(), query, clusterID, checkID)
+	}
+	return counts.Good, counts.Total, err
+}
+
+// Create a new record. suppressedByParentID is nil unless a failing parent check suppresses this result.
+func (ts *TSCheck) Create(tx *sqlx.Tx, clusterID, CheckID int64, result bool, expressions []CheckExpression, suppressedByParentID *int64, deletedFrom int64) error {
 	expressionsJSON, err := json.Marshal(expressions)
 	if err != nil {
 		return err
@@ -158,6 +293,9 @@ func (ts *TSCheck) Create(tx *sqlx.Tx, clusterID, CheckID int64, result bool, ex
 	insertData["check_id"] = CheckID
 	insertData["result"] = result
 	insertData["expressions"] = expressionsJSON
+	if suppressedByParentID != nil {
+		insertData["suppressed_by_parent_id"] = *suppressedByParentID
+	}
 	insertData["deleted"] = time.Unix(deletedFrom, 0).UTC()
 
 	_, err = ts.InsertIntoTable(tx, insertData)
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/jmoiron/sqlx"
+	sqlx_types "github.com/jmoiron/sqlx/types"
 
 	"github.com/resourced/resourced-master/contexthelper"
 	"github.com/resourced/resourced-master/models/shared"
@@ -24,12 +25,20 @@ func NewTSEvent(ctx context.Context, clusterID int64) *TSEvent {
 }
 
 type TSEventRow struct {
-	ID          int64     `db:"id"`
-	ClusterID   int64     `db:"cluster_id"`
-	CreatedFrom time.Time `db:"created_from"`
-	CreatedTo   time.Time `db:"created_to"`
-	Deleted     time.Time `db:"deleted"`
-	Description string    `db:"description"`
+	ID          int64               `db:"id"`
+	ClusterID   int64               `db:"cluster_id"`
+	CreatedFrom time.Time           `db:"created_from"`
+	CreatedTo   time.Time           `db:"created_to"`
+	Deleted     time.Time           `db:"deleted"`
+	Description string              `db:"description"`
+	Tags        sqlx_types.JSONText `db:"tags"`
+}
+
+// GetTags returns the tags of an event.
+func (tsr *TSEventRow) GetTags() []string {
+	tags := make([]string, 0)
+	tsr.Tags.Unmarshal(&tags)
+	return tags
 }
 
 type TSEvent struct {
@@ -116,6 +125,39 @@ deleted >= to_timestamp($4) at time zone 'utc'`, ts.table)
 	return hcRows, err
 }
 
+// AllByClusterIDAndCreatedFromRange returns all rows given created_from range.
+func (ts *TSEvent) AllByClusterIDAndCreatedFromRange(tx *sqlx.Tx, clusterID, from, to, deletedFrom int64) ([]shared.TSEventPayload, error) {
+	pgdb, err := ts.GetPGDB()
+	if err != nil {
+		return nil, err
+	}
+
+	rows := []*TSEventRow{}
+	query := fmt.Sprintf(`SELECT * FROM %v WHERE cluster_id=$1 AND
+created_from >= to_timestamp($2) at time zone 'utc' AND
+created_from <= to_timestamp($3) at time zone 'utc' AND
+deleted >= to_timestamp($4) at time zone 'utc'`, ts.table)
+
+	err = pgdb.Select(&rows, query, clusterID, from, to, deletedFrom)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]shared.TSEventPayload, len(rows))
+
+	for i, row := range rows {
+		events[i] = shared.TSEventPayload{
+			ID:          row.ID,
+			CreatedFrom: row.CreatedFrom.Unix(),
+			CreatedTo:   row.CreatedTo.Unix(),
+			Description: row.Description,
+			Tags:        row.GetTags(),
+		}
+	}
+
+	return events, err
+}
+
 // GetByID returns record by id.
 func (ts *TSEvent) GetByID(tx *sqlx.Tx, id int64) (*TSEventRow, error) {
 	pgdb, err := ts.GetPGDB()
@@ -138,11 +180,25 @@ func (ts *TSEvent) CreateFromJSON(tx *sqlx.Tx, id, clusterID int64, jsonData []b
 		return nil, err
 	}
 
-	return ts.Create(tx, id, clusterID, payload.From, payload.To, payload.Description, deletedFrom)
+	return ts.CreateWithTags(tx, id, clusterID, payload.From, payload.To, payload.Description, payload.Tags, deletedFrom)
 }
 
 // Create a new record.
 func (ts *TSEvent) Create(tx *sqlx.Tx, id, clusterID, fromUnix, toUnix int64, description string, deletedFrom int64) (*TSEventRow, error) {
+	return ts.CreateWithTags(tx, id, clusterID, fromUnix, toUnix, description, nil, deletedFrom)
+}
+
+// CreateWithTags creates a new record with tags.
+func (ts *TSEvent) CreateWithTags(tx *sqlx.Tx, id, clusterID, fromUnix, toUnix int64, description string, tags []string, deletedFrom int64) (*TSEventRow, error) {
+	if tags == nil {
+		tags = make([]string, 0)
+	}
+
+	tagsJSON, err := json.Marshal(tags)
+	if err != nil {
+		return nil, err
+	}
+
 	var from time.Time
 	var to time.Time
 
@@ -164,9 +220,10 @@ func (ts *TSEvent) Create(tx *sqlx.Tx, id, clusterID, fromUnix, toUnix int64, de
 	insertData["created_from"] = from
 	insertData["created_to"] = to
 	insertData["description"] = description
+	insertData["tags"] = tagsJSON
 	insertData["deleted"] = time.Unix(deletedFrom, 0).UTC()
 
-	_, err := ts.InsertIntoTable(tx, insertData)
+	_, err = ts.InsertIntoTable(tx, insertData)
 	if err != nil {
 		return nil, err
 	}
@@ -243,3 +243,31 @@ deleted >= to_timestamp($4) at time zone 'utc' AND
 	}
 	return count, err
 }
+
+// CountByClusterIDRangeAndQuery returns count of logs across all hosts, created between from and to, that match resourced query.
+func (ts *TSLog) CountByClusterIDRangeAndQuery(tx *sqlx.Tx, clusterID, from, to int64, resourcedQuery string, deletedFrom int64) (int64, error) {
+	pgdb, err := ts.GetPGDB()
+	if err != nil {
+		return -1, err
+	}
+
+	pgQuery := querybuilder.Parse(resourcedQuery)
+	if pgQuery == "" {
+		return -1, errors.New("Query is unparsable")
+	}
+
+	var count int64
+
+	query := fmt.Sprintf(`SELECT count(logline) FROM %v WHERE cluster_id=$1 AND
+created >= to_timestamp($2) at time zone 'utc' AND
+created < to_timestamp($3) at time zone 'utc' AND
+deleted >= to_timestamp($4) at time zone 'utc' AND
+%v`, ts.table, pgQuery)
+
+	err = pgdb.Get(&count, query, clusterID, from, to, deletedFrom)
+
+	if err != nil {
+		err = fmt.Errorf("%v. Query: %v, ClusterID: %v, From: %v, To: %v", err.Error(), query, clusterID, from, to)
+	}
+	return count, err
+}
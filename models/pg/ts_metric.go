@@ -3,6 +3,7 @@ package pg
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/Sirupsen/logrus"
@@ -168,6 +169,28 @@ ORDER BY cluster_id,metric_id,created ASC`, ts.table)
 	return rows, err
 }
 
+// SumByMetricIDAndRange adds up the values of a metric, across all hosts, created between from and to.
+func (ts *TSMetric) SumByMetricIDAndRange(tx *sqlx.Tx, clusterID, metricID, from, to, deletedFrom int64) (float64, error) {
+	pgdb, err := ts.GetPGDB()
+	if err != nil {
+		return -1, err
+	}
+
+	var sum float64
+
+	query := fmt.Sprintf(`SELECT COALESCE(sum(value), 0) FROM %v WHERE cluster_id=$1 AND metric_id=$2 AND
+created >= to_timestamp($3) at time zone 'utc' AND
+created < to_timestamp($4) at time zone 'utc' AND
+deleted >= to_timestamp($5) at time zone 'utc'`, ts.table)
+
+	err = pgdb.Get(&sum, query, clusterID, metricID, from, to, deletedFrom)
+
+	if err != nil {
+		err = fmt.Errorf("%v. Query: %v, ClusterID: %v, MetricID: %v", err.Error(), query, clusterID, metricID)
+	}
+	return sum, err
+}
+
 func (ts *TSMetric) AllByMetricIDAndRangeForHighchart(tx *sqlx.Tx, clusterID, metricID, from, to, deletedFrom int64) ([]*shared.TSMetricHighchartPayload, error) {
 	tsMetricRows, err := ts.AllByMetricIDAndRange(tx, clusterID, metricID, from, to, deletedFrom)
 	if err != nil {
@@ -219,3 +242,47 @@ func (ts *TSMetric) GetAggregateXMinutesByMetricIDAndHostname(tx *sqlx.Tx, clust
 	}
 	return row, err
 }
+
+// GetAggregateXMinutesByMetricIDAndHostnames rolls up a metric across hostnames over the last X minutes.
+// percentile is between 0 and 100.
+func (ts *TSMetric) GetAggregateXMinutesByMetricIDAndHostnames(tx *sqlx.Tx, clusterID, metricID int64, minutes int, hostnames []string, percentile float64) (*shared.TSMetricAggregateRow, error) {
+	pgdb, err := ts.GetPGDB()
+	if err != nil {
+		return nil, err
+	}
+
+	row := &shared.TSMetricAggregateRow{ClusterID: clusterID}
+
+	if len(hostnames) == 0 {
+		return row, nil
+	}
+
+	now := time.Now().UTC()
+	from := now.Add(-1 * time.Duration(minutes) * time.Minute).UTC().Unix()
+
+	inPlaceHolders := make([]string, len(hostnames))
+
+	for i := 0; i < len(hostnames); i++ {
+		inPlaceHolders[i] = fmt.Sprintf("$%v", i+5)
+	}
+
+	query := fmt.Sprintf(`SELECT count(value) as count, COALESCE(avg(value), 0) as avg, COALESCE(max(value), 0) as max, COALESCE(min(value), 0) as min, COALESCE(sum(value), 0) as sum,
+COALESCE(percentile_cont($4) WITHIN GROUP (ORDER BY value), 0) as percentile
+FROM %v WHERE cluster_id=$1 AND metric_id=$2 AND created >= to_timestamp($3) at time zone 'utc' AND host IN (%v)`, ts.table, strings.Join(inPlaceHolders, ","))
+
+	args := make([]interface{}, len(hostnames)+4)
+	args[0] = clusterID
+	args[1] = metricID
+	args[2] = from
+	args[3] = percentile / 100
+
+	for i := 0; i < len(hostnames); i++ {
+		args[i+4] = hostnames[i]
+	}
+
+	err = pgdb.Get(row, query, args...)
+	if err != nil {
+		err = fmt.Errorf("%v. Query: %v, ClusterID: %v, MetricID: %v", err.Error(), query, clusterID, metricID)
+	}
+	return row, err
+}
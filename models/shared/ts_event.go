@@ -1,5 +1,9 @@
 package shared
 
+import (
+	"strings"
+)
+
 type TSEventHighchartLinePayload struct {
 	ID          int64  `json:"ID"`
 	CreatedFrom int64  `json:"CreatedFrom"`
@@ -8,7 +12,40 @@ type TSEventHighchartLinePayload struct {
 }
 
 type TSEventCreatePayload struct {
-	From        int64  `json:"from"`
-	To          int64  `json:"to"`
-	Description string `json:"description"`
+	From        int64    `json:"from"`
+	To          int64    `json:"to"`
+	Description string   `json:"description"`
+	Tags        []string `json:"tags"`
+}
+
+// TSEventPayload is an event read from either database. Timestamps are in UNIX seconds.
+type TSEventPayload struct {
+	ID          int64
+	CreatedFrom int64
+	CreatedTo   int64
+	Description string
+	Tags        []string
+}
+
+// Matches tells if the description contains search, ignoring case, and the event has every one of tags.
+// Empty search and tags match every event.
+func (payload TSEventPayload) Matches(search string, tags []string) bool {
+	if search != "" && !strings.Contains(strings.ToLower(payload.Description), strings.ToLower(search)) {
+		return false
+	}
+
+	for _, tag := range tags {
+		found := false
+		for _, eventTag := range payload.Tags {
+			if eventTag == tag {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return true
 }
@@ -0,0 +1,25 @@
+package shared
+
+import (
+	"testing"
+)
+
+func TestTSEventPayloadMatches(t *testing.T) {
+	event := TSEventPayload{Description: "Deploy API v1.2", Tags: []string{"deploy", "api"}}
+
+	if !event.Matches("", nil) {
+		t.Errorf("Empty filters should match every event")
+	}
+	if !event.Matches("deploy api", nil) {
+		t.Errorf("Search should ignore case")
+	}
+	if event.Matches("rollback", nil) {
+		t.Errorf("Search should match the description")
+	}
+	if !event.Matches("", []string{"api", "deploy"}) {
+		t.Errorf("Event has both tags")
+	}
+	if event.Matches("", []string{"deploy", "web"}) {
+		t.Errorf("Event should have every tag")
+	}
+}
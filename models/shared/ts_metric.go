@@ -2,6 +2,7 @@ package shared
 
 import (
 	"math"
+	"sort"
 )
 
 type TSMetricHighchartPayload struct {
@@ -17,6 +18,40 @@ type TSMetricAggregateRow struct {
 	Max       float64 `db:"max"`
 	Min       float64 `db:"min"`
 	Sum       float64 `db:"sum"`
+
+	// Count and Percentile are only filled by aggregates across hosts.
+	Count      int64   `db:"count"`
+	Percentile float64 `db:"percentile"`
+}
+
+// AggregateValues rolls values up into avg, max, min, sum and the given percentile (0-100).
+// Percentile interpolates linearly between the closest ranks, like percentile_cont in PostgreSQL.
+func AggregateValues(values []float64, percentile float64) *TSMetricAggregateRow {
+	row := &TSMetricAggregateRow{Count: int64(len(values))}
+
+	if len(values) == 0 {
+		return row
+	}
+
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+
+	for _, value := range sorted {
+		row.Sum = row.Sum + value
+	}
+
+	row.Min = sorted[0]
+	row.Max = sorted[len(sorted)-1]
+	row.Avg = row.Sum / float64(len(sorted))
+
+	rank := math.Min(math.Max(percentile, 0), 100) / 100 * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+
+	row.Percentile = sorted[lower] + (sorted[upper]-sorted[lower])*(rank-float64(lower))
+
+	return row
 }
 
 type TSMetricRow struct {
@@ -0,0 +1,26 @@
+package shared
+
+import (
+	"testing"
+)
+
+func TestAggregateValues(t *testing.T) {
+	row := AggregateValues([]float64{4, 1, 3, 2}, 50)
+
+	if row.Count != 4 || row.Sum != 10 || row.Avg != 2.5 || row.Min != 1 || row.Max != 4 {
+		t.Errorf("Aggregates are wrong. Row: %+v", row)
+	}
+	if row.Percentile != 2.5 {
+		t.Errorf("Median of 1,2,3,4 should be 2.5. Got: %v", row.Percentile)
+	}
+
+	row = AggregateValues([]float64{4, 1, 3, 2}, 100)
+	if row.Percentile != 4 {
+		t.Errorf("100th percentile should be the max. Got: %v", row.Percentile)
+	}
+
+	row = AggregateValues(nil, 95)
+	if row.Count != 0 {
+		t.Errorf("Empty values should have no count. Got: %v", row.Count)
+	}
+}
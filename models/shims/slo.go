@@ -0,0 +1,24 @@
+package shims
+
+import (
+	"context"
+)
+
+func NewSLOEventSource(ctx context.Context) *SLOEventSource {
+	source := &SLOEventSource{}
+	source.AppContext = ctx
+	return source
+}
+
+// SLOEventSource reads the metrics and logs of SLOs from the database each of them is configured to use.
+type SLOEventSource struct {
+	Base
+}
+
+func (source *SLOEventSource) SumByMetricIDAndRange(clusterID, metricID, from, to, deletedFrom int64) (float64, error) {
+	return NewTSMetric(source.AppContext, clusterID).SumByMetricIDAndRange(clusterID, metricID, from, to, deletedFrom)
+}
+
+func (source *SLOEventSource) CountByClusterIDRangeAndQuery(clusterID, from, to int64, resourcedQuery string, deletedFrom int64) (int64, error) {
+	return NewTSLog(source.AppContext, clusterID).CountByClusterIDRangeAndQuery(clusterID, from, to, resourcedQuery, deletedFrom)
+}
@@ -75,3 +75,14 @@ func (ts *TSEvent) AllBandsByClusterIDAndCreatedFromRangeForHighchart(clusterID,
 
 	return nil, fmt.Errorf("Unrecognized DBType, valid options are: pg or cassandra")
 }
+
+func (ts *TSEvent) AllByClusterIDAndCreatedFromRange(clusterID, from, to, deletedFrom int64) ([]shared.TSEventPayload, error) {
+	if ts.GetDBType() == "pg" {
+		return pg.NewTSEvent(ts.AppContext, ts.ClusterID).AllByClusterIDAndCreatedFromRange(nil, clusterID, from, to, deletedFrom)
+
+	} else if ts.GetDBType() == "cassandra" {
+		return cassandra.NewTSEvent(ts.AppContext).AllByClusterIDAndCreatedFromRange(clusterID, from, to)
+	}
+
+	return nil, fmt.Errorf("Unrecognized DBType, valid options are: pg or cassandra")
+}
@@ -77,3 +77,15 @@ func (ts *TSLog) AllByClusterIDRangeAndQuery(clusterID int64, from, to int64, re
 
 	return nil, fmt.Errorf("Unrecognized DBType, valid options are: pg or cassandra")
 }
+
+// CountByClusterIDRangeAndQuery returns count of logs across all hosts, created between from and to, that match resourced query.
+func (ts *TSLog) CountByClusterIDRangeAndQuery(clusterID, from, to int64, resourcedQuery string, deletedFrom int64) (int64, error) {
+	if ts.GetDBType() == "pg" {
+		return pg.NewTSLog(ts.AppContext, ts.ClusterID).CountByClusterIDRangeAndQuery(nil, clusterID, from, to, resourcedQuery, deletedFrom)
+
+	} else if ts.GetDBType() == "cassandra" {
+		return cassandra.NewTSLog(ts.AppContext).CountByClusterIDRangeAndQuery(clusterID, from, to, resourcedQuery)
+	}
+
+	return -1, fmt.Errorf("Unrecognized DBType, valid options are: pg or cassandra")
+}
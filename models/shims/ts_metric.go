@@ -123,3 +123,26 @@ func (ts *TSMetric) GetAggregateXMinutesByMetricIDAndHostname(clusterID, metricI
 
 	return nil, fmt.Errorf("Unrecognized DBType, valid options are: pg or cassandra")
 }
+
+func (ts *TSMetric) GetAggregateXMinutesByMetricIDAndHostnames(clusterID, metricID int64, minutes int, hostnames []string, percentile float64) (*shared.TSMetricAggregateRow, error) {
+	if ts.GetDBType() == "pg" {
+		return pg.NewTSMetric(ts.AppContext, ts.ClusterID).GetAggregateXMinutesByMetricIDAndHostnames(nil, clusterID, metricID, minutes, hostnames, percentile)
+
+	} else if ts.GetDBType() == "cassandra" {
+		return cassandra.NewTSMetric(ts.AppContext).GetAggregateXMinutesByMetricIDAndHostnames(clusterID, metricID, minutes, hostnames, percentile)
+	}
+
+	return nil, fmt.Errorf("Unrecognized DBType, valid options are: pg or cassandra")
+}
+
+// SumByMetricIDAndRange adds up the values of a metric, across all hosts, created between from and to.
+func (ts *TSMetric) SumByMetricIDAndRange(clusterID, metricID, from, to, deletedFrom int64) (float64, error) {
+	if ts.GetDBType() == "pg" {
+		return pg.NewTSMetric(ts.AppContext, ts.ClusterID).SumByMetricIDAndRange(nil, clusterID, metricID, from, to, deletedFrom)
+
+	} else if ts.GetDBType() == "cassandra" {
+		return cassandra.NewTSMetric(ts.AppContext).SumByMetricIDAndRange(clusterID, metricID, from, to)
+	}
+
+	return -1, fmt.Errorf("Unrecognized DBType, valid options are: pg or cassandra")
+}
@@ -0,0 +1,49 @@
+// Package sms provides a pluggable abstraction around sending SMS.
+package sms
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/resourced/resourced-master/config"
+)
+
+var e164Regexp = regexp.MustCompile(`^\+[1-9][0-9]{1,14}$`)
+
+// Provider sends SMS through an external API.
+type Provider interface {
+	Send(to, body string) error
+}
+
+// New returns the provider named in conf.
+func New(conf *config.SMSConfig) (Provider, error) {
+	switch strings.ToLower(conf.Provider) {
+	case "twilio":
+		return NewTwilio(conf)
+	default:
+		return nil, fmt.Errorf("Unknown SMS provider: %v", conf.Provider)
+	}
+}
+
+// IsE164 checks if phone is in E.164 format, e.g. +14155552671.
+func IsE164(phone string) bool {
+	return e164Regexp.MatchString(phone)
+}
+
+// NormalizeE164 strips spaces, dots, dashes and parentheses from phone, then validates it as E.164.
+func NormalizeE164(phone string) (string, error) {
+	normalized := strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '.', '-', '(', ')':
+			return -1
+		}
+		return r
+	}, phone)
+
+	if !IsE164(normalized) {
+		return "", fmt.Errorf("Phone number %q is not in E.164 format, e.g. +14155552671", phone)
+	}
+
+	return normalized, nil
+}
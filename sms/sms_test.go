@@ -0,0 +1,86 @@
+package sms
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/resourced/resourced-master/config"
+)
+
+func TestNormalizeE164(t *testing.T) {
+	valid := map[string]string{
+		"+14155552671":      "+14155552671",
+		"+1 (415) 555-2671": "+14155552671",
+		"+44 20 7946 0958":  "+442079460958",
+		"+62.812.3456.7890": "+6281234567890",
+	}
+
+	for phone, expected := range valid {
+		normalized, err := NormalizeE164(phone)
+		if err != nil {
+			t.Errorf("%v should be valid. Error: %v", phone, err)
+		}
+		if normalized != expected {
+			t.Errorf("%v is normalized incorrectly. Expected: %v, Got: %v", phone, expected, normalized)
+		}
+	}
+
+	invalid := []string{"", "4155552671", "+04155552671", "+1415555267123456", "+1415abc2671"}
+
+	for _, phone := range invalid {
+		if _, err := NormalizeE164(phone); err == nil {
+			t.Errorf("%v should not be valid", phone)
+		}
+	}
+}
+
+func TestTwilioSend(t *testing.T) {
+	var receivedPath, receivedTo, receivedBody, receivedUser string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		receivedPath = r.URL.Path
+		receivedUser, _, _ = r.BasicAuth()
+		r.ParseForm()
+		receivedTo = r.PostForm.Get("To")
+		receivedBody = r.PostForm.Get("Body")
+
+		if receivedTo == "+15005550001" {
+			w.WriteHeader(400)
+			w.Write([]byte(`{"code": 21211, "message": "The 'To' number is not a valid phone number.", "status": 400}`))
+			return
+		}
+
+		w.WriteHeader(201)
+		w.Write([]byte(`{"sid": "SM123"}`))
+	}))
+	defer server.Close()
+
+	provider, err := New(&config.SMSConfig{Provider: "twilio", URL: server.URL, AccountSID: "AC123", AuthToken: "token", From: "+15005550006"})
+	if err != nil {
+		t.Fatalf("Creating Twilio provider should not fail. Error: %v", err)
+	}
+
+	err = provider.Send("+1 415 555 2671", "Check failed")
+	if err != nil {
+		t.Fatalf("Sending SMS should not fail. Error: %v", err)
+	}
+
+	if receivedPath != "/2010-04-01/Accounts/AC123/Messages.json" || receivedUser != "AC123" || receivedTo != "+14155552671" || receivedBody != "Check failed" {
+		t.Errorf("Request is incorrect. Path: %v, User: %v, To: %v, Body: %v", receivedPath, receivedUser, receivedTo, receivedBody)
+	}
+
+	err = provider.Send("+15005550001", "Check failed")
+	if err == nil || !strings.Contains(err.Error(), "21211") {
+		t.Errorf("Provider errors should be reported. Error: %v", err)
+	}
+
+	if provider.Send("4155552671", "Check failed") == nil {
+		t.Errorf("Numbers not in E.164 format should not be sent")
+	}
+
+	if _, err = New(&config.SMSConfig{Provider: "carrier-pigeon"}); err == nil {
+		t.Errorf("Unknown provider should fail")
+	}
+}
@@ -0,0 +1,93 @@
+package sms
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/resourced/resourced-master/config"
+)
+
+const twilioURL = "https://api.twilio.com"
+
+// NewTwilio returns an instance of Twilio struct.
+func NewTwilio(conf *config.SMSConfig) (*Twilio, error) {
+	if conf.AccountSID == "" || conf.AuthToken == "" {
+		return nil, fmt.Errorf("Twilio requires AccountSID and AuthToken")
+	}
+
+	from, err := NormalizeE164(conf.From)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &Twilio{}
+	t.URL = strings.TrimSuffix(conf.URL, "/")
+	if t.URL == "" {
+		t.URL = twilioURL
+	}
+	t.AccountSID = conf.AccountSID
+	t.AuthToken = conf.AuthToken
+	t.From = from
+	t.Client = &http.Client{Timeout: 10 * time.Second}
+
+	return t, nil
+}
+
+// Twilio sends SMS through the Twilio REST API, or any API compatible with it.
+type Twilio struct {
+	URL        string
+	AccountSID string
+	AuthToken  string
+	From       string
+	Client     *http.Client
+}
+
+// Send creates a message resource. Error responses are returned with the code and message of the provider.
+func (t *Twilio) Send(to, body string) error {
+	to, err := NormalizeE164(to)
+	if err != nil {
+		return err
+	}
+
+	form := url.Values{}
+	form.Set("To", to)
+	form.Set("From", t.From)
+	form.Set("Body", body)
+
+	endpoint := fmt.Sprintf("%v/2010-04-01/Accounts/%v/Messages.json", t.URL, url.PathEscape(t.AccountSID))
+
+	req, err := http.NewRequest("POST", endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(t.AccountSID, t.AuthToken)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := t.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+
+	respBody, _ := ioutil.ReadAll(resp.Body)
+
+	twilioErr := struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}{}
+
+	if json.Unmarshal(respBody, &twilioErr) == nil && twilioErr.Message != "" {
+		return fmt.Errorf("Twilio failed to send SMS to %v. Status: %v, Code: %v, Message: %v", to, resp.StatusCode, twilioErr.Code, twilioErr.Message)
+	}
+
+	return fmt.Errorf("Twilio failed to send SMS to %v. Status: %v", to, resp.StatusCode)
+}
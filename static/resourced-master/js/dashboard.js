@@ -54,6 +54,20 @@ ResourcedMaster.checks.results.get = function(accessToken, options) {
     });
 };
 
+ResourcedMaster.checks.preview = function(accessToken, check, options) {
+    return $.ajax({
+        url: '/api/checks/preview',
+        method: 'POST',
+        contentType: 'application/json',
+        data: JSON.stringify(check),
+        beforeSend: function(xhr) {
+            xhr.setRequestHeader('Authorization', 'Basic ' + window.btoa(accessToken + ':'));
+        },
+        success: options.successCallback || null,
+        error: options.errorCallback || null
+    });
+};
+
 ResourcedMaster.hosts = {};
 ResourcedMaster.hosts.get = function(accessToken, options) {
     var path = '/api/hosts';
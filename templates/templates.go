@@ -0,0 +1,12 @@
+// Package templates embeds the templates that are rendered outside of an HTTP request,
+// so they are found no matter which directory the binary runs from.
+package templates
+
+import (
+	"embed"
+)
+
+// Email holds the default alert email templates.
+//
+//go:embed checks/email-trigger.txt.tmpl checks/email-trigger.html.tmpl
+var Email embed.FS